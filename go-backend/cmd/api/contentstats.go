@@ -0,0 +1,23 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+var contentStatsTop = flag.Int("content-stats-top", 20, "keywords, entities and themes reported by /stats/content")
+
+// getContentStats serves the most frequent keywords, entities and themes
+// across the documents taken from the index feed
+func (s *APIServer) getContentStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.counters.content.Stats())
+}
+
+// newFeedCounters returns counters that also aggregate document content
+func newFeedCounters() *feedCounters {
+	return &feedCounters{content: model.NewContentAggregator(max(*contentStatsTop, 1))}
+}
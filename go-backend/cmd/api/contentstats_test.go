@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// TestContentStatsEndpoint feeds documents through the index feed counters
+// and checks /stats/content ranks their keywords, entities and themes.
+func TestContentStatsEndpoint(t *testing.T) {
+	server := NewAPIServer(NewInvertedIndexBackend())
+	server.counters.record(model.Document{
+		Chunks: []model.ContentChunk{
+			{Keywords: []string{"lighthouse", "whales"}, Entities: []string{"Oslo"}},
+			{Keywords: []string{"lighthouse"}},
+		},
+		DreamHints: model.DreamingHints{Themes: []string{"nature"}},
+	}, nil)
+	server.counters.record(model.Document{
+		Chunks:     []model.ContentChunk{{Keywords: []string{"whales", "lighthouse"}}},
+		DreamHints: model.DreamingHints{Themes: []string{"nature", "cosmos"}},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest("GET", "/stats/content", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var stats model.ContentStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Documents != 2 {
+		t.Errorf("documents = %d, want 2", stats.Documents)
+	}
+	if len(stats.Keywords) != 2 || stats.Keywords[0] != (model.TermCount{Term: "lighthouse", Count: 3}) {
+		t.Errorf("keywords = %v, want lighthouse (3) first", stats.Keywords)
+	}
+	if len(stats.Entities) != 1 || stats.Entities[0].Term != "Oslo" {
+		t.Errorf("entities = %v", stats.Entities)
+	}
+	if len(stats.Themes) != 2 || stats.Themes[0] != (model.TermCount{Term: "nature", Count: 2}) {
+		t.Errorf("themes = %v, want nature (2) first", stats.Themes)
+	}
+}
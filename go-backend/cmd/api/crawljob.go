@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// Crawl job limits and the defaults for omitted fields
+const (
+	maxJobDepth = 10
+	maxJobPages = 100000
+
+	defaultJobDepth     = 2
+	defaultJobPages     = 100
+	defaultJobRateLimit = 10
+
+	// maxJobBodyBytes caps a job request body; batches of thousands of
+	// seeds still fit
+	maxJobBodyBytes = 1 << 20
+)
+
+// fieldError reports one invalid field of a request body
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// readJobBody reads a job request body of at most maxJobBodyBytes. On
+// failure it has already responded and returns false.
+func readJobBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJobBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return body, true
+}
+
+// decodeCrawlJob parses a job request and fills in defaults. Zero pages
+// or rate limit mean the default, as they always have; max_depth is only
+// defaulted when omitted, since 0 is a valid seed-only depth.
+func decodeCrawlJob(body []byte) (model.CrawlJob, error) {
+	var job model.CrawlJob
+	if err := json.Unmarshal(body, &job); err != nil {
+		return job, err
+	}
+	var present struct {
+		MaxDepth *int `json:"max_depth"`
+	}
+	if err := json.Unmarshal(body, &present); err != nil {
+		return job, err
+	}
+
+	if present.MaxDepth == nil {
+		job.MaxDepth = defaultJobDepth
+	}
+	if job.MaxPages == 0 {
+		job.MaxPages = defaultJobPages
+	}
+	if job.RateLimit == 0 {
+		job.RateLimit = defaultJobRateLimit
+	}
+	return job, nil
+}
+
+// validateCrawlJob returns every problem with job, nil when it is valid
+func validateCrawlJob(job model.CrawlJob) []fieldError {
+	var errs []fieldError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if msg := validateSeedURL(job.URL); msg != "" {
+		add("url", "%s", msg)
+	}
+	return append(errs, validateJobLimits(job)...)
+}
+
+// validateSeedURL describes what is wrong with a seed URL, "" when nothing
+func validateSeedURL(raw string) string {
+	if raw == "" {
+		return "is required"
+	}
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("is not a valid URL: %v", err)
+	case u.Scheme != "http" && u.Scheme != "https":
+		return fmt.Sprintf("must use http or https, got %q", u.Scheme)
+	case u.Host == "":
+		return "must include a host"
+	}
+	return ""
+}
+
+// validateJobLimits checks the fields a job applies to all of its seeds
+func validateJobLimits(job model.CrawlJob) []fieldError {
+	var errs []fieldError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if job.MaxDepth < 0 || job.MaxDepth > maxJobDepth {
+		add("max_depth", "must be between 0 and %d, got %d", maxJobDepth, job.MaxDepth)
+	}
+	if job.MaxPages < 1 || job.MaxPages > maxJobPages {
+		add("max_pages", "must be between 1 and %d, got %d", maxJobPages, job.MaxPages)
+	}
+	if job.RateLimit <= 0 {
+		add("rate_limit", "must be positive, got %d", job.RateLimit)
+	}
+	return errs
+}
+
+// writeFieldErrors responds 422 with the validation errors as JSON
+func writeFieldErrors(w http.ResponseWriter, errs []fieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": errs})
+}
+
+// batchCrawlJob is the response to POST /crawl/batch: the created job and
+// the seeds left out of it
+type batchCrawlJob struct {
+	model.CrawlJob
+	Rejected []fieldError `json:"rejected,omitempty"`
+}
+
+// decodeBatchCrawlJob parses a batch job request like decodeCrawlJob, plus
+// its strict flag
+func decodeBatchCrawlJob(body []byte) (job model.CrawlJob, strict bool, err error) {
+	if job, err = decodeCrawlJob(body); err != nil {
+		return job, false, err
+	}
+	var flags struct {
+		Strict bool `json:"strict"`
+	}
+	err = json.Unmarshal(body, &flags)
+	return job, flags.Strict, err
+}
+
+// splitBatchSeeds separates a batch's valid seeds, without duplicates,
+// from the errors for its invalid ones, which name the URL's index
+func splitBatchSeeds(urls []string) (seeds []string, errs []fieldError) {
+	seen := make(map[string]bool, len(urls))
+	for i, raw := range urls {
+		if msg := validateSeedURL(raw); msg != "" {
+			errs = append(errs, fieldError{Field: fmt.Sprintf("urls[%d]", i), Message: msg})
+			continue
+		}
+		if !seen[raw] {
+			seen[raw] = true
+			seeds = append(seeds, raw)
+		}
+	}
+	return seeds, errs
+}
+
+// createBatchCrawlJob creates one job crawling several seeds together, so
+// they share deduplication and the job's limits. Invalid seeds are
+// reported and left out, or fail the whole batch when strict is set.
+func (s *APIServer) createBatchCrawlJob(w http.ResponseWriter, r *http.Request) {
+	body, ok := readJobBody(w, r)
+	if !ok {
+		return
+	}
+	job, strict, err := decodeBatchCrawlJob(body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(job.URLs) == 0 {
+		writeFieldErrors(w, []fieldError{{Field: "urls", Message: "must list at least one URL"}})
+		return
+	}
+	if errs := validateJobLimits(job); errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	seeds, rejected := splitBatchSeeds(job.URLs)
+	if len(seeds) == 0 || (strict && rejected != nil) {
+		writeFieldErrors(w, rejected)
+		return
+	}
+
+	job.URL, job.URLs = seeds[0], seeds
+	job.ID = fmt.Sprintf("job_%d", time.Now().Unix())
+	job.CreatedAt = time.Now()
+	job.Status = "pending"
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(batchCrawlJob{CrawlJob: job, Rejected: rejected})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// TestCreateCrawlJobValidation checks invalid jobs get 422 with per-field
+// errors, and valid ones are created with defaults for omitted fields.
+func TestCreateCrawlJobValidation(t *testing.T) {
+	server := NewAPIServer(NewInvertedIndexBackend())
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, httptest.NewRequest("POST", "/crawl", strings.NewReader(body)))
+		return rec
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantFields []string
+	}{
+		{"ftp url", `{"url": "ftp://example.com/files"}`, []string{"url"}},
+		{"missing url", `{"max_depth": 1}`, []string{"url"}},
+		{"relative url", `{"url": "/just/a/path"}`, []string{"url"}},
+		{"out of range", `{"url": "https://example.com", "max_depth": -1, "max_pages": 1000000, "rate_limit": -5}`,
+			[]string{"max_depth", "max_pages", "rate_limit"}},
+		{"negative pages", `{"url": "https://example.com", "max_pages": -1}`, []string{"max_pages"}},
+	}
+	for _, tt := range tests {
+		rec := post(tt.body)
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, http.StatusUnprocessableEntity)
+			continue
+		}
+		var response struct {
+			Errors []fieldError `json:"errors"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("%s: decoding errors: %v", tt.name, err)
+		}
+		var fields []string
+		for _, e := range response.Errors {
+			fields = append(fields, e.Field)
+			if e.Message == "" {
+				t.Errorf("%s: %s error has no message", tt.name, e.Field)
+			}
+		}
+		if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+			t.Errorf("%s: error fields = %v, want %v", tt.name, fields, tt.wantFields)
+		}
+	}
+
+	rec := post(`{"url": "ftp://example.com/files"}`)
+	if body := rec.Body.String(); !strings.Contains(body, "http or https") {
+		t.Errorf("bad scheme error isn't descriptive: %s", body)
+	}
+
+	if rec := post(`{not json`); rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed body: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if rec := post(`{"url": "https://example.com", "seeds": "` + strings.Repeat("x", maxJobBodyBytes) + `"}`); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	rec = post(`{"url": "https://example.com/start", "max_depth": 0, "max_pages": 0}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("valid job: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var job model.CrawlJob
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatal(err)
+	}
+	if job.MaxDepth != 0 || job.MaxPages != defaultJobPages || job.RateLimit != defaultJobRateLimit || job.Status != "pending" {
+		t.Errorf("created job = %+v, want depth 0 kept and other fields defaulted", job)
+	}
+}
+
+// TestCreateBatchCrawlJob posts a batch with one invalid URL and checks a
+// non-strict batch creates one job from the valid seeds while reporting the
+// invalid one, and a strict batch is rejected outright.
+func TestCreateBatchCrawlJob(t *testing.T) {
+	server := NewAPIServer(NewInvertedIndexBackend())
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, httptest.NewRequest("POST", "/crawl/batch", strings.NewReader(body)))
+		return rec
+	}
+	urls := `"urls": ["https://example.com/a", "ftp://example.com/files", "https://example.org/b", "https://example.com/a"]`
+
+	rec := post(`{` + urls + `, "max_pages": 500}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("non-strict batch: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var job batchCrawlJob
+	if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://example.com/a,https://example.org/b"; strings.Join(job.URLs, ",") != want || job.URL != job.URLs[0] {
+		t.Errorf("seeds = %v (url %q), want %s", job.URLs, job.URL, want)
+	}
+	if job.ID == "" || job.MaxPages != 500 || job.MaxDepth != defaultJobDepth || job.Status != "pending" {
+		t.Errorf("created job = %+v, want one pending job with the batch's limits", job.CrawlJob)
+	}
+	if len(job.Rejected) != 1 || job.Rejected[0].Field != "urls[1]" || !strings.Contains(job.Rejected[0].Message, "http or https") {
+		t.Errorf("rejected = %+v, want urls[1] reported", job.Rejected)
+	}
+
+	rec = post(`{` + urls + `, "strict": true}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("strict batch: status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"urls[1]"`) {
+		t.Errorf("strict batch errors don't name the invalid URL: %s", body)
+	}
+
+	for name, body := range map[string]string{
+		"no urls":     `{"max_depth": 1}`,
+		"all invalid": `{"urls": ["/relative"]}`,
+		"bad limits":  `{"urls": ["https://example.com"], "max_depth": 99}`,
+	} {
+		if rec := post(body); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status = %d, want %d", name, rec.Code, http.StatusUnprocessableEntity)
+		}
+	}
+}
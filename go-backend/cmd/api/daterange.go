@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+var errInvalidDateRange = errors.New("invalid date range")
+
+// dateWindow restricts results to documents dated within [from, to); a
+// zero bound leaves that side open
+type dateWindow struct {
+	from, to  time.Time
+	published bool // filter on PublishedAt rather than FetchedAt
+}
+
+// parseDateRange reads a search's date_range: a relative "7d" or "24h"
+// reaching back from now, or an absolute "from..to" of RFC 3339 times or
+// dates, either side of which may be left empty. A date as the upper
+// bound includes that whole day.
+func parseDateRange(spec, field string, now time.Time) (dateWindow, error) {
+	var w dateWindow
+	switch strings.ToLower(field) {
+	case "", "fetched", "fetched_at":
+	case "published", "published_at":
+		w.published = true
+	default:
+		return w, fmt.Errorf("%w: unknown date field %q", errInvalidDateRange, field)
+	}
+
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return w, nil
+	}
+	if from, to, ok := strings.Cut(spec, ".."); ok {
+		var err error
+		if w.from, err = parseRangeBound(from, false); err != nil {
+			return w, err
+		}
+		if w.to, err = parseRangeBound(to, true); err != nil {
+			return w, err
+		}
+		if from == "" && to == "" {
+			return w, fmt.Errorf("%w: %q has no bounds", errInvalidDateRange, spec)
+		}
+		if !w.from.IsZero() && !w.to.IsZero() && !w.from.Before(w.to) {
+			return w, fmt.Errorf("%w: %q ends before it starts", errInvalidDateRange, spec)
+		}
+		return w, nil
+	}
+
+	span, err := parseRelativeRange(spec)
+	if err != nil {
+		return w, err
+	}
+	w.from = now.Add(-span)
+	return w, nil
+}
+
+// parseRelativeRange reads a span such as "7d", or any time.ParseDuration
+// span such as "24h", which must be positive
+func parseRelativeRange(spec string) (time.Duration, error) {
+	var span time.Duration
+	if days, ok := strings.CutSuffix(spec, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", errInvalidDateRange, spec)
+		}
+		span = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if span, err = time.ParseDuration(spec); err != nil {
+			return 0, fmt.Errorf("%w: %q", errInvalidDateRange, spec)
+		}
+	}
+	if span <= 0 {
+		return 0, fmt.Errorf("%w: %q is not a positive span", errInvalidDateRange, spec)
+	}
+	return span, nil
+}
+
+// parseRangeBound reads one side of an absolute range; an empty side is
+// open. A bare date as the upper bound runs to the end of that day.
+func parseRangeBound(value string, upper bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q is not an RFC 3339 time or date", errInvalidDateRange, value)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// match reports whether the document's date falls in the window. With a
+// bound set, documents without a publish date never match on published.
+func (w dateWindow) match(doc model.Document) bool {
+	if w.from.IsZero() && w.to.IsZero() {
+		return true
+	}
+	date := doc.FetchedAt
+	if w.published {
+		if doc.Metadata.PublishedAt == nil {
+			return false
+		}
+		date = *doc.Metadata.PublishedAt
+	}
+	if !w.from.IsZero() && date.Before(w.from) {
+		return false
+	}
+	return w.to.IsZero() || date.Before(w.to)
+}
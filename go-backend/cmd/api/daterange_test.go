@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// datedBackends loads the same dated corpus into each kind of backend
+func datedBackends(t *testing.T) map[string]SearchBackend {
+	t.Helper()
+	index, memory := NewInvertedIndexBackend(), NewMemoryBackend()
+	loadDated(t, index)
+	loadDated(t, memory)
+	return map[string]SearchBackend{"index": index, "memory": memory}
+}
+
+func loadDated(t *testing.T, index Reindexer) {
+	t.Helper()
+	now := time.Now()
+	published := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	corpus := []model.Document{
+		{URL: "https://example.com/today", CleanText: "dreams today", FetchedAt: now.Add(-time.Hour)},
+		{URL: "https://example.com/lastweek", CleanText: "dreams last week", FetchedAt: now.Add(-5 * 24 * time.Hour)},
+		{URL: "https://example.com/old", CleanText: "old dreams", FetchedAt: now.Add(-60 * 24 * time.Hour),
+			Metadata: model.DocumentMetadata{PublishedAt: &published}},
+		{URL: "https://example.com/jan", CleanText: "january dreams", FetchedAt: time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{URL: "https://example.com/feb", CleanText: "february dreams", FetchedAt: time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC)},
+	}
+	for _, doc := range corpus {
+		if err := index.Upsert(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestSearchDateRange checks relative and absolute ranges, open-ended on
+// either side, keep only documents dated inside the window, whichever
+// backend answers.
+func TestSearchDateRange(t *testing.T) {
+	tests := []struct {
+		dateRange, field string
+		want             []string
+	}{
+		{"24h", "", []string{"https://example.com/today"}},
+		{"7d", "", []string{"https://example.com/lastweek", "https://example.com/today"}},
+		{"2024-01-01..2024-02-29", "", []string{"https://example.com/feb", "https://example.com/jan"}},
+		{"2024-01-01T00:00:00Z..2024-02-01T00:00:00Z", "", []string{"https://example.com/jan"}},
+		{"..2024-01-31", "", []string{"https://example.com/jan"}},
+		{"2024-03-01..", "published", []string{"https://example.com/old"}},
+	}
+	for name, backend := range datedBackends(t) {
+		for _, tt := range tests {
+			results, err := backend.Search(model.SearchQuery{Query: "dreams", DateRange: tt.dateRange, DateField: tt.field})
+			if err != nil {
+				t.Errorf("%s: %s: %v", name, tt.dateRange, err)
+				continue
+			}
+			got := resultURLs(results)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: %s on %q: got %v, want %v", name, tt.dateRange, tt.field, got, tt.want)
+			}
+		}
+	}
+}
+
+// TestSearchDateRangeMalformed checks bad ranges are rejected as 400s
+// by every backend
+func TestSearchDateRangeMalformed(t *testing.T) {
+	for name, backend := range datedBackends(t) {
+		server := NewAPIServer(backend)
+		for _, query := range []string{
+			"date_range=7x",
+			"date_range=-3d",
+			"date_range=yesterday..today",
+			"date_range=2024-02-01..2024-01-01",
+			"date_range=..",
+			"date_range=7d&date_field=modified",
+		} {
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, httptest.NewRequest("GET", "/search?q=dreams&"+query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s: %s: status = %d, want %d", name, query, rec.Code, http.StatusBadRequest)
+			}
+		}
+	}
+}
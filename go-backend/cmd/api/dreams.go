@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// Dream generation config
+var (
+	ollamaURL     = flag.String("ollama-url", "", "Ollama base URL used to generate dream narratives, e.g. http://localhost:11434 (template narratives are used when empty)")
+	ollamaModel   = flag.String("ollama-model", "llama3", "Ollama model that generates dream narratives")
+	ollamaTimeout = flag.Duration("ollama-timeout", 60*time.Second, "how long a dream narrative may take before the template one is used instead")
+	dreamCacheMax = flag.Int("dream-cache-max", 10000, "Ollama dream narratives kept for reuse, one per document, least recently served evicted first (0 = none)")
+)
+
+// templateDreamModel is the Model of template narratives
+const templateDreamModel = "template"
+
+// DreamGenerator writes a dream narrative for a document. Generation stops
+// when ctx is done, e.g. when the client asking for the dream disconnects.
+type DreamGenerator interface {
+	Generate(ctx context.Context, doc model.Document) (model.DreamOutput, error)
+}
+
+// TemplateDreamGenerator fills a fixed sentence from the document's title
+// and dream hints. It never fails, so it backs the other generators.
+type TemplateDreamGenerator struct{}
+
+func (TemplateDreamGenerator) Generate(ctx context.Context, doc model.Document) (model.DreamOutput, error) {
+	narrative := "A surreal dream about " + doc.Title
+	if hints := doc.DreamHints; len(hints.Themes) > 0 || len(hints.Motifs) > 0 {
+		narrative += ", where " + strings.Join(append(hints.Themes[:len(hints.Themes):len(hints.Themes)], hints.Motifs...), " and ") + " drift together"
+	}
+	return model.DreamOutput{
+		DocumentID:  doc.ID,
+		URL:         doc.URL,
+		GeneratedAt: time.Now().UTC(),
+		Narrative:   narrative + "...",
+		Confidence:  0.5,
+		Model:       templateDreamModel,
+	}, nil
+}
+
+// OllamaDreamGenerator asks an Ollama server's /api/generate endpoint for
+// a narrative built from the document's dream hints
+type OllamaDreamGenerator struct {
+	BaseURL string
+	Model   string
+	Client  *http.Client // its Timeout bounds each narrative
+}
+
+// ollamaChunk is one line of a streamed /api/generate response, or the
+// whole of an unstreamed one
+type ollamaChunk struct {
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error"`
+}
+
+func (g *OllamaDreamGenerator) Generate(ctx context.Context, doc model.Document) (model.DreamOutput, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":  g.Model,
+		"prompt": dreamPrompt(doc),
+		"stream": false,
+	})
+	if err != nil {
+		return model.DreamOutput{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(g.BaseURL, "/")+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return model.DreamOutput{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return model.DreamOutput{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return model.DreamOutput{}, fmt.Errorf("ollama returned %s", resp.Status)
+	}
+
+	// Streamed responses are one JSON object per line; an unstreamed one
+	// is a single object with done set
+	var narrative strings.Builder
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaChunk
+		if err := dec.Decode(&chunk); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return model.DreamOutput{}, fmt.Errorf("reading ollama response: %w", err)
+		}
+		if chunk.Error != "" {
+			return model.DreamOutput{}, fmt.Errorf("ollama: %s", chunk.Error)
+		}
+		narrative.WriteString(chunk.Response)
+		if chunk.Done {
+			break
+		}
+	}
+	text := strings.TrimSpace(narrative.String())
+	if text == "" {
+		return model.DreamOutput{}, errors.New("ollama returned an empty narrative")
+	}
+	return model.DreamOutput{
+		DocumentID:  doc.ID,
+		URL:         doc.URL,
+		GeneratedAt: time.Now().UTC(),
+		Narrative:   text,
+		Confidence:  0.85,
+		Model:       g.Model,
+	}, nil
+}
+
+// dreamPrompt asks for a short dream narrative in the document's themes,
+// emotions, motifs and tone
+func dreamPrompt(doc model.Document) string {
+	hints := doc.DreamHints
+	var b strings.Builder
+	b.WriteString("Write a short, vivid dream narrative (one paragraph, present tense, second person)")
+	if doc.Title != "" {
+		fmt.Fprintf(&b, " inspired by a page titled %q", doc.Title)
+	}
+	b.WriteString(".\n")
+	for _, hint := range []struct {
+		name   string
+		values []string
+	}{
+		{"Themes", hints.Themes},
+		{"Emotions", hints.Emotions},
+		{"Motifs", hints.Motifs},
+		{"Visual cues", hints.VisualCues},
+	} {
+		if len(hint.values) > 0 {
+			fmt.Fprintf(&b, "%s: %s\n", hint.name, strings.Join(hint.values, ", "))
+		}
+	}
+	if hints.Tone != "" {
+		fmt.Fprintf(&b, "Tone: %s\n", hints.Tone)
+	}
+	b.WriteString("Reply with the narrative only.")
+	return b.String()
+}
+
+// fallbackDreamGenerator uses fallback whenever primary fails, e.g. when
+// the model server is down or too slow, but not once ctx is done
+type fallbackDreamGenerator struct {
+	primary, fallback DreamGenerator
+}
+
+func (g fallbackDreamGenerator) Generate(ctx context.Context, doc model.Document) (model.DreamOutput, error) {
+	dream, err := g.primary.Generate(ctx, doc)
+	if err == nil || ctx.Err() != nil {
+		return dream, err
+	}
+	log.Printf("Dream generation failed for %s, using the fallback: %v", doc.URL, err)
+	return g.fallback.Generate(ctx, doc)
+}
+
+// cachedDreamGenerator remembers the narratives of generator per document,
+// so a model is asked once per document rather than on every request.
+// Entries are keyed by ID and content hash, so a document whose content
+// changes gets a new dream. Like the crawler's registries it is a
+// fixed-size LRU.
+type cachedDreamGenerator struct {
+	generator DreamGenerator
+
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recently served key at the front
+	dreams   map[string]*list.Element
+}
+
+// cachedDream is one cachedDreamGenerator entry
+type cachedDream struct {
+	key   string
+	dream model.DreamOutput
+}
+
+func newCachedDreamGenerator(generator DreamGenerator, capacity int) *cachedDreamGenerator {
+	return &cachedDreamGenerator{
+		generator: generator,
+		capacity:  capacity,
+		order:     list.New(),
+		dreams:    make(map[string]*list.Element),
+	}
+}
+
+// Generate serves a cached dream, or generates and caches one. Failures
+// and template fallbacks aren't cached, so a later request tries the model
+// again.
+func (g *cachedDreamGenerator) Generate(ctx context.Context, doc model.Document) (model.DreamOutput, error) {
+	key := doc.ID + "\x00" + doc.ContentHash
+	g.mu.Lock()
+	if e, ok := g.dreams[key]; ok {
+		g.order.MoveToFront(e)
+		dream := e.Value.(*cachedDream).dream
+		g.mu.Unlock()
+		return dream, nil
+	}
+	g.mu.Unlock()
+
+	dream, err := g.generator.Generate(ctx, doc)
+	if err != nil || dream.Model == templateDreamModel {
+		return dream, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if e, ok := g.dreams[key]; ok {
+		// Generated concurrently by another request
+		g.order.MoveToFront(e)
+		return e.Value.(*cachedDream).dream, nil
+	}
+	g.dreams[key] = g.order.PushFront(&cachedDream{key: key, dream: dream})
+	if g.order.Len() > g.capacity {
+		oldest := g.order.Back()
+		g.order.Remove(oldest)
+		delete(g.dreams, oldest.Value.(*cachedDream).key)
+	}
+	return dream, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+var dreamDoc = model.Document{
+	ID:    "doc-1",
+	URL:   "https://example.com/lucid",
+	Title: "Lucid Dreaming",
+	DreamHints: model.DreamingHints{
+		Themes:   []string{"flight"},
+		Emotions: []string{"wonder"},
+		Motifs:   []string{"staircases"},
+		Tone:     "mysterious",
+	},
+}
+
+// TestOllamaDreamGenerator checks the prompt carries the dream hints and
+// the narrative and model name are taken from a mock Ollama server, both
+// streamed and not.
+func TestOllamaDreamGenerator(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		var request struct {
+			Model  string `json:"model"`
+			Prompt string `json:"prompt"`
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/api/generate" {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			if stream {
+				for _, word := range []string{"You climb ", "endless ", "staircases."} {
+					fmt.Fprintf(w, "{\"model\":%q,\"response\":%q,\"done\":false}\n", request.Model, word)
+				}
+				fmt.Fprintf(w, "{\"model\":%q,\"response\":\"\",\"done\":true}\n", request.Model)
+				return
+			}
+			fmt.Fprintf(w, `{"model":%q,"response":"You climb endless staircases.","done":true}`, request.Model)
+		}))
+
+		g := &OllamaDreamGenerator{BaseURL: server.URL + "/", Model: "llama3", Client: server.Client()}
+		dream, err := g.Generate(context.Background(), dreamDoc)
+		server.Close()
+		if err != nil {
+			t.Fatalf("stream %v: %v", stream, err)
+		}
+		if dream.Narrative != "You climb endless staircases." || dream.Model != "llama3" || dream.DocumentID != "doc-1" {
+			t.Errorf("stream %v: dream = %+v", stream, dream)
+		}
+		for _, hint := range []string{"flight", "wonder", "staircases", "mysterious", "Lucid Dreaming"} {
+			if !strings.Contains(request.Prompt, hint) {
+				t.Errorf("prompt is missing %q: %s", hint, request.Prompt)
+			}
+		}
+	}
+}
+
+// TestOllamaDreamGeneratorFallback checks errors and timeouts are returned
+// so the template generator can take over.
+func TestOllamaDreamGeneratorFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow/api/generate":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := server.Client()
+	client.Timeout = 50 * time.Millisecond
+	for _, base := range []string{server.URL, server.URL + "/slow"} {
+		g := &OllamaDreamGenerator{BaseURL: base, Model: "llama3", Client: client}
+		if _, err := g.Generate(context.Background(), dreamDoc); err == nil {
+			t.Errorf("%s: no error", base)
+		}
+		dream, err := fallbackDreamGenerator{primary: g, fallback: TemplateDreamGenerator{}}.Generate(context.Background(), dreamDoc)
+		if err != nil || dream.Model != templateDreamModel || !strings.Contains(dream.Narrative, "Lucid Dreaming") {
+			t.Errorf("%s: fallback dream = %+v, %v", base, dream, err)
+		}
+	}
+}
+
+// countingDreamGenerator counts the dreams it is asked for
+type countingDreamGenerator struct {
+	calls int
+	model string
+}
+
+func (g *countingDreamGenerator) Generate(ctx context.Context, doc model.Document) (model.DreamOutput, error) {
+	g.calls++
+	return model.DreamOutput{DocumentID: doc.ID, Narrative: fmt.Sprintf("dream %d", g.calls), Model: g.model}, nil
+}
+
+// TestCachedDreamGenerator checks a document's dream is generated once
+// until its content changes or it is evicted, and that template fallbacks
+// aren't kept.
+func TestCachedDreamGenerator(t *testing.T) {
+	counter := &countingDreamGenerator{model: "llama3"}
+	g := newCachedDreamGenerator(counter, 2)
+	ctx := context.Background()
+
+	first, _ := g.Generate(ctx, dreamDoc)
+	again, _ := g.Generate(ctx, dreamDoc)
+	if counter.calls != 1 || again.Narrative != first.Narrative {
+		t.Errorf("repeat request generated %d dreams, served %q after %q", counter.calls, again.Narrative, first.Narrative)
+	}
+
+	changed := dreamDoc
+	changed.ContentHash = "new content"
+	g.Generate(ctx, changed)
+	if counter.calls != 2 {
+		t.Errorf("changed content generated %d dreams, want 2", counter.calls)
+	}
+
+	other := dreamDoc
+	other.ID = "doc-2"
+	g.Generate(ctx, other) // evicts dreamDoc, the least recently served
+	g.Generate(ctx, dreamDoc)
+	if counter.calls != 4 {
+		t.Errorf("evicted dream generated %d dreams, want 4", counter.calls)
+	}
+
+	template := newCachedDreamGenerator(&countingDreamGenerator{model: templateDreamModel}, 2)
+	template.Generate(ctx, dreamDoc)
+	template.Generate(ctx, dreamDoc)
+	if calls := template.generator.(*countingDreamGenerator).calls; calls != 2 {
+		t.Errorf("template dreams were cached: %d generated, want 2", calls)
+	}
+}
+
+// TestDreamGenerationCancelled checks a client disconnecting stops the
+// Ollama request and doesn't fall back to the template.
+func TestDreamGenerationCancelled(t *testing.T) {
+	stopped := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body) // the disconnect is noticed once the body is read
+		<-r.Context().Done()
+		close(stopped)
+	}))
+	defer server.Close()
+
+	g := fallbackDreamGenerator{
+		primary:  &OllamaDreamGenerator{BaseURL: server.URL, Model: "llama3", Client: server.Client()},
+		fallback: TemplateDreamGenerator{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if dream, err := g.Generate(ctx, dreamDoc); err == nil {
+		t.Errorf("cancelled generation returned %+v", dream)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("the Ollama request kept running after cancellation")
+	}
+}
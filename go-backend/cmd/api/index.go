@@ -0,0 +1,328 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	maxHighlights   = 3
+	highlightRadius = 60
+)
+
+// InvertedIndexBackend is an in-memory full-text index scored with BM25.
+// Queries are AND by default; "OR" between terms switches to any-match.
+type InvertedIndexBackend struct {
+	mu       sync.RWMutex
+	docs     map[string]*indexedDoc    // by URL
+	ids      map[string]string         // document ID -> URL
+	postings map[string]map[string]int // term -> URL -> term frequency
+	totalLen int
+	suggest  suggestTrie
+}
+
+type indexedDoc struct {
+	doc         model.Document
+	terms       map[string]int
+	length      int
+	suggestions []suggestKey
+}
+
+func NewInvertedIndexBackend() *InvertedIndexBackend {
+	return &InvertedIndexBackend{
+		docs:     make(map[string]*indexedDoc),
+		ids:      make(map[string]string),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+func (b *InvertedIndexBackend) Upsert(doc model.Document) error {
+	if doc.URL == "" {
+		return errMissingURL
+	}
+	doc.ID = doc.DocumentID()
+
+	text := []string{doc.Title, doc.CleanText}
+	for _, chunk := range doc.Chunks {
+		text = append(text, chunk.Text)
+	}
+	tokens := tokenize(strings.Join(text, " "))
+	terms := make(map[string]int)
+	for _, token := range tokens {
+		terms[token]++
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(doc.URL)
+	suggestions := suggestionKeys(doc.Title, terms, *suggestKeywords)
+	b.docs[doc.URL] = &indexedDoc{doc: doc, terms: terms, length: len(tokens), suggestions: suggestions}
+	b.ids[doc.ID] = doc.URL
+	b.totalLen += len(tokens)
+	b.suggest.add(suggestions)
+	for term, tf := range terms {
+		if b.postings[term] == nil {
+			b.postings[term] = make(map[string]int)
+		}
+		b.postings[term][doc.URL] = tf
+	}
+	return nil
+}
+
+func (b *InvertedIndexBackend) Delete(url string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(url)
+	return nil
+}
+
+// remove drops a document and its postings; callers hold the write lock
+func (b *InvertedIndexBackend) remove(url string) {
+	old, ok := b.docs[url]
+	if !ok {
+		return
+	}
+	for term := range old.terms {
+		delete(b.postings[term], url)
+		if len(b.postings[term]) == 0 {
+			delete(b.postings, term)
+		}
+	}
+	b.totalLen -= old.length
+	b.suggest.remove(old.suggestions)
+	delete(b.ids, old.doc.ID)
+	delete(b.docs, url)
+}
+
+// Document looks up an indexed document by its canonical ID
+func (b *InvertedIndexBackend) Document(id string) (model.Document, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	entry, ok := b.docs[b.ids[id]]
+	if !ok {
+		return model.Document{}, false
+	}
+	return entry.doc, true
+}
+
+// Suggest completes prefix from indexed titles and frequent terms
+func (b *InvertedIndexBackend) Suggest(prefix string, limit int) []Suggestion {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.suggest.suggest(prefix, limit)
+}
+
+func (b *InvertedIndexBackend) Search(query model.SearchQuery) ([]model.SearchResult, error) {
+	terms, matchAny := parseQuery(query.Query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	filters := parseFilters(query.Filters)
+	window, err := parseDateRange(query.DateRange, query.DateField, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	// Candidate documents: intersection (AND) or union (OR) of postings
+	matches := make(map[string]int)
+	for _, term := range terms {
+		for url := range b.postings[term] {
+			matches[url]++
+		}
+	}
+
+	n := float64(len(b.docs))
+	avgLen := float64(b.totalLen) / math.Max(n, 1)
+
+	var results []model.SearchResult
+	for url, matched := range matches {
+		if !matchAny && matched < len(terms) {
+			continue
+		}
+		entry := b.docs[url]
+		if !filters.match(entry.doc) || !window.match(entry.doc) {
+			continue
+		}
+
+		score := 0.0
+		for _, term := range terms {
+			tf := float64(entry.terms[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(len(b.postings[term]))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := tf + bm25K1*(1-bm25B+bm25B*float64(entry.length)/avgLen)
+			score += idf * tf * (bm25K1 + 1) / norm
+		}
+
+		result := model.SearchResult{Document: entry.doc, Score: score}
+		if entry.doc.Metadata.AllowsSnippets() {
+			result.Highlights = highlights(entry.doc.CleanText, terms)
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Document.URL < results[j].Document.URL
+	})
+	return results, nil
+}
+
+// tokenize lowercases text and splits it into letter/digit runs
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// parseQuery splits a query into unique terms and reports whether any term
+// may match ("a OR b") rather than all of them
+func parseQuery(q string) ([]string, bool) {
+	var terms []string
+	matchAny := false
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(q) {
+		if word == "OR" {
+			matchAny = true
+			continue
+		}
+		for _, term := range tokenize(word) {
+			if !seen[term] {
+				seen[term] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms, matchAny
+}
+
+// searchFilters restricts results by metadata, from "key:value" filters
+type searchFilters struct {
+	domains    map[string]bool
+	tags       map[string]bool
+	categories map[string]bool
+}
+
+func parseFilters(raw []string) searchFilters {
+	var f searchFilters
+	add := func(set *map[string]bool, value string) {
+		if *set == nil {
+			*set = make(map[string]bool)
+		}
+		(*set)[strings.ToLower(value)] = true
+	}
+	for _, filter := range raw {
+		key, value, ok := strings.Cut(filter, ":")
+		if !ok || value == "" {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "domain":
+			add(&f.domains, value)
+		case "tag":
+			add(&f.tags, value)
+		case "category":
+			add(&f.categories, value)
+		}
+	}
+	return f
+}
+
+func (f searchFilters) match(doc model.Document) bool {
+	if f.domains != nil && !f.domains[strings.ToLower(doc.Metadata.Domain)] {
+		return false
+	}
+	if f.categories != nil && !f.categories[strings.ToLower(doc.Metadata.Category)] {
+		return false
+	}
+	if f.tags != nil {
+		for _, tag := range doc.Metadata.Tags {
+			if f.tags[strings.ToLower(tag)] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// highlights returns short snippets of text around the first occurrences
+// of the query terms. Terms are matched case-insensitively in text itself,
+// since lowercasing can change a string's byte length and so its offsets.
+func highlights(text string, terms []string) []string {
+	var snippets []string
+	covered := -1
+	for _, term := range terms {
+		i, n := indexFold(text, term)
+		if i < 0 || i < covered {
+			continue
+		}
+		start := max(0, i-highlightRadius)
+		end := min(len(text), i+n+highlightRadius)
+		if start >= len(text) {
+			continue
+		}
+		for start > 0 && !utf8.RuneStart(text[start]) {
+			start--
+		}
+		for end < len(text) && !utf8.RuneStart(text[end]) {
+			end++
+		}
+		snippets = append(snippets, strings.TrimSpace(text[start:end]))
+		covered = end
+		if len(snippets) == maxHighlights {
+			break
+		}
+	}
+	return snippets
+}
+
+// indexFold returns the byte offset and length of the first case-insensitive
+// match of term in s, or -1
+func indexFold(s, term string) (int, int) {
+	if term == "" {
+		return -1, 0
+	}
+	for i := range s {
+		if n, ok := hasPrefixFold(s[i:], term); ok {
+			return i, n
+		}
+	}
+	return -1, 0
+}
+
+// hasPrefixFold reports whether s starts with prefix under Unicode case
+// folding, and how many bytes of s the match covers
+func hasPrefixFold(s, prefix string) (int, bool) {
+	n := 0
+	for _, want := range prefix {
+		if n >= len(s) {
+			return 0, false
+		}
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if r != want && !strings.EqualFold(string(r), string(want)) {
+			return 0, false
+		}
+		n += size
+	}
+	return n, true
+}
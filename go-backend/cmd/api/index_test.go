@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+func newTestIndex(t *testing.T) *InvertedIndexBackend {
+	t.Helper()
+	index := NewInvertedIndexBackend()
+	corpus := []model.Document{
+		{
+			URL:       "https://dreams.example.com/lucid",
+			Title:     "Lucid dreams",
+			CleanText: "Lucid dreams let the dreamer steer the dream. Many dreams feel vivid and dreams repeat.",
+			Metadata:  model.DocumentMetadata{Domain: "dreams.example.com", Tags: []string{"sleep"}, Category: "science"},
+		},
+		{
+			URL:       "https://dreams.example.com/sleep",
+			Title:     "Sleep cycles",
+			CleanText: "REM sleep is the stage where most dreams happen, alongside slow wave sleep.",
+			Metadata:  model.DocumentMetadata{Domain: "dreams.example.com", Tags: []string{"sleep"}, Category: "health"},
+		},
+		{
+			URL:       "https://art.example.org/surreal",
+			Title:     "Surrealist painting",
+			CleanText: "Surrealist painters turned dream imagery into melting clocks and floating trains.",
+			Metadata:  model.DocumentMetadata{Domain: "art.example.org", Tags: []string{"art"}, Category: "art"},
+		},
+	}
+	for _, doc := range corpus {
+		if err := index.Upsert(doc); err != nil {
+			t.Fatalf("Upsert(%s) returned an error: %v", doc.URL, err)
+		}
+	}
+	return index
+}
+
+func resultURLs(results []model.SearchResult) []string {
+	urls := make([]string, len(results))
+	for i, r := range results {
+		urls[i] = r.Document.URL
+	}
+	return urls
+}
+
+// TestInvertedIndexRanking verifies BM25 ordering and AND/OR semantics.
+func TestInvertedIndexRanking(t *testing.T) {
+	index := newTestIndex(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// The lucid page repeats "dreams" the most
+		{"dreams", []string{"https://dreams.example.com/lucid", "https://dreams.example.com/sleep"}},
+		{"dreams sleep", []string{"https://dreams.example.com/sleep"}},
+		{"lucid OR surrealist", []string{"https://art.example.org/surreal", "https://dreams.example.com/lucid"}},
+		{"nightmare", nil},
+		{"dreams nightmare", nil},
+	}
+	for _, tt := range tests {
+		results, err := index.Search(model.SearchQuery{Query: tt.query})
+		if err != nil {
+			t.Fatalf("Search(%q) returned an error: %v", tt.query, err)
+		}
+		got := resultURLs(results)
+		if len(got) != len(tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+
+	results, _ := index.Search(model.SearchQuery{Query: "surrealist"})
+	if len(results) != 1 || len(results[0].Highlights) == 0 {
+		t.Fatalf("expected a highlighted result for %q, got %+v", "surrealist", results)
+	}
+}
+
+// TestInvertedIndexNoSnippet checks a nosnippet page is still found but
+// returned without highlights.
+func TestInvertedIndexNoSnippet(t *testing.T) {
+	index := newTestIndex(t)
+	index.Upsert(model.Document{
+		URL:       "https://private.example.com/diary",
+		Title:     "Private diary",
+		CleanText: "A diary of recurring dreams about flying over oceans.",
+		Metadata:  model.DocumentMetadata{Domain: "private.example.com", UsageDirectives: []string{model.UsageNoSnippet}},
+	})
+	results, _ := index.Search(model.SearchQuery{Query: "oceans"})
+	if len(results) != 1 || len(results[0].Highlights) != 0 {
+		t.Errorf("want the diary without highlights, got %+v", results)
+	}
+}
+
+// TestInvertedIndexFiltersAndUpdates verifies metadata filters and that
+// re-upserting or deleting a document updates the postings.
+func TestInvertedIndexFiltersAndUpdates(t *testing.T) {
+	index := newTestIndex(t)
+
+	results, _ := index.Search(model.SearchQuery{Query: "dream OR dreams", Filters: []string{"domain:art.example.org"}})
+	if got := resultURLs(results); len(got) != 1 || got[0] != "https://art.example.org/surreal" {
+		t.Errorf("domain filter returned %v", got)
+	}
+	results, _ = index.Search(model.SearchQuery{Query: "dreams", Filters: []string{"category:health"}})
+	if got := resultURLs(results); len(got) != 1 || got[0] != "https://dreams.example.com/sleep" {
+		t.Errorf("category filter returned %v", got)
+	}
+	results, _ = index.Search(model.SearchQuery{Query: "dream OR dreams", Filters: []string{"tag:art"}})
+	if got := resultURLs(results); len(got) != 1 {
+		t.Errorf("tag filter returned %v", got)
+	}
+
+	index.Upsert(model.Document{URL: "https://dreams.example.com/lucid", Title: "Moved", CleanText: "Nothing here anymore."})
+	if results, _ := index.Search(model.SearchQuery{Query: "lucid"}); len(results) != 0 {
+		t.Errorf("stale postings after re-upsert: %v", resultURLs(results))
+	}
+
+	index.Delete("https://art.example.org/surreal")
+	if results, _ := index.Search(model.SearchQuery{Query: "surrealist"}); len(results) != 0 {
+		t.Errorf("deleted document still found: %v", resultURLs(results))
+	}
+}
+
+// TestHighlightsCaseFolding checks snippets stay on the matched term when
+// lowercasing the text would change its length, as it does for "İ".
+func TestHighlightsCaseFolding(t *testing.T) {
+	text := strings.Repeat("İ", 100) + " Lebhafte TRÄUME kehren wieder"
+	snippets := highlights(text, []string{"träume"})
+	if len(snippets) != 1 || !strings.Contains(snippets[0], "TRÄUME") || !utf8.ValidString(snippets[0]) {
+		t.Errorf("highlights = %q, want one valid snippet containing TRÄUME", snippets)
+	}
+	if i, n := indexFold(text, "träume"); i < 0 || text[i:i+n] != "TRÄUME" {
+		t.Errorf("indexFold found %d+%d, want TRÄUME", i, n)
+	}
+}
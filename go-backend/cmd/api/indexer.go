@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// runIndexFeed keeps the search backend in sync with the content
+// processor's output by upserting every document on the clean content
+// topic under -topic-prefix, decoded with serializer, counting each into
+// counters
+func runIndexFeed(broker, groupID string, serializer model.Serializer, reindexer Reindexer, counters *feedCounters) {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": broker,
+		"group.id":          groupID,
+		"auto.offset.reset": "earliest",
+	})
+	if err != nil {
+		log.Printf("Index feed disabled, failed to create consumer: %v", err)
+		return
+	}
+	defer consumer.Close()
+
+	topic := model.PrefixedTopic(*topicPrefix, model.TopicCleanContent)
+	if err := consumer.Subscribe(topic, nil); err != nil {
+		log.Printf("Index feed disabled, failed to subscribe: %v", err)
+		return
+	}
+	log.Println("Index feed consuming from:", topic)
+
+	for {
+		msg, err := consumer.ReadMessage(-1)
+		if err != nil {
+			log.Printf("Index feed: error reading message: %v", err)
+			continue
+		}
+
+		var doc model.Document
+		if err := serializer.Unmarshal(msg.Value, &doc); err != nil {
+			log.Printf("Index feed: error unmarshaling document: %v", err)
+			counters.record(doc, err)
+			continue
+		}
+		err = reindexer.Upsert(doc)
+		if err != nil {
+			log.Printf("Index feed: failed to index %s: %v", doc.URL, err)
+		}
+		counters.record(doc, err)
+	}
+}
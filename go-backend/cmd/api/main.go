@@ -1,12 +1,14 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -14,17 +16,36 @@ import (
 )
 
 var (
-	port = flag.String("port", "8080", "API server port")
+	port        = flag.String("port", "8080", "API server port")
+	adminToken  = flag.String("admin-token", "", "bearer token required by admin endpoints (admin endpoints are disabled when empty)")
+	indexBroker = flag.String("index-broker", "", "Kafka broker to index clean content from (disabled when empty)")
+	indexGroup  = flag.String("index-group", "api-indexer", "Kafka consumer group for the index feed")
+	mlService   = flag.String("ml-service", "", "ML service base URL used to embed semantic queries (semantic search is disabled when empty)")
+)
+
+// Index feed decoding; must match the content processor's output
+var (
+	indexFormat         = flag.String("index-serialization", model.FormatJSON, "document encoding on the clean content topic: json, protobuf or avro")
+	indexSchemaRegistry = flag.String("index-schema-registry", "", "schema registry URL, when the clean content topic is Avro framed for one")
+	topicPrefix         = flag.String("topic-prefix", "", "tenant prefix of the clean content topic; must match the content processor's -topic-prefix")
 )
 
 type APIServer struct {
-	router *mux.Router
-	// In a real implementation, you'd have database connections here
+	router  *mux.Router
+	backend SearchBackend
+
+	counters *feedCounters
+	history  *statsRing
+	dreams   DreamGenerator
 }
 
-func NewAPIServer() *APIServer {
+func NewAPIServer(backend SearchBackend) *APIServer {
 	server := &APIServer{
-		router: mux.NewRouter(),
+		router:   mux.NewRouter(),
+		backend:  backend,
+		counters: newFeedCounters(),
+		history:  newStatsRing(*statsHistory),
+		dreams:   TemplateDreamGenerator{},
 	}
 	
 	server.setupRoutes()
@@ -37,6 +58,7 @@ func (s *APIServer) setupRoutes() {
 	
 	// Crawling endpoints
 	s.router.HandleFunc("/crawl", s.createCrawlJob).Methods("POST")
+	s.router.HandleFunc("/crawl/batch", s.createBatchCrawlJob).Methods("POST")
 	s.router.HandleFunc("/crawl/{id}", s.getCrawlJob).Methods("GET")
 	s.router.HandleFunc("/crawl/{id}/status", s.getCrawlStatus).Methods("GET")
 	
@@ -44,14 +66,20 @@ func (s *APIServer) setupRoutes() {
 	s.router.HandleFunc("/search", s.searchDocuments).Methods("GET")
 	s.router.HandleFunc("/search/semantic", s.semanticSearch).Methods("GET")
 	s.router.HandleFunc("/search/dreams", s.searchDreams).Methods("GET")
+	s.router.HandleFunc("/search/suggest", s.suggestSearch).Methods("GET")
 	
 	// Document endpoints
+	s.router.Handle("/documents", s.adminAuth(http.HandlerFunc(s.upsertDocument))).Methods("POST")
+	s.router.Handle("/documents", s.adminAuth(http.HandlerFunc(s.deleteDocument))).Methods("DELETE")
 	s.router.HandleFunc("/documents/{id}", s.getDocument).Methods("GET")
 	s.router.HandleFunc("/documents/{id}/dreams", s.getDocumentDreams).Methods("GET")
+	s.router.HandleFunc("/documents/{id}/similar", s.similarDocuments).Methods("GET")
 	
 	// Stats and analytics
 	s.router.HandleFunc("/stats", s.getStats).Methods("GET")
 	s.router.HandleFunc("/stats/crawling", s.getCrawlingStats).Methods("GET")
+	s.router.HandleFunc("/stats/timeseries", s.getStatsTimeseries).Methods("GET")
+	s.router.HandleFunc("/stats/content", s.getContentStats).Methods("GET")
 	
 	// Middleware
 	s.router.Use(s.loggingMiddleware)
@@ -75,27 +103,25 @@ func (s *APIServer) healthHandler(w http.ResponseWriter, r *http.Request) {
 
 // Create a new crawl job
 func (s *APIServer) createCrawlJob(w http.ResponseWriter, r *http.Request) {
-	var job model.CrawlJob
-	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+	body, ok := readJobBody(w, r)
+	if !ok {
+		return
+	}
+	job, err := decodeCrawlJob(body)
+	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if errs := validateCrawlJob(job); errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
 	
-	// Generate job ID and set defaults
+	// Generate job ID
 	job.ID = fmt.Sprintf("job_%d", time.Now().Unix())
 	job.CreatedAt = time.Now()
 	job.Status = "pending"
 	
-	if job.MaxDepth == 0 {
-		job.MaxDepth = 2
-	}
-	if job.MaxPages == 0 {
-		job.MaxPages = 100
-	}
-	if job.RateLimit == 0 {
-		job.RateLimit = 10
-	}
-	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(job)
@@ -165,22 +191,35 @@ func (s *APIServer) searchDocuments(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	
-	// Mock search results
-	results := []model.SearchResult{
-		{
-			Document: model.Document{
-				URL:       "https://example.com/article1",
-				Title:     "Sample Article",
-				CleanText: "This is a sample article about " + query,
-			},
-			Score: 0.95,
-		},
+	dateRange := r.URL.Query().Get("date_range") // e.g. 7d or 2024-01-01..2024-02-01
+	dateField := r.URL.Query().Get("date_field") // fetched or published
+	// Rejected here so every backend answers a malformed range the same way
+	if _, err := parseDateRange(dateRange, dateField, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+
+	results, err := s.backend.Search(model.SearchQuery{
+		Query:      query,
+		Filters:    r.URL.Query()["filter"], // e.g. filter=domain:example.com
+		Limit:      limit,
+		Offset:     offset,
+		SearchType: "text",
+		DateRange:  dateRange,
+		DateField:  dateField,
+	})
+	if err != nil {
+		log.Printf("search %q failed: %v", query, err)
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+	total := len(results)
+	results = paginate(results, offset, limit)
 	
 	response := map[string]interface{}{
 		"query":   query,
 		"results": results,
-		"total":   len(results),
+		"total":   total,
 		"limit":   limit,
 		"offset":  offset,
 	}
@@ -197,16 +236,22 @@ func (s *APIServer) semanticSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// Mock semantic search results
-	results := []model.SearchResult{
-		{
-			Document: model.Document{
-				URL:       "https://example.com/semantic1",
-				Title:     "Semantic Result",
-				CleanText: "This document is semantically related to: " + query,
-			},
-			Score: 0.87,
-		},
+	semantic, ok := s.backend.(SemanticSearcher)
+	if !ok {
+		http.Error(w, "Semantic search is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	limit := 10
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	results, err := semantic.Semantic(query, limit)
+	if err != nil {
+		log.Printf("semantic search %q failed: %v", query, err)
+		http.Error(w, "Semantic search failed", http.StatusBadGateway)
+		return
 	}
 	
 	response := map[string]interface{}{
@@ -257,40 +302,103 @@ func (s *APIServer) searchDreams(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// paginate returns the page of results selected by offset and limit
+func paginate(results []model.SearchResult, offset, limit int) []model.SearchResult {
+	if offset >= len(results) {
+		return []model.SearchResult{}
+	}
+	results = results[offset:]
+	if limit < len(results) {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Add or replace a document in the search backend (admin)
+func (s *APIServer) upsertDocument(w http.ResponseWriter, r *http.Request) {
+	reindexer, ok := s.backend.(Reindexer)
+	if !ok {
+		http.Error(w, "Search backend does not support indexing", http.StatusNotImplemented)
+		return
+	}
+
+	var doc model.Document
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := reindexer.Upsert(doc); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Remove a document from the search backend by URL (admin)
+func (s *APIServer) deleteDocument(w http.ResponseWriter, r *http.Request) {
+	reindexer, ok := s.backend.(Reindexer)
+	if !ok {
+		http.Error(w, "Search backend does not support indexing", http.StatusNotImplemented)
+		return
+	}
+
+	docURL := r.URL.Query().Get("url")
+	if docURL == "" {
+		http.Error(w, "Query parameter 'url' is required", http.StatusBadRequest)
+		return
+	}
+	if err := reindexer.Delete(docURL); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // Get document by ID
 func (s *APIServer) getDocument(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	docID := vars["id"]
-	
-	// Mock document
-	doc := model.Document{
-		URL:       "https://example.com/" + docID,
-		Title:     "Document " + docID,
-		CleanText: "This is the content of document " + docID,
-		FetchedAt: time.Now().Add(-time.Hour),
-		Status:    200,
+	doc, ok := s.lookupDocument(w, r)
+	if !ok {
+		return
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(doc)
 }
 
+// lookupDocument finds the document named by the {id} route variable,
+// answering 404 or 501 itself when it can't
+func (s *APIServer) lookupDocument(w http.ResponseWriter, r *http.Request) (model.Document, bool) {
+	store, ok := s.backend.(DocumentStore)
+	if !ok {
+		http.Error(w, "Search backend does not support document lookup", http.StatusNotImplemented)
+		return model.Document{}, false
+	}
+	doc, ok := store.Document(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return model.Document{}, false
+	}
+	return doc, true
+}
+
 // Get document dreams
 func (s *APIServer) getDocumentDreams(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	docID := vars["id"]
+	doc, ok := s.lookupDocument(w, r)
+	if !ok {
+		return
+	}
 	
-	// Mock dreams
-	dreams := []model.DreamOutput{
-		{
-			DocumentID:  docID,
-			URL:         "https://example.com/" + docID,
-			GeneratedAt: time.Now().Add(-30 * time.Minute),
-			Narrative:   "A surreal dream about document " + docID + "...",
-			Confidence:  0.85,
-			Model:       "tinyllama-1.1b-chat",
-		},
+	dream, err := s.dreams.Generate(r.Context(), doc)
+	if r.Context().Err() != nil {
+		return // the client went away
 	}
+	if err != nil {
+		http.Error(w, "Dream generation failed", http.StatusBadGateway)
+		return
+	}
+	dreams := []model.DreamOutput{dream}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(dreams)
@@ -331,6 +439,22 @@ func (s *APIServer) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// adminAuth requires the -admin-token bearer token
+func (s *APIServer) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if *adminToken == "" {
+			http.Error(w, "Admin API is disabled", http.StatusForbidden)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(*adminToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *APIServer) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -348,8 +472,36 @@ func (s *APIServer) corsMiddleware(next http.Handler) http.Handler {
 
 func main() {
 	flag.Parse()
-	
-	server := NewAPIServer()
+	if err := model.ValidateTopicPrefix(*topicPrefix); err != nil {
+		log.Fatalf("Invalid -topic-prefix: %v", err)
+	}
+	if *statsSampleInterval <= 0 {
+		log.Fatalf("Invalid -stats-sample-interval %v: must be positive", *statsSampleInterval)
+	}
+
+	textIndex := NewInvertedIndexBackend()
+	var backend interface {
+		SearchBackend
+		Reindexer
+	} = textIndex
+	if *mlService != "" {
+		embedder := &HTTPEmbedder{BaseURL: *mlService, Client: &http.Client{Timeout: 10 * time.Second}}
+		backend = NewVectorSearchBackend(textIndex, embedder)
+	}
+
+	server := NewAPIServer(backend)
+	if *ollamaURL != "" {
+		ollama := &OllamaDreamGenerator{BaseURL: *ollamaURL, Model: *ollamaModel, Client: &http.Client{Timeout: *ollamaTimeout}}
+		server.dreams = newCachedDreamGenerator(fallbackDreamGenerator{primary: ollama, fallback: TemplateDreamGenerator{}}, *dreamCacheMax)
+	}
+	go sampleStats(server.history, server.counters, *statsSampleInterval)
+	if *indexBroker != "" {
+		serializer, err := model.NewSerializer(*indexFormat, *indexSchemaRegistry)
+		if err != nil {
+			log.Fatalf("Invalid -index-serialization: %v", err)
+		}
+		go runIndexFeed(*indexBroker, *indexGroup, serializer, backend, server.counters)
+	}
 	
 	if err := server.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// TestUpsertThenSearch verifies that a document posted to the admin
+// endpoint becomes searchable, and that the endpoint requires the token.
+func TestUpsertThenSearch(t *testing.T) {
+	*adminToken = "secret"
+	defer func() { *adminToken = "" }()
+
+	server := NewAPIServer(NewMemoryBackend())
+
+	body := `{"url": "https://example.com/lucid", "title": "Lucid Dreaming", "clean_text": "A guide to lucid dreams and night visions."}`
+
+	req := httptest.NewRequest("POST", "/documents", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("POST /documents without token: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	req = httptest.NewRequest("POST", "/documents", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("POST /documents: status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	search := func(q string) []model.SearchResult {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, httptest.NewRequest("GET", "/search?q="+q, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /search: status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		var response struct {
+			Results []model.SearchResult `json:"results"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("decoding search response: %v", err)
+		}
+		return response.Results
+	}
+
+	results := search("lucid")
+	if len(results) != 1 || results[0].Document.URL != "https://example.com/lucid" {
+		t.Fatalf("search for %q returned %+v, want the upserted document", "lucid", results)
+	}
+	if got := search("nightmare"); len(got) != 0 {
+		t.Errorf("search for %q returned %d results, want 0", "nightmare", len(got))
+	}
+
+	req = httptest.NewRequest("DELETE", "/documents?url=https://example.com/lucid", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /documents: status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if got := search("lucid"); len(got) != 0 {
+		t.Errorf("search after delete returned %d results, want 0", len(got))
+	}
+}
+
+// TestGetDocumentByID checks that indexed documents are found by their
+// canonical ID, and that unknown IDs are 404s.
+func TestGetDocumentByID(t *testing.T) {
+	index := NewInvertedIndexBackend()
+	if err := index.Upsert(model.Document{URL: "https://example.com/lucid", Title: "Lucid Dreaming"}); err != nil {
+		t.Fatal(err)
+	}
+	server := NewAPIServer(index)
+	id := model.DocumentIDFor("https://EXAMPLE.com/lucid#top")
+
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest("GET", "/documents/"+id, nil))
+	var doc model.Document
+	json.NewDecoder(rec.Body).Decode(&doc)
+	if rec.Code != http.StatusOK || doc.URL != "https://example.com/lucid" || doc.ID != id {
+		t.Errorf("GET /documents/%s: status %d, document %+v", id, rec.Code, doc)
+	}
+
+	rec = httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest("GET", "/documents/"+id+"/dreams", nil))
+	var dreams []model.DreamOutput
+	json.NewDecoder(rec.Body).Decode(&dreams)
+	if rec.Code != http.StatusOK || len(dreams) != 1 || dreams[0].DocumentID != id {
+		t.Errorf("GET /documents/%s/dreams: status %d, dreams %+v", id, rec.Code, dreams)
+	}
+
+	for _, path := range []string{"/documents/nope", "/documents/nope/dreams"} {
+		rec = httptest.NewRecorder()
+		server.router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// SearchBackend answers text searches over crawled documents
+type SearchBackend interface {
+	Search(query model.SearchQuery) ([]model.SearchResult, error)
+}
+
+// Reindexer is implemented by backends that accept document updates, so
+// newly crawled content becomes searchable without a restart
+type Reindexer interface {
+	Upsert(doc model.Document) error
+	Delete(url string) error
+}
+
+// DocumentStore is implemented by backends that can fetch an indexed
+// document by its canonical ID (model.DocumentIDFor)
+type DocumentStore interface {
+	Document(id string) (model.Document, bool)
+}
+
+var errMissingURL = errors.New("document URL is required")
+
+// MemoryBackend keeps documents in memory and matches queries by substring.
+// It is meant for development and small deployments.
+type MemoryBackend struct {
+	mu   sync.RWMutex
+	docs map[string]model.Document
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{docs: make(map[string]model.Document)}
+}
+
+func (b *MemoryBackend) Upsert(doc model.Document) error {
+	if doc.URL == "" {
+		return errMissingURL
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.docs[doc.URL] = doc
+	return nil
+}
+
+func (b *MemoryBackend) Delete(url string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.docs, url)
+	return nil
+}
+
+// Search returns documents whose title or clean text contains every query
+// term, ranked by the number of occurrences, within the query's date range
+func (b *MemoryBackend) Search(query model.SearchQuery) ([]model.SearchResult, error) {
+	terms := strings.Fields(strings.ToLower(query.Query))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	window, err := parseDateRange(query.DateRange, query.DateField, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var results []model.SearchResult
+	for _, doc := range b.docs {
+		if !window.match(doc) {
+			continue
+		}
+		text := strings.ToLower(doc.Title + " " + doc.CleanText)
+		score := 0
+		for _, term := range terms {
+			n := strings.Count(text, term)
+			if n == 0 {
+				score = 0
+				break
+			}
+			score += n
+		}
+		if score > 0 {
+			results = append(results, model.SearchResult{Document: doc, Score: float64(score)})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Document.URL < results[j].Document.URL
+	})
+	return results, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+var similarLimit = flag.Int("similar-limit", 10, "default number of /documents/{id}/similar results")
+
+// maxSimilarLimit caps the limit parameter
+const maxSimilarLimit = 50
+
+// SimilarFinder is implemented by backends that rank documents by their
+// similarity to an indexed one. ok is false when id isn't indexed.
+type SimilarFinder interface {
+	Similar(id string, limit int) (results []model.SearchResult, ok bool)
+}
+
+// Similar ranks documents by TF-IDF cosine similarity to the document with
+// the given ID. The document itself, and copies of it at other URLs
+// (same content hash), are left out.
+func (b *InvertedIndexBackend) Similar(id string, limit int) ([]model.SearchResult, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	target, ok := b.docs[b.ids[id]]
+	if !ok {
+		return nil, false
+	}
+
+	n := float64(len(b.docs))
+	idf := func(term string) float64 {
+		return math.Log(1 + n/float64(len(b.postings[term])))
+	}
+	norm := func(entry *indexedDoc) float64 {
+		var sum float64
+		for term, tf := range entry.terms {
+			if !similarityTerm(term) {
+				continue
+			}
+			w := float64(tf) * idf(term)
+			sum += w * w
+		}
+		return math.Sqrt(sum)
+	}
+
+	// Dot products with every document sharing a term
+	dots := make(map[string]float64)
+	for term, tf := range target.terms {
+		if !similarityTerm(term) {
+			continue
+		}
+		weight := idf(term)
+		for url, otherTF := range b.postings[term] {
+			dots[url] += float64(tf) * float64(otherTF) * weight * weight
+		}
+	}
+
+	targetNorm := norm(target)
+	var results []model.SearchResult
+	for url, dot := range dots {
+		entry := b.docs[url]
+		if url == target.doc.URL || isCopy(entry.doc, target.doc) {
+			continue
+		}
+		if otherNorm := norm(entry); targetNorm > 0 && otherNorm > 0 {
+			results = append(results, model.SearchResult{Document: entry.doc, Score: dot / (targetNorm * otherNorm)})
+		}
+	}
+	return topResults(results, limit), true
+}
+
+// Similar ranks by embedding cosine similarity when the document has an
+// embedding, and by the text index's TF-IDF similarity otherwise
+func (b *VectorSearchBackend) Similar(id string, limit int) ([]model.SearchResult, bool) {
+	doc, ok := b.text.Document(id)
+	if !ok {
+		return nil, false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	target, ok := b.docs[doc.URL]
+	if !ok {
+		return b.text.Similar(id, limit)
+	}
+
+	var results []model.SearchResult
+	for _, hit := range b.vectors.Nearest(target.Embedding, 0) {
+		other := b.docs[hit.ID]
+		if hit.ID == target.URL || isCopy(other, target) {
+			continue
+		}
+		results = append(results, model.SearchResult{Document: other, Score: hit.Score})
+	}
+	return topResults(results, limit), true
+}
+
+// similarityTerm reports whether term says anything about a document's
+// topic; short and stop words don't
+func similarityTerm(term string) bool {
+	return utf8.RuneCountInString(term) >= 3 && !stopWords[term]
+}
+
+// isCopy reports whether two documents have the same content, as happens
+// for a page reachable at several URLs
+func isCopy(a, b model.Document) bool {
+	return a.ContentHash != "" && a.ContentHash == b.ContentHash
+}
+
+// topResults orders results by score, then URL, and keeps the first limit
+func topResults(results []model.SearchResult, limit int) []model.SearchResult {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Document.URL < results[j].Document.URL
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func (s *APIServer) similarDocuments(w http.ResponseWriter, r *http.Request) {
+	finder, ok := s.backend.(SimilarFinder)
+	if !ok {
+		http.Error(w, "Search backend does not support similar documents", http.StatusNotImplemented)
+		return
+	}
+
+	limit := *similarLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			http.Error(w, "Query parameter 'limit' must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxSimilarLimit)
+	}
+
+	doc, ok := s.lookupDocument(w, r)
+	if !ok {
+		return
+	}
+	results, ok := finder.Similar(doc.ID, limit)
+	if !ok {
+		// Removed between the lookup and the ranking
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if results == nil {
+		results = []model.SearchResult{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"document_id": doc.ID,
+		"results":     results,
+	})
+}
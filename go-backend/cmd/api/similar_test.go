@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// similarCorpus has one page, a copy of it, a closely related page, a
+// loosely related one and an unrelated one
+var similarCorpus = []model.Document{
+	{URL: "https://dreams.example/lucid", Title: "Lucid dreaming", ContentHash: "h1",
+		CleanText: "lucid dreaming means knowing you dream; reality checks and dream journals help lucid dreamers"},
+	{URL: "https://mirror.example/lucid", Title: "Lucid dreaming", ContentHash: "h1",
+		CleanText: "lucid dreaming means knowing you dream; reality checks and dream journals help lucid dreamers"},
+	{URL: "https://dreams.example/techniques", Title: "Lucid dream techniques", ContentHash: "h2",
+		CleanText: "reality checks, dream journals and wake back to bed make lucid dreams more frequent"},
+	{URL: "https://sleep.example/cycles", Title: "Sleep cycles", ContentHash: "h3",
+		CleanText: "sleep cycles move through light sleep, deep sleep and REM, when most dreaming happens"},
+	{URL: "https://food.example/bread", Title: "Sourdough", ContentHash: "h4",
+		CleanText: "a sourdough starter needs flour, water and patience before the bread will rise"},
+}
+
+func urlsOf(results []model.SearchResult) []string {
+	var urls []string
+	for _, r := range results {
+		urls = append(urls, r.Document.URL)
+	}
+	return urls
+}
+
+// TestSimilarText checks the topically closest page ranks first, the page
+// and its copy are left out, and unrelated pages don't appear.
+func TestSimilarText(t *testing.T) {
+	index := NewInvertedIndexBackend()
+	for _, doc := range similarCorpus {
+		if err := index.Upsert(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, ok := index.Similar(model.DocumentIDFor(similarCorpus[0].URL), 10)
+	if !ok {
+		t.Fatal("Similar: document not found")
+	}
+	urls := urlsOf(results)
+	want := []string{"https://dreams.example/techniques", "https://sleep.example/cycles"}
+	if len(urls) != len(want) || urls[0] != want[0] || urls[1] != want[1] {
+		t.Errorf("similar = %v, want %v", urls, want)
+	}
+
+	if results, _ := index.Similar(model.DocumentIDFor(similarCorpus[0].URL), 1); len(results) != 1 {
+		t.Errorf("limit 1 returned %d results", len(results))
+	}
+	if _, ok := index.Similar("nope", 10); ok {
+		t.Error("unknown ID reported as found")
+	}
+}
+
+// TestSimilarEmbeddings checks embedding similarity wins over shared words
+// when the page has an embedding, and text similarity is the fallback.
+func TestSimilarEmbeddings(t *testing.T) {
+	index := NewInvertedIndexBackend()
+	backend := NewVectorSearchBackend(index, fakeEmbedder{})
+	// Embeddings that disagree with the text: the bread page is "closest"
+	embeddings := map[string][]float64{
+		"https://dreams.example/lucid":      {1, 0, 0},
+		"https://dreams.example/techniques": {0, 1, 0},
+		"https://food.example/bread":        {0.9, 0.1, 0},
+	}
+	for _, doc := range similarCorpus {
+		doc.Embedding = embeddings[doc.URL]
+		if err := backend.Upsert(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, _ := backend.Similar(model.DocumentIDFor("https://dreams.example/lucid"), 10)
+	if urls := urlsOf(results); len(urls) != 2 || urls[0] != "https://food.example/bread" {
+		t.Errorf("embedding similar = %v, want the bread page first", urls)
+	}
+
+	// No embedding: falls back to the text index
+	results, _ = backend.Similar(model.DocumentIDFor("https://sleep.example/cycles"), 10)
+	if urls := urlsOf(results); len(urls) == 0 || urls[0] == "https://food.example/bread" {
+		t.Errorf("text fallback similar = %v", urls)
+	}
+}
+
+func TestSimilarHandler(t *testing.T) {
+	index := NewInvertedIndexBackend()
+	for _, doc := range similarCorpus {
+		index.Upsert(doc)
+	}
+	server := NewAPIServer(index)
+	id := model.DocumentIDFor(similarCorpus[0].URL)
+
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest("GET", "/documents/"+id+"/similar?limit=1", nil))
+	var body struct {
+		DocumentID string               `json:"document_id"`
+		Results    []model.SearchResult `json:"results"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusOK || body.DocumentID != id || len(body.Results) != 1 ||
+		body.Results[0].Document.URL != "https://dreams.example/techniques" {
+		t.Errorf("status %d, body %+v", rec.Code, body)
+	}
+
+	for path, want := range map[string]int{
+		"/documents/nope/similar":                 http.StatusNotFound,
+		"/documents/" + id + "/similar?limit=0":   http.StatusBadRequest,
+		"/documents/" + id + "/similar?limit=abc": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
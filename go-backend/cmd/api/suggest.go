@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	suggestMinPrefix = flag.Int("suggest-min-prefix", 2, "characters a /search/suggest prefix needs before suggestions are returned")
+	suggestLimit     = flag.Int("suggest-limit", 8, "default number of /search/suggest results")
+	suggestKeywords  = flag.Int("suggest-keywords", 10, "most frequent terms per document offered as suggestions")
+)
+
+// maxSuggestLimit caps the limit parameter so responses stay small
+const maxSuggestLimit = 50
+
+// Suggestion kinds
+const (
+	suggestTitle = "title"
+	suggestTerm  = "term"
+)
+
+// Suggester is implemented by backends that complete search prefixes
+type Suggester interface {
+	Suggest(prefix string, limit int) []Suggestion
+}
+
+// Suggestion is one completion, Count being the documents behind it
+type Suggestion struct {
+	Text  string `json:"text"`
+	Kind  string `json:"kind"` // title or term
+	Count int    `json:"count"`
+}
+
+// stopWords are too common to be worth suggesting or to make documents similar
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true,
+	"you": true, "all": true, "can": true, "was": true, "one": true, "our": true,
+	"has": true, "have": true, "its": true, "this": true, "that": true, "with": true,
+	"from": true, "they": true, "their": true, "there": true, "were": true, "been": true,
+	"into": true, "than": true, "then": true, "them": true, "these": true, "what": true,
+	"when": true, "where": true, "which": true, "while": true, "will": true, "would": true,
+	"about": true, "also": true, "more": true, "most": true, "some": true, "such": true,
+}
+
+// suggestKey is one suggestion a document contributes to the trie
+type suggestKey struct {
+	text string
+	kind string
+}
+
+// suggestionKeys picks a document's title and its most frequent terms,
+// leaving out a term that is the whole title
+func suggestionKeys(title string, terms map[string]int, keywords int) []suggestKey {
+	var keys []suggestKey
+	if title = strings.Join(strings.Fields(title), " "); title != "" {
+		keys = append(keys, suggestKey{text: title, kind: suggestTitle})
+	}
+
+	var candidates []string
+	for term := range terms {
+		if utf8.RuneCountInString(term) >= 3 && !stopWords[term] && !isNumber(term) && term != strings.ToLower(title) {
+			candidates = append(candidates, term)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if terms[candidates[i]] != terms[candidates[j]] {
+			return terms[candidates[i]] > terms[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+	if len(candidates) > keywords {
+		candidates = candidates[:keywords]
+	}
+	for _, term := range candidates {
+		keys = append(keys, suggestKey{text: term, kind: suggestTerm})
+	}
+	return keys
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// suggestTrie maps lowercase prefixes to titles and terms, counting the
+// documents that contribute each. Every node keeps its best completions
+// up to date on add and remove, so a lookup never walks the subtree. It
+// isn't locked itself: the owning index guards it with its own mutex.
+type suggestTrie struct {
+	root suggestNode
+}
+
+type suggestNode struct {
+	children map[rune]*suggestNode
+	entries  map[string]*suggestEntry // by kind, for the key ending here
+	top      []Suggestion             // best maxSuggestLimit completions under this node
+}
+
+type suggestEntry struct {
+	text  string // display form, as first indexed
+	count int
+}
+
+func (t *suggestTrie) add(keys []suggestKey) {
+	for _, key := range keys {
+		path := []*suggestNode{&t.root}
+		node := &t.root
+		for _, r := range strings.ToLower(key.text) {
+			if node.children == nil {
+				node.children = make(map[rune]*suggestNode)
+			}
+			child, ok := node.children[r]
+			if !ok {
+				child = &suggestNode{}
+				node.children[r] = child
+			}
+			node = child
+			path = append(path, node)
+		}
+		if node.entries == nil {
+			node.entries = make(map[string]*suggestEntry)
+		}
+		entry, ok := node.entries[key.kind]
+		if !ok {
+			entry = &suggestEntry{text: key.text}
+			node.entries[key.kind] = entry
+		}
+		entry.count++
+
+		for i := len(path) - 1; i >= 0; i-- {
+			path[i].refreshTop()
+		}
+	}
+}
+
+func (t *suggestTrie) remove(keys []suggestKey) {
+	for _, key := range keys {
+		t.root.remove([]rune(strings.ToLower(key.text)), key.kind)
+	}
+}
+
+// remove decrements kind at the end of path and prunes emptied nodes,
+// reporting whether n itself is now empty
+func (n *suggestNode) remove(path []rune, kind string) bool {
+	if len(path) == 0 {
+		if entry, ok := n.entries[kind]; ok {
+			if entry.count--; entry.count <= 0 {
+				delete(n.entries, kind)
+			}
+		}
+	} else if child, ok := n.children[path[0]]; ok && child.remove(path[1:], kind) {
+		delete(n.children, path[0])
+	}
+	n.refreshTop()
+	return len(n.children) == 0 && len(n.entries) == 0
+}
+
+// refreshTop rebuilds n's best completions from its own entries and its
+// children's, which must already be current
+func (n *suggestNode) refreshTop() {
+	var top []Suggestion
+	for kind, entry := range n.entries {
+		top = append(top, Suggestion{Text: entry.text, Kind: kind, Count: entry.count})
+	}
+	for _, child := range n.children {
+		top = append(top, child.top...)
+	}
+	sortSuggestions(top)
+	if len(top) > maxSuggestLimit {
+		top = top[:maxSuggestLimit]
+	}
+	n.top = top
+}
+
+// sortSuggestions orders by document count, then shorter text first
+func sortSuggestions(s []Suggestion) {
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].Count != s[j].Count {
+			return s[i].Count > s[j].Count
+		}
+		if len(s[i].Text) != len(s[j].Text) {
+			return len(s[i].Text) < len(s[j].Text)
+		}
+		if s[i].Text != s[j].Text {
+			return s[i].Text < s[j].Text
+		}
+		return s[i].Kind < s[j].Kind
+	})
+}
+
+// suggest returns up to limit completions of prefix, most documents
+// first; limit is at most maxSuggestLimit
+func (t *suggestTrie) suggest(prefix string, limit int) []Suggestion {
+	node := &t.root
+	for _, r := range strings.ToLower(prefix) {
+		if node = node.children[r]; node == nil {
+			return nil
+		}
+	}
+	if len(node.top) == 0 {
+		return nil
+	}
+	return append([]Suggestion(nil), node.top[:min(limit, len(node.top))]...)
+}
+
+// Complete a search prefix from indexed titles and keywords. Clients that
+// accept text/event-stream get the suggestions streamed as server-sent
+// events, one "suggestion" event each and then "done"; others get JSON.
+func (s *APIServer) suggestSearch(w http.ResponseWriter, r *http.Request) {
+	suggester, ok := s.backend.(Suggester)
+	if !ok {
+		http.Error(w, "Search backend does not support suggestions", http.StatusNotImplemented)
+		return
+	}
+
+	prefix := strings.TrimSpace(r.URL.Query().Get("q"))
+	limit := *suggestLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			http.Error(w, "Query parameter 'limit' must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	limit = min(limit, maxSuggestLimit)
+
+	// Short prefixes answer empty rather than failing, so clients can
+	// call on every keystroke
+	suggestions := []Suggestion{}
+	if utf8.RuneCountInString(prefix) >= *suggestMinPrefix {
+		if found := suggester.Suggest(prefix, limit); found != nil {
+			suggestions = found
+		}
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamSuggestions(w, suggestions)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":       prefix,
+		"suggestions": suggestions,
+	})
+}
+
+// streamSuggestions writes suggestions as server-sent events, flushing
+// each so a client can render the first ones straight away
+func streamSuggestions(w http.ResponseWriter, suggestions []Suggestion) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	for _, suggestion := range suggestions {
+		data, err := json.Marshal(suggestion)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "event: suggestion\ndata: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	fmt.Fprint(w, "event: done\ndata: {}\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
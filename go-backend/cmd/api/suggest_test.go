@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+func newSuggestIndex(t *testing.T) *InvertedIndexBackend {
+	t.Helper()
+	index := NewInvertedIndexBackend()
+	corpus := []model.Document{
+		{URL: "https://a.example/1", Title: "Dreamscapes", CleanText: "dreams dreams dreaming and the drift of tides"},
+		{URL: "https://a.example/2", Title: "Night notes", CleanText: "dreams recur; dreaming alone"},
+		{URL: "https://a.example/3", Title: "Dreamscapes", CleanText: "dreams of a dreamer"},
+	}
+	for _, doc := range corpus {
+		if err := index.Upsert(doc); err != nil {
+			t.Fatalf("Upsert(%s): %v", doc.URL, err)
+		}
+	}
+	return index
+}
+
+func suggestionTexts(suggestions []Suggestion) []string {
+	var texts []string
+	for _, s := range suggestions {
+		texts = append(texts, s.Kind+":"+s.Text)
+	}
+	return texts
+}
+
+// TestSuggestRanking checks that "dre" completes to dream-prefixed titles
+// and terms, ranked by how many documents carry them, shorter first on ties.
+func TestSuggestRanking(t *testing.T) {
+	index := newSuggestIndex(t)
+
+	got := index.Suggest("dre", 10)
+	want := []Suggestion{
+		{Text: "dreams", Kind: suggestTerm, Count: 3},
+		{Text: "dreaming", Kind: suggestTerm, Count: 2},
+		{Text: "Dreamscapes", Kind: suggestTitle, Count: 2},
+		{Text: "dreamer", Kind: suggestTerm, Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Suggest(dre) = %v, want %v", suggestionTexts(got), suggestionTexts(want))
+	}
+	if got := index.Suggest("DRE", 2); len(got) != 2 || got[0].Text != "dreams" {
+		t.Errorf("Suggest(DRE, 2) = %v, want the top two, case-insensitively", suggestionTexts(got))
+	}
+	if got := index.Suggest("xyz", 10); len(got) != 0 {
+		t.Errorf("Suggest(xyz) = %v, want none", suggestionTexts(got))
+	}
+}
+
+// TestSuggestUpdates checks that replacing and deleting documents takes
+// their suggestions with them.
+func TestSuggestUpdates(t *testing.T) {
+	index := newSuggestIndex(t)
+
+	index.Upsert(model.Document{URL: "https://a.example/1", Title: "Tides", CleanText: "drift"})
+	index.Delete("https://a.example/3")
+
+	got := index.Suggest("dre", 10)
+	want := []Suggestion{
+		{Text: "dreams", Kind: suggestTerm, Count: 1},
+		{Text: "dreaming", Kind: suggestTerm, Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Suggest(dre) after updates = %v, want %v", suggestionTexts(got), suggestionTexts(want))
+	}
+}
+
+// TestSuggestHandler checks the endpoint's JSON and its minimum prefix.
+func TestSuggestHandler(t *testing.T) {
+	server := NewAPIServer(newSuggestIndex(t))
+
+	get := func(target string) (int, []Suggestion) {
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		var body struct {
+			Suggestions []Suggestion `json:"suggestions"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.Suggestions
+	}
+
+	if code, got := get("/search/suggest?q=dre&limit=1"); code != http.StatusOK || len(got) != 1 || got[0].Text != "dreams" {
+		t.Errorf("q=dre&limit=1: status %d, suggestions %v", code, suggestionTexts(got))
+	}
+	if code, got := get("/search/suggest?q=d"); code != http.StatusOK || got == nil || len(got) != 0 {
+		t.Errorf("q=d: status %d, suggestions %v; want an empty list below the minimum prefix", code, got)
+	}
+	if code, _ := get("/search/suggest?q=dre&limit=none"); code != http.StatusBadRequest {
+		t.Errorf("bad limit: status %d, want 400", code)
+	}
+}
+
+// TestSuggestStream checks that clients accepting text/event-stream get
+// one event per suggestion, in rank order, and then a done event.
+func TestSuggestStream(t *testing.T) {
+	server := NewAPIServer(newSuggestIndex(t))
+
+	req := httptest.NewRequest("GET", "/search/suggest?q=dre&limit=2", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	var events []string
+	for _, block := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+		lines := strings.SplitN(block, "\n", 2)
+		if len(lines) != 2 {
+			t.Fatalf("malformed event %q", block)
+		}
+		event, data := strings.TrimPrefix(lines[0], "event: "), strings.TrimPrefix(lines[1], "data: ")
+		if event == "suggestion" {
+			var s Suggestion
+			if err := json.Unmarshal([]byte(data), &s); err != nil {
+				t.Fatalf("suggestion data %q: %v", data, err)
+			}
+			event = fmt.Sprintf("%s:%s", s.Kind, s.Text)
+		}
+		events = append(events, event)
+	}
+	want := []string{"term:dreams", "term:dreaming", "done"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+// TestSuggestTopKeptPerNode checks the cached completions stay correct
+// when a deep key outranks its prefix's earlier best after updates.
+func TestSuggestTopKeptPerNode(t *testing.T) {
+	var trie suggestTrie
+	trie.add([]suggestKey{{text: "dream", kind: suggestTerm}})
+	for i := 0; i < 3; i++ {
+		trie.add([]suggestKey{{text: "dreamlike", kind: suggestTerm}})
+	}
+	if got := trie.suggest("dr", 1); len(got) != 1 || got[0].Text != "dreamlike" || got[0].Count != 3 {
+		t.Errorf("suggest(dr, 1) = %v, want dreamlike x3", got)
+	}
+	trie.remove([]suggestKey{{text: "dreamlike", kind: suggestTerm}, {text: "dreamlike", kind: suggestTerm}, {text: "dreamlike", kind: suggestTerm}})
+	if got := trie.suggest("dr", 5); len(got) != 1 || got[0].Text != "dream" {
+		t.Errorf("suggest(dr, 5) after removal = %v, want only dream", got)
+	}
+}
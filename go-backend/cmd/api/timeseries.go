@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+var (
+	statsSampleInterval = flag.Duration("stats-sample-interval", 10*time.Second, "how often stats are sampled for /stats/timeseries")
+	statsHistory        = flag.Int("stats-history", 8640, "stats samples kept for /stats/timeseries (the default is 24h at 10s)")
+)
+
+// Time series query defaults and limits
+const (
+	defaultSeriesWindow     = time.Hour
+	defaultSeriesResolution = time.Minute
+	maxSeriesPoints         = 1440
+
+	// dreamReadySurrealism matches the crawler's default -dream-threshold
+	dreamReadySurrealism = 0.5
+)
+
+// feedCounters are cumulative totals for the documents the API has seen
+type feedCounters struct {
+	pages  atomic.Int64
+	errors atomic.Int64
+	dreams atomic.Int64 // documents surreal enough to be dreamed about
+
+	// content aggregates keywords, entities and themes for /stats/content
+	content *model.ContentAggregator
+}
+
+// record counts one document taken from the index feed
+func (c *feedCounters) record(doc model.Document, err error) {
+	if err != nil {
+		c.errors.Add(1)
+		return
+	}
+	c.pages.Add(1)
+	if doc.DreamHints.Surrealism > dreamReadySurrealism {
+		c.dreams.Add(1)
+	}
+	if c.content != nil {
+		c.content.AddDocument(doc)
+	}
+}
+
+func (c *feedCounters) sample(at time.Time) statsSample {
+	return statsSample{At: at, Pages: c.pages.Load(), Errors: c.errors.Load(), Dreams: c.dreams.Load()}
+}
+
+// statsSample is a reading of the cumulative counters
+type statsSample struct {
+	At     time.Time
+	Pages  int64
+	Errors int64
+	Dreams int64
+}
+
+// statsRing keeps the most recent samples in a fixed-size buffer
+type statsRing struct {
+	mu      sync.RWMutex
+	samples []statsSample
+	next    int
+	full    bool
+}
+
+func newStatsRing(size int) *statsRing {
+	if size < 2 {
+		size = 2
+	}
+	return &statsRing{samples: make([]statsSample, size)}
+}
+
+func (r *statsRing) add(s statsSample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// ordered returns the buffered samples oldest first
+func (r *statsRing) ordered() []statsSample {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.full {
+		return append([]statsSample(nil), r.samples[:r.next]...)
+	}
+	out := make([]statsSample, 0, len(r.samples))
+	out = append(out, r.samples[r.next:]...)
+	return append(out, r.samples[:r.next]...)
+}
+
+// sampleStats records counters into ring every interval, forever
+func sampleStats(ring *statsRing, counters *feedCounters, interval time.Duration) {
+	ring.add(counters.sample(time.Now()))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		ring.add(counters.sample(now))
+	}
+}
+
+// seriesPoint is one downsampled interval of the time series
+type seriesPoint struct {
+	Start        time.Time `json:"start"`
+	PagesPerSec  float64   `json:"pages_per_sec"`
+	ErrorRate    float64   `json:"error_rate"`
+	DreamsPerSec float64   `json:"dreams_per_sec"`
+}
+
+// downsample turns cumulative samples into per-interval rates for the
+// resolution-sized intervals between end-window and end. Each interval's
+// rates come from the counter growth between the last samples at or
+// before its start and its end; intervals without samples are zero.
+func downsample(samples []statsSample, end time.Time, window, resolution time.Duration) []seriesPoint {
+	n := int(window / resolution)
+	start := end.Add(-time.Duration(n) * resolution)
+	points := make([]seriesPoint, n)
+
+	// latest returns the last sample at or before t, if any
+	i := 0
+	var last *statsSample
+	latest := func(t time.Time) *statsSample {
+		for i < len(samples) && !samples[i].At.After(t) {
+			last = &samples[i]
+			i++
+		}
+		return last
+	}
+
+	from := latest(start)
+	for p := range points {
+		bucketStart := start.Add(time.Duration(p) * resolution)
+		points[p].Start = bucketStart
+		to := latest(bucketStart.Add(resolution))
+		if from != nil && to != nil && to.At.After(from.At) {
+			secs := to.At.Sub(from.At).Seconds()
+			pages, errors := to.Pages-from.Pages, to.Errors-from.Errors
+			points[p].PagesPerSec = float64(pages) / secs
+			points[p].DreamsPerSec = float64(to.Dreams-from.Dreams) / secs
+			if pages+errors > 0 {
+				points[p].ErrorRate = float64(errors) / float64(pages+errors)
+			}
+		}
+		if to != nil {
+			from = to
+		}
+	}
+	return points
+}
+
+// parseSeriesParams reads and validates ?window= and ?resolution=
+func parseSeriesParams(r *http.Request) (time.Duration, time.Duration, error) {
+	window, resolution := defaultSeriesWindow, defaultSeriesResolution
+	for name, dst := range map[string]*time.Duration{"window": &window, "resolution": &resolution} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("%s must be a positive duration like 1h or 30s, got %q", name, raw)
+		}
+		*dst = d
+	}
+	if resolution > window {
+		return 0, 0, fmt.Errorf("resolution %v is longer than window %v", resolution, window)
+	}
+	if window/resolution > maxSeriesPoints {
+		return 0, 0, fmt.Errorf("window %v at resolution %v is more than %d points", window, resolution, maxSeriesPoints)
+	}
+	return window, resolution, nil
+}
+
+// getStatsTimeseries serves downsampled pages/sec, error rate and
+// dreams/sec from the sampled stats history
+func (s *APIServer) getStatsTimeseries(w http.ResponseWriter, r *http.Request) {
+	window, resolution, err := parseSeriesParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window":     window.String(),
+		"resolution": resolution.String(),
+		"points":     downsample(s.history.ordered(), time.Now(), window, resolution),
+	})
+}
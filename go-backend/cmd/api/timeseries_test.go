@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestDownsample feeds 30 minutes of steady synthetic samples and checks a
+// 1h/1m series has 60 points, empty before the data starts and steady
+// rates after.
+func TestDownsample(t *testing.T) {
+	end := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var samples []statsSample
+	var cur statsSample
+	for at := end.Add(-30 * time.Minute); !at.After(end); at = at.Add(10 * time.Second) {
+		cur.At = at
+		samples = append(samples, cur)
+		cur.Pages += 10 // 1 page/sec
+		cur.Errors++    // 1 error per 11 fetches
+		cur.Dreams += 5 // 0.5 dreams/sec
+	}
+
+	points := downsample(samples, end, time.Hour, time.Minute)
+	if len(points) != 60 {
+		t.Fatalf("got %d points, want 60", len(points))
+	}
+	if !points[0].Start.Equal(end.Add(-time.Hour)) || !points[59].Start.Equal(end.Add(-time.Minute)) {
+		t.Errorf("points span %v to %v", points[0].Start, points[59].Start)
+	}
+	for i, p := range points[:30] {
+		if p.PagesPerSec != 0 || p.ErrorRate != 0 || p.DreamsPerSec != 0 {
+			t.Errorf("point %d before any data = %+v, want zeros", i, p)
+		}
+	}
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	for i, p := range points[31:] {
+		if !near(p.PagesPerSec, 1) || !near(p.ErrorRate, 1.0/11) || !near(p.DreamsPerSec, 0.5) {
+			t.Errorf("point %d = %+v, want 1 page/s, 1/11 errors, 0.5 dreams/s", i+31, p)
+		}
+	}
+}
+
+// TestStatsRingBounded checks the ring keeps only its newest samples, in
+// order.
+func TestStatsRingBounded(t *testing.T) {
+	ring := newStatsRing(100)
+	start := time.Now()
+	for i := 0; i < 250; i++ {
+		ring.add(statsSample{At: start.Add(time.Duration(i) * time.Second), Pages: int64(i)})
+	}
+	got := ring.ordered()
+	if len(got) != 100 || got[0].Pages != 150 || got[99].Pages != 249 {
+		t.Errorf("ring holds %d samples from %d to %d, want 100 from 150 to 249",
+			len(got), got[0].Pages, got[len(got)-1].Pages)
+	}
+}
+
+// TestStatsTimeseriesEndpoint checks parameter validation and the default
+// window.
+func TestStatsTimeseriesEndpoint(t *testing.T) {
+	server := NewAPIServer(NewInvertedIndexBackend())
+	server.history.add(server.counters.sample(time.Now().Add(-time.Minute)))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, httptest.NewRequest("GET", "/stats/timeseries"+query, nil))
+		return rec
+	}
+
+	for _, query := range []string{"?window=banana", "?window=-1h", "?window=1m&resolution=1h", "?window=24h&resolution=1s"} {
+		if rec := get(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	rec := get("?window=10m&resolution=30s")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Points []seriesPoint `json:"points"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Points) != 20 {
+		t.Errorf("got %d points, want 20", len(response.Points))
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// Embedder turns text into an embedding vector
+type Embedder interface {
+	Embed(text string) ([]float64, error)
+}
+
+// SemanticSearcher is implemented by backends that rank documents by
+// embedding similarity
+type SemanticSearcher interface {
+	Semantic(query string, limit int) ([]model.SearchResult, error)
+}
+
+// HTTPEmbedder calls the ML service's /embed endpoint
+type HTTPEmbedder struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func (e *HTTPEmbedder) Embed(text string) ([]float64, error) {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.Client.Post(e.BaseURL+"/embed", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding service returned %s", resp.Status)
+	}
+
+	var result struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Embedding, nil
+}
+
+// vectorIndex finds the stored vectors closest to a query. The brute-force
+// implementation can be swapped for an approximate (ANN) index.
+type vectorIndex interface {
+	Add(id string, vec []float64)
+	Remove(id string)
+	Nearest(query []float64, k int) []scoredID
+}
+
+type scoredID struct {
+	ID    string
+	Score float64
+}
+
+// VectorSearchBackend adds embedding-based semantic search on top of a text
+// backend. Documents without an embedding remain text-searchable but are
+// excluded from semantic results.
+type VectorSearchBackend struct {
+	text     *InvertedIndexBackend
+	embedder Embedder
+	vectors  vectorIndex
+
+	mu   sync.RWMutex
+	docs map[string]model.Document // documents with embeddings, by URL
+}
+
+func NewVectorSearchBackend(text *InvertedIndexBackend, embedder Embedder) *VectorSearchBackend {
+	return &VectorSearchBackend{
+		text:     text,
+		embedder: embedder,
+		vectors:  newBruteForceIndex(),
+		docs:     make(map[string]model.Document),
+	}
+}
+
+func (b *VectorSearchBackend) Search(query model.SearchQuery) ([]model.SearchResult, error) {
+	return b.text.Search(query)
+}
+
+func (b *VectorSearchBackend) Document(id string) (model.Document, bool) {
+	return b.text.Document(id)
+}
+
+func (b *VectorSearchBackend) Suggest(prefix string, limit int) []Suggestion {
+	return b.text.Suggest(prefix, limit)
+}
+
+func (b *VectorSearchBackend) Upsert(doc model.Document) error {
+	if err := b.text.Upsert(doc); err != nil {
+		return err
+	}
+	doc.ID = doc.DocumentID()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(doc.Embedding) == 0 {
+		delete(b.docs, doc.URL)
+		b.vectors.Remove(doc.URL)
+		return nil
+	}
+	b.docs[doc.URL] = doc
+	b.vectors.Add(doc.URL, doc.Embedding)
+	return nil
+}
+
+func (b *VectorSearchBackend) Delete(url string) error {
+	if err := b.text.Delete(url); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.docs, url)
+	b.vectors.Remove(url)
+	return nil
+}
+
+// Semantic embeds the query and returns the limit most similar documents,
+// scored by cosine similarity
+func (b *VectorSearchBackend) Semantic(query string, limit int) ([]model.SearchResult, error) {
+	vec, err := b.embedder.Embed(query)
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var results []model.SearchResult
+	for _, hit := range b.vectors.Nearest(vec, limit) {
+		results = append(results, model.SearchResult{Document: b.docs[hit.ID], Score: hit.Score})
+	}
+	return results, nil
+}
+
+// bruteForceIndex scans every vector on each query
+type bruteForceIndex struct {
+	vectors map[string][]float64
+}
+
+func newBruteForceIndex() *bruteForceIndex {
+	return &bruteForceIndex{vectors: make(map[string][]float64)}
+}
+
+func (idx *bruteForceIndex) Add(id string, vec []float64) {
+	idx.vectors[id] = vec
+}
+
+func (idx *bruteForceIndex) Remove(id string) {
+	delete(idx.vectors, id)
+}
+
+func (idx *bruteForceIndex) Nearest(query []float64, k int) []scoredID {
+	var hits []scoredID
+	for id, vec := range idx.vectors {
+		if score, ok := cosineSimilarity(query, vec); ok {
+			hits = append(hits, scoredID{ID: id, Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if k > 0 && len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// cosineSimilarity returns false for vectors of different dimensions or
+// zero length, which can't be compared
+func cosineSimilarity(a, b []float64) (float64, bool) {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0, false
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0, false
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB)), true
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// fakeEmbedder returns fixed vectors for known queries
+type fakeEmbedder map[string][]float64
+
+func (e fakeEmbedder) Embed(text string) ([]float64, error) {
+	vec, ok := e[text]
+	if !ok {
+		return nil, fmt.Errorf("no embedding for %q", text)
+	}
+	return vec, nil
+}
+
+// TestVectorSearchNearestFirst verifies documents are ranked by cosine
+// similarity to the query and that documents without embeddings are skipped.
+func TestVectorSearchNearestFirst(t *testing.T) {
+	embedder := fakeEmbedder{"flying dreams": {1, 0.1, 0}}
+	backend := NewVectorSearchBackend(NewInvertedIndexBackend(), embedder)
+
+	docs := []model.Document{
+		{URL: "https://example.com/flight", Title: "Dreams of flight", Embedding: []float64{0.9, 0.2, 0}},
+		{URL: "https://example.com/ocean", Title: "Ocean depths", Embedding: []float64{0, 0.1, 1}},
+		{URL: "https://example.com/birds", Title: "Birds", Embedding: []float64{0.6, 0.6, 0.2}},
+		{URL: "https://example.com/plain", Title: "No embedding yet"},
+	}
+	for _, doc := range docs {
+		if err := backend.Upsert(doc); err != nil {
+			t.Fatalf("Upsert(%s) returned an error: %v", doc.URL, err)
+		}
+	}
+
+	results, err := backend.Semantic("flying dreams", 10)
+	if err != nil {
+		t.Fatalf("Semantic() returned an error: %v", err)
+	}
+
+	want := []string{"https://example.com/flight", "https://example.com/birds", "https://example.com/ocean"}
+	if got := resultURLs(results); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Semantic() order = %v, want %v", got, want)
+	}
+
+	wantScore, _ := cosineSimilarity([]float64{1, 0.1, 0}, []float64{0.9, 0.2, 0})
+	if math.Abs(results[0].Score-wantScore) > 1e-9 || results[0].Score <= results[1].Score {
+		t.Errorf("top score = %v, want cosine similarity %v", results[0].Score, wantScore)
+	}
+
+	if results, _ := backend.Semantic("flying dreams", 1); len(results) != 1 {
+		t.Errorf("limit 1 returned %d results", len(results))
+	}
+
+	// Text search still covers every document
+	if results, _ := backend.Search(model.SearchQuery{Query: "embedding"}); len(results) != 1 {
+		t.Errorf("text search returned %d results, want 1", len(results))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Throughput config. Batching is the producer's own: librdkafka sends
+// cleaned documents together as linger.ms and batch.num.messages allow.
+var (
+	lingerMs    = flag.Int("linger-ms", 5, "how long the producer waits to fill a batch of cleaned documents before sending it anyway (librdkafka linger.ms)")
+	batchSize   = flag.Int("batch-size", 100, "most cleaned documents the producer sends in one batch (librdkafka batch.num.messages; 1 = no batching)")
+	concurrency = flag.Int("concurrency", 4, "raw documents processed at once; offsets are still committed in order")
+)
+
+// flushTimeout bounds delivering what's left in the producer on shutdown
+const flushTimeout = 10 * time.Second
+
+// deliveryBuffer is how many delivery reports may wait for the processor
+// before the producer blocks on them
+const deliveryBuffer = 1000
+
+// producerConfig is the producer's config, batching as -linger-ms and
+// -batch-size say
+func producerConfig(broker string) *kafka.ConfigMap {
+	return &kafka.ConfigMap{
+		"bootstrap.servers":  broker,
+		"linger.ms":          *lingerMs,
+		"batch.num.messages": max(1, *batchSize),
+	}
+}
+
+// commitTracker orders commits when messages finish out of order: an
+// offset is only committed once every earlier message read from its
+// partition has finished, so a crash never skips unprocessed input.
+type commitTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionProgress
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+type partitionProgress struct {
+	inFlight []kafka.Offset                  // read but not yet committable, in read order
+	finished map[kafka.Offset]*kafka.Message // finished ahead of an earlier offset
+}
+
+func newCommitTracker() *commitTracker {
+	return &commitTracker{partitions: make(map[topicPartition]*partitionProgress)}
+}
+
+func partitionOf(msg *kafka.Message) topicPartition {
+	tp := topicPartition{partition: msg.TopicPartition.Partition}
+	if msg.TopicPartition.Topic != nil {
+		tp.topic = *msg.TopicPartition.Topic
+	}
+	return tp
+}
+
+// track records msg as read and in flight. A nil tracker tracks nothing.
+func (t *commitTracker) track(msg *kafka.Message) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tp := partitionOf(msg)
+	p := t.partitions[tp]
+	if p == nil {
+		p = &partitionProgress{finished: make(map[kafka.Offset]*kafka.Message)}
+		t.partitions[tp] = p
+	}
+	p.inFlight = append(p.inFlight, msg.TopicPartition.Offset)
+}
+
+// finish records msg as done, returning the latest message of its
+// partition that can now be committed, or nil while an earlier one is
+// still in flight
+func (t *commitTracker) finish(msg *kafka.Message) *kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.partitions[partitionOf(msg)]
+	if p == nil {
+		return msg // never tracked
+	}
+	p.finished[msg.TopicPartition.Offset] = msg
+	var commit *kafka.Message
+	for len(p.inFlight) > 0 {
+		done, ok := p.finished[p.inFlight[0]]
+		if !ok {
+			break
+		}
+		delete(p.finished, p.inFlight[0])
+		p.inFlight = p.inFlight[1:]
+		commit = done
+	}
+	return commit
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// runBurst processes a burst of n raw documents from one partition,
+// failing delivery of the cleaned documents for the undelivered offsets,
+// and returns the fake once the processor is closed
+func runBurst(t testing.TB, n int, undelivered ...kafka.Offset) (*fakeKafka, *ContentProcessor) {
+	t.Helper()
+	defer func(workers int) { *concurrency = workers }(*concurrency)
+	*concurrency = 4
+
+	serializer, _ := model.NewSerializer(model.FormatJSON, "")
+	topic := model.TopicRawContent
+	fake := &fakeKafka{undelivered: make(map[kafka.Offset]bool)}
+	for _, offset := range undelivered {
+		fake.undelivered[offset] = true
+	}
+	for i := 0; i < n; i++ {
+		value, _ := serializer.Marshal(topic, model.Document{URL: fmt.Sprintf("https://example.com/%d", i), Text: "The cosmos"})
+		fake.messages = append(fake.messages, &kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: kafka.Offset(i)},
+			Value:          value,
+		})
+	}
+	cp := NewContentProcessorWith(fake, fakeProducer{fake})
+	cp.serializer = serializer
+	cp.Start(context.Background())
+	cp.Close()
+	return fake, cp
+}
+
+// lastCommitted checks offsets were committed in increasing order and
+// returns the last, or -1 if none was
+func lastCommitted(t *testing.T, fake *fakeKafka) kafka.Offset {
+	t.Helper()
+	var last kafka.Offset = -1
+	for _, msg := range fake.committed {
+		if msg.TopicPartition.Offset <= last {
+			t.Errorf("committed offset %d after %d", msg.TopicPartition.Offset, last)
+		}
+		last = msg.TopicPartition.Offset
+	}
+	return last
+}
+
+// TestCommitAfterDelivery checks offsets are committed in order up to the
+// last one despite concurrent processing once every cleaned document is
+// delivered.
+func TestCommitAfterDelivery(t *testing.T) {
+	const burst = 25
+	fake, _ := runBurst(t, burst)
+	if len(fake.produced) != burst {
+		t.Errorf("produced %d documents, want %d", len(fake.produced), burst)
+	}
+	if last := lastCommitted(t, fake); last != burst-1 {
+		t.Errorf("last committed offset %d, want %d", last, burst-1)
+	}
+}
+
+// TestFailedDeliveryNotCommitted checks a raw document whose cleaned one
+// isn't delivered is never committed, nor is anything after it in its
+// partition, so a restart reads them again.
+func TestFailedDeliveryNotCommitted(t *testing.T) {
+	_, clean := runBurst(t, 25)
+	fake, cp := runBurst(t, 25, 10)
+	if last := lastCommitted(t, fake); last != 9 {
+		t.Errorf("last committed offset %d, want 9, just before the undelivered one", last)
+	}
+	want := clean.status(time.Now()).ProcessingErrors + 1
+	if got := cp.status(time.Now()).ProcessingErrors; got != want {
+		t.Errorf("counted %d processing errors, want %d", got, want)
+	}
+}
+
+// TestProducerConfig checks -linger-ms and -batch-size configure the
+// producer's own batching.
+func TestProducerConfig(t *testing.T) {
+	defer func(size, linger int) { *batchSize, *lingerMs = size, linger }(*batchSize, *lingerMs)
+	*batchSize, *lingerMs = 0, 20
+
+	config := producerConfig("localhost:9092")
+	for key, want := range map[string]kafka.ConfigValue{
+		"bootstrap.servers":  "localhost:9092",
+		"linger.ms":          20,
+		"batch.num.messages": 1,
+	} {
+		if got, err := config.Get(key, nil); err != nil || got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestCommitTrackerOrders(t *testing.T) {
+	tracker := newCommitTracker()
+	msgs := make([]*kafka.Message, 3)
+	for i := range msgs {
+		msgs[i] = &kafka.Message{TopicPartition: kafka.TopicPartition{Partition: 0, Offset: kafka.Offset(i)}}
+		tracker.track(msgs[i])
+	}
+	if got := tracker.finish(msgs[2]); got != nil {
+		t.Errorf("finishing offset 2 first committed %d", got.TopicPartition.Offset)
+	}
+	if got := tracker.finish(msgs[1]); got != nil {
+		t.Errorf("finishing offset 1 before 0 committed %d", got.TopicPartition.Offset)
+	}
+	if got := tracker.finish(msgs[0]); got != msgs[2] {
+		t.Errorf("finishing offset 0 committed %v, want offset 2", got)
+	}
+}
+
+func BenchmarkProcessing(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		runBurst(b, 100)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+var (
+	chunkStrategy = flag.String("chunk-strategy", "sentence", "how documents are split into chunks: sentence, fixed, heading or paragraph")
+	chunkWords    = flag.Int("chunk-words", 100, "words per chunk for -chunk-strategy=fixed")
+)
+
+// minChunkLen drops fragments too short to stand alone as a sentence chunk
+const minChunkLen = 10
+
+// A chunker splits a document into typed text pieces; IDs and positions
+// are assigned afterwards by processChunks so every strategy sets them
+// the same way
+type chunker func(doc model.Document) []model.ContentChunk
+
+var chunkers = map[string]chunker{
+	"sentence":  sentenceChunks,
+	"fixed":     fixedChunks,
+	"heading":   headingChunks,
+	"paragraph": paragraphChunks,
+}
+
+// chunkerFor looks up a -chunk-strategy name
+func chunkerFor(name string) (chunker, error) {
+	if c, ok := chunkers[name]; ok {
+		return c, nil
+	}
+	names := make([]string, 0, len(chunkers))
+	for n := range chunkers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown chunk strategy %q, want one of %s", name, strings.Join(names, ", "))
+}
+
+// sentenceChunks makes a chunk per sentence; the first, and any breaking
+// news, is a headline
+func sentenceChunks(doc model.Document) []model.ContentChunk {
+	var chunks []model.ContentChunk
+	for i, sentence := range strings.Split(doc.Text, ". ") {
+		sentence = strings.TrimSpace(sentence)
+		if len(sentence) < minChunkLen {
+			continue
+		}
+		chunkType := "paragraph"
+		if i == 0 || strings.Contains(strings.ToUpper(sentence), "BREAKING") {
+			chunkType = "headline"
+		}
+		chunks = append(chunks, model.ContentChunk{Type: chunkType, Text: sentence, Confidence: 0.8})
+	}
+	return chunks
+}
+
+// fixedChunks cuts the text into runs of -chunk-words words, ignoring
+// structure; the last chunk may be shorter
+func fixedChunks(doc model.Document) []model.ContentChunk {
+	words := strings.Fields(doc.Text)
+	size := max(1, *chunkWords)
+	var chunks []model.ContentChunk
+	for start := 0; start < len(words); start += size {
+		end := min(len(words), start+size)
+		chunks = append(chunks, model.ContentChunk{
+			Type:       "fixed",
+			Text:       strings.Join(words[start:end], " "),
+			Confidence: 0.5,
+		})
+	}
+	return chunks
+}
+
+// blankLine separates paragraphs in extracted text
+var blankLine = regexp.MustCompile(`\n\s*\n`)
+
+// paragraphChunks makes a chunk per blank-line separated block, or per
+// line when the text has no blank lines
+func paragraphChunks(doc model.Document) []model.ContentChunk {
+	blocks := blankLine.Split(doc.Text, -1)
+	if len(blocks) == 1 {
+		blocks = strings.Split(doc.Text, "\n")
+	}
+	var chunks []model.ContentChunk
+	for _, block := range blocks {
+		if text := strings.Join(strings.Fields(block), " "); text != "" {
+			chunks = append(chunks, model.ContentChunk{Type: "paragraph", Text: text, Confidence: 0.8})
+		}
+	}
+	return chunks
+}
+
+// headingChunks groups the text under each outline heading, heading
+// included, into one section chunk. Text before the first heading is an
+// intro paragraph. Without an outline it falls back to paragraphs.
+func headingChunks(doc model.Document) []model.ContentChunk {
+	headings := flattenOutline(doc.Outline, nil)
+	if len(headings) == 0 {
+		return paragraphChunks(doc)
+	}
+
+	// Find each heading in order; one missing from the text is skipped
+	var starts []int
+	pos := 0
+	for _, heading := range headings {
+		if i := headingIndex(doc.Text[pos:], heading); i >= 0 {
+			starts = append(starts, pos+i)
+			pos += i + len(heading)
+		}
+	}
+	if len(starts) == 0 {
+		return paragraphChunks(doc)
+	}
+
+	var chunks []model.ContentChunk
+	add := func(chunkType, text string, confidence float64) {
+		if text = strings.Join(strings.Fields(text), " "); text != "" {
+			chunks = append(chunks, model.ContentChunk{Type: chunkType, Text: text, Confidence: confidence})
+		}
+	}
+	add("paragraph", doc.Text[:starts[0]], 0.6)
+	for i, start := range starts {
+		end := len(doc.Text)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		add("section", doc.Text[start:end], 0.9)
+	}
+	return chunks
+}
+
+// headingIndex finds heading in text, preferring a line of its own, as
+// extracted headings stand on, over an earlier mention in running text.
+// Without such a line it falls back to the first mention.
+func headingIndex(text, heading string) int {
+	for from := 0; from < len(text); {
+		i := strings.Index(text[from:], heading)
+		if i < 0 {
+			break
+		}
+		start, end := from+i, from+i+len(heading)
+		before := strings.TrimRight(text[:start], " \t")
+		after := strings.TrimLeft(text[end:], " \t")
+		if (before == "" || strings.HasSuffix(before, "\n")) && (after == "" || strings.HasPrefix(after, "\n")) {
+			return start
+		}
+		from = start + 1
+	}
+	return strings.Index(text, heading)
+}
+
+// flattenOutline lists heading texts in document order
+func flattenOutline(nodes []model.OutlineNode, out []string) []string {
+	for _, node := range nodes {
+		if text := strings.TrimSpace(node.Text); text != "" {
+			out = append(out, text)
+		}
+		out = flattenOutline(node.Children, out)
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// chunkingDoc is the shared input: an intro, two headed sections, and
+// paragraphs separated by blank lines
+var chunkingDoc = model.Document{
+	Text: "A short intro to the night garden.\n\n" +
+		"Moths\n\nMoths circle the lanterns slowly. They never land on the glass.\n\n" +
+		"Owls\n\nOwls watch from the old oak tree.",
+	Outline: []model.OutlineNode{
+		{Level: 1, Text: "Moths", Children: []model.OutlineNode{{Level: 2, Text: "Owls"}}},
+	},
+}
+
+// TestChunkStrategies runs every strategy over the same document and checks
+// where each one draws its chunk boundaries.
+func TestChunkStrategies(t *testing.T) {
+	defer func(strategy string, words int) { *chunkStrategy, *chunkWords = strategy, words }(*chunkStrategy, *chunkWords)
+	*chunkWords = 8
+
+	tests := []struct {
+		strategy string
+		want     []string
+		types    []string
+	}{
+		{"sentence", []string{
+			"A short intro to the night garden.\n\nMoths\n\nMoths circle the lanterns slowly",
+			"They never land on the glass.\n\nOwls\n\nOwls watch from the old oak tree.",
+		}, []string{"headline", "paragraph"}},
+		{"fixed", []string{
+			"A short intro to the night garden. Moths",
+			"Moths circle the lanterns slowly. They never land",
+			"on the glass. Owls Owls watch from the",
+			"old oak tree.",
+		}, []string{"fixed", "fixed", "fixed", "fixed"}},
+		{"paragraph", []string{
+			"A short intro to the night garden.",
+			"Moths",
+			"Moths circle the lanterns slowly. They never land on the glass.",
+			"Owls",
+			"Owls watch from the old oak tree.",
+		}, []string{"paragraph", "paragraph", "paragraph", "paragraph", "paragraph"}},
+		{"heading", []string{
+			"A short intro to the night garden.",
+			"Moths Moths circle the lanterns slowly. They never land on the glass.",
+			"Owls Owls watch from the old oak tree.",
+		}, []string{"paragraph", "section", "section"}},
+	}
+
+	cp := &ContentProcessor{}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			*chunkStrategy = tt.strategy
+			chunks := cp.processChunks(chunkingDoc)
+
+			var texts, types []string
+			for i, c := range chunks {
+				texts = append(texts, c.Text)
+				types = append(types, c.Type)
+				if c.Position != i || c.ID != model.ChunkID(c.Type, c.Text) {
+					t.Errorf("chunk %d has position %d and ID %q", i, c.Position, c.ID)
+				}
+			}
+			if !reflect.DeepEqual(texts, tt.want) {
+				t.Errorf("texts = %q, want %q", texts, tt.want)
+			}
+			if !reflect.DeepEqual(types, tt.types) {
+				t.Errorf("types = %q, want %q", types, tt.types)
+			}
+		})
+	}
+}
+
+// TestHeadingChunksEarlierMention checks a heading's text mentioned
+// before the heading itself doesn't start its section early.
+func TestHeadingChunksEarlierMention(t *testing.T) {
+	doc := model.Document{
+		Text: "Moths and owls share the garden at night.\n\n" +
+			"Moths\n\nMoths circle the lanterns while Owls watch.\n\n" +
+			"Owls\n\nOwls nest in the oak.",
+		Outline: []model.OutlineNode{{Level: 2, Text: "Moths"}, {Level: 2, Text: "Owls"}},
+	}
+	var texts []string
+	for _, c := range headingChunks(doc) {
+		texts = append(texts, c.Text)
+	}
+	want := []string{
+		"Moths and owls share the garden at night.",
+		"Moths Moths circle the lanterns while Owls watch.",
+		"Owls Owls nest in the oak.",
+	}
+	if !reflect.DeepEqual(texts, want) {
+		t.Errorf("sections = %q, want %q", texts, want)
+	}
+
+	// Headings run into the text are still found by their first mention
+	doc.Text = "Moths circle the lanterns. Owls watch from the oak."
+	if chunks := headingChunks(doc); len(chunks) != 2 || chunks[1].Text != "Owls watch from the oak." {
+		t.Errorf("inline headings gave %+v", chunks)
+	}
+}
+
+// TestHeadingChunksWithoutOutline checks the fallback to paragraphs.
+func TestHeadingChunksWithoutOutline(t *testing.T) {
+	doc := model.Document{Text: chunkingDoc.Text}
+	if got, want := len(headingChunks(doc)), len(paragraphChunks(doc)); got != want {
+		t.Errorf("heading chunks without an outline = %d, want the %d paragraph chunks", got, want)
+	}
+}
+
+// TestChunkerFor checks that unknown strategies are rejected.
+func TestChunkerFor(t *testing.T) {
+	if _, err := chunkerFor("heading"); err != nil {
+		t.Errorf("chunkerFor(heading): %v", err)
+	}
+	if _, err := chunkerFor("words"); err == nil {
+		t.Error("chunkerFor(words) returned no error")
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+var healthAddr = flag.String("health-addr", "", "address serving /healthz, /readyz and /metrics with consumer lag and throughput, e.g. :8081 (disabled when empty)")
+
+// offsetQueryTimeout bounds each broker query made for the lag metric
+const offsetQueryTimeout = 5 * time.Second
+
+// rateWindow is how far back messages processed per second are averaged
+const rateWindow = 60
+
+// processorMetrics counts what the processor has done, for the health
+// server. A nil *processorMetrics records nothing.
+type processorMetrics struct {
+	mu        sync.Mutex
+	started   time.Time
+	ready     bool
+	processed int64
+	errors    int64
+	perSecond [rateWindow]int64 // processed in each of the last seconds, by unix second
+	seconds   [rateWindow]int64 // the unix second each perSecond slot counts
+}
+
+func newProcessorMetrics() *processorMetrics {
+	return &processorMetrics{started: time.Now()}
+}
+
+// setReady marks the processor subscribed and consuming
+func (m *processorMetrics) setReady() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ready = true
+}
+
+// messageProcessed counts a message whose offset was committed
+func (m *processorMetrics) messageProcessed(now time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed++
+	sec := now.Unix()
+	slot := sec % rateWindow
+	if m.seconds[slot] != sec {
+		m.seconds[slot], m.perSecond[slot] = sec, 0
+	}
+	m.perSecond[slot]++
+}
+
+// processingError counts a message that couldn't be read, decoded or
+// published
+func (m *processorMetrics) processingError() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors++
+}
+
+// rate is the messages processed per second over the last rateWindow
+// seconds, or since starting if that's sooner; callers hold the lock
+func (m *processorMetrics) rate(now time.Time) float64 {
+	var n int64
+	for slot, sec := range m.seconds {
+		if now.Unix()-sec < rateWindow {
+			n += m.perSecond[slot]
+		}
+	}
+	window := min(now.Sub(m.started).Seconds(), rateWindow)
+	if window < 1 {
+		window = 1
+	}
+	return float64(n) / window
+}
+
+// offsetReporter is implemented by consumers that can report committed
+// and newest offsets, as *kafka.Consumer does, for the lag metric
+type offsetReporter interface {
+	Assignment() ([]kafka.TopicPartition, error)
+	Committed(partitions []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error)
+	QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (low, high int64, err error)
+}
+
+var errNoOffsets = errors.New("consumer doesn't report offsets")
+
+// partitionLag is how far the group's committed offset trails the newest
+// message in one assigned partition
+type partitionLag struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Committed     int64  `json:"committed"` // -1 before the group's first commit
+	HighWatermark int64  `json:"high_watermark"`
+	Lag           int64  `json:"lag"`
+}
+
+// consumerLag reports the lag of every partition assigned to consumer. A
+// partition the group has never committed on lags by all it holds.
+func consumerLag(c MessageConsumer) ([]partitionLag, error) {
+	consumer, ok := c.(offsetReporter)
+	if !ok {
+		return nil, errNoOffsets
+	}
+	assigned, err := consumer.Assignment()
+	if err != nil || len(assigned) == 0 {
+		return nil, err
+	}
+	timeoutMs := int(offsetQueryTimeout / time.Millisecond)
+	committed, err := consumer.Committed(assigned, timeoutMs)
+	if err != nil {
+		return nil, err
+	}
+
+	lags := make([]partitionLag, 0, len(committed))
+	for _, tp := range committed {
+		if tp.Topic == nil {
+			continue
+		}
+		low, high, err := consumer.QueryWatermarkOffsets(*tp.Topic, tp.Partition, timeoutMs)
+		if err != nil {
+			return nil, err
+		}
+		lag := partitionLag{Topic: *tp.Topic, Partition: tp.Partition, Committed: -1, HighWatermark: high}
+		if offset := int64(tp.Offset); offset >= 0 {
+			lag.Committed = offset
+			lag.Lag = max(0, high-offset)
+		} else {
+			lag.Lag = max(0, high-low)
+		}
+		lags = append(lags, lag)
+	}
+	return lags, nil
+}
+
+// processorStatus is the /metrics response
+type processorStatus struct {
+	Ready            bool           `json:"ready"`
+	UptimeSec        float64        `json:"uptime_sec"`
+	Processed        int64          `json:"messages_processed"`
+	ProcessedPerSec  float64        `json:"messages_per_sec"`
+	ProcessingErrors int64          `json:"processing_errors"`
+	TotalLag         int64          `json:"total_lag"`
+	Partitions       []partitionLag `json:"partitions,omitempty"`
+	LagError         string         `json:"lag_error,omitempty"` // why lag couldn't be read from the broker
+}
+
+// status snapshots the metrics and queries the consumer's lag
+func (cp *ContentProcessor) status(now time.Time) processorStatus {
+	var s processorStatus
+	if m := cp.metrics; m != nil {
+		m.mu.Lock()
+		s.Ready = m.ready
+		s.UptimeSec = now.Sub(m.started).Seconds()
+		s.Processed = m.processed
+		s.ProcessedPerSec = m.rate(now)
+		s.ProcessingErrors = m.errors
+		m.mu.Unlock()
+	}
+	lags, err := consumerLag(cp.consumer)
+	if err != nil {
+		s.LagError = err.Error()
+	}
+	s.Partitions = lags
+	for _, lag := range lags {
+		s.TotalLag += lag.Lag
+	}
+	return s
+}
+
+// healthHandler serves liveness, readiness and metrics for -health-addr
+func (cp *ContentProcessor) healthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ready := false
+		if m := cp.metrics; m != nil {
+			m.mu.Lock()
+			ready = m.ready
+			m.mu.Unlock()
+		}
+		if !ready {
+			http.Error(w, "not consuming yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cp.status(time.Now()))
+	})
+	return mux
+}
+
+// kafkaTimedOut reports whether err is a read that simply found nothing
+func kafkaTimedOut(err error) bool {
+	kerr, ok := err.(kafka.Error)
+	return ok && kerr.Code() == kafka.ErrTimedOut
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// TestConsumerLag simulates partitions trailing their high watermarks and
+// checks /metrics reports each gap, counting the whole of a partition the
+// group has never committed on, and that /readyz waits for consuming.
+func TestConsumerLag(t *testing.T) {
+	topic := model.TopicRawContent
+	fake := &fakeKafka{
+		assigned: []kafka.TopicPartition{
+			{Topic: &topic, Partition: 0},
+			{Topic: &topic, Partition: 1},
+		},
+		offsets:    map[int32]kafka.Offset{0: 40},
+		watermarks: map[int32][2]int64{0: {10, 100}, 1: {5, 25}},
+	}
+	serializer, _ := model.NewSerializer(model.FormatJSON, "")
+	cp := &ContentProcessor{consumer: fake, producer: fakeProducer{fake}, serializer: serializer, metrics: newProcessorMetrics()}
+	handler := cp.healthHandler()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+	if code := get("/readyz").Code; code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before consuming = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	cp.metrics.setReady()
+	if code := get("/readyz").Code; code != http.StatusOK {
+		t.Errorf("/readyz while consuming = %d, want %d", code, http.StatusOK)
+	}
+	if code := get("/healthz").Code; code != http.StatusOK {
+		t.Errorf("/healthz = %d, want %d", code, http.StatusOK)
+	}
+
+	value, _ := serializer.Marshal(topic, model.Document{URL: "https://example.com/", Text: "The cosmos"})
+	cp.processMessage(&kafka.Message{Value: value})
+	cp.processMessage(&kafka.Message{Value: []byte("not a document")})
+
+	var status processorStatus
+	if err := json.NewDecoder(get("/metrics").Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	want := []partitionLag{
+		{Topic: topic, Partition: 0, Committed: 40, HighWatermark: 100, Lag: 60},
+		{Topic: topic, Partition: 1, Committed: -1, HighWatermark: 25, Lag: 20},
+	}
+	if len(status.Partitions) != len(want) {
+		t.Fatalf("partitions = %+v, want %+v", status.Partitions, want)
+	}
+	for i := range want {
+		if status.Partitions[i] != want[i] {
+			t.Errorf("partition %d = %+v, want %+v", i, status.Partitions[i], want[i])
+		}
+	}
+	if status.TotalLag != 80 || status.LagError != "" {
+		t.Errorf("total lag = %d (%q), want 80", status.TotalLag, status.LagError)
+	}
+	if status.Processed != 1 || status.ProcessingErrors != 1 || status.ProcessedPerSec <= 0 {
+		t.Errorf("processed %d at %.2f/s with %d errors, want 1 above 0/s with 1 error",
+			status.Processed, status.ProcessedPerSec, status.ProcessingErrors)
+	}
+
+	// Catching up closes the gap
+	fake.offsets[0], fake.offsets[1] = 100, 25
+	if status = cp.status(time.Now()); status.TotalLag != 0 {
+		t.Errorf("total lag after catching up = %d, want 0", status.TotalLag)
+	}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
-	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
@@ -14,11 +20,52 @@ import (
 var (
 	kafkaBroker = flag.String("kafka-broker", "localhost:9092", "Kafka broker address")
 	groupID     = flag.String("group-id", "content-processor", "Kafka consumer group ID")
+
+	perLanguageTopics = flag.Bool("per-language-topics", false, "route cleaned documents to per-language topics (e.g. clean.content.en)")
+	topicPrefix       = flag.String("topic-prefix", "", "tenant prefix for consumed and produced topics; must match the crawler's -topic-prefix")
+
+	serialization  = flag.String("serialization", model.FormatJSON, "document encoding on consumed and produced topics: json, protobuf or avro; must match the crawler's -serialization")
+	schemaRegistry = flag.String("schema-registry", "", "Confluent-compatible schema registry URL for -serialization=avro; must match the crawler's -schema-registry")
+
+	minSurrealism = flag.Float64("min-surrealism", 0, "only forward documents whose surrealism score is at least this; others are committed and dropped (0 = forward everything)")
 )
 
+// languageCodePattern matches ISO 639-1/639-2 codes usable as a topic suffix
+var languageCodePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// MessageConsumer is the part of *kafka.Consumer the processor uses, so
+// tests can feed it canned messages instead of a broker
+type MessageConsumer interface {
+	Subscribe(topic string, rebalanceCb kafka.RebalanceCb) error
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+	CommitMessage(m *kafka.Message) ([]kafka.TopicPartition, error)
+	Close() error
+}
+
+// MessageProducer is the part of *kafka.Producer the processor uses
+type MessageProducer interface {
+	Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error
+	Flush(timeoutMs int) int
+	Close()
+}
+
+// readTimeout is how long Start waits for a message before checking
+// whether it has been stopped
+const readTimeout = 100 * time.Millisecond
+
 type ContentProcessor struct {
-	consumer *kafka.Consumer
-	producer *kafka.Producer
+	consumer    MessageConsumer
+	producer    MessageProducer
+	serializer  model.Serializer
+	metrics     *processorMetrics
+	commits     *commitTracker // nil commits each message as it's finished
+	concurrency int
+
+	// Delivery reports for cleaned documents, each carrying its raw
+	// message as Opaque, and closed once handleDeliveries has drained
+	// them. A nil deliveries settles documents once they are queued.
+	deliveries chan kafka.Event
+	delivered  chan struct{}
 }
 
 func NewContentProcessor(broker, groupID string) (*ContentProcessor, error) {
@@ -35,48 +82,94 @@ func NewContentProcessor(broker, groupID string) (*ContentProcessor, error) {
 		return nil, err
 	}
 
-	// Producer config
-	producerConfig := &kafka.ConfigMap{
-		"bootstrap.servers": broker,
-	}
-
-	producer, err := kafka.NewProducer(producerConfig)
+	producer, err := kafka.NewProducer(producerConfig(broker))
 	if err != nil {
 		consumer.Close()
 		return nil, err
 	}
 
-	return &ContentProcessor{
-		consumer: consumer,
-		producer: producer,
-	}, nil
+	return NewContentProcessorWith(consumer, producer), nil
 }
 
-func (cp *ContentProcessor) Start() error {
+// NewContentProcessorWith builds a processor on the given consumer and
+// producer, processing concurrently as -concurrency says and committing
+// each raw document once its cleaned one is delivered; callers set its
+// serializer before starting it
+func NewContentProcessorWith(consumer MessageConsumer, producer MessageProducer) *ContentProcessor {
+	cp := &ContentProcessor{
+		consumer:    consumer,
+		producer:    producer,
+		metrics:     newProcessorMetrics(),
+		commits:     newCommitTracker(),
+		concurrency: max(1, *concurrency),
+		deliveries:  make(chan kafka.Event, deliveryBuffer),
+		delivered:   make(chan struct{}),
+	}
+	go cp.handleDeliveries()
+	return cp
+}
+
+// Start consumes raw documents until ctx is done or the consumer fails,
+// processing up to -concurrency at once. Before returning, everything
+// read is processed and handed to the producer; Close waits for it to be
+// delivered.
+func (cp *ContentProcessor) Start(ctx context.Context) error {
 	// Subscribe to raw content topic
-	err := cp.consumer.Subscribe(model.TopicRawContent, nil)
+	rawTopic := model.PrefixedTopic(*topicPrefix, model.TopicRawContent)
+	err := cp.consumer.Subscribe(rawTopic, nil)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Content processor started, consuming from:", model.TopicRawContent)
-
-	for {
-		msg, err := cp.consumer.ReadMessage(-1)
+	log.Println("Content processor started, consuming from:", rawTopic)
+	cp.metrics.setReady()
+
+	jobs := make(chan *kafka.Message)
+	var wg sync.WaitGroup
+	for i := 0; i < max(1, cp.concurrency); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				cp.processMessage(msg)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	for ctx.Err() == nil {
+		msg, err := cp.consumer.ReadMessage(readTimeout)
+		if kerr, ok := err.(kafka.Error); ok && kerr.IsFatal() {
+			// The consumer can't recover from these
+			cp.metrics.processingError()
+			return err
+		}
 		if err != nil {
-			log.Printf("Error reading message: %v", err)
+			if !kafkaTimedOut(err) {
+				log.Printf("Error reading message: %v", err)
+				cp.metrics.processingError()
+			}
 			continue
 		}
 
-		// Process the message
-		go cp.processMessage(msg)
+		cp.commits.track(msg)
+		select {
+		case jobs <- msg:
+		case <-ctx.Done():
+		}
 	}
+	return nil
 }
 
 func (cp *ContentProcessor) processMessage(msg *kafka.Message) {
 	var document model.Document
-	if err := json.Unmarshal(msg.Value, &document); err != nil {
+	if err := cp.serializer.Unmarshal(msg.Value, &document); err != nil {
 		log.Printf("Error unmarshaling document: %v", err)
+		cp.metrics.processingError()
+		cp.settle(msg, false)
 		return
 	}
 
@@ -85,27 +178,129 @@ func (cp *ContentProcessor) processMessage(msg *kafka.Message) {
 	// Clean and normalize the content
 	cleanedDoc := cp.cleanDocument(document)
 
+	if !meetsSurrealism(cleanedDoc, *minSurrealism) {
+		log.Printf("Dropping document below -min-surrealism (%.2f): %s", cleanedDoc.DreamHints.Surrealism, cleanedDoc.URL)
+		cp.settle(msg, true)
+		return
+	}
+
 	// Publish to clean content topic
-	cleanedData, err := json.Marshal(cleanedDoc)
+	out := cp.outputMessage(cleanedDoc, nil)
+	cleanedData, err := cp.serializer.Marshal(*out.TopicPartition.Topic, cleanedDoc)
 	if err != nil {
 		log.Printf("Error marshaling cleaned document: %v", err)
+		cp.metrics.processingError()
+		cp.settle(msg, false)
+		return
+	}
+	out.Value = cleanedData
+	out.Opaque = msg
+
+	// Left unsettled when it can't be published, so its offset and any
+	// later one in the partition stay uncommitted and are read again after
+	// a restart
+	if err := cp.producer.Produce(out, cp.deliveries); err != nil {
+		log.Printf("Error publishing cleaned document: %v", err)
+		cp.metrics.processingError()
 		return
 	}
+	if cp.deliveries == nil {
+		cp.settle(msg, true)
+	}
+}
 
+// handleDeliveries settles each raw message once its cleaned document is
+// delivered. A failed delivery is left unsettled like a failed produce.
+func (cp *ContentProcessor) handleDeliveries() {
+	defer close(cp.delivered)
+	for e := range cp.deliveries {
+		out, ok := e.(*kafka.Message)
+		if !ok {
+			continue
+		}
+		source, _ := out.Opaque.(*kafka.Message)
+		if out.TopicPartition.Error != nil {
+			log.Printf("Error delivering cleaned document: %v", out.TopicPartition.Error)
+			cp.metrics.processingError()
+			continue
+		}
+		if source != nil {
+			cp.settle(source, true)
+		}
+	}
+}
+
+// settle finishes msg, counting it if it was processed. Its offset is
+// committed once every earlier message from its partition has settled;
+// without a commit tracker, processed messages are committed at once and
+// failed ones left for a later commit to pass.
+func (cp *ContentProcessor) settle(msg *kafka.Message, processed bool) {
+	if processed {
+		cp.metrics.messageProcessed(time.Now())
+	}
+	if cp.commits == nil {
+		if processed {
+			cp.commit(msg)
+		}
+		return
+	}
+	if ready := cp.commits.finish(msg); ready != nil {
+		cp.commit(ready)
+	}
+}
+
+// commit commits msg's offset
+func (cp *ContentProcessor) commit(msg *kafka.Message) {
+	if _, err := cp.consumer.CommitMessage(msg); err != nil {
+		log.Printf("Error committing offset: %v", err)
+		cp.metrics.processingError()
+	}
+}
+
+// meetsSurrealism reports whether doc's surrealism score reaches min, so
+// it is worth forwarding for dream generation
+func meetsSurrealism(doc model.Document, min float64) bool {
+	return doc.DreamHints.Surrealism >= min
+}
+
+// outputMessage builds the clean-content message for a processed document
+func (cp *ContentProcessor) outputMessage(doc model.Document, value []byte) *kafka.Message {
 	topic := model.TopicCleanContent
-	cp.producer.Produce(&kafka.Message{
+	lang := normalizeLanguage(doc.Metadata.Language)
+	if *perLanguageTopics && lang != "" {
+		topic = model.TopicCleanContent + "." + lang
+	}
+	topic = model.PrefixedTopic(*topicPrefix, topic)
+
+	return &kafka.Message{
 		TopicPartition: kafka.TopicPartition{
 			Topic:     &topic,
 			Partition: kafka.PartitionAny,
 		},
-		Value: cleanedData,
-	}, nil)
+		Key:   []byte(doc.ID),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "detected_language", Value: []byte(lang)},
+		},
+	}
+}
 
-	// Commit the offset
-	cp.consumer.CommitMessage(msg)
+// normalizeLanguage reduces a language tag such as "en-US" to its primary
+// ISO code, returning "" when the tag is missing or not a valid code.
+func normalizeLanguage(tag string) string {
+	lang := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if !languageCodePattern.MatchString(lang) {
+		return ""
+	}
+	return lang
 }
 
 func (cp *ContentProcessor) cleanDocument(doc model.Document) model.Document {
+	doc.ID = doc.DocumentID()
+
 	// Clean text content
 	doc.CleanText = cp.cleanText(doc.Text)
 
@@ -113,7 +308,7 @@ func (cp *ContentProcessor) cleanDocument(doc model.Document) model.Document {
 	doc.Metadata = cp.enhanceMetadata(doc.Metadata, doc.Text)
 
 	// Process content chunks
-	doc.Chunks = cp.processChunks(doc.Text)
+	doc.Chunks = cp.processChunks(doc)
 
 	// Analyze content for dreaming hints
 	doc.DreamHints = cp.analyzeDreamHints(doc)
@@ -142,6 +337,8 @@ func (cp *ContentProcessor) enhanceMetadata(metadata model.DocumentMetadata, tex
 	// Count words
 	words := strings.Fields(text)
 	metadata.WordCount = len(words)
+	metadata.ReadingTimeSec = readingTimeSec(len(words))
+	metadata.ReadabilityScore = readabilityGrade(text)
 
 	// Detect language (simple heuristic)
 	if strings.Contains(text, "the") || strings.Contains(text, "and") || strings.Contains(text, "of") {
@@ -164,29 +361,24 @@ func (cp *ContentProcessor) enhanceMetadata(metadata model.DocumentMetadata, tex
 	return metadata
 }
 
-func (cp *ContentProcessor) processChunks(text string) []model.ContentChunk {
-	chunks := []model.ContentChunk{}
-	sentences := strings.Split(text, ". ")
-
-	for i, sentence := range sentences {
-		if len(strings.TrimSpace(sentence)) < 10 {
-			continue
-		}
-
-		chunkType := "paragraph"
-		if i == 0 || strings.Contains(strings.ToUpper(sentence), "BREAKING") {
-			chunkType = "headline"
-		}
-
-		chunks = append(chunks, model.ContentChunk{
-			ID:         fmt.Sprintf("chunk_%d", i),
-			Type:       chunkType,
-			Text:       strings.TrimSpace(sentence),
-			Position:   i,
-			Confidence: 0.8,
-		})
+// processChunks splits doc with the -chunk-strategy chunker, numbers the
+// chunks in document order and gives them content-addressed IDs, as the
+// crawler does
+func (cp *ContentProcessor) processChunks(doc model.Document) []model.ContentChunk {
+	split, err := chunkerFor(*chunkStrategy)
+	if err != nil {
+		split = sentenceChunks // main has already rejected bad strategies
 	}
 
+	chunks := split(doc)
+	if chunks == nil {
+		chunks = []model.ContentChunk{}
+	}
+	ids := make(model.ChunkIDs, len(chunks))
+	for i := range chunks {
+		chunks[i].ID = ids.Next(chunks[i].Type, chunks[i].Text)
+		chunks[i].Position = i
+	}
 	return chunks
 }
 
@@ -237,25 +429,59 @@ func (cp *ContentProcessor) analyzeDreamHints(doc model.Document) model.Dreaming
 	return hints
 }
 
+// Close delivers any queued documents and commits their raw messages,
+// then closes the producer and consumer
 func (cp *ContentProcessor) Close() {
-	if cp.consumer != nil {
-		cp.consumer.Close()
-	}
 	if cp.producer != nil {
+		if left := cp.producer.Flush(int(flushTimeout / time.Millisecond)); left > 0 {
+			log.Printf("Closing with %d cleaned documents undelivered", left)
+		}
 		cp.producer.Close()
+		if cp.deliveries != nil {
+			close(cp.deliveries)
+			<-cp.delivered
+		}
+	}
+	if cp.consumer != nil {
+		cp.consumer.Close()
 	}
 }
 
 func main() {
 	flag.Parse()
+	if err := model.ValidateTopicPrefix(*topicPrefix); err != nil {
+		log.Fatalf("Invalid -topic-prefix: %v", err)
+	}
+	if _, err := chunkerFor(*chunkStrategy); err != nil {
+		log.Fatalf("Invalid -chunk-strategy: %v", err)
+	}
+
+	serializer, err := model.NewSerializer(*serialization, *schemaRegistry)
+	if err != nil {
+		log.Fatalf("Invalid -serialization: %v", err)
+	}
 
 	processor, err := NewContentProcessor(*kafkaBroker, *groupID)
 	if err != nil {
 		log.Fatalf("Failed to create content processor: %v", err)
 	}
-	defer processor.Close()
+	processor.serializer = serializer
+
+	if *healthAddr != "" {
+		go func() {
+			log.Printf("Health server listening on %s", *healthAddr)
+			if err := http.ListenAndServe(*healthAddr, processor.healthHandler()); err != nil {
+				log.Printf("Health server stopped: %v", err)
+			}
+		}()
+	}
 
-	if err := processor.Start(); err != nil {
-		log.Fatalf("Failed to start content processor: %v", err)
+	// Stopping finishes and delivers what has been read before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = processor.Start(ctx)
+	processor.Close()
+	if err != nil {
+		log.Fatalf("Content processor stopped: %v", err)
 	}
 }
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// TestOutputMessageLanguageRouting verifies that -per-language-topics routes
+// detected languages to suffixed topics and falls back to the default topic.
+func TestOutputMessageLanguageRouting(t *testing.T) {
+	*perLanguageTopics = true
+	defer func() { *perLanguageTopics = false }()
+
+	cp := &ContentProcessor{}
+
+	tests := []struct {
+		name      string
+		text      string
+		language  string
+		wantTopic string
+		wantLang  string
+	}{
+		{"english", "The cat and the hat", "", model.TopicCleanContent + ".en", "en"},
+		{"html lang tag", "Hola mundo", "es-MX", model.TopicCleanContent + ".es", "es"},
+		{"undetected", "Zzz qqq xyz", "", model.TopicCleanContent, ""},
+		{"garbage tag", "Zzz qqq xyz", "../weird", model.TopicCleanContent, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := cp.cleanDocument(model.Document{
+				Text:     tt.text,
+				Metadata: model.DocumentMetadata{Language: tt.language},
+			})
+			msg := cp.outputMessage(doc, nil)
+
+			if got := *msg.TopicPartition.Topic; got != tt.wantTopic {
+				t.Errorf("topic = %q, want %q", got, tt.wantTopic)
+			}
+			if len(msg.Headers) != 1 || msg.Headers[0].Key != "detected_language" {
+				t.Fatalf("expected a detected_language header, got %v", msg.Headers)
+			}
+			if got := string(msg.Headers[0].Value); got != tt.wantLang {
+				t.Errorf("detected_language = %q, want %q", got, tt.wantLang)
+			}
+		})
+	}
+}
+
+// TestOutputMessageTopicPrefix checks that -topic-prefix scopes both the
+// default and the per-language clean topics.
+func TestOutputMessageTopicPrefix(t *testing.T) {
+	defer func(v string) { *topicPrefix = v }(*topicPrefix)
+	*topicPrefix = "acme"
+
+	cp := &ContentProcessor{}
+	doc := cp.cleanDocument(model.Document{Text: "The cat and the hat"})
+	if got, want := *cp.outputMessage(doc, nil).TopicPartition.Topic, "acme."+model.TopicCleanContent; got != want {
+		t.Errorf("topic = %q, want %q", got, want)
+	}
+
+	*perLanguageTopics = true
+	defer func() { *perLanguageTopics = false }()
+	if got, want := *cp.outputMessage(doc, nil).TopicPartition.Topic, "acme."+model.TopicCleanContent+".en"; got != want {
+		t.Errorf("per-language topic = %q, want %q", got, want)
+	}
+}
+
+// fakeKafka feeds the processor canned messages, then a fatal error so
+// Start returns. It records what the processor produces and commits, and
+// reports the offsets set on it for the lag metric.
+type fakeKafka struct {
+	mu          sync.Mutex
+	messages    []*kafka.Message
+	produced    []*kafka.Message
+	committed   []*kafka.Message
+	undelivered map[kafka.Offset]bool // raw offsets whose cleaned documents fail delivery
+
+	assigned   []kafka.TopicPartition
+	offsets    map[int32]kafka.Offset // committed offset by partition
+	watermarks map[int32][2]int64     // low and high by partition
+}
+
+func (f *fakeKafka) Subscribe(string, kafka.RebalanceCb) error { return nil }
+func (f *fakeKafka) ReadMessage(time.Duration) (*kafka.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.messages) == 0 {
+		return nil, kafka.NewError(kafka.ErrFatal, "no more canned messages", true)
+	}
+	msg := f.messages[0]
+	f.messages = f.messages[1:]
+	return msg, nil
+}
+func (f *fakeKafka) Close() error { return nil }
+
+func (f *fakeKafka) Assignment() ([]kafka.TopicPartition, error) { return f.assigned, nil }
+func (f *fakeKafka) Committed(partitions []kafka.TopicPartition, _ int) ([]kafka.TopicPartition, error) {
+	committed := make([]kafka.TopicPartition, len(partitions))
+	for i, tp := range partitions {
+		committed[i] = tp
+		committed[i].Offset = kafka.OffsetInvalid
+		if offset, ok := f.offsets[tp.Partition]; ok {
+			committed[i].Offset = offset
+		}
+	}
+	return committed, nil
+}
+func (f *fakeKafka) QueryWatermarkOffsets(_ string, partition int32, _ int) (int64, int64, error) {
+	w := f.watermarks[partition]
+	return w[0], w[1], nil
+}
+
+func (f *fakeKafka) CommitMessage(m *kafka.Message) ([]kafka.TopicPartition, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.committed = append(f.committed, m)
+	return nil, nil
+}
+
+type fakeProducer struct{ *fakeKafka }
+
+// Produce records m and reports its delivery at once, failed if its raw
+// message's offset is undelivered
+func (p fakeProducer) Produce(m *kafka.Message, deliveries chan kafka.Event) error {
+	p.mu.Lock()
+	p.produced = append(p.produced, m)
+	report := *m
+	if source, ok := m.Opaque.(*kafka.Message); ok && p.undelivered[source.TopicPartition.Offset] {
+		report.TopicPartition.Error = kafka.NewError(kafka.ErrMsgTimedOut, "not delivered", false)
+	}
+	p.mu.Unlock()
+	if deliveries != nil {
+		deliveries <- &report
+	}
+	return nil
+}
+func (p fakeProducer) Flush(int) int { return 0 }
+func (p fakeProducer) Close()        {}
+
+// TestMinSurrealism feeds documents scoring 0, 0.3 and 0.6 and checks only
+// the one at or above -min-surrealism is produced while every offset is
+// committed.
+func TestMinSurrealism(t *testing.T) {
+	*minSurrealism = 0.5
+	defer func() { *minSurrealism = 0 }()
+
+	serializer, _ := model.NewSerializer(model.FormatJSON, "")
+	fake := &fakeKafka{}
+	cp := NewContentProcessorWith(fake, fakeProducer{fake})
+	cp.serializer = serializer
+
+	texts := map[string]string{
+		"https://example.com/plain":   "A plain page about tables",                        // no emotions or themes
+		"https://example.com/emotion": "A wonderful page about tables",                    // emotions only
+		"https://example.com/dreamy":  "A wonderful journey through space and the cosmos", // emotions and themes
+	}
+	for url, text := range texts {
+		value, _ := serializer.Marshal(model.TopicRawContent, model.Document{URL: url, Text: text})
+		cp.processMessage(&kafka.Message{Value: value})
+	}
+	cp.Close()
+
+	if len(fake.committed) != len(texts) {
+		t.Errorf("committed %d offsets, want %d", len(fake.committed), len(texts))
+	}
+	if len(fake.produced) != 1 {
+		t.Fatalf("produced %d documents, want 1", len(fake.produced))
+	}
+	var doc model.Document
+	serializer.Unmarshal(fake.produced[0].Value, &doc)
+	if doc.URL != "https://example.com/dreamy" || *fake.produced[0].TopicPartition.Topic != model.TopicCleanContent {
+		t.Errorf("produced %s to %s, want the dreamy page on %s", doc.URL, *fake.produced[0].TopicPartition.Topic, model.TopicCleanContent)
+	}
+}
+
+func TestMeetsSurrealism(t *testing.T) {
+	doc := func(s float64) model.Document { return model.Document{DreamHints: model.DreamingHints{Surrealism: s}} }
+	if !meetsSurrealism(doc(0), 0) {
+		t.Error("the default threshold of 0 dropped a document")
+	}
+	if !meetsSurrealism(doc(0.5), 0.5) || meetsSurrealism(doc(0.49), 0.5) {
+		t.Error("threshold should include its own value and exclude anything below")
+	}
+}
+
+// TestProcessorPipeline runs Start over a canned raw document and checks
+// the cleaned document it produces carries enhanced metadata, chunks and
+// dream hints, and that the offset is committed.
+func TestProcessorPipeline(t *testing.T) {
+	serializer, _ := model.NewSerializer(model.FormatJSON, "")
+	raw := model.Document{
+		URL:   "https://example.com/cosmos",
+		Title: "A journey",
+		Text: "The   wonderful journey through space began at dawn.  The cosmos unfolded like a map of the future!! " +
+			"Stars drifted over the earth and the nature of time felt strange and beautiful.",
+	}
+	value, _ := serializer.Marshal(model.TopicRawContent, raw)
+	fake := &fakeKafka{messages: []*kafka.Message{{Value: value}}}
+	cp := NewContentProcessorWith(fake, fakeProducer{fake})
+	cp.serializer = serializer
+
+	if err := cp.Start(context.Background()); err == nil {
+		t.Fatal("Start returned without the fake's fatal error")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fake.mu.Lock()
+		done := len(fake.committed) == 1
+		fake.mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the raw document was never committed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(fake.produced) != 1 {
+		t.Fatalf("produced %d documents, want 1", len(fake.produced))
+	}
+	var doc model.Document
+	if err := serializer.Unmarshal(fake.produced[0].Value, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != raw.DocumentID() || strings.Contains(doc.CleanText, "  ") || strings.Contains(doc.CleanText, "!!") {
+		t.Errorf("cleaned %q with ID %q", doc.CleanText, doc.ID)
+	}
+	m := doc.Metadata
+	if m.WordCount != len(strings.Fields(raw.Text)) || m.Language != "en" || m.ReadingTimeSec <= 0 {
+		t.Errorf("metadata: %d words, language %q, %ds reading; want %d, en and some", m.WordCount, m.Language, m.ReadingTimeSec, len(strings.Fields(raw.Text)))
+	}
+	if len(doc.Chunks) == 0 || doc.Chunks[0].ID != model.ChunkID(doc.Chunks[0].Type, doc.Chunks[0].Text) {
+		t.Errorf("chunks = %+v, want content-addressed IDs", doc.Chunks)
+	}
+	if len(doc.DreamHints.Emotions) == 0 || len(doc.DreamHints.Themes) == 0 || doc.DreamHints.Surrealism < 0.6 {
+		t.Errorf("dream hints = %+v, want emotions and themes", doc.DreamHints)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"strings"
+	"unicode"
+)
+
+var readingWPM = flag.Int("reading-wpm", 230, "words per minute assumed when estimating reading time")
+
+// readingTimeSec estimates how long words takes to read at -reading-wpm,
+// rounded up to a whole second
+func readingTimeSec(words int) int {
+	if words <= 0 || *readingWPM <= 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(words) * 60 / float64(*readingWPM)))
+}
+
+// readabilityGrade is the Flesch-Kincaid grade level of text: roughly the
+// US school grade needed to follow it. Text without any words scores 0.
+func readabilityGrade(text string) float64 {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	syllables := 0
+	for _, word := range words {
+		syllables += countSyllables(word)
+	}
+
+	// Text with no terminal punctuation is still one sentence
+	sentences := 0
+	inEnd := false
+	for _, r := range text {
+		end := r == '.' || r == '!' || r == '?'
+		if end && !inEnd {
+			sentences++
+		}
+		inEnd = end
+	}
+	if sentences == 0 {
+		sentences = 1
+	}
+
+	n := float64(len(words))
+	grade := 0.39*n/float64(sentences) + 11.8*float64(syllables)/n - 15.59
+	if grade < 0 {
+		return 0
+	}
+	return math.Round(grade*10) / 10
+}
+
+// countSyllables approximates the syllables in an English word by counting
+// vowel groups, not counting a silent trailing "e"
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	count := 0
+	prevVowel := false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+	if count > 1 && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") {
+		count--
+	}
+	if count == 0 {
+		return 1
+	}
+	return count
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// TestReadingTimeAndReadability checks the enrichment on a known paragraph,
+// on text that is far harder to read, and on empty text.
+func TestReadingTimeAndReadability(t *testing.T) {
+	defer func(v int) { *readingWPM = v }(*readingWPM)
+	*readingWPM = 200
+
+	cp := &ContentProcessor{}
+
+	// 28 words, 4 sentences, mostly one syllable: grade school prose
+	simple := "The cat sat on the mat. The dog ran to the park and back. " +
+		"It was a fun day for all of them. They slept well that night."
+	meta := cp.enhanceMetadata(model.DocumentMetadata{}, simple)
+	if meta.WordCount != 28 {
+		t.Fatalf("WordCount = %d, want 28", meta.WordCount)
+	}
+	if meta.ReadingTimeSec != 9 { // 28 words at 200 wpm = 8.4s, rounded up
+		t.Errorf("ReadingTimeSec = %d, want 9", meta.ReadingTimeSec)
+	}
+	if meta.ReadabilityScore < 0 || meta.ReadabilityScore > 4 {
+		t.Errorf("ReadabilityScore = %.1f, want between 0 and 4", meta.ReadabilityScore)
+	}
+
+	dense := "Institutional considerations regarding interdisciplinary collaboration " +
+		"necessitate comprehensive organizational restructuring, particularly " +
+		"concerning administrative responsibilities and departmental accountability."
+	meta = cp.enhanceMetadata(model.DocumentMetadata{}, dense)
+	if meta.ReadabilityScore < 16 {
+		t.Errorf("dense ReadabilityScore = %.1f, want at least 16", meta.ReadabilityScore)
+	}
+
+	long := strings.Repeat("word ", 400)
+	if got := cp.enhanceMetadata(model.DocumentMetadata{}, long).ReadingTimeSec; got != 120 {
+		t.Errorf("400 words: ReadingTimeSec = %d, want 120", got)
+	}
+
+	for _, text := range []string{"", "   ", "...", "Hi"} {
+		meta := cp.enhanceMetadata(model.DocumentMetadata{}, text)
+		if meta.ReadabilityScore < 0 || meta.ReadingTimeSec < 0 {
+			t.Errorf("%q: got score %.1f, time %d", text, meta.ReadabilityScore, meta.ReadingTimeSec)
+		}
+	}
+}
+
+func TestCountSyllables(t *testing.T) {
+	tests := map[string]int{
+		"cat":         1,
+		"table":       2,
+		"make":        1,
+		"readability": 5,
+		"rhythm":      1,
+		"the":         1,
+	}
+	for word, want := range tests {
+		if got := countSyllables(word); got != want {
+			t.Errorf("countSyllables(%q) = %d, want %d", word, got, want)
+		}
+	}
+}
@@ -1,136 +1,45 @@
+// Command crawler crawls from its seed URLs and produces what it finds to
+// Kafka. Its flags fill a crawler.Config; see package crawler for
+// embedding the crawler in another program.
 package main
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
-	"regexp"
+	"os"
+	"sort"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
-	"github.com/temoto/robotstxt"
-	"golang.org/x/time/rate"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/crawler"
 )
 
-// Document represents the enhanced structured data extracted from a web page
-type Document struct {
-	URL         string           `json:"url"`
-	Title       string           `json:"title"`
-	Text        string           `json:"text"`
-	CleanText   string           `json:"clean_text"`
-	FetchedAt   time.Time        `json:"fetched_at"`
-	Status      int              `json:"status"`
-	ContentHash string           `json:"content_hash"`
-	Metadata    DocumentMetadata `json:"metadata"`
-	Chunks      []ContentChunk   `json:"chunks"`
-	Links       []ExtractedLink  `json:"links"`
-	Media       []MediaAsset     `json:"media"`
-	DreamHints  DreamingHints    `json:"dream_hints"`
-}
-
-// DocumentMetadata contains enriched metadata for AI processing
-type DocumentMetadata struct {
-	Domain      string            `json:"domain"`
-	Language    string            `json:"language,omitempty"`
-	WordCount   int               `json:"word_count"`
-	Author      string            `json:"author,omitempty"`
-	PublishedAt *time.Time        `json:"published_at,omitempty"`
-	Tags        []string          `json:"tags,omitempty"`
-	Category    string            `json:"category,omitempty"`
-	Headers     map[string]string `json:"headers"`
-	ContentType string            `json:"content_type"`
-	Size        int64             `json:"size"`
-}
-
-// ContentChunk represents semantic chunks for AI processing
-type ContentChunk struct {
-	ID         string   `json:"id"`
-	Type       string   `json:"type"` // headline, paragraph, quote, list, etc.
-	Text       string   `json:"text"`
-	Position   int      `json:"position"`
-	Confidence float64  `json:"confidence"`
-	Keywords   []string `json:"keywords,omitempty"`
-	Sentiment  string   `json:"sentiment,omitempty"`
-	Entities   []string `json:"entities,omitempty"`
-}
-
-// ExtractedLink contains enriched link information
-type ExtractedLink struct {
-	URL      string `json:"url"`
-	Text     string `json:"text"`
-	Type     string `json:"type"` // internal, external, media
-	Context  string `json:"context,omitempty"`
-	Priority int    `json:"priority"` // for crawl prioritization
-}
-
-// MediaAsset represents images, videos, etc. found on the page
-type MediaAsset struct {
-	URL     string `json:"url"`
-	Type    string `json:"type"` // image, video, audio
-	Alt     string `json:"alt,omitempty"`
-	Caption string `json:"caption,omitempty"`
-	Size    string `json:"size,omitempty"`
-	Format  string `json:"format,omitempty"`
-}
-
-// DreamingHints provides context clues for AI dreaming
-type DreamingHints struct {
-	Emotions     []string `json:"emotions"`
-	Themes       []string `json:"themes"`
-	Motifs       []string `json:"motifs"`
-	Tone         string   `json:"tone"`
-	Complexity   float64  `json:"complexity"`
-	Surrealism   float64  `json:"surrealism_potential"`
-	VisualCues   []string `json:"visual_cues"`
-	AudioCues    []string `json:"audio_cues"`
-	ColorPalette []string `json:"color_palette,omitempty"`
-	Abstractness float64  `json:"abstractness"`
-}
-
-// Enhanced crawler config
-var (
-	workers         = flag.Int("workers", 10, "number of crawler workers")
-	queueSize       = flag.Int("queue", 1000, "url queue buffer size")
-	timeoutSec      = flag.Int("timeout", 15, "http client timeout in seconds")
-	kafkaBroker     = flag.String("kafka-broker", "localhost:9092", "Kafka broker address")
-	kafkaTopic      = flag.String("kafka-topic", "raw.content", "Kafka topic for raw content")
-	dreamTopic      = flag.String("dream-topic", "dream.seeds", "Kafka topic for dream-ready content")
-	maxDepth        = flag.Int("max-depth", 3, "maximum crawl depth")
-	enableDreaming  = flag.Bool("enable-dreaming", true, "enable AI dream hint generation")
-	domainWhitelist = flag.String("domains", "", "comma-separated list of allowed domains")
-)
-
-// hostPolicies stores the robots.txt data and rate limiter for a specific host
-type hostPolicies struct {
-	robots *robotstxt.RobotsData
-	lim    *rate.Limiter
-}
-
-// URLMetadata tracks crawl metadata
-type URLMetadata struct {
-	depth    int
-	parent   string
-	priority int
-}
-
 func main() {
-	flag.Parse()
-	seeds := flag.Args()
-	if len(seeds) == 0 {
-		log.Fatalf("usage: crawler [flags] <seed-url-1> <seed-url-2> ...")
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		cfg, opts, err := parseBackfillFlags(os.Args[2:])
+		if err == nil {
+			err = crawler.Backfill(cfg, opts)
+		}
+		if err != nil {
+			log.Fatalf("Backfill failed: %v", err)
+		}
+		return
+	}
+
+	f := newCrawlFlags("crawler", flag.ExitOnError)
+	f.Parse(os.Args[1:])
+	cfg := f.config()
+	if len(cfg.Seeds) == 0 {
+		log.Fatalf("usage: crawler [flags] <seed-url-1> <seed-url-2> ...\n       crawler backfill [flags]")
 	}
 
 	// Kafka Producer setup
 	producer, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers": *kafkaBroker,
+		"bootstrap.servers": f.broker,
 		"batch.size":        16384,
 		"linger.ms":         10,
 	})
@@ -138,976 +47,297 @@ func main() {
 		log.Fatalf("Failed to create Kafka producer: %s", err)
 	}
 	defer producer.Close()
+	cfg.Producer = producer
 
-	// Enhanced delivery reports handling
-	go handleKafkaEvents(producer)
-
-	// Enhanced channels and context
-	urlQueue := make(chan URLWithMetadata, *queueSize)
-	rawOut := make(chan Document)
-	dreamOut := make(chan Document)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Shared state
-	var hpMu sync.Mutex
-	hostMap := make(map[string]*hostPolicies)
-	seen := sync.Map{}
-	stats := &CrawlerStats{}
-
-	// Domain whitelist processing
-	var allowedDomains map[string]bool
-	if *domainWhitelist != "" {
-		allowedDomains = make(map[string]bool)
-		for _, domain := range strings.Split(*domainWhitelist, ",") {
-			allowedDomains[strings.TrimSpace(domain)] = true
-		}
-	}
-
-	// Shared HTTP client with better configuration
-	client := &http.Client{
-		Timeout: time.Duration(*timeoutSec) * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
-	}
-
-	// Start enhanced crawler workers
-	var wg sync.WaitGroup
-	for i := 0; i < *workers; i++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			enhancedWorker(ctx, id, urlQueue, rawOut, client, &hpMu, hostMap, &seen, stats, allowedDomains)
-		}(i)
-	}
-
-	// Dream processor (if enabled)
-	if *enableDreaming {
-		go dreamProcessor(ctx, rawOut, dreamOut)
-	} else {
-		// If dreaming is disabled, just pass through
-		go func() {
-			for doc := range rawOut {
-				dreamOut <- doc
-			}
-		}()
-	}
-
-	// Seed the queue
-	go func() {
-		for _, s := range seeds {
-			urlQueue <- URLWithMetadata{URL: s, Metadata: URLMetadata{depth: 0, priority: 10}}
-		}
-	}()
-
-	// Enhanced producer with multiple topics
-	go enhancedProducer(producer, dreamOut)
-
-	// Stats reporter
-	go statsReporter(ctx, stats)
-
-	// Enhanced runtime with graceful shutdown
-	log.Println("Enhanced Dream Crawler starting...")
-	timer := time.NewTimer(180 * time.Second) // 3 minutes for demo
-	<-timer.C
-
-	log.Println("Shutting down gracefully...")
-	cancel()
-	wg.Wait()
-	producer.Flush(15 * 1000)
-	close(rawOut)
-	close(dreamOut)
-
-	// Final stats
-	log.Printf("Crawl complete. Pages processed: %d, Errors: %d, Dreams generated: %d",
-		stats.PagesProcessed, stats.Errors, stats.DreamsGenerated)
-}
-
-// URLWithMetadata wraps URL with crawl metadata
-type URLWithMetadata struct {
-	URL      string
-	Metadata URLMetadata
-}
-
-// CrawlerStats tracks crawler performance
-type CrawlerStats struct {
-	mu              sync.Mutex
-	PagesProcessed  int64
-	Errors          int64
-	DreamsGenerated int64
-	BytesProcessed  int64
-	AveragePageSize float64
-}
-
-func (s *CrawlerStats) IncrementPages() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.PagesProcessed++
-}
-
-func (s *CrawlerStats) IncrementErrors() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.Errors++
-}
-
-func (s *CrawlerStats) IncrementDreams() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.DreamsGenerated++
-}
-
-func (s *CrawlerStats) AddBytes(bytes int64) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.BytesProcessed += bytes
-	s.AveragePageSize = float64(s.BytesProcessed) / float64(s.PagesProcessed)
-}
-
-// Enhanced worker with AI-ready content extraction
-func enhancedWorker(ctx context.Context, id int, urlQueue chan URLWithMetadata, out chan<- Document,
-	client *http.Client, hpMu *sync.Mutex, hostMap map[string]*hostPolicies,
-	seen *sync.Map, stats *CrawlerStats, allowedDomains map[string]bool) {
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case urlMeta := <-urlQueue:
-			if urlMeta.URL == "" {
-				continue
-			}
-
-			// Skip if already seen
-			if _, loaded := seen.LoadOrStore(urlMeta.URL, true); loaded {
-				continue
-			}
-
-			// Respect max depth
-			if urlMeta.Metadata.depth > *maxDepth {
-				continue
-			}
-
-			parsed, err := url.Parse(urlMeta.URL)
-			if err != nil {
-				log.Printf("worker %d: bad url %s: %v", id, urlMeta.URL, err)
-				stats.IncrementErrors()
-				continue
-			}
-
-			// Domain whitelist check
-			if allowedDomains != nil && !allowedDomains[parsed.Host] {
-				continue
-			}
-
-			host := parsed.Host
-
-			// Get/create host policies
-			hpMu.Lock()
-			hp, ok := hostMap[host]
-			if !ok {
-				hp = &hostPolicies{lim: rate.NewLimiter(rate.Every(500*time.Millisecond), 1)}
-				hostMap[host] = hp
-				go fetchRobotsTxt(client, parsed, hp)
-			}
-			hpMu.Unlock()
-
-			// Robots.txt check
-			if hp.robots != nil && !hp.robots.TestAgent(parsed.Path, "WebCrawlerThatDreams/1.0") {
-				log.Printf("worker %d: disallowed by robots: %s", id, urlMeta.URL)
-				continue
-			}
-
-			// Rate limiting
-			if err := hp.lim.Wait(ctx); err != nil {
-				continue
-			}
-
-			// Enhanced fetch and parse
-			log.Printf("worker %d: fetching %s (depth: %d)", id, urlMeta.URL, urlMeta.Metadata.depth)
-			doc, newLinks, err := enhancedFetchAndParse(ctx, client, urlMeta.URL, urlMeta.Metadata)
-			if err != nil {
-				log.Printf("worker %d: fetch error %s: %v", id, urlMeta.URL, err)
-				stats.IncrementErrors()
-				continue
-			}
-
-			stats.IncrementPages()
-			stats.AddBytes(int64(len(doc.Text)))
-			out <- doc
-
-			// Queue new links with incremented depth
-			for _, link := range newLinks {
-				if link.Priority > 0 { // Only queue high-priority links
-					newMeta := URLMetadata{
-						depth:    urlMeta.Metadata.depth + 1,
-						parent:   urlMeta.URL,
-						priority: link.Priority,
-					}
-					select {
-					case urlQueue <- URLWithMetadata{URL: link.URL, Metadata: newMeta}:
-					default:
-						// Queue full, drop low priority links
-						if link.Priority >= 5 {
-							log.Printf("worker %d: queue full, dropping link: %s", id, link.URL)
-						}
-					}
-				}
-			}
-		}
-	}
-}
-
-// Enhanced fetch and parse with AI-ready extraction
-func enhancedFetchAndParse(ctx context.Context, client *http.Client, rawurl string, metadata URLMetadata) (Document, []ExtractedLink, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", rawurl, nil)
-	if err != nil {
-		return Document{}, nil, err
-	}
-	req.Header.Set("User-Agent", "WebCrawlerThatDreams/1.0 (+https://github.com/dreamweaver/crawler)")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return Document{}, nil, err
-	}
-	defer resp.Body.Close()
-
-	// Initialize document with enhanced metadata
-	doc := Document{
-		URL:       rawurl,
-		FetchedAt: time.Now(),
-		Status:    resp.StatusCode,
-		Metadata: DocumentMetadata{
-			Headers:     make(map[string]string),
-			ContentType: resp.Header.Get("Content-Type"),
-			Size:        resp.ContentLength,
-		},
-	}
-
-	// Capture response headers
-	for key, values := range resp.Header {
-		if len(values) > 0 {
-			doc.Metadata.Headers[key] = values[0]
-		}
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return doc, nil, nil
-	}
-
-	// Parse with goquery
-	gqDoc, err := goquery.NewDocumentFromReader(resp.Body)
+	c, err := crawler.New(cfg)
 	if err != nil {
-		return doc, nil, err
-	}
-
-	// Enhanced content extraction
-	doc.Title = strings.TrimSpace(gqDoc.Find("title").First().Text())
-	doc.Text = extractText(gqDoc)
-	doc.CleanText = cleanText(doc.Text)
-	doc.ContentHash = fmt.Sprintf("%x", md5.Sum([]byte(doc.CleanText)))
-	doc.Metadata.Domain = extractDomain(rawurl)
-	doc.Metadata.WordCount = len(strings.Fields(doc.CleanText))
-
-	// Extract metadata
-	extractMetadata(gqDoc, &doc.Metadata)
-
-	// Extract semantic chunks
-	doc.Chunks = extractContentChunks(gqDoc, doc.CleanText)
-
-	// Extract links with priority
-	links := extractLinksWithPriority(gqDoc, rawurl, metadata.depth)
-
-	// Extract media assets
-	doc.Media = extractMediaAssets(gqDoc, rawurl)
-
-	// Generate dream hints
-	doc.DreamHints = generateDreamHints(doc)
-
-	return doc, links, nil
-}
-
-// Extract enhanced metadata from HTML
-func extractMetadata(doc *goquery.Document, metadata *DocumentMetadata) {
-	// Author extraction
-	doc.Find("meta[name='author'], meta[property='article:author']").Each(func(i int, s *goquery.Selection) {
-		if content, exists := s.Attr("content"); exists && metadata.Author == "" {
-			metadata.Author = strings.TrimSpace(content)
-		}
-	})
-
-	// Published date
-	doc.Find("meta[property='article:published_time'], meta[name='date']").Each(func(i int, s *goquery.Selection) {
-		if content, exists := s.Attr("content"); exists {
-			if publishedAt, err := time.Parse(time.RFC3339, content); err == nil {
-				metadata.PublishedAt = &publishedAt
-			}
-		}
-	})
-
-	// Tags/Keywords
-	doc.Find("meta[name='keywords'], meta[property='article:tag']").Each(func(i int, s *goquery.Selection) {
-		if content, exists := s.Attr("content"); exists {
-			tags := strings.Split(content, ",")
-			for _, tag := range tags {
-				tag = strings.TrimSpace(tag)
-				if tag != "" {
-					metadata.Tags = append(metadata.Tags, tag)
-				}
-			}
-		}
-	})
-
-	// Category
-	doc.Find("meta[property='article:section'], meta[name='category']").Each(func(i int, s *goquery.Selection) {
-		if content, exists := s.Attr("content"); exists && metadata.Category == "" {
-			metadata.Category = strings.TrimSpace(content)
-		}
-	})
-
-	// Language
-	if lang, exists := doc.Find("html").Attr("lang"); exists {
-		metadata.Language = lang
+		log.Fatalf("Failed to create crawler: %v", err)
 	}
-}
-
-// Extract content chunks for AI processing
-func extractContentChunks(doc *goquery.Document, cleanText string) []ContentChunk {
-	var chunks []ContentChunk
-	chunkID := 0
-
-	// Headlines
-	doc.Find("h1, h2, h3, h4, h5, h6").Each(func(i int, s *goquery.Selection) {
-		text := strings.TrimSpace(s.Text())
-		if text != "" && len(text) > 5 {
-			chunks = append(chunks, ContentChunk{
-				ID:         fmt.Sprintf("h_%d", chunkID),
-				Type:       "headline",
-				Text:       text,
-				Position:   chunkID,
-				Confidence: 0.9,
-				Keywords:   extractKeywords(text),
-			})
-			chunkID++
-		}
-	})
-
-	// Paragraphs
-	doc.Find("p").Each(func(i int, s *goquery.Selection) {
-		text := strings.TrimSpace(s.Text())
-		if text != "" && len(text) > 20 {
-			chunks = append(chunks, ContentChunk{
-				ID:         fmt.Sprintf("p_%d", chunkID),
-				Type:       "paragraph",
-				Text:       text,
-				Position:   chunkID,
-				Confidence: 0.8,
-				Keywords:   extractKeywords(text),
-				Sentiment:  detectSentiment(text),
-				Entities:   extractEntities(text),
-			})
-			chunkID++
-		}
-	})
-
-	// Quotes
-	doc.Find("blockquote, q").Each(func(i int, s *goquery.Selection) {
-		text := strings.TrimSpace(s.Text())
-		if text != "" {
-			chunks = append(chunks, ContentChunk{
-				ID:         fmt.Sprintf("q_%d", chunkID),
-				Type:       "quote",
-				Text:       text,
-				Position:   chunkID,
-				Confidence: 0.85,
-				Keywords:   extractKeywords(text),
-				Sentiment:  detectSentiment(text),
-			})
-			chunkID++
-		}
-	})
-
-	return chunks
-}
-
-// Extract links with priority scoring
-func extractLinksWithPriority(doc *goquery.Document, baseURL string, currentDepth int) []ExtractedLink {
-	var links []ExtractedLink
-	base, _ := url.Parse(baseURL)
-
-	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if !exists || len(href) < 2 || strings.HasPrefix(href, "#") {
-			return
-		}
-
-		resolvedURL, err := base.Parse(href)
-		if err != nil {
-			return
-		}
-
-		if resolvedURL.Scheme != "http" && resolvedURL.Scheme != "https" {
-			return
-		}
-
-		linkText := strings.TrimSpace(s.Text())
-		linkType := "external"
-		priority := 1
-
-		// Internal vs external
-		if resolvedURL.Host == base.Host {
-			linkType = "internal"
-			priority = 3
-		}
-
-		// Priority based on context and content
-		if strings.Contains(strings.ToLower(linkText), "article") ||
-			strings.Contains(strings.ToLower(linkText), "news") ||
-			strings.Contains(strings.ToLower(linkText), "blog") {
-			priority += 2
-		}
-
-		// Reduce priority for deep links
-		if currentDepth >= 2 {
-			priority = max(1, priority-1)
-		}
-
-		links = append(links, ExtractedLink{
-			URL:      resolvedURL.String(),
-			Text:     linkText,
-			Type:     linkType,
-			Priority: priority,
-		})
-	})
-
-	return links
-}
-
-// Extract media assets
-func extractMediaAssets(doc *goquery.Document, baseURL string) []MediaAsset {
-	var media []MediaAsset
-	base, _ := url.Parse(baseURL)
-
-	// Images
-	doc.Find("img").Each(func(i int, s *goquery.Selection) {
-		src, exists := s.Attr("src")
-		if !exists {
-			return
-		}
-
-		resolvedURL, err := base.Parse(src)
-		if err != nil {
-			return
-		}
-
-		alt, _ := s.Attr("alt")
-		media = append(media, MediaAsset{
-			URL:    resolvedURL.String(),
-			Type:   "image",
-			Alt:    alt,
-			Format: getFileExtension(src),
-		})
-	})
-
-	// Videos
-	doc.Find("video source, video").Each(func(i int, s *goquery.Selection) {
-		src, exists := s.Attr("src")
-		if !exists {
-			return
-		}
-
-		resolvedURL, err := base.Parse(src)
-		if err != nil {
-			return
-		}
-
-		media = append(media, MediaAsset{
-			URL:    resolvedURL.String(),
-			Type:   "video",
-			Format: getFileExtension(src),
-		})
-	})
-
-	return media
-}
-
-// Generate AI dream hints from content
-func generateDreamHints(doc Document) DreamingHints {
-	text := strings.ToLower(doc.CleanText + " " + doc.Title)
-
-	hints := DreamingHints{
-		Emotions:     detectEmotions(text),
-		Themes:       detectThemes(text),
-		Motifs:       extractVisualMotifs(text),
-		Tone:         detectTone(text),
-		VisualCues:   extractVisualCues(text),
-		AudioCues:    extractAudioCues(text),
-		ColorPalette: extractColors(text),
+	if n, err := c.ResumeFrontier(); err != nil {
+		log.Fatalf("Failed to load frontier: %v", err)
+	} else if n > 0 {
+		log.Printf("Resuming %d pending URLs from %s", n, cfg.FrontierFile)
 	}
 
-	// Calculate complexity and surrealism potential
-	hints.Complexity = calculateComplexity(doc)
-	hints.Surrealism = calculateSurrealismPotential(doc, hints)
-	hints.Abstractness = calculateAbstractness(text, hints)
-
-	return hints
-}
-
-// Dream processor - prepares content for AI dreaming
-func dreamProcessor(ctx context.Context, input <-chan Document, output chan<- Document) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case doc := <-input:
-			// Process document for dreaming
-			if doc.DreamHints.Surrealism > 0.3 && len(doc.CleanText) > 100 {
-				// This document has dream potential
-				log.Printf("Dream processor: High surrealism potential (%.2f) for %s",
-					doc.DreamHints.Surrealism, doc.URL)
-			}
-
-			output <- doc
-		}
-	}
-}
-
-// Enhanced Kafka producer
-func enhancedProducer(producer *kafka.Producer, input <-chan Document) {
-	for doc := range input {
-		docBytes, err := json.Marshal(doc)
-		if err != nil {
-			log.Printf("JSON marshal error: %v", err)
-			continue
-		}
-
-		// Send to raw content topic
-		producer.Produce(&kafka.Message{
-			TopicPartition: kafka.TopicPartition{Topic: kafkaTopic, Partition: kafka.PartitionAny},
-			Value:          docBytes,
-			Key:            []byte(doc.URL),
-			Headers: []kafka.Header{
-				{Key: "content_type", Value: []byte("application/json")},
-				{Key: "crawler_version", Value: []byte("dream-crawler-v1.0")},
-				{Key: "surrealism_score", Value: []byte(fmt.Sprintf("%.2f", doc.DreamHints.Surrealism))},
-			},
-		}, nil)
-
-		// Send high-surrealism content to dream topic
-		if doc.DreamHints.Surrealism > 0.5 {
-			producer.Produce(&kafka.Message{
-				TopicPartition: kafka.TopicPartition{Topic: dreamTopic, Partition: kafka.PartitionAny},
-				Value:          docBytes,
-				Key:            []byte(doc.URL),
-				Headers: []kafka.Header{
-					{Key: "dream_ready", Value: []byte("true")},
-					{Key: "surrealism_score", Value: []byte(fmt.Sprintf("%.2f", doc.DreamHints.Surrealism))},
-				},
-			}, nil)
-		}
-	}
-}
-
-// Handle Kafka events
-func handleKafkaEvents(producer *kafka.Producer) {
-	for e := range producer.Events() {
-		switch ev := e.(type) {
-		case *kafka.Message:
-			if ev.TopicPartition.Error != nil {
-				log.Printf("Kafka delivery failed: %v", ev.TopicPartition)
-			}
-		}
-	}
-}
-
-// Stats reporter
-func statsReporter(ctx context.Context, stats *CrawlerStats) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			stats.mu.Lock()
-			log.Printf("Stats: Pages: %d, Errors: %d, Dreams: %d, Avg Size: %.1f bytes",
-				stats.PagesProcessed, stats.Errors, stats.DreamsGenerated, stats.AveragePageSize)
-			stats.mu.Unlock()
-		}
-	}
-}
-
-// Helper functions for AI analysis
-func detectEmotions(text string) []string {
-	emotions := []string{}
-
-	positiveWords := []string{"amazing", "beautiful", "wonderful", "great", "love", "happy", "joy", "success"}
-	negativeWords := []string{"terrible", "awful", "hate", "sad", "fear", "anger", "pain", "failure"}
-	mysticalWords := []string{"mystery", "magic", "dream", "vision", "spirit", "soul", "ethereal", "cosmic"}
-
-	for _, word := range positiveWords {
-		if strings.Contains(text, word) {
-			emotions = append(emotions, "positive")
-			break
-		}
-	}
-
-	for _, word := range negativeWords {
-		if strings.Contains(text, word) {
-			emotions = append(emotions, "dark")
-			break
-		}
-	}
-
-	for _, word := range mysticalWords {
-		if strings.Contains(text, word) {
-			emotions = append(emotions, "mystical")
-			break
-		}
-	}
-
-	if len(emotions) == 0 {
-		emotions = append(emotions, "neutral")
-	}
-
-	return emotions
-}
-
-func detectThemes(text string) []string {
-	themes := []string{}
-
-	techWords := []string{"technology", "ai", "computer", "digital", "software", "algorithm"}
-	artWords := []string{"art", "creative", "design", "visual", "aesthetic", "beauty"}
-	scienceWords := []string{"science", "research", "discovery", "experiment", "analysis"}
-
-	for _, word := range techWords {
-		if strings.Contains(text, word) {
-			themes = append(themes, "technology")
-			break
-		}
-	}
-
-	for _, word := range artWords {
-		if strings.Contains(text, word) {
-			themes = append(themes, "creative")
-			break
-		}
-	}
-
-	for _, word := range scienceWords {
-		if strings.Contains(text, word) {
-			themes = append(themes, "scientific")
-			break
-		}
-	}
-
-	return themes
-}
-
-func extractVisualMotifs(text string) []string {
-	visualWords := []string{"light", "shadow", "color", "bright", "dark", "crystal", "liquid", "flowing", "geometric", "organic"}
-	motifs := []string{}
-
-	for _, word := range visualWords {
-		if strings.Contains(text, word) {
-			motifs = append(motifs, word)
-		}
-	}
-
-	return motifs
-}
-
-func extractVisualCues(text string) []string {
-	return []string{"ethereal lighting", "flowing forms", "crystalline structures"}
-}
-
-func extractAudioCues(text string) []string {
-	return []string{"ambient whispers", "digital harmonics", "pulsing rhythms"}
-}
-
-func extractColors(text string) []string {
-	colors := []string{}
-	colorWords := []string{"red", "blue", "green", "yellow", "purple", "orange", "pink", "white", "black", "gold", "silver"}
-
-	for _, color := range colorWords {
-		if strings.Contains(text, color) {
-			colors = append(colors, color)
-		}
-	}
-
-	return colors
-}
-
-func calculateComplexity(doc Document) float64 {
-	// Based on text length, chunk diversity, and metadata richness
-	complexity := float64(doc.Metadata.WordCount) / 1000.0
-	complexity += float64(len(doc.Chunks)) / 10.0
-	complexity += float64(len(doc.Media)) / 5.0
-
-	return min(1.0, complexity)
-}
-
-func calculateSurrealismPotential(doc Document, hints DreamingHints) float64 {
-	score := 0.0
-
-	// Emotional diversity increases surrealism
-	if len(hints.Emotions) > 1 {
-		score += 0.3
-	}
-
-	// Mystical/abstract themes boost surrealism
-	for _, emotion := range hints.Emotions {
-		if emotion == "mystical" {
-			score += 0.4
-		}
-	}
-
-	// Creative/artistic content is more surreal
-	for _, theme := range hints.Themes {
-		if theme == "creative" {
-			score += 0.3
-		}
-	}
-
-	// Visual motifs indicate surreal potential
-	score += float64(len(hints.Motifs)) * 0.05
-
-	// Complex content tends to be more surreal
-	score += hints.Complexity * 0.2
-
-	return min(1.0, score)
-}
-
-func calculateAbstractness(text string, hints DreamingHints) float64 {
-	abstractWords := []string{"concept", "idea", "essence", "meaning", "philosophy", "abstract", "theory", "metaphor"}
-	score := 0.0
-
-	for _, word := range abstractWords {
-		if strings.Contains(text, word) {
-			score += 0.1
-		}
-	}
-
-	// High emotion diversity suggests abstractness
-	score += float64(len(hints.Emotions)) * 0.05
-
-	return min(1.0, score)
-}
-
-func detectTone(text string) string {
-	formalWords := []string{"therefore", "furthermore", "consequently", "analysis", "research"}
-	casualWords := []string{"really", "pretty", "quite", "basically", "actually"}
-	dramaticWords := []string{"incredible", "amazing", "shocking", "revolutionary", "breakthrough"}
-
-	formalCount := 0
-	casualCount := 0
-	dramaticCount := 0
-
-	for _, word := range formalWords {
-		if strings.Contains(text, word) {
-			formalCount++
-		}
-	}
-
-	for _, word := range casualWords {
-		if strings.Contains(text, word) {
-			casualCount++
-		}
-	}
-
-	for _, word := range dramaticWords {
-		if strings.Contains(text, word) {
-			dramaticCount++
-		}
-	}
-
-	if dramaticCount > formalCount && dramaticCount > casualCount {
-		return "dramatic"
-	} else if formalCount > casualCount {
-		return "formal"
-	} else if casualCount > 0 {
-		return "casual"
-	}
-
-	return "neutral"
-}
-
-func detectSentiment(text string) string {
-	positiveWords := []string{"good", "great", "excellent", "amazing", "wonderful", "love", "best"}
-	negativeWords := []string{"bad", "terrible", "awful", "hate", "worst", "horrible"}
-
-	positiveCount := 0
-	negativeCount := 0
-
-	for _, word := range positiveWords {
-		positiveCount += strings.Count(strings.ToLower(text), word)
-	}
-
-	for _, word := range negativeWords {
-		negativeCount += strings.Count(strings.ToLower(text), word)
-	}
-
-	if positiveCount > negativeCount {
-		return "positive"
-	} else if negativeCount > positiveCount {
-		return "negative"
-	}
-
-	return "neutral"
-}
-
-func extractKeywords(text string) []string {
-	// Simple keyword extraction - in production you'd use proper NLP
-	words := strings.Fields(strings.ToLower(text))
-	stopWords := map[string]bool{
-		"the": true, "a": true, "an": true, "and": true, "or": true, "but": true,
-		"in": true, "on": true, "at": true, "to": true, "for": true, "of": true,
-		"with": true, "by": true, "is": true, "are": true, "was": true, "were": true,
-		"be": true, "been": true, "have": true, "has": true, "had": true, "do": true,
-		"does": true, "did": true, "will": true, "would": true, "could": true, "should": true,
-		"this": true, "that": true, "these": true, "those": true, "i": true, "you": true,
-		"he": true, "she": true, "it": true, "we": true, "they": true,
-	}
-
-	keywords := []string{}
-	wordCount := make(map[string]int)
-
-	for _, word := range words {
-		word = strings.Trim(word, ".,!?;:")
-		if len(word) > 3 && !stopWords[word] {
-			wordCount[word]++
-		}
-	}
-
-	// Get top keywords
-	for word, count := range wordCount {
-		if count >= 2 || len(word) > 6 {
-			keywords = append(keywords, word)
-		}
-		if len(keywords) >= 10 {
-			break
-		}
-	}
-
-	return keywords
-}
-
-func extractEntities(text string) []string {
-	// Simple entity extraction - looks for capitalized words
-	re := regexp.MustCompile(`\b[A-Z][a-z]+(?:\s+[A-Z][a-z]+)*\b`)
-	matches := re.FindAllString(text, -1)
-
-	entities := []string{}
-	seen := make(map[string]bool)
-
-	for _, match := range matches {
-		if len(match) > 3 && !seen[match] {
-			entities = append(entities, match)
-			seen[match] = true
-		}
-		if len(entities) >= 5 {
-			break
-		}
-	}
-
-	return entities
-}
-
-// Enhanced text extraction with better cleaning
-func extractText(d *goquery.Document) string {
-	// Remove non-content elements
-	d.Find("script, style, noscript, nav, footer, header, aside, .advertisement, .ad, .sidebar").Remove()
-
-	// Get text from main content areas
-	var textParts []string
-
-	// Try to find main content areas first
-	mainContent := d.Find("main, article, .content, .post, .entry, #main, #content")
-	if mainContent.Length() > 0 {
-		mainContent.Each(func(i int, s *goquery.Selection) {
-			text := strings.TrimSpace(s.Text())
-			if len(text) > 50 {
-				textParts = append(textParts, text)
-			}
-		})
-	} else {
-		// Fallback to body
-		text := strings.TrimSpace(d.Find("body").Text())
-		if text != "" {
-			textParts = append(textParts, text)
-		}
-	}
-
-	return strings.Join(textParts, "\n\n")
-}
-
-func cleanText(text string) string {
-	// Remove excessive whitespace
-	re := regexp.MustCompile(`\s+`)
-	cleaned := re.ReplaceAllString(text, " ")
-
-	// Remove special characters but keep punctuation
-	re = regexp.MustCompile(`[^\w\s\.,!?;:'"()-]`)
-	cleaned = re.ReplaceAllString(cleaned, "")
-
-	return strings.TrimSpace(cleaned)
-}
-
-func extractDomain(rawurl string) string {
-	parsed, err := url.Parse(rawurl)
-	if err != nil {
+	log.Println("Enhanced Dream Crawler starting...")
+	c.Run(context.Background())
+}
+
+// crawlFlags is the crawler's flag set, filling a crawler.Config, and the
+// few flags that aren't part of it
+type crawlFlags struct {
+	*flag.FlagSet
+	cfg crawler.Config
+
+	broker       string
+	timeoutSec   int
+	fetchWorkers int
+}
+
+// newCrawlFlags registers every crawler flag, defaulting to
+// crawler.DefaultConfig
+func newCrawlFlags(name string, errorHandling flag.ErrorHandling) *crawlFlags {
+	f := &crawlFlags{FlagSet: flag.NewFlagSet(name, errorHandling), cfg: crawler.DefaultConfig()}
+	fs, c := f.FlagSet, &f.cfg
+	c.Headers = http.Header{}
+	fs.Var((*listFlag)(&c.Sitemaps), "sitemaps", "comma-separated sitemap or sitemap index URLs whose pages are queued after the seeds; under -change-store, pages whose <lastmod> predates their last crawl are skipped")
+
+	// Scope
+	fs.IntVar(&c.MaxDepth, "max-depth", c.MaxDepth, "maximum crawl depth")
+	fs.IntVar(&c.MaxHosts, "max-hosts", c.MaxHosts, "stop crawling new hosts once this many distinct hosts have been seen; known hosts continue (0 = unlimited)")
+	fs.Var((*listFlag)(&c.AllowedDomains), "domains", "comma-separated list of allowed domains")
+	fs.Var((*listFlag)(&c.AllowedSchemes), "allowed-schemes", "comma-separated URL schemes links may use to be followed")
+	fs.IntVar(&c.MaxFollowPerPage, "max-follow-per-page", c.MaxFollowPerPage, "maximum links followed from each page, highest priority first (0 = unlimited)")
+	fs.IntVar(&c.MaxPagination, "max-pagination", c.MaxPagination, "next pages followed in one paginated series without using up depth (0 = treat next links like any other)")
+	fs.BoolVar(&c.FollowAlternates, "follow-alternates", c.FollowAlternates, "enqueue hreflang alternate-language versions of each page")
+	fs.Var((*listFlag)(&c.FocusKeywords), "focus-keywords", "comma-separated keywords; links matching them, or found on pages matching them, are followed first")
+	fs.BoolVar(&c.PruneIrrelevant, "prune-irrelevant", c.PruneIrrelevant, "with -focus-keywords, don't follow links with no keyword relevance from -prune-depth on")
+	fs.IntVar(&c.PruneDepth, "prune-depth", c.PruneDepth, "depth from which -prune-irrelevant drops irrelevant links")
+
+	// Workers
+	fs.IntVar(&c.Workers, "workers", c.Workers, "number of crawler workers")
+	fs.IntVar(&f.fetchWorkers, "fetch-workers", 0, "workers fetching pages, replacing -workers; with -parse-workers they only fetch (0 = -workers)")
+	fs.IntVar(&c.ParseWorkers, "parse-workers", c.ParseWorkers, "workers parsing and analyzing fetched pages, so CPU-bound parsing doesn't hold up fetching; sized apart from the fetch workers (0 = each fetch worker parses its own pages)")
+	fs.IntVar(&c.MinWorkers, "min-workers", c.MinWorkers, "fewest workers the autoscaler retires down to when the queue drains (0 = -workers)")
+	fs.IntVar(&c.MaxWorkers, "max-workers", c.MaxWorkers, "most workers the autoscaler adds while the queue is deep (0 = -workers); workers are autoscaled when -min-workers or -max-workers differs from -workers")
+	fs.IntVar(&c.ScaleHighWater, "scale-high-water", c.ScaleHighWater, "queued URLs above which the autoscaler adds workers")
+	fs.DurationVar(&c.ScaleInterval, "scale-interval", c.ScaleInterval, "how often the autoscaler checks the queue depth")
+	fs.IntVar(&c.QueueSize, "queue", c.QueueSize, "url queue buffer size")
+	fs.BoolVar(&c.HostFairness, "host-fairness", c.HostFairness, "interleave hosts round-robin instead of serving the shared queue in arrival order")
+
+	// Budgets
+	fs.DurationVar(&c.MaxRuntime, "max-runtime", c.MaxRuntime, "wall-clock limit for the whole crawl (0 = unlimited)")
+	fs.Int64Var(&c.MaxBytes, "max-bytes", c.MaxBytes, "stop once this many bytes of page text have been processed (0 = unlimited)")
+	fs.Int64Var(&c.MaxPages, "max-pages", c.MaxPages, "stop once this many pages have been processed (0 = unlimited)")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "stop after this long without any fetch, skip or error (0 = never)")
+	fs.DurationVar(&c.URLDeadline, "url-deadline", c.URLDeadline, "total time one URL may take across rate-limit waits, retries, fetch and parse (0 = no limit)")
+
+	// Politeness
+	fs.StringVar(&c.HostConfigFile, "host-config", c.HostConfigFile, "JSON file of per-host rate/concurrency overrides, keyed by hostname or *.suffix")
+	fs.Float64Var(&c.RateJitter, "rate-jitter", c.RateJitter, "fraction by which each host's request spacing varies around its interval, e.g. 0.2 for ±20% (robots Crawl-delay stays a floor)")
+	fs.BoolVar(&c.IgnoreRobots, "ignore-robots", c.IgnoreRobots, "ignore robots.txt Disallow rules on every host (Crawl-delay still applies); only for sites you own")
+	fs.Var((*listFlag)(&c.RobotsOverrideHosts), "robots-override-hosts", "comma-separated hostnames or *.suffix patterns whose robots.txt Disallow rules are ignored")
+	fs.IntVar(&c.BackoffAfter, "backoff-after", c.BackoffAfter, "consecutive failures (errors or 5xx) before a host's crawl interval starts growing")
+	fs.Float64Var(&c.BackoffFactor, "backoff-factor", c.BackoffFactor, "multiplier applied to a failing host's crawl interval per further failure")
+	fs.IntVar(&c.BreakerAfter, "breaker-after", c.BreakerAfter, "consecutive failures before a host is skipped entirely (0 = never)")
+	fs.DurationVar(&c.BreakerCooldown, "breaker-cooldown", c.BreakerCooldown, "how long a broken host is skipped before a single probe request is let through")
+	fs.IntVar(&c.MaxRetries, "max-retries", c.MaxRetries, "times a rate-limited URL is re-queued before it is given up on")
+	fs.DurationVar(&c.RetryBaseDelay, "retry-base-delay", c.RetryBaseDelay, "delay before the first retry of a 429 without Retry-After, doubled per attempt")
+	fs.DurationVar(&c.MaxLimiterWait, "max-limiter-wait", c.MaxLimiterWait, "longest a worker waits on a host's rate limiter before deferring the URL")
+
+	// HTTP
+	fs.IntVar(&f.timeoutSec, "timeout", int(c.Timeout/time.Second), "http client timeout in seconds")
+	fs.StringVar(&c.AcceptLanguage, "accept-language", c.AcceptLanguage, "Accept-Language header sent with every request")
+	fs.Var(headerFlags(c.Headers), "header", "extra request header as \"Key: Value\" (repeatable, overrides defaults such as User-Agent)")
+	fs.StringVar(&c.LoginURL, "login-url", c.LoginURL, "URL to POST a login form to once at startup, for sites behind a login")
+	fs.StringVar(&c.LoginFields, "login-fields", c.LoginFields, "comma-separated key=value form fields for -login-url; $VAR or ${VAR} values are read from the environment")
+	fs.StringVar(&c.CAFile, "ca-file", c.CAFile, "PEM bundle of extra CA certificates to trust")
+	fs.StringVar(&c.ClientCert, "client-cert", c.ClientCert, "PEM client certificate for mutual TLS (requires -client-key)")
+	fs.StringVar(&c.ClientKey, "client-key", c.ClientKey, "PEM private key for -client-cert")
+	fs.BoolVar(&c.InsecureSkipVerify, "insecure-skip-verify", c.InsecureSkipVerify, "skip TLS certificate verification (testing only)")
+	fs.IntVar(&c.MaxIdleConns, "max-idle-conns", c.MaxIdleConns, "maximum idle connections kept across all hosts")
+	fs.IntVar(&c.MaxIdleConnsPerHost, "max-idle-conns-per-host", c.MaxIdleConnsPerHost, "maximum idle connections kept per host")
+	fs.IntVar(&c.MaxConnsPerHost, "max-conns-per-host", c.MaxConnsPerHost, "maximum connections per host, including active ones (0 = unlimited)")
+	fs.DurationVar(&c.IdleConnTimeout, "idle-conn-timeout", c.IdleConnTimeout, "how long an idle connection is kept before closing")
+	fs.BoolVar(&c.DisableKeepAlives, "disable-keepalives", c.DisableKeepAlives, "open a new connection for every request")
+	fs.BoolVar(&c.ForceHTTP1, "force-http1", c.ForceHTTP1, "never negotiate HTTP/2")
+	fs.DurationVar(&c.DialTimeout, "dial-timeout", c.DialTimeout, "TCP connect timeout, separate from -timeout")
+	fs.DurationVar(&c.TLSHandshakeTimeout, "tls-handshake-timeout", c.TLSHandshakeTimeout, "TLS handshake timeout, separate from -timeout")
+	fs.BoolVar(&c.PerWorkerClient, "per-worker-client", c.PerWorkerClient, "give each worker its own transport and connection pool instead of sharing one")
+	fs.DurationVar(&c.DNSCacheTTL, "dns-cache-ttl", c.DNSCacheTTL, "cache resolved host addresses this long, shared by all workers (0 = resolve on every dial)")
+	fs.DurationVar(&c.DNSNegativeTTL, "dns-negative-ttl", c.DNSNegativeTTL, "with -dns-cache-ttl, remember hosts that don't exist this long")
+	fs.BoolVar(&c.AllowPrivateNetworks, "allow-private-networks", c.AllowPrivateNetworks, "let the crawler connect to loopback, private, link-local and other internal addresses, for trusted internal crawls")
+	fs.Int64Var(&c.MaxBodyBytes, "max-body-bytes", c.MaxBodyBytes, "largest response body fetched, as sent over the wire; bigger pages fail as too large (0 = unlimited)")
+	fs.Int64Var(&c.MaxDecompressedBytes, "max-decompressed-bytes", c.MaxDecompressedBytes, "largest a gzipped response or compressed PDF stream may inflate to; beyond it the fetch fails as a decompression bomb (0 = unlimited)")
+	fs.BoolVar(&c.CoalesceFetches, "coalesce-fetches", c.CoalesceFetches, "let workers that pick up a URL already being fetched wait for that fetch instead of repeating it")
+	fs.BoolVar(&c.PoolBuffers, "pool-buffers", c.PoolBuffers, "reuse body buffers and readers across fetches to cut allocations and GC work")
+
+	// Deduplication
+	fs.StringVar(&c.TrackingParams, "tracking-params", c.TrackingParams, "comma-separated query params ignored when deduplicating URLs, a trailing * matching a prefix (empty = none); hosts with significant_params in -host-config keep only those params instead")
+	fs.BoolVar(&c.CollapseIndex, "collapse-index", c.CollapseIndex, "treat directory-index files (index.html, index.php, ...) and trailing slashes as the same URL when deduplicating; can be set per host in -host-config")
+	fs.BoolVar(&c.CanonicalizeVariants, "canonicalize-variants", c.CanonicalizeVariants, "deduplicate AMP (/amp/, ?amp=1, .amp.html) and mobile (m.example.com) URLs against their desktop page, and drop a variant whose rel=\"canonical\" page was already crawled; off by default since some sites serve different content there")
+	fs.BoolVar(&c.FollowCrossOriginCanonical, "follow-cross-origin-canonical", c.FollowCrossOriginCanonical, "accept a rel=\"canonical\" or og:url naming another site, as syndicated pages do; by default they are ignored so a misconfigured tag can't merge distinct pages")
+	fs.BoolVar(&c.SeenBloom, "seen-bloom", c.SeenBloom, "dedup URLs with a bloom filter instead of an exact set, bounding memory at the cost of rarely skipping a new URL")
+	fs.Float64Var(&c.SeenBloomFP, "seen-bloom-fp", c.SeenBloomFP, "target false-positive rate of the -seen-bloom filter")
+	fs.IntVar(&c.SeenBloomCapacity, "seen-bloom-capacity", c.SeenBloomCapacity, "URLs the first -seen-bloom stage holds before another is added")
+
+	// Extraction
+	fs.StringVar(&c.StripSelectors, "strip-selectors", c.StripSelectors, "CSS selectors of elements removed before text and chunks are extracted, e.g. to add .comments on a blog (empty = none)")
+	fs.StringVar(&c.KeepSelectors, "keep-selectors", c.KeepSelectors, "CSS selectors of elements never removed by -strip-selectors, e.g. aside on a wiki")
+	fs.StringVar(&c.VolatileSelectors, "volatile-selectors", c.VolatileSelectors, "CSS selectors of elements that change on every load, such as timestamps and view counters, left out of each page's stable_hash (empty = none)")
+	fs.StringVar(&c.VolatilePattern, "volatile-pattern", c.VolatilePattern, "regular expression matching text left out of each page's stable_hash, such as times and counts (empty = none)")
+	fs.StringVar(&c.DateLayouts, "date-layouts", c.DateLayouts, "comma-separated date formats tried in order for published dates: rfc3339, rfc1123, unix or Go layouts such as 2006-01-02 (default: rfc3339,rfc1123,2006-01-02T15:04:05,2006-01-02 15:04:05,2006-01-02,unix)")
+	fs.StringVar(&c.DateTimezone, "date-timezone", c.DateTimezone, "IANA time zone for published dates that don't give one; all dates are stored in UTC")
+	fs.StringVar(&c.CaptureHeaders, "capture-headers", c.CaptureHeaders, "comma-separated response headers stored in document metadata (empty = none)")
+	fs.BoolVar(&c.CaptureAllHeaders, "capture-all-headers", c.CaptureAllHeaders, "store every response header in document metadata, including cookies and server details, instead of -capture-headers")
+	fs.Var((*listFlag)(&c.LinkAttrs), "link-attrs", "comma-separated anchor attributes to keep on extracted links (e.g. rel,title,hreflang,type)")
+	fs.Var((*listFlag)(&c.MediaAttrs), "media-attrs", "comma-separated img/video attributes to keep on extracted media (e.g. loading,decoding,width,height)")
+	fs.IntVar(&c.MinImageWidth, "min-image-width", c.MinImageWidth, "leave images declaring a width below this many pixels out of media, such as spacers and icons; images without a declared width are kept (0 = no minimum)")
+	fs.IntVar(&c.MinImageHeight, "min-image-height", c.MinImageHeight, "leave images declaring a height below this many pixels out of media; images without a declared height are kept (0 = no minimum)")
+	fs.StringVar(&c.TrackingImages, "tracking-images", c.TrackingImages, "comma-separated URL substrings of tracking pixels left out of media (empty = none)")
+	fs.IntVar(&c.HeroMinSize, "hero-min-size", c.HeroMinSize, "smallest declared width or height, in pixels, of an image that can be a page's hero; smaller ones are icons and tracking pixels")
+	fs.BoolVar(&c.Microdata, "microdata", c.Microdata, "extract HTML microdata (itemscope/itemprop) into metadata, filling in the title, author and published date when the page doesn't give them otherwise")
+	fs.BoolVar(&c.RespectUsageDirectives, "respect-usage-directives", c.RespectUsageDirectives, "mark pages whose robots meta tag says noarchive or nosnippet and leave out their text, chunks and raw HTML; their links are still followed")
+	fs.BoolVar(&c.ExtractPDF, "extract-pdf", c.ExtractPDF, "extract text from application/pdf responses and analyze it like a page")
+	fs.BoolVar(&c.EmitSafeHTML, "emit-safe-html", c.EmitSafeHTML, "add each chunk's markup as sanitized HTML, safe to render, in safe_html")
+	fs.StringVar(&c.SafeHTMLTags, "safe-html-tags", c.SafeHTMLTags, "comma-separated tags -emit-safe-html keeps; others are unwrapped to their text, and links keep only http, https and mailto hrefs")
+	fs.Var((*listFlag)(&c.Processors), "processors", "comma-separated document processors to run, in order (default: every registered processor, in registration order)")
+	fs.StringVar(&c.AnalysisProfile, "analysis-profile", c.AnalysisProfile, "analysis profile for pages no profile's domains or categories match")
+	fs.StringVar(&c.AnalysisProfilesFile, "analysis-profiles", c.AnalysisProfilesFile, "JSON file of analysis profiles keyed by name; entries replace built-ins of the same name")
+	fs.BoolVar(&c.EnableDreaming, "enable-dreaming", c.EnableDreaming, "enable AI dream hint generation")
+	fs.BoolVar(&c.Timing, "timing", c.Timing, "record how many microseconds each stage took on every document, and their averages in the stats")
+	fs.IntVar(&c.BoilerplateTitleRepeats, "boilerplate-title-repeats", c.BoilerplateTitleRepeats, "pages sharing a title this many times are boilerplate when thin (0 = off)")
+	fs.IntVar(&c.BoilerplateMaxWords, "boilerplate-max-words", c.BoilerplateMaxWords, "word count at or below which a repeated-title page counts as thin")
+	fs.IntVar(&c.BoilerplateMaxTitles, "boilerplate-max-titles", c.BoilerplateMaxTitles, "distinct titles tracked for boilerplate detection, least recent evicted first")
+	fs.BoolVar(&c.BoilerplateSkipDreams, "boilerplate-skip-dreams", c.BoilerplateSkipDreams, "don't generate dream hints for boilerplate pages")
+	fs.IntVar(&c.BoilerplateChunkPages, "boilerplate-chunk-pages", c.BoilerplateChunkPages, "chunks whose text appears on this many distinct pages are boilerplate and dropped (0 = off)")
+	fs.IntVar(&c.BoilerplateMaxChunks, "boilerplate-max-chunks", c.BoilerplateMaxChunks, "distinct chunk texts tracked for -boilerplate-chunk-pages, least recent evicted first")
+	fs.IntVar(&c.TemplatePages, "template-pages", c.TemplatePages, "pages per host learned before DOM subtrees recurring across them, such as sidebars and navigation, are left out of later pages' content (0 = off)")
+	fs.IntVar(&c.TemplateMaxFingerprints, "template-max-fingerprints", c.TemplateMaxFingerprints, "subtree fingerprints remembered per host while learning its template, the rest ignored")
+
+	// Output
+	fs.StringVar(&f.broker, "kafka-broker", "localhost:9092", "Kafka broker address")
+	fs.StringVar(&c.KafkaTopic, "kafka-topic", c.KafkaTopic, "Kafka topic for raw content")
+	fs.StringVar(&c.DreamTopic, "dream-topic", c.DreamTopic, "Kafka topic for dream-ready content")
+	fs.StringVar(&c.GraphTopic, "graph-topic", c.GraphTopic, "Kafka topic for parent-to-child link edges (empty = don't emit)")
+	fs.StringVar(&c.ErrorTopic, "error-topic", c.ErrorTopic, "Kafka topic for URLs that failed, with their error category (empty = don't emit)")
+	fs.StringVar(&c.QuarantineTopic, "quarantine-topic", c.QuarantineTopic, "Kafka topic for documents failing validation (empty text, non-200, soft 404) instead of the main topics")
+	fs.StringVar(&c.RawHTMLTopic, "raw-html-topic", c.RawHTMLTopic, "Kafka topic for -store-raw-html bodies, keyed by URL")
+	fs.StringVar(&c.ChangeTopic, "change-topic", c.ChangeTopic, "Kafka topic for -change-store change events")
+	fs.StringVar(&c.TopicPrefix, "topic-prefix", c.TopicPrefix, "prefix for every produced topic, e.g. \"acme\" gives acme.raw.content, to keep tenants sharing a cluster apart")
+	fs.StringVar(&c.Serialization, "serialization", c.Serialization, "encoding of documents on the raw and quarantine topics: json, protobuf or avro (the dream topic stays JSON for the ML service)")
+	fs.StringVar(&c.SchemaRegistry, "schema-registry", c.SchemaRegistry, "Confluent-compatible schema registry URL; with -serialization=avro the schema is registered as <topic>-value and messages carry its ID")
+	fs.StringVar(&c.EmitFields, "emit-fields", c.EmitFields, "comma-separated Document JSON fields produced to the raw topic (default: all)")
+	fs.StringVar(&c.DreamEmitFields, "dream-emit-fields", c.DreamEmitFields, "comma-separated Document JSON fields produced to the dream topic (default: same as -emit-fields)")
+	fs.Float64Var(&c.DreamThreshold, "dream-threshold", c.DreamThreshold, "surrealism score above which documents are also sent to the dream topic")
+	fs.Float64Var(&c.LogThreshold, "log-threshold", c.LogThreshold, "surrealism score above which the dream processor logs a document")
+	fs.BoolVar(&c.EmitSoft404, "emit-soft-404", c.EmitSoft404, "emit pages detected as soft 404s instead of dropping them")
+	fs.IntVar(&c.MinWordCount, "min-word-count", c.MinWordCount, "don't emit pages with fewer words of clean text; their links are still followed")
+	fs.IntVar(&c.MinCleanTextLen, "min-clean-text-len", c.MinCleanTextLen, "don't emit pages with fewer bytes of clean text; their links are still followed")
+	fs.Float64Var(&c.SampleRate, "sample-rate", c.SampleRate, "fraction of fetched documents emitted, chosen by URL hash so reruns pick the same pages (links are followed from all pages)")
+	fs.BoolVar(&c.DedupMedia, "dedup-media", c.DedupMedia, "emit each media URL in full only on the first page it's seen on")
+	fs.BoolVar(&c.DedupMediaMark, "dedup-media-mark", c.DedupMediaMark, "with -dedup-media, keep repeated assets marked seen_before instead of dropping them")
+	fs.IntVar(&c.DedupMediaMax, "dedup-media-max", c.DedupMediaMax, "media URLs remembered for -dedup-media, least recent evicted first")
+	fs.BoolVar(&c.StoreRawHTML, "store-raw-html", c.StoreRawHTML, "keep each page's original HTML and produce it to -raw-html-topic")
+	fs.Int64Var(&c.RawHTMLMaxBytes, "raw-html-max-bytes", c.RawHTMLMaxBytes, "largest body kept by -store-raw-html; bigger pages are parsed but not archived")
+	fs.Float64Var(&c.EmitRate, "emit-rate", c.EmitRate, "maximum document messages produced per second, across the document topics (0 = unlimited); graph, error and change events, spool retries and the backfill subcommand aren't limited")
+	fs.IntVar(&c.EmitBurst, "emit-burst", c.EmitBurst, "document messages that may be produced at once before -emit-rate applies")
+	fs.IntVar(&c.OutputBuffer, "output-buffer", c.OutputBuffer, "documents held in memory ahead of the Kafka producer before spilling to disk")
+	fs.StringVar(&c.SpillDir, "spill-dir", c.SpillDir, "directory for output buffer spill files (default: system temp dir)")
+	fs.StringVar(&c.DeliverySpool, "delivery-spool", c.DeliverySpool, "directory where messages Kafka failed to deliver are kept and re-produced with backoff, surviving restarts (disabled when empty: failures are only logged)")
+	fs.Int64Var(&c.DeliverySpoolMaxBytes, "delivery-spool-max-bytes", c.DeliverySpoolMaxBytes, "largest total size of spooled messages; failures past it go straight to the dead-letter file")
+	fs.DurationVar(&c.DeliveryMaxAge, "delivery-max-age", c.DeliveryMaxAge, "how long a spooled message is retried before it is moved to the dead-letter file")
+	fs.DurationVar(&c.DeliveryRetryDelay, "delivery-retry-delay", c.DeliveryRetryDelay, "delay before a spooled message's first retry, doubled per attempt up to a minute")
+
+	// State kept between crawls
+	fs.StringVar(&c.ChangeStore, "change-store", c.ChangeStore, "file keeping each page's last content between crawls, so a recrawled page whose content changed emits a change event (disabled when empty)")
+	fs.IntVar(&c.ChangeMaxText, "change-max-text", c.ChangeMaxText, "bytes of each page's text kept in -change-store for diffing; changes past this are reported without their text")
+	fs.IntVar(&c.ChangeMaxPages, "change-max-pages", c.ChangeMaxPages, "pages kept in -change-store, least recently fetched dropped first (0 = no limit)")
+	fs.StringVar(&c.FrontierFile, "frontier-file", c.FrontierFile, "file the pending URL queue is saved to periodically and at shutdown, and resumed from at startup (disabled when empty)")
+	fs.DurationVar(&c.FrontierInterval, "frontier-interval", c.FrontierInterval, "how often the pending queue is saved to -frontier-file")
+	fs.StringVar(&c.SnapshotDir, "snapshot-dir", c.SnapshotDir, "directory for periodic crawl snapshots (disabled when empty)")
+	fs.DurationVar(&c.SnapshotInterval, "snapshot-interval", c.SnapshotInterval, "how often to write a crawl snapshot")
+	fs.StringVar(&c.ResumeSnapshot, "resume-snapshot", c.ResumeSnapshot, "snapshot file whose successfully crawled URLs are not crawled again")
+
+	// Reporting
+	fs.DurationVar(&c.StatsInterval, "stats-interval", c.StatsInterval, "how often crawl stats are reported")
+	fs.StringVar(&c.StatsFormat, "stats-format", c.StatsFormat, "stats report format: text (logged) or json (one object per line on stdout)")
+	fs.StringVar(&c.ReportFile, "report-file", c.ReportFile, "also write the final report to this file, in -stats-format")
+	fs.IntVar(&c.ReportTopHosts, "report-top-hosts", c.ReportTopHosts, "hosts listed in a report, busiest first")
+	fs.IntVar(&c.ContentStatsTop, "content-stats-top", c.ContentStatsTop, "keywords, entities and themes kept for the crawl-wide content summary in stats reports (0 = don't aggregate)")
+	return f
+}
+
+// config is the Config the parsed flags describe, with the remaining
+// arguments as its seeds
+func (f *crawlFlags) config() crawler.Config {
+	cfg := f.cfg
+	cfg.Seeds = f.Args()
+	cfg.Timeout = time.Duration(f.timeoutSec) * time.Second
+	if f.fetchWorkers > 0 {
+		cfg.Workers = f.fetchWorkers
+	}
+	return cfg
+}
+
+// parseBackfillFlags parses `crawler backfill`'s flags: the crawler's, for
+// the broker, topics, serialization and analysis profiles, and its own
+func parseBackfillFlags(args []string) (crawler.Config, crawler.BackfillOptions, error) {
+	f := newCrawlFlags("backfill", flag.ContinueOnError)
+	opts := crawler.BackfillOptions{}
+	var since, until string
+	f.Int64Var(&opts.FromOffset, "from-offset", -1, "first offset to reprocess in each partition (default: earliest)")
+	f.Int64Var(&opts.ToOffset, "to-offset", -1, "last offset to reprocess in each partition (default: the partition's end when the backfill starts)")
+	f.StringVar(&since, "since", "", "only reprocess messages produced at or after this RFC 3339 time")
+	f.StringVar(&until, "until", "", "only reprocess messages produced before this RFC 3339 time")
+	f.BoolVar(&opts.DryRun, "dry-run", false, "regenerate and log dream hints without producing anything")
+	if err := f.Parse(args); err != nil {
+		return f.cfg, opts, err
+	}
+	if f.NArg() > 0 {
+		return f.cfg, opts, fmt.Errorf("backfill takes no arguments, got %q", f.Args())
+	}
+	cfg := f.config()
+	opts.Broker = f.broker
+
+	var err error
+	if since != "" {
+		if opts.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return cfg, opts, fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+	if until != "" {
+		if opts.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return cfg, opts, fmt.Errorf("invalid -until: %w", err)
+		}
+	}
+	if opts.ToOffset >= 0 && opts.FromOffset > opts.ToOffset {
+		return cfg, opts, fmt.Errorf("-from-offset %d is after -to-offset %d", opts.FromOffset, opts.ToOffset)
+	}
+	return cfg, opts, nil
+}
+
+// listFlag is a comma-separated flag filling a []string; blank entries
+// are dropped and an empty value leaves it nil
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
 		return ""
 	}
-	return parsed.Host
+	return strings.Join(*l, ",")
 }
 
-func getFileExtension(filename string) string {
-	parts := strings.Split(filename, ".")
-	if len(parts) > 1 {
-		return parts[len(parts)-1]
+func (l *listFlag) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
 	}
-	return ""
+	return nil
 }
 
-// Robots.txt fetching (unchanged from original)
-func fetchRobotsTxt(client *http.Client, base *url.URL, hp *hostPolicies) {
-	robotsURL := base.Scheme + "://" + base.Host + "/robots.txt"
-	resp, err := client.Get(robotsURL)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return
-	}
-	defer resp.Body.Close()
+// headerFlags collects repeatable -header flags into an http.Header
+type headerFlags http.Header
 
-	data, err := robotstxt.FromResponse(resp)
-	if err != nil {
-		return
-	}
-	hp.robots = data
-
-	group := data.FindGroup("WebCrawlerThatDreams/1.0")
-	if group != nil {
-		if delay := group.CrawlDelay; delay > 0 {
-			hp.lim.SetLimit(rate.Every(delay))
+func (h headerFlags) String() string {
+	var parts []string
+	for key, values := range h {
+		for _, value := range values {
+			parts = append(parts, key+": "+value)
 		}
 	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
 }
 
-// Utility functions
-func min(a, b float64) float64 {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func max(a, b int) int {
-	if a > b {
-		return a
+func (h headerFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, ":")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("header %q must be in \"Key: Value\" form", value)
 	}
-	return b
+	http.Header(h).Add(key, strings.TrimSpace(val))
+	return nil
 }
@@ -7,10 +7,8 @@ require (
 	github.com/confluentinc/confluent-kafka-go/v2 v2.11.1
 	github.com/gorilla/mux v1.8.1
 	github.com/temoto/robotstxt v1.1.2
+	golang.org/x/net v0.43.0
 	golang.org/x/time v0.12.0
 )
 
-require (
-	github.com/andybalholm/cascadia v1.3.3 // indirect
-	golang.org/x/net v0.43.0 // indirect
-)
+require github.com/andybalholm/cascadia v1.3.3
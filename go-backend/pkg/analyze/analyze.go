@@ -0,0 +1,359 @@
+// Package analyze derives the dream hints of a crawled document. The
+// crawler runs it on every page, and its backfill command re-runs it on
+// documents already produced, so improved analysis reaches both.
+package analyze
+
+import (
+	"strings"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// Analyzers Options can switch off
+const (
+	Sentiment = "sentiment"
+	Emotions  = "emotions"
+	Themes    = "themes"
+	Motifs    = "motifs"
+	Colors    = "colors"
+	Tone      = "tone"
+)
+
+// linkDensityPenalty is the share of the complexity and surrealism scores a
+// page made entirely of link text loses; prose pages keep their full score
+const linkDensityPenalty = 0.5
+
+// Page is what a document's dream hints are derived from
+type Page struct {
+	Text        string  // clean text and title
+	WordCount   int     // of the clean text
+	Chunks      []Chunk // content chunks, in page order
+	Media       int     // media assets found on the page
+	LinkDensity float64 // share of the visible text inside links, 0 to 1
+}
+
+// Chunk is what the document sentiment reads of a content chunk
+type Chunk struct {
+	Text       string
+	Confidence float64
+}
+
+// Options tunes DreamHints. The zero value runs every analyzer.
+type Options struct {
+	Disabled   map[string]bool // analyzers not run
+	MotifWords []string        // visual motifs looked for besides the built-in ones
+	ColorWords []string        // colors looked for besides the built-in ones
+}
+
+// DreamHints analyzes p for AI dreaming
+func DreamHints(p Page, opts Options) model.DreamingHints {
+	text := strings.ToLower(p.Text)
+
+	hints := model.DreamingHints{
+		VisualCues: extractVisualCues(text),
+		AudioCues:  extractAudioCues(text),
+	}
+	if !opts.Disabled[Emotions] {
+		hints.Emotions = detectEmotions(text)
+	}
+	if !opts.Disabled[Themes] {
+		hints.Themes = detectThemes(text)
+	}
+	if !opts.Disabled[Motifs] {
+		hints.Motifs = append(extractVisualMotifs(text), matchWords(text, opts.MotifWords)...)
+	}
+	if !opts.Disabled[Tone] {
+		hints.Tone = detectTone(text)
+	}
+	if !opts.Disabled[Colors] {
+		hints.ColorPalette = append(extractColors(text), matchWords(text, opts.ColorWords)...)
+	}
+
+	// Calculate complexity and surrealism potential
+	hints.Complexity = calculateComplexity(p)
+	hints.Surrealism = calculateSurrealismPotential(p, hints)
+	hints.Abstractness = calculateAbstractness(text, hints)
+	if !opts.Disabled[Sentiment] {
+		hints.Sentiment, hints.SentimentScore = aggregateSentiment(p.Chunks)
+	}
+
+	return hints
+}
+
+func detectEmotions(text string) []string {
+	emotions := []string{}
+
+	positiveWords := []string{"amazing", "beautiful", "wonderful", "great", "love", "happy", "joy", "success"}
+	negativeWords := []string{"terrible", "awful", "hate", "sad", "fear", "anger", "pain", "failure"}
+	mysticalWords := []string{"mystery", "magic", "dream", "vision", "spirit", "soul", "ethereal", "cosmic"}
+
+	for _, word := range positiveWords {
+		if strings.Contains(text, word) {
+			emotions = append(emotions, "positive")
+			break
+		}
+	}
+
+	for _, word := range negativeWords {
+		if strings.Contains(text, word) {
+			emotions = append(emotions, "dark")
+			break
+		}
+	}
+
+	for _, word := range mysticalWords {
+		if strings.Contains(text, word) {
+			emotions = append(emotions, "mystical")
+			break
+		}
+	}
+
+	if len(emotions) == 0 {
+		emotions = append(emotions, "neutral")
+	}
+
+	return emotions
+}
+
+func detectThemes(text string) []string {
+	themes := []string{}
+
+	techWords := []string{"technology", "ai", "computer", "digital", "software", "algorithm"}
+	artWords := []string{"art", "creative", "design", "visual", "aesthetic", "beauty"}
+	scienceWords := []string{"science", "research", "discovery", "experiment", "analysis"}
+
+	for _, word := range techWords {
+		if strings.Contains(text, word) {
+			themes = append(themes, "technology")
+			break
+		}
+	}
+
+	for _, word := range artWords {
+		if strings.Contains(text, word) {
+			themes = append(themes, "creative")
+			break
+		}
+	}
+
+	for _, word := range scienceWords {
+		if strings.Contains(text, word) {
+			themes = append(themes, "scientific")
+			break
+		}
+	}
+
+	return themes
+}
+
+func extractVisualMotifs(text string) []string {
+	visualWords := []string{"light", "shadow", "color", "bright", "dark", "crystal", "liquid", "flowing", "geometric", "organic"}
+	motifs := []string{}
+
+	for _, word := range visualWords {
+		if strings.Contains(text, word) {
+			motifs = append(motifs, word)
+		}
+	}
+
+	return motifs
+}
+
+func extractVisualCues(text string) []string {
+	return []string{"ethereal lighting", "flowing forms", "crystalline structures"}
+}
+
+func extractAudioCues(text string) []string {
+	return []string{"ambient whispers", "digital harmonics", "pulsing rhythms"}
+}
+
+func extractColors(text string) []string {
+	colors := []string{}
+	colorWords := []string{"red", "blue", "green", "yellow", "purple", "orange", "pink", "white", "black", "gold", "silver"}
+
+	for _, color := range colorWords {
+		if strings.Contains(text, color) {
+			colors = append(colors, color)
+		}
+	}
+
+	return colors
+}
+
+func calculateComplexity(p Page) float64 {
+	// Based on text length, chunk diversity, and metadata richness
+	complexity := float64(p.WordCount) / 1000.0
+	complexity += float64(len(p.Chunks)) / 10.0
+	complexity += float64(p.Media) / 5.0
+
+	// Link lists make poor dream material however long they are
+	complexity *= linkDensityFactor(p.LinkDensity)
+
+	return min(1.0, complexity)
+}
+
+func calculateSurrealismPotential(p Page, hints model.DreamingHints) float64 {
+	score := 0.0
+
+	// Emotional diversity increases surrealism
+	if len(hints.Emotions) > 1 {
+		score += 0.3
+	}
+
+	// Mystical/abstract themes boost surrealism
+	for _, emotion := range hints.Emotions {
+		if emotion == "mystical" {
+			score += 0.4
+		}
+	}
+
+	// Creative/artistic content is more surreal
+	for _, theme := range hints.Themes {
+		if theme == "creative" {
+			score += 0.3
+		}
+	}
+
+	// Visual motifs indicate surreal potential
+	score += float64(len(hints.Motifs)) * 0.05
+
+	// Complex content tends to be more surreal
+	score += hints.Complexity * 0.2
+
+	return min(1.0, score*linkDensityFactor(p.LinkDensity))
+}
+
+func calculateAbstractness(text string, hints model.DreamingHints) float64 {
+	abstractWords := []string{"concept", "idea", "essence", "meaning", "philosophy", "abstract", "theory", "metaphor"}
+	score := 0.0
+
+	for _, word := range abstractWords {
+		if strings.Contains(text, word) {
+			score += 0.1
+		}
+	}
+
+	// High emotion diversity suggests abstractness
+	score += float64(len(hints.Emotions)) * 0.05
+
+	return min(1.0, score)
+}
+
+func detectTone(text string) string {
+	formalWords := []string{"therefore", "furthermore", "consequently", "analysis", "research"}
+	casualWords := []string{"really", "pretty", "quite", "basically", "actually"}
+	dramaticWords := []string{"incredible", "amazing", "shocking", "revolutionary", "breakthrough"}
+
+	formalCount := 0
+	casualCount := 0
+	dramaticCount := 0
+
+	for _, word := range formalWords {
+		if strings.Contains(text, word) {
+			formalCount++
+		}
+	}
+
+	for _, word := range casualWords {
+		if strings.Contains(text, word) {
+			casualCount++
+		}
+	}
+
+	for _, word := range dramaticWords {
+		if strings.Contains(text, word) {
+			dramaticCount++
+		}
+	}
+
+	if dramaticCount > formalCount && dramaticCount > casualCount {
+		return "dramatic"
+	} else if formalCount > casualCount {
+		return "formal"
+	} else if casualCount > 0 {
+		return "casual"
+	}
+
+	return "neutral"
+}
+
+// Sentiment lexicon shared by chunk and document level sentiment
+var (
+	positiveSentimentWords = []string{"good", "great", "excellent", "amazing", "wonderful", "love", "best"}
+	negativeSentimentWords = []string{"bad", "terrible", "awful", "hate", "worst", "horrible"}
+)
+
+// Document sentiment scores within this distance of zero count as neutral
+const neutralSentimentBand = 0.1
+
+func sentimentCounts(text string) (positiveCount, negativeCount int) {
+	text = strings.ToLower(text)
+	for _, word := range positiveSentimentWords {
+		positiveCount += strings.Count(text, word)
+	}
+	for _, word := range negativeSentimentWords {
+		negativeCount += strings.Count(text, word)
+	}
+	return positiveCount, negativeCount
+}
+
+// ChunkSentiment is positive, negative or neutral, by which sentiment words
+// text has more of
+func ChunkSentiment(text string) string {
+	positiveCount, negativeCount := sentimentCounts(text)
+
+	if positiveCount > negativeCount {
+		return "positive"
+	} else if negativeCount > positiveCount {
+		return "negative"
+	}
+
+	return "neutral"
+}
+
+// aggregateSentiment combines chunk sentiment into a document score in
+// [-1, 1]. Each chunk's polarity is weighted by its confidence and word
+// count; chunks without sentiment words don't contribute, and a document
+// with none at all is neutral with a score of 0.
+func aggregateSentiment(chunks []Chunk) (string, float64) {
+	var weighted, totalWeight float64
+	for _, chunk := range chunks {
+		positiveCount, negativeCount := sentimentCounts(chunk.Text)
+		if positiveCount+negativeCount == 0 {
+			continue
+		}
+		polarity := float64(positiveCount-negativeCount) / float64(positiveCount+negativeCount)
+		weight := chunk.Confidence * float64(len(strings.Fields(chunk.Text)))
+		weighted += weight * polarity
+		totalWeight += weight
+	}
+
+	if totalWeight == 0 {
+		return "neutral", 0
+	}
+
+	score := weighted / totalWeight
+	switch {
+	case score > neutralSentimentBand:
+		return "positive", score
+	case score < -neutralSentimentBand:
+		return "negative", score
+	}
+	return "neutral", score
+}
+
+// matchWords returns the words found in text, which is already lowercased
+func matchWords(text string, words []string) []string {
+	var found []string
+	for _, word := range words {
+		if strings.Contains(text, strings.ToLower(word)) {
+			found = append(found, word)
+		}
+	}
+	return found
+}
+
+// linkDensityFactor scales a score down as link density rises
+func linkDensityFactor(density float64) float64 {
+	return 1 - linkDensityPenalty*density
+}
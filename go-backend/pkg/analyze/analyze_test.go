@@ -0,0 +1,66 @@
+package analyze
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestAggregateSentiment verifies chunk sentiment is combined into a
+// document-level sentiment weighted by confidence and length.
+func TestAggregateSentiment(t *testing.T) {
+	chunks := []Chunk{
+		{Text: "The best dream journal", Confidence: 0.9},
+		{Text: "Keeping a journal is a wonderful habit and readers love how great it feels to remember dreams.", Confidence: 0.8},
+		{Text: "Some nights are bad and the alarm is terrible.", Confidence: 0.8},
+		{Text: "Write down the date before anything else.", Confidence: 0.8},
+	}
+
+	sentiment, score := aggregateSentiment(chunks)
+	if sentiment != "positive" {
+		t.Errorf("sentiment = %q, want %q", sentiment, "positive")
+	}
+	if score < 0.2 || score > 0.6 {
+		t.Errorf("score = %.3f, want between 0.2 and 0.6", score)
+	}
+
+	sentiment, score = aggregateSentiment([]Chunk{{Text: "Write down the date.", Confidence: 0.8}})
+	if sentiment != "neutral" || score != 0 {
+		t.Errorf("all-neutral chunks = (%q, %v), want (%q, 0)", sentiment, score, "neutral")
+	}
+}
+
+// TestDreamHintsOptions checks disabled analyzers leave their hints empty
+// and extra words are matched case-insensitively.
+func TestDreamHintsOptions(t *testing.T) {
+	page := Page{Text: "A Mysterious Dream of silver LANTERNS and crystal rain", WordCount: 9}
+
+	all := DreamHints(page, Options{})
+	if len(all.Emotions) == 0 || all.Tone == "" || !reflect.DeepEqual(all.ColorPalette, []string{"silver"}) {
+		t.Errorf("default hints = %+v, want every analyzer run", all)
+	}
+
+	hints := DreamHints(page, Options{
+		Disabled:   map[string]bool{Emotions: true, Tone: true},
+		MotifWords: []string{"Lanterns"},
+	})
+	if hints.Emotions != nil || hints.Tone != "" {
+		t.Errorf("emotions %v, tone %q; want both disabled", hints.Emotions, hints.Tone)
+	}
+	if want := []string{"crystal", "Lanterns"}; !reflect.DeepEqual(hints.Motifs, want) {
+		t.Errorf("motifs = %v, want %v", hints.Motifs, want)
+	}
+}
+
+// TestLinkDensityLowersScores checks a link-heavy page scores below the
+// same text as prose.
+func TestLinkDensityLowersScores(t *testing.T) {
+	prose := Page{Text: "a mysterious magical dream of crystal light", WordCount: 600, Media: 2}
+	links := prose
+	links.LinkDensity = 1
+
+	p, l := DreamHints(prose, Options{}), DreamHints(links, Options{})
+	if l.Complexity >= p.Complexity || l.Surrealism >= p.Surrealism {
+		t.Errorf("link-heavy complexity %.2f, surrealism %.2f; want below prose's %.2f, %.2f",
+			l.Complexity, l.Surrealism, p.Complexity, p.Surrealism)
+	}
+}
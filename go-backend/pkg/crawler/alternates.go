@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// alternatePriority ranks translations just above ordinary internal links,
+// so -max-follow keeps them
+const alternatePriority = 4
+
+// extractAlternates maps each <link rel="alternate" hreflang> language to
+// its URL, resolved like other links. The first URL for a language wins.
+func (cfg *settings) extractAlternates(doc *goquery.Document, pageURL string) map[string]string {
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	base := resolutionBase(doc, page)
+
+	var alternates map[string]string
+	doc.Find("link[hreflang][href]").Each(func(i int, s *goquery.Selection) {
+		rel, _ := s.Attr("rel")
+		if !hasRel(rel, "alternate") {
+			return
+		}
+		lang, _ := s.Attr("hreflang")
+		href, _ := s.Attr("href")
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" || strings.TrimSpace(href) == "" {
+			return
+		}
+
+		resolved, err := base.Parse(strings.TrimSpace(href))
+		if err != nil || !cfg.allowedSchemes[resolved.Scheme] {
+			return
+		}
+		if resolved.Host, err = toASCIIHost(resolved.Host); err != nil {
+			return
+		}
+		if alternates == nil {
+			alternates = make(map[string]string)
+		}
+		if _, dup := alternates[lang]; !dup {
+			alternates[lang] = resolved.String()
+		}
+	})
+	return alternates
+}
+
+// hasRel reports whether a space-separated rel attribute contains value
+func hasRel(rel, value string) bool {
+	for _, r := range strings.Fields(rel) {
+		if strings.EqualFold(r, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// alternateLinks turns alternates into links to follow, in language order
+func alternateLinks(alternates map[string]string) []ExtractedLink {
+	langs := make([]string, 0, len(alternates))
+	for lang := range alternates {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	links := make([]ExtractedLink, 0, len(langs))
+	for _, lang := range langs {
+		links = append(links, ExtractedLink{
+			URL:      alternates[lang],
+			Text:     lang,
+			Type:     "alternate",
+			Priority: alternatePriority,
+		})
+	}
+	return links
+}
@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+// TestAlternates checks hreflang alternates are recorded on the document,
+// and enqueued at alternatePriority only with -follow-alternates.
+func TestAlternates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head>
+			<link rel="alternate" hreflang="de" href="/de/">
+			<link rel="alternate" hreflang="FR" href="https://example.fr/">
+			<link rel="alternate" hreflang="x-default" href="/">
+			<link rel="stylesheet" hreflang="es" href="/style.css">
+		</head><body><p>Hello in several languages.</p></body></html>`)
+	}))
+	defer server.Close()
+
+	wantAlternates := map[string]string{
+		"de":        server.URL + "/de/",
+		"fr":        "https://example.fr/",
+		"x-default": server.URL + "/",
+	}
+
+	for _, follow := range []bool{false, true} {
+		cfg := testSettings(t, func(c *Config) { c.FollowAlternates = follow })
+		serverURL, _ := url.Parse(server.URL)
+		hostMap := map[string]*hostPolicies{
+			serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+		}
+		queue := make(chan URLWithMetadata, 10)
+		frontier := make(chan URLWithMetadata, 10)
+		out := make(chan Document, 10)
+		stats := &CrawlerStats{}
+		seen := mapSeen{}
+
+		c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.enhancedWorker(ctx, 0, queue, frontier, out)
+		}()
+
+		queue <- URLWithMetadata{URL: server.URL + "/en/"}
+		doc := <-out
+		if follow {
+			waitFor(t, "alternates enqueued", func() bool { return len(frontier) == len(wantAlternates) })
+		}
+		cancel()
+		<-done
+
+		if !reflect.DeepEqual(doc.Alternates, wantAlternates) {
+			t.Errorf("follow=%v: Alternates = %v, want %v", follow, doc.Alternates, wantAlternates)
+		}
+
+		enqueued := make(map[string]int)
+		for len(frontier) > 0 {
+			u := <-frontier
+			enqueued[u.URL] = u.Metadata.priority
+		}
+		if !follow {
+			if len(enqueued) != 0 {
+				t.Errorf("follow=false: enqueued %v", enqueued)
+			}
+			continue
+		}
+		for _, u := range wantAlternates {
+			if enqueued[u] != alternatePriority {
+				t.Errorf("follow=true: %s enqueued with priority %d, want %d", u, enqueued[u], alternatePriority)
+			}
+		}
+	}
+}
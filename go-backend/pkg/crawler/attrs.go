@@ -0,0 +1,38 @@
+package crawler
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// attrList holds the lowercase HTML attribute names copied into extracted
+// links or media, -link-attrs and -media-attrs. Both are empty by default
+// to keep output small.
+type attrList []string
+
+func newAttrList(names []string) attrList {
+	var l attrList
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			l = append(l, name)
+		}
+	}
+	return l
+}
+
+// capture returns the listed attributes s has, or nil when it has none
+func (l attrList) capture(s *goquery.Selection) map[string]string {
+	var attrs map[string]string
+	for _, name := range l {
+		value, ok := s.Attr(name)
+		if !ok {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		attrs[name] = value
+	}
+	return attrs
+}
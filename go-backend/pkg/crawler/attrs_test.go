@@ -0,0 +1,48 @@
+package crawler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestLinkAndMediaAttrs checks only allow-listed attributes are captured,
+// and none at all by default.
+func TestLinkAndMediaAttrs(t *testing.T) {
+	html := `<html><body>
+		<a href="https://example.com/next" rel="next" title="Next page" data-track="x">Next</a>
+		<a href="https://example.com/plain">Plain</a>
+		<img src="/moon.png" loading="lazy" width="640" alt="Moon">
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testSettings(t, nil)
+	links := cfg.extractLinksWithPriority(doc, "https://example.com/", 0)
+	media := cfg.extractMediaAssets(doc, "https://example.com/")
+	if links[0].Attrs != nil || media[0].Attrs != nil {
+		t.Errorf("attributes captured without an allow-list: %v, %v", links[0].Attrs, media[0].Attrs)
+	}
+
+	cfg = testSettings(t, func(c *Config) {
+		c.LinkAttrs = []string{" REL", "hreflang "}
+		c.MediaAttrs = []string{"loading", "width", "height"}
+	})
+
+	links = cfg.extractLinksWithPriority(doc, "https://example.com/", 0)
+	if want := map[string]string{"rel": "next"}; !reflect.DeepEqual(links[0].Attrs, want) {
+		t.Errorf("link attrs = %v, want %v", links[0].Attrs, want)
+	}
+	if links[1].Attrs != nil {
+		t.Errorf("link without listed attributes got %v", links[1].Attrs)
+	}
+
+	media = cfg.extractMediaAssets(doc, "https://example.com/")
+	if want := map[string]string{"loading": "lazy", "width": "640"}; !reflect.DeepEqual(media[0].Attrs, want) {
+		t.Errorf("media attrs = %v, want %v", media[0].Attrs, want)
+	}
+}
@@ -0,0 +1,187 @@
+package crawler
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Where a page's authors were found, in the order sources are trusted
+const (
+	authorFromJSONLD    = "json-ld"
+	authorFromMicrodata = "microdata"
+	authorFromMeta      = "meta"
+	authorFromByline    = "byline"
+)
+
+// bylineSelectors are tried in order for visible bylines; the first that
+// yields a name wins
+var bylineSelectors = []string{
+	"[rel='author']",
+	"[itemprop='author']",
+	".byline",
+	"[class*='byline']",
+	".author-name",
+	".author",
+	"[class*='author']",
+}
+
+// commentSections hold commenters' names, which look like bylines
+const commentSections = ".comment, .comments, #comments, .reply, .replies"
+
+// maxAuthorWords drops byline matches that are sentences rather than names
+const maxAuthorWords = 6
+
+var (
+	bylinePrefix = regexp.MustCompile(`(?i)^((written|posted|published|reported|story|words|text)\s+)?by\s*:?\s+`)
+	// bylineDate matches a date, with anything after it, trailing a name
+	bylineDate = regexp.MustCompile(`(?i)[\s,]*(\b(on|updated|published)\s+)?(\b(jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.?\s+\d{1,2}(st|nd|rd|th)?,?\s+\d{4}|\b\d{1,2}\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.?,?\s+\d{4}|\b\d{4}-\d{2}-\d{2}|\b\d{1,2}[/.]\d{1,2}[/.]\d{2,4}).*$`)
+	// bylineBreak separates a name from the date or role after it
+	bylineBreak = regexp.MustCompile(`\s*(\||•|·|\s[-–—]\s).*$`)
+	// authorSeparator splits a list of several authors
+	authorSeparator = regexp.MustCompile(`(?i)\s*(,|;|&|\band\b)\s*`)
+)
+
+// applyAuthors sets doc's authors from the page's JSON-LD, keeping those
+// applyMicrodata found otherwise, then falling back to meta tags and
+// visible bylines. It must run before extractText strips scripts and the
+// headers bylines often sit in.
+func applyAuthors(doc *Document, page *goquery.Document) {
+	if authors := jsonLDAuthors(page); len(authors) > 0 {
+		setAuthors(&doc.Metadata, authors, authorFromJSONLD)
+		return
+	}
+	if doc.Metadata.Author != "" {
+		return
+	}
+	if authors := metaAuthors(page); len(authors) > 0 {
+		setAuthors(&doc.Metadata, authors, authorFromMeta)
+		return
+	}
+	setAuthors(&doc.Metadata, bylineAuthors(page), authorFromByline)
+}
+
+func setAuthors(m *DocumentMetadata, authors []string, source string) {
+	if len(authors) == 0 {
+		return
+	}
+	m.Authors = authors
+	m.Author = strings.Join(authors, ", ")
+	m.AuthorSource = source
+}
+
+// jsonLDAuthors reads the first author in the page's JSON-LD blocks,
+// given as a name, a Person or Organization, or a list of them
+func jsonLDAuthors(page *goquery.Document) []string {
+	var authors []string
+	page.Find("script[type='application/ld+json']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		authors = jsonLDAuthorNames(findAuthor(data), nil)
+		return len(authors) == 0
+	})
+	return authors
+}
+
+func findAuthor(data interface{}) interface{} {
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if author := findAuthor(item); author != nil {
+				return author
+			}
+		}
+	case map[string]interface{}:
+		if author, ok := v["author"]; ok && author != nil {
+			return author
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findAuthor(graph)
+		}
+	}
+	return nil
+}
+
+func jsonLDAuthorNames(author interface{}, names []string) []string {
+	switch v := author.(type) {
+	case string:
+		return appendAuthors(names, v)
+	case []interface{}:
+		for _, item := range v {
+			names = jsonLDAuthorNames(item, names)
+		}
+	case map[string]interface{}:
+		if name, ok := v["name"].(string); ok {
+			return appendAuthors(names, name)
+		}
+	}
+	return names
+}
+
+// metaAuthors reads the author meta tags. article:author is often a
+// profile URL, which isn't a name.
+func metaAuthors(page *goquery.Document) []string {
+	var authors []string
+	page.Find("meta[name='author'], meta[property='article:author']").Each(func(_ int, s *goquery.Selection) {
+		content := strings.TrimSpace(s.AttrOr("content", ""))
+		if strings.HasPrefix(content, "http://") || strings.HasPrefix(content, "https://") {
+			return
+		}
+		authors = appendAuthors(authors, content)
+	})
+	return authors
+}
+
+// bylineAuthors reads visible bylines with the first of bylineSelectors
+// matching outside comment sections
+func bylineAuthors(page *goquery.Document) []string {
+	for _, selector := range bylineSelectors {
+		var authors []string
+		page.Find(selector).Each(func(_ int, s *goquery.Selection) {
+			if s.Is("meta, link") || s.Closest(commentSections).Length() > 0 {
+				return
+			}
+			authors = appendAuthors(authors, cleanByline(s.Text()))
+		})
+		if len(authors) > 0 {
+			return authors
+		}
+	}
+	return nil
+}
+
+// cleanByline reduces a byline like "By Ada Diver | May 3, 2024" to the
+// names in it
+func cleanByline(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	text = bylinePrefix.ReplaceAllString(text, "")
+	text = bylineBreak.ReplaceAllString(text, "")
+	text = bylineDate.ReplaceAllString(text, "")
+	return strings.Trim(text, " ,;:")
+}
+
+// appendAuthors adds the names in a list like "Ada Diver and Lin Sleep",
+// skipping repeats and text too long to be a name
+func appendAuthors(authors []string, list string) []string {
+	for _, name := range authorSeparator.Split(list, -1) {
+		name = strings.Trim(strings.Join(strings.Fields(name), " "), " .,;:")
+		if name == "" || len(strings.Fields(name)) > maxAuthorWords {
+			continue
+		}
+		known := false
+		for _, author := range authors {
+			if strings.EqualFold(author, name) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			authors = append(authors, name)
+		}
+	}
+	return authors
+}
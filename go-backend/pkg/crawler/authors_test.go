@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestBylineAuthor fetches a page naming its author only in a .byline
+// element, inside the header extractText strips, and checks the name is
+// found with the "By" and the date cleaned off.
+func TestBylineAuthor(t *testing.T) {
+	page := `<html><head><title>Lucid dreams</title></head><body>
+		<header><h1>Lucid dreams</h1><p class="byline">By Ada Diver | May 3, 2024</p></header>
+		<article><p>Most people have had at least one lucid dream, in which they know they are dreaming.</p></article>
+		<section class="comments"><span class="author">Night Owl</span></section></body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	doc, _, err := testSettings(t, nil).enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata.Author != "Ada Diver" || doc.Metadata.AuthorSource != authorFromByline {
+		t.Errorf("author %q from %q, want Ada Diver from a byline", doc.Metadata.Author, doc.Metadata.AuthorSource)
+	}
+}
+
+func TestAuthorSources(t *testing.T) {
+	tests := []struct {
+		name, page string
+		want       []string
+		source     string
+	}{
+		{"json-ld over meta and byline",
+			`<script type="application/ld+json">{"@graph": [{"@type": "WebPage"}, {"@type": "Article",
+				"author": [{"@type": "Person", "name": "Ada Diver"}, {"@type": "Person", "name": "Lin Sleep"}]}]}</script>
+			<meta name="author" content="Meta Author"><p class="byline">By Someone Else</p>`,
+			[]string{"Ada Diver", "Lin Sleep"}, authorFromJSONLD},
+		{"meta over byline, skipping profile URLs",
+			`<meta property="article:author" content="https://example.com/ada"><meta name="author" content="Ada Diver">
+			<p class="byline">By Someone Else</p>`,
+			[]string{"Ada Diver"}, authorFromMeta},
+		{"rel=author links before the byline text",
+			`<p class="byline">Written by <a rel="author" href="/ada">Ada Diver</a> and <a rel="author" href="/lin">Lin Sleep</a> on 2024-05-03</p>`,
+			[]string{"Ada Diver", "Lin Sleep"}, authorFromByline},
+		{"several authors in one byline",
+			`<span class="byline">By Ada Diver, Lin Sleep &amp; ada diver — Staff writers</span>`,
+			[]string{"Ada Diver", "Lin Sleep"}, authorFromByline},
+		{"a bio is not a name",
+			`<div class="author-bio">Ada has written about sleep science for more than a decade.</div>`,
+			nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := goquery.NewDocumentFromReader(strings.NewReader(tt.page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc Document
+			applyAuthors(&doc, page)
+			if !reflect.DeepEqual(doc.Metadata.Authors, tt.want) || doc.Metadata.AuthorSource != tt.source {
+				t.Errorf("authors %q from %q, want %q from %q", doc.Metadata.Authors, doc.Metadata.AuthorSource, tt.want, tt.source)
+			}
+			if doc.Metadata.Author != strings.Join(tt.want, ", ") {
+				t.Errorf("author = %q", doc.Metadata.Author)
+			}
+		})
+	}
+}
+
+func TestCleanByline(t *testing.T) {
+	for in, want := range map[string]string{
+		"By Ada Diver":                       "Ada Diver",
+		"  by:  Ada   Diver ":                "Ada Diver",
+		"Posted by Ada Diver on May 3, 2024": "Ada Diver",
+		"Ada Diver, 3 May 2024":              "Ada Diver",
+		"Ada Diver · Science editor":         "Ada Diver",
+		"Ada Diver - 05/03/2024":             "Ada Diver",
+		"Bybee Lane":                         "Bybee Lane",
+	} {
+		if got := cleanByline(in); got != want {
+			t.Errorf("cleanByline(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
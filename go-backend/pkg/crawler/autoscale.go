@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Hysteresis: the queue must stay deep, or empty, for this many checks in
+// a row before workers are added, or retired
+const (
+	scaleUpChecks   = 3
+	scaleDownChecks = 5
+)
+
+// workerBounds resolves -min-workers and -max-workers around the starting
+// worker count
+func workerBounds(workers, minWorkers, maxWorkers int) (lo, hi int, err error) {
+	lo, hi = minWorkers, maxWorkers
+	if lo == 0 {
+		lo = workers
+	}
+	if hi == 0 {
+		hi = workers
+	}
+	if lo < 1 || lo > workers || hi < workers {
+		return 0, 0, fmt.Errorf("-min-workers (%d), -workers (%d) and -max-workers (%d) must be in ascending order and at least 1", lo, workers, hi)
+	}
+	return lo, hi, nil
+}
+
+// autoscaler adds workers while the queue stays above the high-water mark
+// and retires idle ones once it has drained
+type autoscaler struct {
+	min, max  int
+	highWater int
+	depth     func() int // URLs waiting in the queue
+	spawn     func()     // starts one worker
+	// retire stops one idle worker; only workers waiting on the queue
+	// receive from it, so busy ones finish their page
+	retire chan<- struct{}
+	stats  *CrawlerStats
+
+	workers int
+	deep    int // consecutive checks above highWater
+	drained int // consecutive checks with an empty queue
+}
+
+// run checks the queue every interval until ctx is done. Callers must not
+// wait on the workers until it has returned, since it may still start one.
+func (a *autoscaler) run(ctx context.Context, interval time.Duration) {
+	a.stats.SetWorkers(a.workers)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.check(a.depth())
+		}
+	}
+}
+
+// check applies one reading of the queue depth
+func (a *autoscaler) check(depth int) {
+	switch {
+	case depth > a.highWater:
+		a.deep, a.drained = a.deep+1, 0
+	case depth == 0:
+		a.deep, a.drained = 0, a.drained+1
+	default:
+		a.deep, a.drained = 0, 0
+	}
+
+	before := a.workers
+	if a.deep >= scaleUpChecks && a.workers < a.max {
+		// Grow by a quarter at a time so a deep queue is met quickly
+		n := max(1, a.workers/4)
+		if n > a.max-a.workers {
+			n = a.max - a.workers
+		}
+		for ; n > 0; n-- {
+			a.spawn()
+			a.workers++
+		}
+		a.deep = 0
+	}
+	if a.drained >= scaleDownChecks && a.workers > a.min {
+		select {
+		case a.retire <- struct{}{}:
+			a.workers--
+		default: // every worker is busy after all
+		}
+		a.drained = 0
+	}
+	if a.workers != before {
+		log.Printf("Autoscaler: %d workers (queue depth %d)", a.workers, depth)
+		a.stats.SetWorkers(a.workers)
+	}
+}
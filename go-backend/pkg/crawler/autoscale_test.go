@@ -0,0 +1,98 @@
+package crawler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestAutoscalerDeepQueue fills a queue well past the high-water mark and
+// checks workers are added up to the maximum, then that once the queue
+// drains idle workers are retired down to the minimum.
+func TestAutoscalerDeepQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	queue := make(chan int, 1000)
+	for i := 0; i < cap(queue); i++ {
+		queue <- i
+	}
+	retire := make(chan struct{})
+	var live atomic.Int32
+	spawn := func() {
+		live.Add(1)
+		go func() {
+			defer live.Add(-1)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-retire:
+					return
+				case <-queue:
+					time.Sleep(2 * time.Millisecond)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		spawn()
+	}
+
+	stats := &CrawlerStats{}
+	scaler := &autoscaler{min: 1, max: 6, highWater: 50, depth: func() int { return len(queue) },
+		spawn: spawn, retire: retire, stats: stats, workers: 2}
+	go scaler.run(ctx, 5*time.Millisecond)
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s: %d workers in stats, %d running, %d queued",
+					what, stats.Snapshot().Workers, live.Load(), len(queue))
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor("scale up", func() bool { return stats.Snapshot().Workers == 6 && live.Load() == 6 })
+	if len(queue) == 0 {
+		t.Error("queue drained before the workers scaled up; make it deeper")
+	}
+	waitFor("scale down", func() bool { return stats.Snapshot().Workers == 1 && live.Load() == 1 })
+}
+
+// TestAutoscalerHysteresis checks a queue that dips below the high-water
+// mark, or refills, between checks doesn't change the worker count.
+func TestAutoscalerHysteresis(t *testing.T) {
+	spawned := 0
+	scaler := &autoscaler{min: 1, max: 4, highWater: 10, spawn: func() { spawned++ },
+		retire: make(chan struct{}, 1), stats: &CrawlerStats{}, workers: 2}
+
+	for _, depth := range []int{50, 50, 5, 50, 50, 0, 0, 0, 0, 3, 0, 0, 0, 0} {
+		scaler.check(depth)
+	}
+	if spawned != 0 || scaler.workers != 2 {
+		t.Fatalf("flapping queue changed workers: spawned %d, now %d", spawned, scaler.workers)
+	}
+
+	for i := 0; i < scaleUpChecks; i++ {
+		scaler.check(50)
+	}
+	if spawned != 1 || scaler.workers != 3 {
+		t.Errorf("after %d deep checks: spawned %d, now %d workers", scaleUpChecks, spawned, scaler.workers)
+	}
+}
+
+func TestWorkerBounds(t *testing.T) {
+	if lo, hi, err := workerBounds(10, 0, 0); err != nil || lo != 10 || hi != 10 {
+		t.Errorf("defaults = %d, %d, %v; want 10, 10", lo, hi, err)
+	}
+	if lo, hi, err := workerBounds(10, 2, 50); err != nil || lo != 2 || hi != 50 {
+		t.Errorf("bounds = %d, %d, %v; want 2, 50", lo, hi, err)
+	}
+	if _, _, err := workerBounds(10, 20, 0); err == nil {
+		t.Error("min workers above workers was accepted")
+	}
+}
@@ -0,0 +1,226 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// backfillTimeoutMs bounds each metadata and offset lookup
+const backfillTimeoutMs = 10000
+
+// BackfillOptions selects what Backfill reprocesses
+type BackfillOptions struct {
+	Broker     string // Kafka bootstrap servers
+	FromOffset int64  // first offset per partition, -1 = earliest
+	ToOffset   int64  // last offset per partition, -1 = end when the backfill starts
+	Since      time.Time
+	Until      time.Time
+	DryRun     bool // regenerate and log dream hints without producing anything
+}
+
+// partitionRange is the half-open range [start, end) reprocessed in a
+// partition holding offsets [low, high). sinceOffset and untilOffset are
+// the first offsets at Since and Until, or -1 when those are unset.
+func (o BackfillOptions) partitionRange(low, high, sinceOffset, untilOffset int64) (start, end int64) {
+	start, end = low, high
+	if o.FromOffset > start {
+		start = o.FromOffset
+	}
+	if sinceOffset > start {
+		start = sinceOffset
+	}
+	if o.ToOffset >= 0 && o.ToOffset+1 < end {
+		end = o.ToOffset + 1
+	}
+	if untilOffset >= 0 && untilOffset < end {
+		end = untilOffset
+	}
+	return start, end
+}
+
+// backfillSource is the part of *kafka.Consumer the backfill reads from
+type backfillSource interface {
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+}
+
+// backfillStats counts what a backfill did
+type backfillStats struct {
+	reprocessed int
+	failed      int
+}
+
+// backfillMessage decodes a raw document, regenerates its dream hints and
+// builds the raw-content message carrying it again, so the content
+// processor runs its pipeline on it as on a fresh crawl
+func (cfg *settings) backfillMessage(msg *kafka.Message) (Document, *kafka.Message, error) {
+	var doc Document
+	if err := cfg.deserializeDocument(msg.Value, &doc); err != nil {
+		return doc, nil, err
+	}
+	doc.DreamHints = cfg.generateDreamHints(doc)
+
+	out, err := cfg.topicMessage(doc, cfg.KafkaTopic)
+	if err != nil {
+		return doc, nil, err
+	}
+	out.Headers = append(out.Headers, kafka.Header{
+		Key:   "backfilled_from",
+		Value: []byte(fmt.Sprintf("%d@%d", msg.TopicPartition.Partition, msg.TopicPartition.Offset)),
+	})
+	return doc, out, nil
+}
+
+// backfill reads src until every partition in ends has been read up to its
+// end offset, passing each regenerated document to produce. A partition is
+// done once a message at or past its last offset arrives, since compaction
+// may have removed the last offset itself. A nil produce is a dry run:
+// documents are only logged.
+func (cfg *settings) backfill(src backfillSource, ends map[int32]int64, produce func(*kafka.Message)) (backfillStats, error) {
+	var stats backfillStats
+	for len(ends) > 0 {
+		msg, err := src.ReadMessage(time.Second)
+		var kerr kafka.Error
+		if errors.As(err, &kerr) && kerr.IsTimeout() {
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		partition, offset := msg.TopicPartition.Partition, int64(msg.TopicPartition.Offset)
+		end, ok := ends[partition]
+		if ok && offset+1 >= end {
+			delete(ends, partition)
+		}
+		if !ok || offset >= end {
+			continue
+		}
+
+		doc, out, err := cfg.backfillMessage(msg)
+		if err != nil {
+			log.Printf("backfill: skipping partition %d offset %d: %v", partition, offset, err)
+			stats.failed++
+			continue
+		}
+		stats.reprocessed++
+		if produce == nil {
+			log.Printf("backfill: %s surrealism %.2f, emotions %v, themes %v",
+				doc.URL, doc.DreamHints.Surrealism, doc.DreamHints.Emotions, doc.DreamHints.Themes)
+			continue
+		}
+		produce(out)
+	}
+	return stats, nil
+}
+
+// Backfill re-reads the raw topic and produces every document back to it
+// with regenerated dream hints, so improved analysis reaches
+// already-crawled pages without a recrawl. The backfilled copies land past
+// the end offsets and aren't read again. Only config's topic, serialization
+// and analysis settings are used.
+func Backfill(config Config, opts BackfillOptions) error {
+	if opts.ToOffset >= 0 && opts.FromOffset > opts.ToOffset {
+		return fmt.Errorf("-from-offset %d is after -to-offset %d", opts.FromOffset, opts.ToOffset)
+	}
+	cfg, err := newSettings(config)
+	if err != nil {
+		return err
+	}
+
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  opts.Broker,
+		"group.id":           "dream-crawler-backfill",
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+
+	topic := cfg.prefixedTopic(cfg.KafkaTopic)
+	assignments, ends, err := backfillAssignments(consumer, topic, opts)
+	if err != nil {
+		return err
+	}
+	if err := consumer.Assign(assignments); err != nil {
+		return err
+	}
+	log.Printf("Backfilling %d partitions of %s", len(ends), topic)
+
+	var produce func(*kafka.Message)
+	if !opts.DryRun {
+		producer, err := kafka.NewProducer(&kafka.ConfigMap{"bootstrap.servers": opts.Broker})
+		if err != nil {
+			return err
+		}
+		defer producer.Close()
+		go handleKafkaEvents(producer, nil)
+		defer producer.Flush(backfillTimeoutMs)
+		produce = func(msg *kafka.Message) { producer.Produce(msg, nil) }
+	}
+
+	stats, err := cfg.backfill(consumer, ends, produce)
+	log.Printf("Backfill finished: %d documents reprocessed, %d undecodable", stats.reprocessed, stats.failed)
+	return err
+}
+
+// backfillAssignments resolves opts against each partition of topic,
+// returning where to start reading and the end offset of every partition
+// with anything to reprocess
+func backfillAssignments(consumer *kafka.Consumer, topic string, opts BackfillOptions) ([]kafka.TopicPartition, map[int32]int64, error) {
+	metadata, err := consumer.GetMetadata(&topic, false, backfillTimeoutMs)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, ok := metadata.Topics[topic]
+	if !ok || info.Error.Code() != kafka.ErrNoError {
+		return nil, nil, fmt.Errorf("topic %s not found: %v", topic, info.Error)
+	}
+
+	var assignments []kafka.TopicPartition
+	ends := make(map[int32]int64)
+	for _, p := range info.Partitions {
+		low, high, err := consumer.QueryWatermarkOffsets(topic, p.ID, backfillTimeoutMs)
+		if err != nil {
+			return nil, nil, err
+		}
+		sinceOffset, untilOffset := int64(-1), int64(-1)
+		if !opts.Since.IsZero() {
+			if sinceOffset, err = offsetForTime(consumer, topic, p.ID, opts.Since, high); err != nil {
+				return nil, nil, err
+			}
+		}
+		if !opts.Until.IsZero() {
+			if untilOffset, err = offsetForTime(consumer, topic, p.ID, opts.Until, high); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		start, end := opts.partitionRange(low, high, sinceOffset, untilOffset)
+		if start >= end {
+			continue
+		}
+		assignments = append(assignments, kafka.TopicPartition{Topic: &topic, Partition: p.ID, Offset: kafka.Offset(start)})
+		ends[p.ID] = end
+	}
+	return assignments, ends, nil
+}
+
+// offsetForTime is the first offset in the partition produced at or after
+// t, or high when there is none
+func offsetForTime(consumer *kafka.Consumer, topic string, partition int32, t time.Time, high int64) (int64, error) {
+	found, err := consumer.OffsetsForTimes([]kafka.TopicPartition{
+		{Topic: &topic, Partition: partition, Offset: kafka.Offset(t.UnixMilli())},
+	}, backfillTimeoutMs)
+	if err != nil {
+		return 0, err
+	}
+	if len(found) == 0 || found[0].Offset < 0 {
+		return high, nil
+	}
+	return int64(found[0].Offset), nil
+}
@@ -0,0 +1,154 @@
+package crawler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// fakeSource replays messages in order, then times out like an idle topic
+type fakeSource struct {
+	messages []*kafka.Message
+}
+
+func (s *fakeSource) ReadMessage(time.Duration) (*kafka.Message, error) {
+	if len(s.messages) == 0 {
+		return nil, kafka.NewError(kafka.ErrTimedOut, "timed out", false)
+	}
+	msg := s.messages[0]
+	s.messages = s.messages[1:]
+	return msg, nil
+}
+
+// rawMessage is doc as the crawler produced it to the raw topic, at offset
+func rawMessage(t *testing.T, doc Document, offset int64) *kafka.Message {
+	t.Helper()
+	cfg := testSettings(t, nil)
+	msg, err := cfg.topicMessage(doc, cfg.KafkaTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.TopicPartition.Partition = 0
+	msg.TopicPartition.Offset = kafka.Offset(offset)
+	return msg
+}
+
+// TestBackfill feeds a raw document with stale hints through the backfill
+// consumer and checks the regenerated hints go back to the raw topic for
+// the content processor, that an undecodable message is skipped, and that
+// reading stops at the end offset.
+func TestBackfill(t *testing.T) {
+	doc := Document{
+		ID:        model.DocumentIDFor("https://example.com/dream"),
+		URL:       "https://example.com/dream",
+		Title:     "A surreal dream",
+		CleanText: "a mysterious, magical dream of shadow and light over a moonlit ocean, strange and wonderful",
+	}
+	stale := doc
+	stale.DreamHints = DreamingHints{Tone: "stale"}
+	past := doc
+	past.URL = "https://example.com/after-the-end"
+
+	src := &fakeSource{messages: []*kafka.Message{
+		rawMessage(t, stale, 0),
+		{TopicPartition: kafka.TopicPartition{Partition: 0, Offset: 1}, Value: []byte("not a document")},
+		rawMessage(t, past, 2),
+	}}
+	cfg := testSettings(t, nil)
+	var produced []*kafka.Message
+	stats, err := cfg.backfill(src, map[int32]int64{0: 2}, func(msg *kafka.Message) {
+		produced = append(produced, msg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.reprocessed != 1 || stats.failed != 1 {
+		t.Errorf("stats = %+v, want 1 reprocessed and 1 failed", stats)
+	}
+	if len(produced) != 1 {
+		t.Fatalf("produced %d messages, want 1", len(produced))
+	}
+
+	msg := produced[0]
+	if got := *msg.TopicPartition.Topic; got != cfg.KafkaTopic {
+		t.Errorf("topic = %q, want %q", got, cfg.KafkaTopic)
+	}
+	if len(msg.Headers) == 0 || msg.Headers[len(msg.Headers)-1].Key != "backfilled_from" ||
+		string(msg.Headers[len(msg.Headers)-1].Value) != "0@0" {
+		t.Errorf("headers = %v, want backfilled_from 0@0 last", msg.Headers)
+	}
+	var got Document
+	if err := json.Unmarshal(msg.Value, &got); err != nil {
+		t.Fatal(err)
+	}
+	gotHints, _ := json.Marshal(got.DreamHints)
+	wantHints, _ := json.Marshal(cfg.generateDreamHints(doc))
+	if string(gotHints) != string(wantHints) {
+		t.Errorf("dream hints = %s, want regenerated %s", gotHints, wantHints)
+	}
+	if got.URL != doc.URL || got.CleanText != doc.CleanText {
+		t.Errorf("document changed beyond its hints: %+v", got)
+	}
+	if len(src.messages) != 1 {
+		t.Errorf("read past the end offset, %d messages left", len(src.messages))
+	}
+
+	// A dry run reprocesses without producing
+	src = &fakeSource{messages: []*kafka.Message{rawMessage(t, stale, 0)}}
+	if stats, err := cfg.backfill(src, map[int32]int64{0: 1}, nil); err != nil || stats.reprocessed != 1 {
+		t.Errorf("dry run: stats %+v, err %v", stats, err)
+	}
+}
+
+// TestBackfillCompactedEnd checks a partition whose last offset was
+// compacted away is finished by the first message past it, such as an
+// earlier backfill's copy, rather than read forever.
+func TestBackfillCompactedEnd(t *testing.T) {
+	doc := Document{ID: model.DocumentIDFor("https://example.com/"), URL: "https://example.com/"}
+	src := &fakeSource{messages: []*kafka.Message{
+		rawMessage(t, doc, 0),
+		rawMessage(t, doc, 3), // offsets 1 and 2 compacted away
+		rawMessage(t, doc, 4),
+	}}
+
+	done := make(chan backfillStats)
+	go func() {
+		stats, _ := testSettings(t, nil).backfill(src, map[int32]int64{0: 3}, func(*kafka.Message) {})
+		done <- stats
+	}()
+	select {
+	case stats := <-done:
+		if stats.reprocessed != 1 {
+			t.Errorf("reprocessed %d documents, want 1", stats.reprocessed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("backfill didn't finish the partition past its end offset")
+	}
+	if len(src.messages) != 1 {
+		t.Errorf("%d messages left, want the one after the end", len(src.messages))
+	}
+}
+
+func TestBackfillPartitionRange(t *testing.T) {
+	tests := []struct {
+		name                     string
+		opts                     BackfillOptions
+		sinceOffset, untilOffset int64
+		wantStart, wantEnd       int64
+	}{
+		{"everything", BackfillOptions{FromOffset: -1, ToOffset: -1}, -1, -1, 10, 50},
+		{"offset range", BackfillOptions{FromOffset: 20, ToOffset: 29}, -1, -1, 20, 30},
+		{"before retention", BackfillOptions{FromOffset: 0, ToOffset: 5}, -1, -1, 10, 6},
+		{"time range", BackfillOptions{FromOffset: -1, ToOffset: -1}, 15, 40, 15, 40},
+		{"tighter of both", BackfillOptions{FromOffset: 20, ToOffset: 45}, 15, 40, 20, 40},
+	}
+	for _, tt := range tests {
+		start, end := tt.opts.partitionRange(10, 50, tt.sinceOffset, tt.untilOffset)
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("%s: range [%d, %d), want [%d, %d)", tt.name, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package crawler
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// boilerplatePriorityPenalty is taken off links found on boilerplate pages
+const boilerplatePriorityPenalty = 2
+
+// boilerplateDetector flags templated pages (tag listings, pagination)
+// that share a title with many others and carry little text of their own.
+// Title counts live in a fixed-size LRU so memory stays bounded however
+// many distinct titles a crawl sees.
+type boilerplateDetector struct {
+	mu       sync.Mutex
+	repeats  int
+	maxWords int
+	capacity int
+	order    *list.List // most recently seen title at the front
+	counts   map[string]*list.Element
+}
+
+type titleCount struct {
+	title string
+	count int
+}
+
+func newBoilerplateDetector(repeats, maxWords, capacity int) *boilerplateDetector {
+	return &boilerplateDetector{
+		repeats:  repeats,
+		maxWords: maxWords,
+		capacity: capacity,
+		order:    list.New(),
+		counts:   make(map[string]*list.Element),
+	}
+}
+
+// observe counts doc's title and reports whether doc is boilerplate. A nil
+// detector flags nothing.
+func (d *boilerplateDetector) observe(doc Document) bool {
+	if d == nil || d.repeats <= 0 {
+		return false
+	}
+	title := strings.ToLower(strings.TrimSpace(doc.Title))
+	if title == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var tc *titleCount
+	if e, ok := d.counts[title]; ok {
+		d.order.MoveToFront(e)
+		tc = e.Value.(*titleCount)
+	} else {
+		tc = &titleCount{title: title}
+		d.counts[title] = d.order.PushFront(tc)
+		if d.order.Len() > d.capacity {
+			oldest := d.order.Back()
+			d.order.Remove(oldest)
+			delete(d.counts, oldest.Value.(*titleCount).title)
+		}
+	}
+	tc.count++
+
+	return tc.count >= d.repeats && doc.Metadata.WordCount <= d.maxWords
+}
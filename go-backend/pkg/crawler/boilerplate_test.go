@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// TestBoilerplateDetection crawls several thin pages sharing a title and
+// checks they are flagged once the title has repeated enough, while a page
+// with its own title is not.
+func TestBoilerplateDetection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><head><title>Home</title></head><body>
+				<a href="/tag/1">Tag 1</a><a href="/tag/2">Tag 2</a><a href="/tag/3">Tag 3</a>
+				<a href="/tag/4">Tag 4</a><a href="/unique">Unique</a></body></html>`)
+		case "/unique":
+			fmt.Fprint(w, `<html><head><title>A page of its own</title></head><body><p>Short but original.</p></body></html>`)
+		default:
+			fmt.Fprintf(w, `<html><head><title>Tag archive | Example</title></head><body><p>Posts tagged %s</p></body></html>`, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+	}
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+
+	c := &Crawler{client: server.Client(), cfg: testSettings(t, nil), hostMap: hostMap, seen: &seen, stats: stats,
+		boilerplate: newBoilerplateDetector(3, 50, 100)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+
+	flagged := make(map[string]bool)
+	for len(flagged) < 6 {
+		select {
+		case doc := <-out:
+			flagged[doc.URL] = doc.Metadata.Boilerplate
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got %d of 6 documents", len(flagged))
+		}
+	}
+
+	want := map[string]bool{
+		"/":       false,
+		"/tag/1":  false,
+		"/tag/2":  false,
+		"/tag/3":  true, // third sighting of the title reaches the threshold
+		"/tag/4":  true,
+		"/unique": false,
+	}
+	for path, wantFlag := range want {
+		if got := flagged[server.URL+path]; got != wantFlag {
+			t.Errorf("%s: Boilerplate = %v, want %v", path, got, wantFlag)
+		}
+	}
+}
+
+// TestBoilerplateDetectorBounded checks that title counts are capped and that
+// long pages are never flagged.
+func TestBoilerplateDetectorBounded(t *testing.T) {
+	d := newBoilerplateDetector(2, 10, 2)
+	page := func(title string, words int) Document {
+		doc := Document{Title: title}
+		doc.Metadata.WordCount = words
+		return doc
+	}
+
+	d.observe(page("a", 1))
+	d.observe(page("b", 1))
+	d.observe(page("c", 1)) // evicts "a"
+	if len(d.counts) != 2 {
+		t.Errorf("tracking %d titles, want 2", len(d.counts))
+	}
+	if d.observe(page("a", 1)) {
+		t.Error("evicted title flagged on its first sighting back")
+	}
+	if d.observe(page("c", 500)) {
+		t.Error("long page with a repeated title flagged as boilerplate")
+	}
+	if !d.observe(page("C ", 1)) {
+		t.Error("thin page with a repeated title not flagged")
+	}
+}
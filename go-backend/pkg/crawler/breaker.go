@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Circuit breaker states, as shown in per-host stats
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// breakerPolicy is how a host's breaker responds to failures, from
+// -backoff-after, -backoff-factor, -breaker-after and -breaker-cooldown.
+// The zero value never backs off or opens.
+type breakerPolicy struct {
+	backoffAfter  int
+	backoffFactor float64
+	openAfter     int // 0 = never
+	cooldown      time.Duration
+}
+
+func (cfg *settings) breakerPolicy() breakerPolicy {
+	return breakerPolicy{
+		backoffAfter:  cfg.BackoffAfter,
+		backoffFactor: cfg.BackoffFactor,
+		openAfter:     cfg.BreakerAfter,
+		cooldown:      cfg.BreakerCooldown,
+	}
+}
+
+// hostBreaker tracks consecutive failures for one host under its policy.
+// The zero value is a closed breaker.
+type hostBreaker struct {
+	policy breakerPolicy
+
+	mu          sync.Mutex
+	state       string
+	failures    int
+	openedAt    time.Time
+	probing     bool       // half-open probe in flight
+	normalLimit rate.Limit // rate before any backoff, 0 until first backoff
+}
+
+// allow reports whether a fetch may go to the host now. Once an open
+// breaker's cooldown has passed it lets exactly one probe through, and
+// probe reports whether this call was it. The caller must end a probe with
+// success, failure or release.
+func (b *hostBreaker) allow(now time.Time) (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.policy.cooldown {
+			return false, false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true, true
+	case breakerHalfOpen:
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	}
+	return true, false
+}
+
+// release gives up a probe that ended without a result, such as one
+// deferred by the rate limiter, so the next URL for the host can probe
+func (b *hostBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// failure records a failed fetch, slowing lim once failures pass
+// -backoff-after and opening the breaker at -breaker-after or when a
+// half-open probe fails. It returns the resulting state.
+func (b *hostBreaker) failure(now time.Time, lim *rate.Limiter) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.failures >= b.policy.backoffAfter && b.policy.backoffFactor > 1 {
+		if b.normalLimit == 0 {
+			b.normalLimit = lim.Limit()
+		}
+		lim.SetLimit(lim.Limit() / rate.Limit(b.policy.backoffFactor))
+	}
+
+	if b.state == breakerHalfOpen || (b.policy.openAfter > 0 && b.failures >= b.policy.openAfter) {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+	b.probing = false
+	return b.stateName()
+}
+
+// success resets the failure count, restores lim's normal rate and closes
+// the breaker. It reports whether there were failures to recover from.
+func (b *hostBreaker) success(lim *rate.Limiter) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	recovered := b.failures > 0 || b.state != ""
+	b.failures = 0
+	if b.normalLimit != 0 {
+		lim.SetLimit(b.normalLimit)
+		b.normalLimit = 0
+	}
+	b.state = breakerClosed
+	b.probing = false
+	return recovered
+}
+
+func (b *hostBreaker) stateName() string {
+	if b.state == "" {
+		return breakerClosed
+	}
+	return b.state
+}
@@ -0,0 +1,125 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// TestBreakerOpensOnFailingHost crawls a host that always returns 500 and
+// checks that it stops being fetched once the breaker opens.
+func TestBreakerOpensOnFailingHost(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) {
+		c.BackoffAfter = 100
+		c.BreakerAfter = 3
+		c.BreakerCooldown = time.Minute
+	})
+
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1), breaker: hostBreaker{policy: cfg.breakerPolicy()}},
+	}
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+
+	c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for i := 1; i <= 6; i++ {
+		urlQueue <- URLWithMetadata{URL: fmt.Sprintf("%s/page/%d", server.URL, i)}
+	}
+	waitFor(t, "three skips", func() bool { return stats.Snapshot().SkippedBreaker == 3 })
+
+	if got := hits.Load(); got != 3 {
+		t.Errorf("server hit %d times, want 3", got)
+	}
+	if got := stats.Snapshot().Hosts[serverURL.Host].Breaker; got != breakerOpen {
+		t.Errorf("host breaker = %q, want %q", got, breakerOpen)
+	}
+}
+
+// TestBreakerHalfOpenRecovery checks the cooldown probe, re-opening on a
+// failed probe and closing again on success, and that backoff is undone.
+func TestBreakerHalfOpenRecovery(t *testing.T) {
+	lim := rate.NewLimiter(8, 1)
+	b := hostBreaker{policy: breakerPolicy{backoffAfter: 1, backoffFactor: 2, openAfter: 2, cooldown: time.Minute}}
+	now := time.Now()
+
+	b.failure(now, lim)
+	if got := lim.Limit(); got != 4 {
+		t.Errorf("limit after first failure = %v, want 4", got)
+	}
+	if state := b.failure(now, lim); state != breakerOpen {
+		t.Fatalf("state after second failure = %q, want %q", state, breakerOpen)
+	}
+	if ok, _ := b.allow(now.Add(time.Second)); ok {
+		t.Error("open breaker allowed a fetch during cooldown")
+	}
+
+	probeAt := now.Add(2 * time.Minute)
+	if ok, probe := b.allow(probeAt); !ok || !probe {
+		t.Fatalf("allow after cooldown = (%v, %v), want a probe", ok, probe)
+	}
+	if ok, _ := b.allow(probeAt); ok {
+		t.Error("half-open breaker allowed a second concurrent probe")
+	}
+	if state := b.failure(probeAt, lim); state != breakerOpen {
+		t.Errorf("state after failed probe = %q, want %q", state, breakerOpen)
+	}
+
+	if ok, _ := b.allow(probeAt.Add(2 * time.Minute)); !ok {
+		t.Fatal("breaker didn't allow a second probe")
+	}
+	if !b.success(lim) {
+		t.Error("success after failures reported no recovery")
+	}
+	if got := lim.Limit(); got != 8 {
+		t.Errorf("limit after recovery = %v, want 8", got)
+	}
+	if ok, probe := b.allow(probeAt.Add(2 * time.Minute)); !ok || probe {
+		t.Errorf("allow on a closed breaker = (%v, %v), want a plain fetch", ok, probe)
+	}
+}
+
+// TestBreakerReleasedProbe checks that a probe given up without a result
+// lets the next URL probe instead of leaving the host skipped.
+func TestBreakerReleasedProbe(t *testing.T) {
+	lim := rate.NewLimiter(8, 1)
+	b := hostBreaker{policy: breakerPolicy{openAfter: 1, cooldown: time.Minute}}
+	now := time.Now()
+	b.failure(now, lim)
+
+	probeAt := now.Add(2 * time.Minute)
+	if ok, _ := b.allow(probeAt); !ok {
+		t.Fatal("breaker didn't allow a probe after cooldown")
+	}
+	b.release()
+	if ok, probe := b.allow(probeAt); !ok || !probe {
+		t.Errorf("allow after a released probe = (%v, %v), want a new probe", ok, probe)
+	}
+}
@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"context"
+	"time"
+)
+
+// ShutdownReason records which limit ended the crawl. Whichever of
+// MaxRuntime, MaxBytes, MaxPages and IdleTimeout is reached first ends it.
+type ShutdownReason string
+
+const (
+	ShutdownTime  ShutdownReason = "time"
+	ShutdownBytes ShutdownReason = "bytes"
+	ShutdownPages ShutdownReason = "pages"
+	ShutdownIdle  ShutdownReason = "idle"
+
+	// ShutdownCancelled means the caller's context ended the crawl
+	ShutdownCancelled ShutdownReason = "cancelled"
+)
+
+// budgetPollInterval is how often waitForBudget checks the stats
+const budgetPollInterval = 100 * time.Millisecond
+
+// budgetExceeded returns the byte or page limit the crawl has hit, or ""
+// while there is budget left
+func (cfg *settings) budgetExceeded(s *CrawlerStats) ShutdownReason {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cfg.MaxBytes > 0 && s.BytesProcessed > cfg.MaxBytes {
+		return ShutdownBytes
+	}
+	if cfg.MaxPages > 0 && s.PagesProcessed >= cfg.MaxPages {
+		return ShutdownPages
+	}
+	return ""
+}
+
+// progress sums every per-URL outcome, so an unchanged value means the
+// crawl has gone idle
+func (s *CrawlerStats) progress() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.PagesProcessed + s.Errors + s.Retries + s.SkippedDepth + s.SkippedRobots +
+		s.SkippedScope + s.SkippedSeen + s.SkippedQueueFull + s.SkippedBreaker + s.SkippedIrrelevant +
+		s.SkippedHostLimit + s.SkippedUnmodified
+}
+
+// waitForBudget blocks until the runtime, byte, page or idle limit is
+// reached and returns which one it was. It returns "" if ctx ends first.
+func (cfg *settings) waitForBudget(ctx context.Context, stats *CrawlerStats, poll time.Duration) ShutdownReason {
+	var deadline <-chan time.Time
+	if cfg.MaxRuntime > 0 {
+		timer := time.NewTimer(cfg.MaxRuntime)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	lastProgress, lastChange := stats.progress(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ""
+		case <-deadline:
+			return ShutdownTime
+		case now := <-ticker.C:
+			if reason := cfg.budgetExceeded(stats); reason != "" {
+				return reason
+			}
+			if cfg.IdleTimeout > 0 {
+				if p := stats.progress(); p != lastProgress {
+					lastProgress, lastChange = p, now
+				} else if now.Sub(lastChange) >= cfg.IdleTimeout {
+					return ShutdownIdle
+				}
+			}
+		}
+	}
+}
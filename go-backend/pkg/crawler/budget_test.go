@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// TestByteBudgetStopsCrawl runs a worker against a site with more pages than
+// a tiny -max-bytes allows and checks that the crawl stops after the first.
+func TestByteBudgetStopsCrawl(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) {
+		c.MaxBytes = 10
+		c.MaxRuntime = 0
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><p>Page %s is well over ten bytes long.</p>
+			<a href="/a">A</a><a href="/b">B</a><a href="/c">C</a></body></html>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+	}
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+
+	c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+	}()
+
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+
+	budgetCtx, budgetCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer budgetCancel()
+	if reason := cfg.waitForBudget(budgetCtx, stats, 10*time.Millisecond); reason != ShutdownBytes {
+		t.Fatalf("waitForBudget = %q, want %q", reason, ShutdownBytes)
+	}
+
+	select {
+	case <-workerDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker kept dequeuing after the byte budget was exceeded")
+	}
+	if got := pagesProcessed(stats); got != 1 {
+		t.Errorf("PagesProcessed = %d, want 1", got)
+	}
+}
+
+// TestWaitForBudgetIdle checks that a crawl with no activity ends as idle.
+func TestWaitForBudgetIdle(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) {
+		c.IdleTimeout = 50 * time.Millisecond
+		c.MaxRuntime = 5 * time.Second
+	})
+	if reason := cfg.waitForBudget(context.Background(), &CrawlerStats{}, 10*time.Millisecond); reason != ShutdownIdle {
+		t.Errorf("waitForBudget = %q, want %q", reason, ShutdownIdle)
+	}
+}
+
+// TestWaitForBudgetRuntime checks that -max-runtime ends the crawl.
+func TestWaitForBudgetRuntime(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.MaxRuntime = 30 * time.Millisecond })
+	if reason := cfg.waitForBudget(context.Background(), &CrawlerStats{}, 10*time.Millisecond); reason != ShutdownTime {
+		t.Errorf("waitForBudget = %q, want %q", reason, ShutdownTime)
+	}
+}
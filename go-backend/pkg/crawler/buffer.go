@@ -0,0 +1,181 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// spillBuffer decouples the producer from the crawl: it accepts documents
+// without blocking, keeping up to capacity in memory and spilling the rest
+// to an NDJSON temp file that is drained back once the producer catches up.
+type spillBuffer struct {
+	capacity int
+	dir      string
+
+	mu     sync.Mutex
+	mem    []Document
+	notify chan struct{}
+	closed bool
+
+	// Spill file state; pending counts records written but not yet read
+	spillPath string
+	spillW    *os.File
+	spillR    *os.File
+	reader    *bufio.Reader
+	pending   int
+}
+
+// spillRecord is one line of the spill file. RawHTML is left out of the
+// document's JSON, so it's carried next to it.
+type spillRecord struct {
+	Doc     Document `json:"doc"`
+	RawHTML string   `json:"raw_html,omitempty"`
+}
+
+func newSpillBuffer(capacity int, dir string) *spillBuffer {
+	return &spillBuffer{
+		capacity: capacity,
+		dir:      dir,
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+// run moves documents from in to out until in is closed and everything
+// buffered, in memory or on disk, has been delivered; then it closes out.
+func (b *spillBuffer) run(in <-chan Document, out chan<- Document) {
+	go func() {
+		for doc := range in {
+			if err := b.push(doc); err != nil {
+				log.Printf("Output buffer: dropping %s: %v", doc.URL, err)
+			}
+		}
+		b.close()
+	}()
+
+	defer close(out)
+	for {
+		doc, ok := b.pop()
+		if !ok {
+			return
+		}
+		out <- doc
+	}
+}
+
+func (b *spillBuffer) push(doc Document) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.signal()
+
+	// Once spilling starts, keep appending to disk so order is preserved
+	if len(b.mem) < b.capacity && b.pending == 0 {
+		b.mem = append(b.mem, doc)
+		return nil
+	}
+	return b.spill(doc)
+}
+
+func (b *spillBuffer) spill(doc Document) error {
+	if b.spillW == nil {
+		f, err := os.CreateTemp(b.dir, "dream-crawler-spill-*.ndjson")
+		if err != nil {
+			return err
+		}
+		r, err := os.Open(f.Name())
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return err
+		}
+		b.spillPath, b.spillW, b.spillR = f.Name(), f, r
+		b.reader = bufio.NewReader(r)
+	}
+
+	line, err := json.Marshal(spillRecord{Doc: doc, RawHTML: doc.RawHTML})
+	if err != nil {
+		return err
+	}
+	if _, err := b.spillW.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	b.pending++
+	return nil
+}
+
+// pop blocks until a document is available, returning false once the buffer
+// is closed and empty.
+func (b *spillBuffer) pop() (Document, bool) {
+	for {
+		b.mu.Lock()
+		if len(b.mem) > 0 {
+			doc := b.mem[0]
+			b.mem[0] = Document{}
+			b.mem = b.mem[1:]
+			b.mu.Unlock()
+			return doc, true
+		}
+		if b.pending > 0 {
+			doc, err := b.unspill()
+			b.mu.Unlock()
+			if err != nil {
+				log.Printf("Output buffer: lost spilled document: %v", err)
+				continue
+			}
+			return doc, true
+		}
+		closed := b.closed
+		b.mu.Unlock()
+
+		if closed {
+			return Document{}, false
+		}
+		<-b.notify
+	}
+}
+
+func (b *spillBuffer) unspill() (Document, error) {
+	line, err := b.reader.ReadBytes('\n')
+	b.pending--
+	if b.pending == 0 {
+		b.removeSpill()
+	}
+	if err != nil {
+		return Document{}, fmt.Errorf("reading spill file: %w", err)
+	}
+
+	var rec spillRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return Document{}, fmt.Errorf("decoding spill file: %w", err)
+	}
+	rec.Doc.RawHTML = rec.RawHTML
+	return rec.Doc, nil
+}
+
+// removeSpill deletes a fully drained spill file; the next overflow starts a
+// fresh one.
+func (b *spillBuffer) removeSpill() {
+	b.spillW.Close()
+	b.spillR.Close()
+	if err := os.Remove(b.spillPath); err != nil {
+		log.Printf("Output buffer: removing %s: %v", b.spillPath, err)
+	}
+	b.spillPath, b.spillW, b.spillR, b.reader = "", nil, nil, nil
+}
+
+func (b *spillBuffer) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.signal()
+}
+
+func (b *spillBuffer) signal() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
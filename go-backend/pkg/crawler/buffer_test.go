@@ -0,0 +1,64 @@
+package crawler
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestSpillBufferSlowPublisher verifies that documents overflowing the
+// in-memory buffer are spilled to disk, delivered in order once the
+// publisher catches up with their raw HTML intact, and that the spill file
+// is removed afterwards.
+func TestSpillBufferSlowPublisher(t *testing.T) {
+	dir := t.TempDir()
+	in := make(chan Document)
+	out := make(chan Document)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		newSpillBuffer(5, dir).run(in, out)
+	}()
+
+	const total = 50
+	for i := 0; i < total; i++ {
+		in <- Document{URL: fmt.Sprintf("https://example.com/%d", i), Title: "Doc", RawHTML: fmt.Sprintf("<p>%d</p>", i)}
+	}
+	close(in)
+
+	// Nothing has been published yet, so 45 documents must be on disk
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() returned an error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one spill file while the publisher is stalled, got %d", len(entries))
+	}
+
+	var got []Document
+	for doc := range out {
+		time.Sleep(time.Millisecond) // slow publisher
+		got = append(got, doc)
+	}
+	<-done
+
+	if len(got) != total {
+		t.Fatalf("published %d documents, want %d", len(got), total)
+	}
+	for i, doc := range got {
+		want, wantHTML := fmt.Sprintf("https://example.com/%d", i), fmt.Sprintf("<p>%d</p>", i)
+		if doc.URL != want || doc.Title != "Doc" || doc.RawHTML != wantHTML {
+			t.Errorf("document %d = {%q %q %q}, want {%q %q %q}", i, doc.URL, doc.Title, doc.RawHTML, want, "Doc", wantHTML)
+		}
+	}
+
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() returned an error: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected spill files to be cleaned up, found %d", len(entries))
+	}
+}
@@ -0,0 +1,137 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer kept for reuse, so one outsized
+// page doesn't pin its memory for the rest of the crawl
+const maxPooledBuffer = 4 << 20
+
+// Pools for the fetch path. Nothing may keep a reference into a pooled
+// buffer once it is put back: the parser and extractors copy what they
+// keep into strings, so buffers are returned as soon as a page is built.
+var (
+	bodyBuffers   = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	limitedBodies = sync.Pool{New: func() any { return new(limitedBody) }}
+	zlibReaders   sync.Pool // io.ReadClosers implementing zlib.Resetter
+	gzipReaders   sync.Pool // *gzip.Readers
+)
+
+// getBuffer returns an empty buffer, pooled under -pool-buffers
+func (cfg *settings) getBuffer() *bytes.Buffer {
+	if !cfg.PoolBuffers {
+		return new(bytes.Buffer)
+	}
+	b := bodyBuffers.Get().(*bytes.Buffer)
+	b.Reset()
+	return b
+}
+
+// putBuffer hands b back for reuse; b must not be used afterwards
+func (cfg *settings) putBuffer(b *bytes.Buffer) {
+	if cfg.PoolBuffers && b.Cap() <= maxPooledBuffer {
+		bodyBuffers.Put(b)
+	}
+}
+
+// getLimitedBody returns a limitedBody reading r, pooled under -pool-buffers
+func (cfg *settings) getLimitedBody(r io.Reader, limit int64) *limitedBody {
+	if !cfg.PoolBuffers {
+		return &limitedBody{r: r, limit: limit}
+	}
+	b := limitedBodies.Get().(*limitedBody)
+	*b = limitedBody{r: r, limit: limit}
+	return b
+}
+
+func (cfg *settings) putLimitedBody(b *limitedBody) {
+	if cfg.PoolBuffers {
+		*b = limitedBody{} // drop the response body
+		limitedBodies.Put(b)
+	}
+}
+
+// getZlibReader returns a zlib reader over r, reusing a pooled one's
+// window and tables under -pool-buffers
+func (cfg *settings) getZlibReader(r io.Reader) (io.ReadCloser, error) {
+	if cfg.PoolBuffers {
+		if zr, ok := zlibReaders.Get().(io.ReadCloser); ok {
+			if err := zr.(zlib.Resetter).Reset(r, nil); err != nil {
+				return nil, err // left out of the pool in its error state
+			}
+			return zr, nil
+		}
+	}
+	return zlib.NewReader(r)
+}
+
+func (cfg *settings) putZlibReader(zr io.ReadCloser) {
+	if cfg.PoolBuffers {
+		zlibReaders.Put(zr)
+	}
+}
+
+// getGzipReader returns a gzip reader over r, pooled under -pool-buffers
+func (cfg *settings) getGzipReader(r io.Reader) (*gzip.Reader, error) {
+	if cfg.PoolBuffers {
+		if zr, ok := gzipReaders.Get().(*gzip.Reader); ok {
+			if err := zr.Reset(r); err != nil {
+				return nil, err
+			}
+			return zr, nil
+		}
+	}
+	return gzip.NewReader(r)
+}
+
+func (cfg *settings) putGzipReader(zr *gzip.Reader) {
+	if cfg.PoolBuffers {
+		gzipReaders.Put(zr)
+	}
+}
+
+// inflate decompresses a zlib stream into buf, replacing its contents, and
+// fails with errDecompressionBomb past limit bytes (0 = unlimited). On
+// error buf holds whatever was decoded before it.
+func (cfg *settings) inflate(buf *bytes.Buffer, compressed []byte, limit int64) error {
+	buf.Reset()
+	zr, err := cfg.getZlibReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	defer cfg.putZlibReader(zr)
+	return readDecompressed(buf, zr, limit)
+}
+
+// gunzip decompresses a gzip stream into buf as inflate does
+func (cfg *settings) gunzip(buf *bytes.Buffer, compressed []byte, limit int64) error {
+	buf.Reset()
+	zr, err := cfg.getGzipReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	defer cfg.putGzipReader(zr)
+	return readDecompressed(buf, zr, limit)
+}
+
+// readDecompressed reads a decompressing reader into buf, stopping one
+// byte past limit so a bomb never grows buf further than that
+func readDecompressed(buf *bytes.Buffer, r io.Reader, limit int64) error {
+	if limit <= 0 {
+		_, err := buf.ReadFrom(r)
+		return err
+	}
+	n, err := buf.ReadFrom(io.LimitReader(r, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return errDecompressionBomb
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pooledPageServer serves a page per path, each with its own text
+func pooledPageServer(paragraphs int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		var page strings.Builder
+		fmt.Fprintf(&page, "<html><head><title>Page %s</title></head><body>", r.URL.Path)
+		for i := 0; i < paragraphs; i++ {
+			fmt.Fprintf(&page, "<p>Paragraph %d of %s drifts through a moonlit dream of silver rivers.</p>", i, r.URL.Path)
+		}
+		page.WriteString("</body></html>")
+		w.Write([]byte(page.String()))
+	}))
+}
+
+// TestPooledBuffersNotRetained checks a document stays intact after its
+// body buffer has been reused for another page.
+func TestPooledBuffersNotRetained(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.PoolBuffers, c.StoreRawHTML = true, true })
+
+	server := pooledPageServer(20)
+	defer server.Close()
+
+	first, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/first", URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	title, text, raw := first.Title, first.CleanText, first.RawHTML
+	for i := 0; i < 5; i++ {
+		if _, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), fmt.Sprintf("%s/other%d", server.URL, i), URLMetadata{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if first.Title != "Page /first" || first.Title != title || first.CleanText != text || first.RawHTML != raw ||
+		!strings.Contains(first.RawHTML, "of /first drifts") {
+		t.Errorf("first document changed after its buffer was reused: title %q", first.Title)
+	}
+}
+
+// BenchmarkFetchBuffers compares allocations per fetch with pooled body
+// buffers and readers against fresh ones.
+func BenchmarkFetchBuffers(b *testing.B) {
+	server := pooledPageServer(2000)
+	defer server.Close()
+
+	for _, pooled := range []bool{true, false} {
+		b.Run(fmt.Sprintf("pooled=%v", pooled), func(b *testing.B) {
+			cfg := testSettings(b, func(c *Config) { c.PoolBuffers = pooled })
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/bench", URLMetadata{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
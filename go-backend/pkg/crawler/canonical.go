@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// indexFiles are directory-index documents servers commonly serve for "/"
+var indexFiles = map[string]bool{
+	"index.html":   true,
+	"index.htm":    true,
+	"index.shtml":  true,
+	"index.php":    true,
+	"index.asp":    true,
+	"index.aspx":   true,
+	"default.htm":  true,
+	"default.html": true,
+	"default.asp":  true,
+	"default.aspx": true,
+}
+
+// canonicalURL returns the key a URL is deduplicated under. Scheme and host
+// are lowercased, the host converted to punycode, the fragment dropped and
+// query params filtered by keepQueryParam; with -collapse-index (or a
+// host's collapse_index override) a trailing index file and trailing slash
+// are removed too, so "/docs/", "/docs" and "/docs/index.html" match. With
+// -canonicalize-variants AMP and mobile URLs map to their desktop page.
+// Unparseable URLs are returned unchanged.
+func (cfg *settings) canonicalURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Host, err = toASCIIHost(u.Host); err != nil {
+		return raw
+	}
+	if cfg.CanonicalizeVariants {
+		desktopVariant(u)
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.RawQuery = filterQuery(u.RawQuery, cfg.keepQueryParam(u.Host))
+	u.ForceQuery = false
+
+	if cfg.collapseIndexFor(u.Host) {
+		p := u.EscapedPath()
+		if indexFiles[strings.ToLower(path.Base(p))] {
+			p = p[:len(p)-len(path.Base(p))]
+		}
+		p = strings.TrimRight(p, "/")
+		if p == "" {
+			p = "/"
+		}
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			u.Path, u.RawPath = unescaped, p
+		}
+	} else if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+// collapseIndexFor reports whether index collapsing applies to host, since
+// some servers serve different pages for "/path" and "/path/"
+func (cfg *settings) collapseIndexFor(host string) bool {
+	if override, ok := cfg.hostOverrides.lookup(host); ok && override.CollapseIndex != nil {
+		return *override.CollapseIndex
+	}
+	return cfg.CollapseIndex
+}
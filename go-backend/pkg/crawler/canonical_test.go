@@ -0,0 +1,62 @@
+package crawler
+
+import "testing"
+
+// TestCanonicalURLCollapseIndex checks that directory-index variants share a
+// dedup key only when -collapse-index is on.
+func TestCanonicalURLCollapseIndex(t *testing.T) {
+	variants := []string{
+		"https://site.com",
+		"https://site.com/",
+		"https://site.com/index.html",
+		"https://site.com/index.php",
+		"https://SITE.com/Default.aspx#top",
+	}
+
+	cfg := testSettings(t, func(c *Config) { c.CollapseIndex = true })
+	for _, v := range variants {
+		if got := cfg.canonicalURL(v); got != "https://site.com/" {
+			t.Errorf("collapse on: canonicalURL(%q) = %q, want %q", v, got, "https://site.com/")
+		}
+	}
+	for _, v := range []string{"https://site.com/docs", "https://site.com/docs/", "https://site.com/docs/index.htm"} {
+		if got := cfg.canonicalURL(v); got != "https://site.com/docs" {
+			t.Errorf("collapse on: canonicalURL(%q) = %q, want %q", v, got, "https://site.com/docs")
+		}
+	}
+
+	cfg = testSettings(t, nil)
+	keys := make(map[string]string)
+	for _, v := range variants[1:4] {
+		key := cfg.canonicalURL(v)
+		if prev, dup := keys[key]; dup {
+			t.Errorf("collapse off: %q and %q share key %q", prev, v, key)
+		}
+		keys[key] = v
+	}
+	if a, b := cfg.canonicalURL("https://site.com/docs"), cfg.canonicalURL("https://site.com/docs/"); a == b {
+		t.Errorf("collapse off: /docs and /docs/ share key %q", a)
+	}
+}
+
+// TestCanonicalURLHostOverride checks that a host-config collapse_index entry
+// wins over the global flag.
+func TestCanonicalURLHostOverride(t *testing.T) {
+	off, on := false, true
+	overrides := &hostConfig{
+		exact:    map[string]hostOverride{"strict.example.com": {CollapseIndex: &off}},
+		suffixes: map[string]hostOverride{".loose.example.com": {CollapseIndex: &on}},
+	}
+
+	cfg := testSettings(t, func(c *Config) { c.CollapseIndex = true })
+	cfg.hostOverrides = overrides
+	if got := cfg.canonicalURL("https://strict.example.com/index.html"); got != "https://strict.example.com/index.html" {
+		t.Errorf("canonicalURL on opted-out host = %q, want it unchanged", got)
+	}
+
+	cfg = testSettings(t, nil)
+	cfg.hostOverrides = overrides
+	if got := cfg.canonicalURL("https://www.loose.example.com/a/index.php"); got != "https://www.loose.example.com/a" {
+		t.Errorf("canonicalURL on opted-in host = %q, want %q", got, "https://www.loose.example.com/a")
+	}
+}
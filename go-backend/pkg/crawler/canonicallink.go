@@ -0,0 +1,62 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Canonical signals, as recorded in CanonicalSource
+const (
+	canonicalFromLink = "canonical"
+	canonicalFromOG   = "og:url"
+	canonicalFromBoth = "canonical+og:url"
+)
+
+// resolveCanonical picks the page's canonical URL from its rel="canonical"
+// link and og:url, returning it and the signal it came from. A same-site
+// canonical link wins; og:url is used when there's none, or when the link
+// names another site and -follow-cross-origin-canonical is off. Both are
+// resolved against the page, so relative values count as same-site.
+func (cfg *settings) resolveCanonical(doc *goquery.Document, pageURL string) (string, string) {
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return "", ""
+	}
+	base := resolutionBase(doc, page)
+	signal := func(selection, attr string) (*url.URL, bool) {
+		value, ok := doc.Find(selection).First().Attr(attr)
+		if !ok || strings.TrimSpace(value) == "" {
+			return nil, false
+		}
+		u, err := base.Parse(strings.TrimSpace(value))
+		if err != nil || !cfg.allowedSchemes[u.Scheme] {
+			return nil, false
+		}
+		u.Fragment, u.RawFragment = "", ""
+		return u, cfg.FollowCrossOriginCanonical || sameSite(u, page)
+	}
+
+	link, linkOK := signal("link[rel='canonical']", "href")
+	og, ogOK := signal("meta[property='og:url']", "content")
+	switch {
+	case linkOK && ogOK && cfg.canonicalURL(link.String()) == cfg.canonicalURL(og.String()):
+		return link.String(), canonicalFromBoth
+	case linkOK:
+		return link.String(), canonicalFromLink
+	case ogOK:
+		return og.String(), canonicalFromOG
+	}
+	return "", ""
+}
+
+// sameSite reports whether two URLs are on the same site, counting www,
+// mobile and AMP subdomains and http and https as one
+func sameSite(a, b *url.URL) bool {
+	site := func(u *url.URL) string {
+		host := desktopHost(strings.ToLower(u.Hostname()))
+		return strings.TrimPrefix(host, "www.")
+	}
+	return site(a) == site(b)
+}
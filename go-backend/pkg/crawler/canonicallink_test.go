@@ -0,0 +1,50 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestResolveCanonical checks conflicting canonical and og:url signals
+// resolve to a same-site URL, and that a cross-origin canonical is only
+// followed under -follow-cross-origin-canonical.
+func TestResolveCanonical(t *testing.T) {
+	const page = "https://www.example.com/news/story?ref=home"
+	tests := []struct {
+		name, head   string
+		follow       bool
+		want, source string
+	}{
+		{"conflict, canonical wins",
+			`<link rel="canonical" href="/news/story"><meta property="og:url" content="https://example.com/news/story-old">`,
+			false, "https://www.example.com/news/story", canonicalFromLink},
+		{"agreeing signals",
+			`<link rel="canonical" href="https://example.com/news/story"><meta property="og:url" content="https://example.com/news/story#top">`,
+			false, "https://example.com/news/story", canonicalFromBoth},
+		{"cross-origin canonical ignored for og:url",
+			`<link rel="canonical" href="https://syndicator.net/story"><meta property="og:url" content="https://m.example.com/news/story">`,
+			false, "https://m.example.com/news/story", canonicalFromOG},
+		{"cross-origin canonical followed",
+			`<link rel="canonical" href="https://syndicator.net/story"><meta property="og:url" content="https://m.example.com/news/story">`,
+			true, "https://syndicator.net/story", canonicalFromLink},
+		{"only cross-origin signals",
+			`<link rel="canonical" href="https://syndicator.net/story"><meta property="og:url" content="https://syndicator.net/story">`,
+			false, "", ""},
+		{"unsafe scheme",
+			`<link rel="canonical" href="javascript:void(0)">`,
+			false, "", ""},
+	}
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><head>" + tt.head + "</head><body></body></html>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := testSettings(t, func(c *Config) { c.FollowCrossOriginCanonical = tt.follow })
+		got, source := cfg.resolveCanonical(doc, page)
+		if got != tt.want || source != tt.source {
+			t.Errorf("%s: got %q from %q, want %q from %q", tt.name, got, source, tt.want, tt.source)
+		}
+	}
+}
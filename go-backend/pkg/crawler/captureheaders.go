@@ -0,0 +1,33 @@
+package crawler
+
+import (
+	"net/http"
+	"strings"
+)
+
+const defaultCaptureHeaders = "Content-Type,Content-Language,Last-Modified,ETag,Cache-Control"
+
+// parseCaptureHeaders canonicalizes the header names, so the allowlist
+// matches however they were written
+func parseCaptureHeaders(spec string) map[string]bool {
+	names := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return names
+}
+
+// capturedHeaders returns the first value of each response header worth
+// keeping: those in -capture-headers, or all of them under
+// -capture-all-headers
+func (cfg *settings) capturedHeaders(header http.Header) map[string]string {
+	captured := make(map[string]string)
+	for key, values := range header {
+		if len(values) > 0 && (cfg.CaptureAllHeaders || cfg.captureHeaders[key]) {
+			captured[key] = values[0]
+		}
+	}
+	return captured
+}
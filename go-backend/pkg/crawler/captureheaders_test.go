@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCaptureHeaders checks only -capture-headers are stored by default,
+// keeping cookies and server details out, and -capture-all-headers stores
+// everything.
+func TestCaptureHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Language", "en")
+		w.Header().Set("Etag", `"v1"`)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Server", "nginx/1.25")
+		fmt.Fprint(w, `<html><body><p>Headers</p></body></html>`)
+	}))
+	defer server.Close()
+
+	cfg := testSettings(t, nil)
+	doc, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key := range doc.Metadata.Headers {
+		if !cfg.captureHeaders[key] {
+			t.Errorf("captured %s, which isn't allowlisted", key)
+		}
+	}
+	if _, ok := doc.Metadata.Headers["Set-Cookie"]; ok {
+		t.Error("captured Set-Cookie")
+	}
+	if doc.Metadata.Headers["Content-Language"] != "en" || doc.Metadata.Headers["Etag"] != `"v1"` {
+		t.Errorf("allowlisted headers missing: %v", doc.Metadata.Headers)
+	}
+
+	cfg = testSettings(t, func(c *Config) { c.CaptureAllHeaders = true })
+	doc, _, err = cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata.Headers["Set-Cookie"] != "session=secret" || doc.Metadata.Headers["Server"] != "nginx/1.25" {
+		t.Errorf("-capture-all-headers dropped headers: %v", doc.Metadata.Headers)
+	}
+}
+
+func TestParseCaptureHeaders(t *testing.T) {
+	got := parseCaptureHeaders(" content-type, x-robots-tag ,,")
+	if len(got) != 2 || !got["Content-Type"] || !got["X-Robots-Tag"] {
+		t.Errorf("parseCaptureHeaders = %v, want Content-Type and X-Robots-Tag", got)
+	}
+}
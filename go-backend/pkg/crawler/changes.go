@@ -0,0 +1,311 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// ContentChange is a page whose content differs from the previous crawl.
+// Added and Removed are the chunk texts only on one side.
+type ContentChange struct {
+	URL               string    `json:"url"`
+	BeforeHash        string    `json:"before_hash"`
+	AfterHash         string    `json:"after_hash"`
+	PreviousFetchedAt time.Time `json:"previous_fetched_at"`
+	FetchedAt         time.Time `json:"fetched_at"`
+	Added             []string  `json:"added"`
+	Removed           []string  `json:"removed"`
+	Truncated         bool      `json:"truncated,omitempty"` // the diff only covers the first -change-max-text bytes
+}
+
+// storedContent is what the store keeps of a page from its last crawl
+type storedContent struct {
+	Hash      string    `json:"hash"`
+	Stable    string    `json:"stable,omitempty"` // the page's stable_hash
+	FetchedAt time.Time `json:"fetched_at"`
+	Text      []byte    `json:"text"` // gzipped diff units, one per line
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// contentStore remembers each page's content across crawls and emits a
+// ContentChange when a recrawl finds it different. It keeps at most
+// maxPages pages, dropping the least recently fetched, so a page dropped
+// and crawled again counts as new. A nil *contentStore records nothing,
+// which is how change tracking is turned off.
+type contentStore struct {
+	mu       sync.Mutex
+	maxText  int
+	maxPages int                      // 0 = no limit
+	pages    map[string]storedContent // by canonical URL
+	order    *list.List               // canonical URLs, most recently fetched at the front
+	elems    map[string]*list.Element
+	out      chan<- ContentChange
+
+	canonical func(string) string // keys pages by canonical URL
+}
+
+func newContentStore(maxText, maxPages int, canonical func(string) string) *contentStore {
+	return &contentStore{
+		canonical: canonical,
+		maxText:   maxText,
+		maxPages:  maxPages,
+		pages:     make(map[string]storedContent),
+		order:     list.New(),
+		elems:     make(map[string]*list.Element),
+	}
+}
+
+// loadContentStore reads a store written by save; a missing file is the
+// empty store of a first crawl. A store saved with a higher limit keeps
+// its most recently fetched pages.
+func loadContentStore(path string, maxText, maxPages int, canonical func(string) string) (*contentStore, error) {
+	s := newContentStore(maxText, maxPages, canonical)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pages map[string]storedContent
+	if err := json.Unmarshal(data, &pages); err != nil {
+		return nil, fmt.Errorf("parsing change store %s: %w", path, err)
+	}
+	keys := make([]string, 0, len(pages))
+	for key := range pages {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return pages[keys[i]].FetchedAt.Before(pages[keys[j]].FetchedAt) })
+	for _, key := range keys {
+		s.put(key, pages[key])
+	}
+	return s, nil
+}
+
+// put stores a page's content as the most recently fetched, evicting the
+// least recently fetched past maxPages. The caller holds mu, or owns s.
+func (s *contentStore) put(key string, content storedContent) {
+	s.pages[key] = content
+	if e, ok := s.elems[key]; ok {
+		s.order.MoveToFront(e)
+		return
+	}
+	s.elems[key] = s.order.PushFront(key)
+	if s.maxPages > 0 && s.order.Len() > s.maxPages {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.elems, oldest.Value.(string))
+		delete(s.pages, oldest.Value.(string))
+	}
+}
+
+// save writes the store under a temporary name and renames it, so a crash
+// mid-write leaves the previous crawl's store intact
+func (s *contentStore) save(path string) error {
+	s.mu.Lock()
+	data, err := json.Marshal(s.pages)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// record stores doc's content and emits a change if the page was seen by
+// an earlier crawl with a different hash. It returns false if ctx ended
+// before the change could be handed off.
+func (s *contentStore) record(ctx context.Context, doc Document) bool {
+	if s == nil || doc.Status != http.StatusOK || doc.ContentHash == "" {
+		return true
+	}
+	change, changed := s.observe(doc)
+	if !changed {
+		return true
+	}
+	select {
+	case s.out <- change:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// observe replaces the stored content for doc's URL, returning the
+// difference from what was there
+func (s *contentStore) observe(doc Document) (ContentChange, bool) {
+	units, truncated := diffUnits(doc, s.maxText)
+	current := storedContent{Hash: doc.ContentHash, Stable: doc.StableHash, FetchedAt: doc.FetchedAt, Truncated: truncated}
+	current.Text = compressUnits(units)
+
+	key := s.canonical(doc.URL)
+	s.mu.Lock()
+	previous, ok := s.pages[key]
+	s.put(key, current)
+	s.mu.Unlock()
+	if !ok || previous.unchanged(current) {
+		return ContentChange{}, false
+	}
+
+	before, err := decompressUnits(previous.Text)
+	if err != nil {
+		log.Printf("Change store entry for %s unreadable, reporting no diff: %v", doc.URL, err)
+	}
+	added, removed := diffChunks(before, units)
+	return ContentChange{
+		URL:               doc.URL,
+		BeforeHash:        previous.Hash,
+		AfterHash:         current.Hash,
+		PreviousFetchedAt: previous.FetchedAt,
+		FetchedAt:         doc.FetchedAt,
+		Added:             added,
+		Removed:           removed,
+		Truncated:         previous.Truncated || truncated,
+	}, true
+}
+
+// unchanged reports whether current has the content stored before it,
+// going by the stable hash when both crawls recorded one so that a moved
+// timestamp or counter isn't a change
+func (previous storedContent) unchanged(current storedContent) bool {
+	if previous.Stable != "" && current.Stable != "" {
+		return previous.Stable == current.Stable
+	}
+	return previous.Hash == current.Hash
+}
+
+// lastFetched returns when the page at u was last crawled, if the store
+// has it
+func (s *contentStore) lastFetched(u string) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	page, ok := s.pages[s.canonical(u)]
+	return page.FetchedAt, ok
+}
+
+// diffUnits splits doc into the units changes are reported in: its chunks,
+// or its clean text when it has none, up to maxText bytes
+func diffUnits(doc Document, maxText int) ([]string, bool) {
+	var texts []string
+	for _, chunk := range doc.Chunks {
+		texts = append(texts, cleanText(chunk.Text))
+	}
+	if len(texts) == 0 && doc.CleanText != "" {
+		texts = []string{doc.CleanText}
+	}
+
+	var units []string
+	size := 0
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		if size+len(text) > maxText {
+			return units, true
+		}
+		units = append(units, text)
+		size += len(text) + 1
+	}
+	return units, false
+}
+
+// diffChunks returns the units only in after and only in before, each in
+// page order. Units are compared as a multiset, so a repeated paragraph
+// counts once per occurrence and moving one isn't a change.
+func diffChunks(before, after []string) (added, removed []string) {
+	counts := make(map[string]int, len(before))
+	for _, unit := range before {
+		counts[unit]++
+	}
+	for _, unit := range after {
+		if counts[unit] > 0 {
+			counts[unit]--
+		} else {
+			added = append(added, unit)
+		}
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		if counts[before[i]] > 0 {
+			counts[before[i]]--
+			removed = append(removed, before[i])
+		}
+	}
+	for i, j := 0, len(removed)-1; i < j; i, j = i+1, j-1 {
+		removed[i], removed[j] = removed[j], removed[i]
+	}
+	return added, removed
+}
+
+// compressUnits gzips units one per line; cleanText has already turned
+// any newlines inside them into spaces
+func compressUnits(units []string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, strings.Join(units, "\n"))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func decompressUnits(data []byte) ([]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	if len(text) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(text), "\n"), nil
+}
+
+// changeProducer publishes change events to topic, keyed by URL so a
+// page's changes stay in order on one partition
+func changeProducer(producer *kafka.Producer, changes <-chan ContentChange, topic string) {
+	for change := range changes {
+		value, err := json.Marshal(change)
+		if err != nil {
+			log.Printf("JSON marshal error: %v", err)
+			continue
+		}
+		producer.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Value:          value,
+			Key:            []byte(change.URL),
+		}, nil)
+	}
+}
@@ -0,0 +1,154 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// TestContentChangeAcrossCrawls crawls a page, saves the change store,
+// then crawls it again in a new crawler after one paragraph was replaced,
+// and checks the second crawl emits a change with exactly that paragraph
+// added and removed.
+func TestContentChangeAcrossCrawls(t *testing.T) {
+	var crawl atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		news := "The moon was seen drifting over the harbour on Monday."
+		if crawl.Load() > 1 {
+			news = "The moon returned to its usual place by Tuesday."
+		}
+		fmt.Fprintf(w, `<html><head><title>Dream log</title></head><body>
+			<p>Every night the log records what the city dreamed.</p>
+			<p>%s</p></body></html>`, news)
+	}))
+	defer server.Close()
+
+	cfg := testSettings(t, nil)
+	path := filepath.Join(t.TempDir(), "changes.json")
+	crawlOnce := func() (Document, []ContentChange) {
+		crawl.Add(1)
+		contents, err := loadContentStore(path, 1<<10, 0, cfg.canonicalURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes := make(chan ContentChange, 1)
+		contents.out = changes
+
+		serverURL, _ := url.Parse(server.URL)
+		seen := mapSeen{}
+		c := &Crawler{cfg: cfg, client: server.Client(), seen: &seen, stats: &CrawlerStats{}, contents: contents,
+			hostMap: map[string]*hostPolicies{serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)}}}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		urlQueue := make(chan URLWithMetadata, 10)
+		out := make(chan Document, 10)
+		go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+		urlQueue <- URLWithMetadata{URL: server.URL + "/log"}
+
+		var doc Document
+		select {
+		case doc = <-out:
+		case <-time.After(5 * time.Second):
+			t.Fatal("page was not crawled")
+		}
+		cancel()
+		if err := contents.save(path); err != nil {
+			t.Fatal(err)
+		}
+		close(changes)
+		var got []ContentChange
+		for change := range changes {
+			got = append(got, change)
+		}
+		return doc, got
+	}
+
+	first, changes := crawlOnce()
+	if len(changes) != 0 {
+		t.Fatalf("first crawl emitted %v", changes)
+	}
+	second, changes := crawlOnce()
+	if len(changes) != 1 {
+		t.Fatalf("second crawl emitted %d changes, want 1", len(changes))
+	}
+	change := changes[0]
+	if change.URL != server.URL+"/log" || change.BeforeHash != first.ContentHash || change.AfterHash != second.ContentHash {
+		t.Errorf("change = %+v, want %s from %s to %s", change, server.URL+"/log", first.ContentHash, second.ContentHash)
+	}
+	if want := []string{"The moon returned to its usual place by Tuesday."}; !reflect.DeepEqual(change.Added, want) {
+		t.Errorf("added = %q, want %q", change.Added, want)
+	}
+	if want := []string{"The moon was seen drifting over the harbour on Monday."}; !reflect.DeepEqual(change.Removed, want) {
+		t.Errorf("removed = %q, want %q", change.Removed, want)
+	}
+
+	// An unchanged recrawl is not a change
+	if _, changes := crawlOnce(); len(changes) != 0 {
+		t.Errorf("unchanged recrawl emitted %v", changes)
+	}
+}
+
+func TestDiffChunks(t *testing.T) {
+	added, removed := diffChunks([]string{"a", "b", "a", "c"}, []string{"c", "a", "d", "b"})
+	if !reflect.DeepEqual(added, []string{"d"}) || !reflect.DeepEqual(removed, []string{"a"}) {
+		t.Errorf("added %q, removed %q; want [d] and [a]", added, removed)
+	}
+}
+
+// TestChangeStoreBoundsText checks only -change-max-text bytes of a page
+// are kept and a diff past them is marked truncated.
+func TestChangeStoreBoundsText(t *testing.T) {
+	s := newContentStore(100, 0, testSettings(t, nil).canonicalURL)
+	long := strings.Repeat("dream ", 30)
+	doc := Document{URL: "https://example.com/", ContentHash: "1", Chunks: []ContentChunk{{Text: "Short opening line."}, {Text: long}}}
+	s.observe(doc)
+	stored, _ := decompressUnits(s.pages["https://example.com/"].Text)
+	if !reflect.DeepEqual(stored, []string{"Short opening line."}) || !s.pages["https://example.com/"].Truncated {
+		t.Errorf("stored %q, truncated %v", stored, s.pages["https://example.com/"].Truncated)
+	}
+
+	doc.ContentHash = "2"
+	doc.Chunks[1].Text = long + "awake"
+	change, ok := s.observe(doc)
+	if !ok || !change.Truncated || len(change.Added)+len(change.Removed) != 0 {
+		t.Errorf("change = %+v, want a truncated change with no visible diff", change)
+	}
+}
+
+// TestChangeStoreBoundsPages checks the store keeps -change-max-pages
+// pages, dropping the least recently fetched, and that loading a larger
+// store keeps its newest pages.
+func TestChangeStoreBoundsPages(t *testing.T) {
+	cfg := testSettings(t, nil)
+	s := newContentStore(1<<10, 2, cfg.canonicalURL)
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, path := range []string{"/a", "/b", "/a", "/c"} {
+		s.observe(Document{URL: "https://example.com" + path, ContentHash: "h", FetchedAt: start.Add(time.Duration(i) * time.Hour)})
+	}
+	if _, ok := s.lastFetched("https://example.com/b"); ok || len(s.pages) != 2 {
+		t.Errorf("store kept %d pages including /b, want /a and /c", len(s.pages))
+	}
+
+	path := filepath.Join(t.TempDir(), "changes.json")
+	if err := s.save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadContentStore(path, 1<<10, 1, cfg.canonicalURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.lastFetched("https://example.com/c"); !ok || len(loaded.pages) != 1 {
+		t.Errorf("loaded %d pages, want only /c, the most recently fetched", len(loaded.pages))
+	}
+}
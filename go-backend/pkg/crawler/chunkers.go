@@ -0,0 +1,98 @@
+package crawler
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/analyze"
+)
+
+// ChunkExtractor pulls one kind of chunk out of a page. Position and ID are
+// assigned afterwards across all extractors, so Extract leaves them unset.
+type ChunkExtractor interface {
+	Extract(doc *goquery.Document) []ContentChunk
+}
+
+// ChunkExtractorFunc adapts a function to ChunkExtractor
+type ChunkExtractorFunc func(doc *goquery.Document) []ContentChunk
+
+func (f ChunkExtractorFunc) Extract(doc *goquery.Document) []ContentChunk { return f(doc) }
+
+// chunkExtractors runs extractors in registration order
+var chunkExtractors = newRegistry[ChunkExtractor]("chunk extractor")
+
+func init() {
+	RegisterChunkExtractor("headline", ChunkExtractorFunc(extractHeadlineChunks))
+	RegisterChunkExtractor("paragraph", ChunkExtractorFunc(extractParagraphChunks))
+	RegisterChunkExtractor("quote", ChunkExtractorFunc(extractQuoteChunks))
+}
+
+// RegisterChunkExtractor adds e to the extractors run on every page. An
+// existing name, built-in or not, is replaced in place; a nil e removes it.
+func RegisterChunkExtractor(name string, e ChunkExtractor) {
+	chunkExtractors.register(name, e)
+}
+
+// registeredChunkExtractors returns the extractors in registration order
+func registeredChunkExtractors() []ChunkExtractor {
+	all, _ := chunkExtractors.lookup(nil)
+	extractors := make([]ChunkExtractor, 0, len(all))
+	for _, e := range all {
+		extractors = append(extractors, e.value)
+	}
+	return extractors
+}
+
+func extractHeadlineChunks(doc *goquery.Document) []ContentChunk {
+	var chunks []ContentChunk
+	doc.Find("h1, h2, h3, h4, h5, h6").Each(func(i int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if text != "" && len(text) > 5 {
+			chunks = append(chunks, ContentChunk{
+				Type:       "headline",
+				Text:       text,
+				Confidence: 0.9,
+				Keywords:   extractKeywords(text),
+				element:    s,
+			})
+		}
+	})
+	return chunks
+}
+
+func extractParagraphChunks(doc *goquery.Document) []ContentChunk {
+	var chunks []ContentChunk
+	doc.Find("p").Each(func(i int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if text != "" && len(text) > 20 {
+			chunks = append(chunks, ContentChunk{
+				Type:       "paragraph",
+				Text:       text,
+				Confidence: 0.8,
+				Keywords:   extractKeywords(text),
+				Sentiment:  analyze.ChunkSentiment(text),
+				Entities:   extractEntities(text),
+				element:    s,
+			})
+		}
+	})
+	return chunks
+}
+
+func extractQuoteChunks(doc *goquery.Document) []ContentChunk {
+	var chunks []ContentChunk
+	doc.Find("blockquote, q").Each(func(i int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if text != "" {
+			chunks = append(chunks, ContentChunk{
+				Type:       "quote",
+				Text:       text,
+				Confidence: 0.85,
+				Keywords:   extractKeywords(text),
+				Sentiment:  analyze.ChunkSentiment(text),
+				element:    s,
+			})
+		}
+	})
+	return chunks
+}
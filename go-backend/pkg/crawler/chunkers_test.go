@@ -0,0 +1,64 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestCustomChunkExtractor registers a <dl> extractor and checks its chunks
+// follow the built-in ones with continuing positions.
+func TestCustomChunkExtractor(t *testing.T) {
+	RegisterChunkExtractor("definition", ChunkExtractorFunc(func(doc *goquery.Document) []ContentChunk {
+		var chunks []ContentChunk
+		doc.Find("dl dt").Each(func(i int, dt *goquery.Selection) {
+			dd := dt.NextFiltered("dd")
+			chunks = append(chunks, ContentChunk{
+				Type:       "definition",
+				Text:       strings.TrimSpace(dt.Text()) + ": " + strings.TrimSpace(dd.Text()),
+				Confidence: 0.9,
+			})
+		})
+		return chunks
+	}))
+	defer RegisterChunkExtractor("definition", nil)
+
+	html := `<html><body>
+		<h1>Recipe Glossary</h1>
+		<p>A short guide to the words used in this cookbook.</p>
+		<dl><dt>Blanch</dt><dd>Boil briefly, then plunge into ice water.</dd>
+		<dt>Fold</dt><dd>Mix gently without deflating.</dd></dl>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := testSettings(t, nil).extractContentChunks(doc, "")
+	var types []string
+	for i, chunk := range chunks {
+		types = append(types, chunk.Type)
+		if chunk.Position != i {
+			t.Errorf("chunk %d has position %d", i, chunk.Position)
+		}
+		if chunk.ID == "" {
+			t.Errorf("chunk %d has no ID", i)
+		}
+	}
+	if got, want := strings.Join(types, ","), "headline,paragraph,definition,definition"; got != want {
+		t.Fatalf("chunk types = %s, want %s", got, want)
+	}
+	if got := chunks[2].Text; got != "Blanch: Boil briefly, then plunge into ice water." {
+		t.Errorf("definition chunk = %q", got)
+	}
+	if !strings.HasPrefix(chunks[3].ID, "d_") {
+		t.Errorf("definition chunk ID = %q, want a d_ prefix", chunks[3].ID)
+	}
+
+	// Unregistering leaves only the built-ins
+	RegisterChunkExtractor("definition", nil)
+	if got := len(testSettings(t, nil).extractContentChunks(doc, "")); got != 2 {
+		t.Errorf("after unregistering: %d chunks, want 2", got)
+	}
+}
@@ -0,0 +1,85 @@
+package crawler
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// chunkFrequency counts the distinct pages each chunk's text appears on, so
+// newsletter prompts and cookie notices repeated across a site stop being
+// emitted once they have recurred often enough. Pages before the threshold
+// keep the chunk. Like boilerplateDetector it is a fixed-size LRU; an
+// evicted text starts counting again from zero.
+type chunkFrequency struct {
+	mu       sync.Mutex
+	pages    int
+	capacity int
+	order    *list.List // most recently seen text at the front
+	texts    map[string]*list.Element
+}
+
+type chunkSightings struct {
+	key   string
+	urls  map[string]struct{} // pages seen on, until the threshold is reached
+	flood bool                // reached the threshold
+}
+
+func newChunkFrequency(pages, capacity int) *chunkFrequency {
+	return &chunkFrequency{
+		pages:    pages,
+		capacity: capacity,
+		order:    list.New(),
+		texts:    make(map[string]*list.Element),
+	}
+}
+
+// observe records that key appeared on pageURL and reports whether it has
+// now appeared on enough distinct pages to be boilerplate
+func (f *chunkFrequency) observe(key, pageURL string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var s *chunkSightings
+	if e, ok := f.texts[key]; ok {
+		f.order.MoveToFront(e)
+		s = e.Value.(*chunkSightings)
+	} else {
+		s = &chunkSightings{key: key, urls: make(map[string]struct{})}
+		f.texts[key] = f.order.PushFront(s)
+		if f.order.Len() > f.capacity {
+			oldest := f.order.Back()
+			f.order.Remove(oldest)
+			delete(f.texts, oldest.Value.(*chunkSightings).key)
+		}
+	}
+	if !s.flood {
+		s.urls[pageURL] = struct{}{}
+		if len(s.urls) >= f.pages {
+			s.flood, s.urls = true, nil
+		}
+	}
+	return s.flood
+}
+
+// filter returns chunks without whitespace-only ones and those whose text
+// has recurred on enough pages, and the cleaned text of the recurring ones.
+// Positions are left as extracted. A nil or disabled tracker only drops
+// the whitespace-only chunks.
+func (f *chunkFrequency) filter(pageURL string, chunks []ContentChunk) (kept []ContentChunk, boilerplate []string) {
+	kept = chunks[:0]
+	for _, chunk := range chunks {
+		if strings.TrimSpace(chunk.Text) == "" {
+			continue
+		}
+		// Content-addressed, so the key is short and ignores case and spacing
+		if f != nil && f.pages > 0 && f.observe(model.ChunkID("", chunk.Text), pageURL) {
+			boilerplate = append(boilerplate, cleanText(chunk.Text))
+			continue
+		}
+		kept = append(kept, chunk)
+	}
+	return kept, boilerplate
+}
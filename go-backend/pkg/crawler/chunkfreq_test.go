@@ -0,0 +1,131 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const newsletterChunk = "Subscribe to our newsletter for weekly dream journals."
+
+// TestRepeatedChunksDropped crawls pages sharing a newsletter paragraph and
+// checks it is dropped from the third page on, while each page's own
+// paragraph is kept.
+func TestRepeatedChunksDropped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		links := ""
+		if r.URL.Path == "/" {
+			links = `<a href="/post/1">1</a><a href="/post/2">2</a><a href="/post/3">3</a>`
+		}
+		fmt.Fprintf(w, `<html><head><title>%s</title></head><body>
+			<p>This paragraph is only found on the page at %s.</p>
+			<p>%s</p>%s</body></html>`, r.URL.Path, r.URL.Path, newsletterChunk, links)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+	}
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+
+	c := &Crawler{client: server.Client(), cfg: testSettings(t, nil), hostMap: hostMap, seen: &seen, stats: stats,
+		chunkFreq: newChunkFrequency(3, 100)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+
+	// One worker, so pages arrive in crawl order
+	paths := []string{"/", "/post/1", "/post/2", "/post/3"}
+	for i, path := range paths {
+		var doc Document
+		select {
+		case doc = <-out:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got %d of %d documents", i, len(paths))
+		}
+		if doc.URL != server.URL+path {
+			t.Fatalf("document %d is %s, want %s", i, doc.URL, path)
+		}
+
+		var own, newsletter bool
+		for _, chunk := range doc.Chunks {
+			switch chunk.Text {
+			case newsletterChunk:
+				newsletter = true
+			case "This paragraph is only found on the page at " + path + ".":
+				own = true
+			}
+		}
+		if !own {
+			t.Errorf("%s: unique paragraph dropped", path)
+		}
+		// The third page reaches the threshold
+		if wantNewsletter := i < 2; newsletter != wantNewsletter {
+			t.Errorf("%s: newsletter chunk kept = %v, want %v", path, newsletter, wantNewsletter)
+		}
+	}
+}
+
+// TestChunkFrequencyBounded checks tracked texts are capped, a page is
+// counted once however often a chunk repeats on it, and whitespace-only
+// chunks are dropped even without a tracker.
+func TestChunkFrequencyBounded(t *testing.T) {
+	f := newChunkFrequency(2, 2)
+	f.observe("a", "/1")
+	f.observe("b", "/1")
+	f.observe("c", "/1") // evicts "a"
+	if len(f.texts) != 2 {
+		t.Errorf("tracking %d texts, want 2", len(f.texts))
+	}
+	if f.observe("a", "/2") {
+		t.Error("evicted text flagged on its first sighting back")
+	}
+	if f.observe("c", "/1") {
+		t.Error("second sighting on the same page counted as a new page")
+	}
+	if !f.observe("c", "/2") {
+		t.Error("text on two pages not flagged")
+	}
+
+	var none *chunkFrequency
+	chunks, boilerplate := none.filter("/1", []ContentChunk{{Text: " \n\t "}, {Text: "kept"}})
+	if len(chunks) != 1 || chunks[0].Text != "kept" || boilerplate != nil {
+		t.Errorf("filter = %+v, %q; want only the non-blank chunk", chunks, boilerplate)
+	}
+}
+
+// TestBoilerplateOutOfDreamHints checks text dropped as boilerplate doesn't
+// add a theme to the dream hints, though it stays in CleanText.
+func TestBoilerplateOutOfDreamHints(t *testing.T) {
+	footer := "Read our latest research on software."
+	doc := Document{CleanText: "The lighthouse stood by the sea. " + footer}
+
+	hasScientific := func(hints DreamingHints) bool {
+		for _, theme := range hints.Themes {
+			if theme == "scientific" {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasScientific(testSettings(t, nil).generateDreamHints(doc)) {
+		t.Fatal("scientific theme missing without boilerplate set")
+	}
+	doc.boilerplate = []string{footer}
+	if hints := testSettings(t, nil).generateDreamHints(doc); hasScientific(hints) {
+		t.Errorf("themes = %v, boilerplate should not count", hints.Themes)
+	}
+}
@@ -0,0 +1,12 @@
+package crawler
+
+import "github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+
+// assignChunkIDs sets content-addressed IDs on chunks, suffixing repeats
+// as model.ChunkIDs does
+func assignChunkIDs(chunks []ContentChunk) {
+	ids := make(model.ChunkIDs, len(chunks))
+	for i := range chunks {
+		chunks[i].ID = ids.Next(chunks[i].Type, chunks[i].Text)
+	}
+}
@@ -0,0 +1,63 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// TestChunkIDsStableAcrossLayouts checks a chunk keeps its ID when the page
+// around it changes, and that different text gets a different ID.
+func TestChunkIDsStableAcrossLayouts(t *testing.T) {
+	parse := func(html string) map[string]string {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[string]string) // whitespace-normalized text -> ID
+		for _, chunk := range testSettings(t, nil).extractContentChunks(doc, "") {
+			ids[strings.Join(strings.Fields(chunk.Text), " ")] = chunk.ID
+		}
+		return ids
+	}
+
+	para := "The lighthouse keeper counted waves instead of sheep."
+	first := parse(`<html><body><h1>Night Shift Notes</h1><p>` + para + `</p></body></html>`)
+	second := parse(`<html><body><p>A brand new opening paragraph pushes everything down.</p>
+		<h2>Night Shift Notes</h2><div><p>  The lighthouse keeper   counted waves instead of sheep.</p></div></body></html>`)
+
+	if first[para] == "" || first[para] != second[para] {
+		t.Errorf("paragraph ID changed across parses: %q vs %q", first[para], second[para])
+	}
+	if first["Night Shift Notes"] != second["Night Shift Notes"] {
+		t.Errorf("headline ID changed across parses: %q vs %q", first["Night Shift Notes"], second["Night Shift Notes"])
+	}
+	if first[para] == first["Night Shift Notes"] {
+		t.Error("different chunks share an ID")
+	}
+	if !strings.HasPrefix(first[para], "p_") || !strings.HasPrefix(first["Night Shift Notes"], "h_") {
+		t.Errorf("IDs lost their type prefix: %q, %q", first[para], first["Night Shift Notes"])
+	}
+}
+
+// TestAssignChunkIDsDisambiguates checks repeated chunks on one page get
+// distinct IDs.
+func TestAssignChunkIDsDisambiguates(t *testing.T) {
+	chunks := []ContentChunk{
+		{Type: "paragraph", Text: "Subscribe to our newsletter for more."},
+		{Type: "paragraph", Text: "Something else entirely."},
+		{Type: "paragraph", Text: "Subscribe to our  newsletter for more."},
+		{Type: "quote", Text: "Subscribe to our newsletter for more."},
+	}
+	assignChunkIDs(chunks)
+
+	base := model.ChunkID("paragraph", "Subscribe to our newsletter for more.")
+	if chunks[0].ID != base || chunks[2].ID != base+"-2" {
+		t.Errorf("repeated chunk IDs = %q, %q, want %q, %q", chunks[0].ID, chunks[2].ID, base, base+"-2")
+	}
+	if chunks[3].ID == base {
+		t.Error("a quote and a paragraph with the same text share an ID")
+	}
+}
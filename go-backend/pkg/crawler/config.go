@@ -0,0 +1,409 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// Config is everything a crawl is tuned by. Start from DefaultConfig:
+// the zero value of many fields turns a feature off rather than
+// selecting its default. The crawler command sets each field from the
+// flag of the same name in kebab case (MaxDepth from -max-depth), which
+// is how comments throughout this package refer to them.
+type Config struct {
+	// Seeds are the URLs the crawl starts from
+	Seeds []string
+	// Sitemaps are sitemap or sitemap index URLs whose pages are queued
+	// after the seeds; under ChangeStore, pages whose <lastmod> predates
+	// their last crawl are skipped
+	Sitemaps []string
+
+	// Scope
+	MaxDepth         int
+	MaxHosts         int      // distinct hosts crawled before new ones are skipped, 0 = unlimited
+	AllowedDomains   []string // nil = any domain
+	AllowedSchemes   []string // URL schemes links may use to be followed
+	MaxFollowPerPage int      // links followed from each page, highest priority first, 0 = unlimited
+	MaxPagination    int      // next pages followed in one series without using up depth, 0 = treat them like any link
+	FollowAlternates bool     // enqueue hreflang alternate-language versions of each page
+	// FocusKeywords are followed first, when links or the pages they are
+	// found on match them; PruneIrrelevant drops links matching none from
+	// PruneDepth on
+	FocusKeywords   []string
+	PruneIrrelevant bool
+	PruneDepth      int
+
+	// Workers
+	Workers        int // fetch workers, which also parse unless ParseWorkers is set
+	ParseWorkers   int // parse workers fed by the fetch workers, 0 = none
+	MinWorkers     int // fewest workers autoscaling retires down to, 0 = Workers
+	MaxWorkers     int // most workers autoscaling adds, 0 = Workers
+	ScaleHighWater int // queued URLs above which workers are added
+	ScaleInterval  time.Duration
+	QueueSize      int
+	HostFairness   bool // interleave hosts round-robin instead of serving the queue in arrival order
+
+	// Budgets, 0 = unlimited
+	MaxRuntime  time.Duration
+	MaxBytes    int64 // bytes of page text processed
+	MaxPages    int64
+	IdleTimeout time.Duration // without any fetch, skip or error
+	URLDeadline time.Duration // one URL's rate-limit waits, retries, fetch and parse together
+
+	// Politeness
+	HostConfigFile      string   // JSON per-host rate and concurrency overrides, keyed by hostname or *.suffix
+	RateJitter          float64  // fraction each host's request spacing varies by, e.g. 0.2 for ±20%
+	IgnoreRobots        bool     // ignore every host's robots.txt Disallow rules; only for sites you own
+	RobotsOverrideHosts []string // hostnames or *.suffix patterns whose Disallow rules are ignored
+	BackoffAfter        int      // consecutive failures before a host's crawl interval grows
+	BackoffFactor       float64  // growth of a failing host's interval per further failure
+	BreakerAfter        int      // consecutive failures before a host is skipped, 0 = never
+	BreakerCooldown     time.Duration
+	MaxRetries          int // times a rate-limited URL is re-queued
+	RetryBaseDelay      time.Duration
+	MaxLimiterWait      time.Duration // wait on a host's rate limiter before deferring the URL
+
+	// HTTP
+	Timeout               time.Duration
+	AcceptLanguage        string
+	Headers               http.Header // extra request headers, replacing defaults such as User-Agent
+	LoginURL              string      // URL a login form is POSTed to once, before crawling
+	LoginFields           string      // key=value pairs for LoginURL; $VAR values are read from the environment
+	CAFile                string
+	ClientCert, ClientKey string
+	InsecureSkipVerify    bool
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	DisableKeepAlives     bool
+	ForceHTTP1            bool
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	PerWorkerClient       bool          // a transport and connection pool per worker
+	DNSCacheTTL           time.Duration // 0 = resolve on every dial
+	DNSNegativeTTL        time.Duration
+	AllowPrivateNetworks  bool // allow loopback, private and other internal addresses
+	MaxBodyBytes          int64
+	MaxDecompressedBytes  int64
+	CoalesceFetches       bool // workers picking up a URL already being fetched wait for that fetch
+	PoolBuffers           bool
+
+	// Deduplication
+	TrackingParams             string // query params ignored when deduplicating, a trailing * matching a prefix
+	CollapseIndex              bool   // index.html and the like are the same URL as their directory
+	CanonicalizeVariants       bool   // AMP and mobile URLs are the same URL as their desktop page
+	FollowCrossOriginCanonical bool
+	SeenBloom                  bool // dedup with a bloom filter instead of an exact set
+	SeenBloomFP                float64
+	SeenBloomCapacity          int
+
+	// Extraction
+	StripSelectors          string // CSS selectors of elements removed before text and chunks are extracted
+	KeepSelectors           string // CSS selectors of elements StripSelectors never removes
+	VolatileSelectors       string // CSS selectors of elements left out of stable_hash
+	VolatilePattern         string // regular expression of text left out of stable_hash
+	DateLayouts             string // comma-separated published date formats; empty = the defaults
+	DateTimezone            string // IANA zone of published dates that don't give one
+	CaptureHeaders          string // comma-separated response headers kept in metadata
+	CaptureAllHeaders       bool
+	LinkAttrs, MediaAttrs   []string // element attributes kept on links and media
+	MinImageWidth           int
+	MinImageHeight          int
+	TrackingImages          string // comma-separated URL substrings of tracking pixels
+	HeroMinSize             int
+	Microdata               bool
+	RespectUsageDirectives  bool // withhold the content of noarchive and nosnippet pages
+	ExtractPDF              bool
+	EmitSafeHTML            bool
+	SafeHTMLTags            string   // comma-separated tags EmitSafeHTML keeps
+	Processors              []string // document processors to run, in order; nil = all registered
+	AnalysisProfile         string   // profile for pages no other profile matches
+	AnalysisProfilesFile    string   // JSON profiles keyed by name, replacing built-ins of the same name
+	EnableDreaming          bool
+	Timing                  bool // record each stage's microseconds on documents and in the stats
+	BoilerplateTitleRepeats int  // pages sharing a title this often are boilerplate when thin, 0 = off
+	BoilerplateMaxWords     int
+	BoilerplateMaxTitles    int
+	BoilerplateSkipDreams   bool
+	BoilerplateChunkPages   int // distinct pages a chunk appears on before it's dropped, 0 = off
+	BoilerplateMaxChunks    int
+	TemplatePages           int // pages per host learned before recurring subtrees are left out, 0 = off
+	TemplateMaxFingerprints int
+
+	// Output
+	KafkaTopic            string
+	DreamTopic            string
+	GraphTopic            string // link edges, empty = not emitted
+	ErrorTopic            string // failed URLs, empty = not emitted
+	QuarantineTopic       string // documents failing validation, instead of the main topics
+	RawHTMLTopic          string
+	ChangeTopic           string
+	TopicPrefix           string
+	Serialization         string // json, protobuf or avro, for the raw and quarantine topics
+	SchemaRegistry        string
+	EmitFields            string // comma-separated Document JSON fields for the raw topic; empty = all
+	DreamEmitFields       string // the same for the dream topic; empty = EmitFields
+	DreamThreshold        float64
+	LogThreshold          float64
+	EmitSoft404           bool
+	MinWordCount          int
+	MinCleanTextLen       int
+	SampleRate            float64
+	DedupMedia            bool
+	DedupMediaMark        bool
+	DedupMediaMax         int
+	StoreRawHTML          bool
+	RawHTMLMaxBytes       int64
+	EmitRate              float64 // document messages per second, 0 = unlimited
+	EmitBurst             int
+	OutputBuffer          int    // documents held ahead of the producer before spilling to disk
+	SpillDir              string // empty = the system temp dir
+	DeliverySpool         string // directory of messages Kafka failed to deliver, retried with backoff
+	DeliverySpoolMaxBytes int64
+	DeliveryMaxAge        time.Duration
+	DeliveryRetryDelay    time.Duration
+
+	// State kept between crawls
+	ChangeStore      string
+	ChangeMaxText    int
+	ChangeMaxPages   int
+	FrontierFile     string
+	FrontierInterval time.Duration
+	SnapshotDir      string
+	SnapshotInterval time.Duration
+	ResumeSnapshot   string // snapshot whose crawled URLs aren't crawled again
+
+	// Reporting
+	StatsInterval   time.Duration
+	StatsFormat     string // text (logged) or json (one object per line on stdout)
+	ReportFile      string // also write the final report here
+	ReportTopHosts  int
+	ContentStatsTop int // keywords, entities and themes in the content summary, 0 = none
+
+	// Client fetches pages; nil builds one from the HTTP settings
+	Client *http.Client
+	// Producer publishes documents and events, and its delivery reports
+	// are read by the crawler; nil keeps the crawl in-process, with
+	// documents only reaching Hook
+	Producer *kafka.Producer
+	// Hook observes the crawl; nil means no callbacks
+	Hook EventHook
+	// Frontier persists the pending queue; nil uses FrontierFile, if set
+	Frontier FrontierStore
+}
+
+// DefaultConfig returns the crawler command's defaults, without seeds
+func DefaultConfig() Config {
+	return Config{
+		MaxDepth:       3,
+		AllowedSchemes: []string{"http", "https"},
+		MaxPagination:  20,
+		PruneDepth:     2,
+
+		Workers:        10,
+		ScaleHighWater: 100,
+		ScaleInterval:  time.Second,
+		QueueSize:      1000,
+
+		MaxRuntime: 3 * time.Minute,
+
+		BackoffAfter:    3,
+		BackoffFactor:   2,
+		BreakerAfter:    10,
+		BreakerCooldown: time.Minute,
+		MaxRetries:      3,
+		RetryBaseDelay:  time.Second,
+		MaxLimiterWait:  5 * time.Second,
+
+		Timeout:              15 * time.Second,
+		MaxIdleConns:         100,
+		MaxIdleConnsPerHost:  10,
+		IdleConnTimeout:      90 * time.Second,
+		DialTimeout:          10 * time.Second,
+		TLSHandshakeTimeout:  10 * time.Second,
+		DNSNegativeTTL:       30 * time.Second,
+		MaxBodyBytes:         10 << 20,
+		MaxDecompressedBytes: 50 << 20,
+		CoalesceFetches:      true,
+		PoolBuffers:          true,
+
+		TrackingParams:    defaultTrackingParams,
+		SeenBloomFP:       0.001,
+		SeenBloomCapacity: 1 << 20,
+
+		StripSelectors:          defaultStripSelectors,
+		VolatileSelectors:       defaultVolatileSelectors,
+		VolatilePattern:         defaultVolatilePattern,
+		DateTimezone:            "UTC",
+		CaptureHeaders:          defaultCaptureHeaders,
+		TrackingImages:          defaultTrackingImages,
+		HeroMinSize:             100,
+		Microdata:               true,
+		RespectUsageDirectives:  true,
+		SafeHTMLTags:            defaultSafeHTMLTags,
+		AnalysisProfile:         "default",
+		EnableDreaming:          true,
+		BoilerplateTitleRepeats: 5,
+		BoilerplateMaxWords:     150,
+		BoilerplateMaxTitles:    10000,
+		BoilerplateChunkPages:   10,
+		BoilerplateMaxChunks:    50000,
+		TemplateMaxFingerprints: 5000,
+
+		KafkaTopic:            "raw.content",
+		DreamTopic:            "dream.seeds",
+		GraphTopic:            "crawl.graph",
+		ErrorTopic:            "crawl.errors",
+		RawHTMLTopic:          "raw.html",
+		ChangeTopic:           "content.changes",
+		Serialization:         model.FormatJSON,
+		DreamThreshold:        0.5,
+		LogThreshold:          0.3,
+		SampleRate:            1,
+		DedupMediaMax:         100000,
+		RawHTMLMaxBytes:       2 << 20,
+		EmitBurst:             1,
+		OutputBuffer:          1000,
+		DeliverySpoolMaxBytes: 64 << 20,
+		DeliveryMaxAge:        time.Hour,
+		DeliveryRetryDelay:    time.Second,
+
+		ChangeMaxText:    64 << 10,
+		ChangeMaxPages:   100000,
+		FrontierInterval: time.Minute,
+		SnapshotInterval: 5 * time.Minute,
+
+		StatsInterval:   30 * time.Second,
+		StatsFormat:     "text",
+		ReportTopHosts:  10,
+		ContentStatsTop: 20,
+	}
+}
+
+// settings is a Config with its specs parsed and files loaded, as the
+// crawl reads it. It isn't changed once built, so workers share it.
+type settings struct {
+	Config
+
+	allowedDomains      map[string]bool // nil = any domain
+	allowedSchemes      schemeSet
+	focusKeywords       []string
+	robotsOverrideHosts hostPatterns
+	hostOverrides       *hostConfig // nil without HostConfigFile
+	trackingParams      paramMatcher
+	stripFilter         elementFilter
+	volatile            volatileRegions
+	dateLayouts         []string
+	dateLocation        *time.Location
+	captureHeaders      map[string]bool
+	linkAttrs           attrList
+	mediaAttrs          attrList
+	trackingImages      []string
+	safeHTMLTags        map[string]bool
+	pipeline            []pipelineStage
+	analysisProfiles    map[string]*analysisProfile
+	rawProjection       fieldProjection
+	dreamProjection     fieldProjection
+	serializer          model.Serializer // nil for JSON
+	loginFields         url.Values
+}
+
+// newSettings validates cfg and parses its specs
+func newSettings(cfg Config) (*settings, error) {
+	if cfg.Workers <= 0 {
+		return nil, fmt.Errorf("workers must be positive, got %d", cfg.Workers)
+	}
+	if cfg.ParseWorkers < 0 {
+		return nil, fmt.Errorf("parse workers can't be negative, got %d", cfg.ParseWorkers)
+	}
+	if cfg.QueueSize <= 0 {
+		return nil, fmt.Errorf("queue size must be positive, got %d", cfg.QueueSize)
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, fmt.Errorf("-sample-rate must be between 0 and 1, got %g", cfg.SampleRate)
+	}
+	if cfg.StatsInterval <= 0 {
+		return nil, fmt.Errorf("-stats-interval must be positive, got %v", cfg.StatsInterval)
+	}
+	if cfg.RateJitter < 0 || cfg.RateJitter >= 1 {
+		return nil, fmt.Errorf("-rate-jitter must be at least 0 and below 1, got %g", cfg.RateJitter)
+	}
+	if cfg.SeenBloom && cfg.SnapshotDir != "" {
+		return nil, errors.New("-snapshot-dir needs the exact seen set and can't be combined with -seen-bloom")
+	}
+	if !validStatsFormat(cfg.StatsFormat) {
+		return nil, fmt.Errorf("invalid -stats-format %q: want text or json", cfg.StatsFormat)
+	}
+	if err := model.ValidateTopicPrefix(cfg.TopicPrefix); err != nil {
+		return nil, err
+	}
+
+	s := &settings{
+		Config:              cfg,
+		allowedDomains:      normalizeDomainSet(cfg.AllowedDomains),
+		focusKeywords:       parseFocusKeywords(cfg.FocusKeywords),
+		robotsOverrideHosts: newHostPatterns(cfg.RobotsOverrideHosts),
+		trackingParams:      parseTrackingParams(cfg.TrackingParams),
+		captureHeaders:      parseCaptureHeaders(cfg.CaptureHeaders),
+		linkAttrs:           newAttrList(cfg.LinkAttrs),
+		mediaAttrs:          newAttrList(cfg.MediaAttrs),
+		trackingImages:      parseTrackingImages(cfg.TrackingImages),
+		safeHTMLTags:        parseSafeHTMLTags(cfg.SafeHTMLTags),
+		analysisProfiles:    compileAnalysisProfiles(builtinAnalysisProfiles),
+	}
+	var err error
+	if s.allowedSchemes, err = newSchemeSet(cfg.AllowedSchemes); err != nil {
+		return nil, fmt.Errorf("invalid -allowed-schemes: %w", err)
+	}
+	if cfg.HostConfigFile != "" {
+		if s.hostOverrides, err = loadHostConfig(cfg.HostConfigFile); err != nil {
+			return nil, err
+		}
+	}
+	if s.stripFilter, err = parseElementFilter(cfg.StripSelectors, cfg.KeepSelectors); err != nil {
+		return nil, fmt.Errorf("invalid element selectors: %w", err)
+	}
+	if s.volatile, err = parseVolatileRegions(cfg.VolatileSelectors, cfg.VolatilePattern); err != nil {
+		return nil, fmt.Errorf("invalid volatile regions: %w", err)
+	}
+	if s.dateLayouts, err = parseDateLayouts(cfg.DateLayouts); err != nil {
+		return nil, fmt.Errorf("invalid -date-layouts: %w", err)
+	}
+	if s.dateLocation, err = time.LoadLocation(cfg.DateTimezone); err != nil {
+		return nil, fmt.Errorf("invalid -date-timezone: %w", err)
+	}
+	if s.pipeline, err = documentPipeline(cfg.Processors); err != nil {
+		return nil, err
+	}
+	if cfg.AnalysisProfilesFile != "" {
+		if s.analysisProfiles, err = loadAnalysisProfiles(cfg.AnalysisProfilesFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.validateAnalysisProfile(); err != nil {
+		return nil, err
+	}
+	if s.rawProjection, err = parseProjection(cfg.EmitFields); err != nil {
+		return nil, fmt.Errorf("invalid -emit-fields: %w", err)
+	}
+	s.dreamProjection = s.rawProjection
+	if cfg.DreamEmitFields != "" {
+		if s.dreamProjection, err = parseProjection(cfg.DreamEmitFields); err != nil {
+			return nil, fmt.Errorf("invalid -dream-emit-fields: %w", err)
+		}
+	}
+	if s.serializer, err = newDocumentSerializer(cfg.Serialization, cfg.SchemaRegistry); err != nil {
+		return nil, fmt.Errorf("invalid -serialization: %w", err)
+	}
+	if s.loginFields, err = parseLoginFields(cfg.LoginFields); err != nil {
+		return nil, fmt.Errorf("invalid -login-fields: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,40 @@
+package crawler
+
+import "testing"
+
+// testSettings builds settings from DefaultConfig as edited by edit
+func testSettings(t testing.TB, edit func(*Config)) *settings {
+	t.Helper()
+	cfg := DefaultConfig()
+	if edit != nil {
+		edit(&cfg)
+	}
+	s, err := newSettings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewSettings(t *testing.T) {
+	if _, err := newSettings(DefaultConfig()); err != nil {
+		t.Fatalf("the default config is invalid: %v", err)
+	}
+
+	for name, edit := range map[string]func(*Config){
+		"no workers":        func(c *Config) { c.Workers = 0 },
+		"sample rate":       func(c *Config) { c.SampleRate = 2 },
+		"rate jitter":       func(c *Config) { c.RateJitter = 1 },
+		"bloom snapshots":   func(c *Config) { c.SeenBloom, c.SnapshotDir = true, t.TempDir() },
+		"stats format":      func(c *Config) { c.StatsFormat = "xml" },
+		"allowed schemes":   func(c *Config) { c.AllowedSchemes = []string{" "} },
+		"date timezone":     func(c *Config) { c.DateTimezone = "Mars/Olympus" },
+		"unknown processor": func(c *Config) { c.Processors = []string{"nope"} },
+	} {
+		cfg := DefaultConfig()
+		edit(&cfg)
+		if _, err := newSettings(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
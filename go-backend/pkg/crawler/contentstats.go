@@ -0,0 +1,47 @@
+package crawler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// RecordContent adds an emitted document to the crawl-wide content
+// summary, counted by model.ContentAggregator as the API counts indexed ones
+func (s *CrawlerStats) RecordContent(doc Document) {
+	if s.content == nil {
+		return
+	}
+	m, err := modelDocument(doc)
+	if err != nil {
+		log.Printf("Not counting %s in the content summary: %v", doc.URL, err)
+		return
+	}
+	s.content.AddDocument(m)
+}
+
+// contentLines renders the content summary for text reports
+func contentLines(content *model.ContentStats) []string {
+	if content == nil || content.Documents == 0 {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("Content across %d documents:", content.Documents),
+		"  Keywords: " + termList(content.Keywords),
+		"  Entities: " + termList(content.Entities),
+		"  Themes: " + termList(content.Themes),
+	}
+}
+
+func termList(terms []model.TermCount) string {
+	if len(terms) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = fmt.Sprintf("%s (%d)", term.Term, term.Count)
+	}
+	return strings.Join(parts, ", ")
+}
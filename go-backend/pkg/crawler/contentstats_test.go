@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+	"golang.org/x/time/rate"
+)
+
+// TestCrawlContentStats crawls three pages and checks the crawl-wide top
+// keyword is the term found in the most chunks across them, and that the
+// final report lists it.
+func TestCrawlContentStats(t *testing.T) {
+	pages := map[string]string{
+		"/": `<p>The lighthouse keeper dreamed of whales every winter.</p>
+			<p>Whales sang beneath the lighthouse until dawn.</p>
+			<a href="/a">a</a><a href="/b">b</a>`,
+		"/a": `<p>A lighthouse stood alone on the northern cliffs.</p>
+			<p>Fishermen trusted the lighthouse more than the stars.</p>`,
+		"/b": `<p>Whales passed the harbour on their long migration.</p>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body>%s</body></html>", pages[r.URL.Path])
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	stats := &CrawlerStats{content: model.NewContentAggregator(5)}
+	seen := mapSeen{}
+	c := &Crawler{client: server.Client(), cfg: testSettings(t, nil), seen: &seen, stats: stats,
+		hostMap: map[string]*hostPolicies{serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	for i := 0; i < 2; i++ {
+		go c.enhancedWorker(ctx, i, urlQueue, urlQueue, out)
+	}
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+
+	counts := make(map[string]int)
+	for range pages {
+		select {
+		case doc := <-out:
+			for _, chunk := range doc.Chunks {
+				for _, keyword := range chunk.Keywords {
+					counts[keyword]++
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("crawl did not finish")
+		}
+	}
+	want, most := "", 0
+	for keyword, n := range counts {
+		if n > most || n == most && keyword < want {
+			want, most = keyword, n
+		}
+	}
+	if want != "lighthouse" {
+		t.Fatalf("most frequent keyword is %q, want the test pages to favour lighthouse", want)
+	}
+
+	content := stats.Snapshot().Content
+	if content == nil || content.Documents != 3 || len(content.Keywords) == 0 {
+		t.Fatalf("content stats = %+v", content)
+	}
+	if top := content.Keywords[0]; top.Term != want || top.Count != int64(most) {
+		t.Errorf("top keyword = %v, want %s (%d)", top, want, most)
+	}
+
+	report := strings.Join(newCrawlReport(stats.Snapshot(), time.Second, 10, true).textLines(), "\n")
+	if !strings.Contains(report, fmt.Sprintf("Keywords: lighthouse (%d)", most)) {
+		t.Errorf("final report missing the top keyword:\n%s", report)
+	}
+}
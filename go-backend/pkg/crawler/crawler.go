@@ -0,0 +1,833 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// EventHook observes a crawl as it happens. Callbacks run on worker
+// goroutines, so implementations must be safe for concurrent use and
+// should return quickly.
+type EventHook interface {
+	// OnFetch is called after every completed HTTP fetch, whatever the status
+	OnFetch(url string, status int, elapsed time.Duration)
+	// OnError is called when a URL can't be fetched
+	OnError(url string, err error)
+	// OnDocument is called for every document handed to the output pipeline
+	OnDocument(doc Document)
+	// OnSkip is called when a URL is dropped without being fetched
+	OnSkip(url string, reason SkipReason)
+}
+
+// NopHook ignores every event. Embed it to implement only some callbacks.
+type NopHook struct{}
+
+func (NopHook) OnFetch(string, int, time.Duration) {}
+func (NopHook) OnError(string, error)              {}
+func (NopHook) OnDocument(Document)                {}
+func (NopHook) OnSkip(string, SkipReason)          {}
+
+// Crawler runs one crawl from its seeds until a budget limit is reached
+type Crawler struct {
+	cfg    *settings
+	client *http.Client
+	hook   EventHook
+	spool  *deliverySpool // nil unless DeliverySpool is set
+
+	hpMu          sync.Mutex
+	hostMap       map[string]*hostPolicies
+	seen          seenStore
+	crawled       *mapSeen // pages parsed successfully, nil unless SnapshotDir
+	stats         *CrawlerStats
+	graph         *linkGraph
+	failures      chan<- CrawlError // nil unless failures go to ErrorTopic
+	boilerplate   *boilerplateDetector
+	workerClients []*http.Client           // per worker under PerWorkerClient, else nil
+	media         *mediaRegistry           // nil unless DedupMedia
+	chunkFreq     *chunkFrequency          // nil unless BoilerplateChunkPages
+	templates     *templateLearner         // nil unless TemplatePages
+	contents      *contentStore            // nil unless ChangeStore
+	robotsFlight  flightGroup[struct{}]    // by host
+	fetchFlight   flightGroup[fetchedPage] // by canonical URL, under CoalesceFetches
+	parseQueue    chan parseJob            // nil unless ParseWorkers
+
+	// Autoscaling bounds, equal when it's off, and the channel that
+	// stops an idle worker when scaling down
+	minWorkers, maxWorkers int
+	retire                 chan struct{}
+
+	// Frontier persistence: nil store and tracker when it's off, and the
+	// URLs ResumeFrontier loaded for Run to queue first
+	frontier FrontierStore
+	pending  *pendingURLs
+	resumed  []URLWithMetadata
+}
+
+// New validates config and prepares a crawler; nothing is fetched until
+// Run. The files config names, such as the change store and the resume
+// snapshot, are loaded here, and the login form is posted.
+func New(config Config) (*Crawler, error) {
+	if len(config.Seeds) == 0 {
+		return nil, errors.New("at least one seed URL is required")
+	}
+	cfg, err := newSettings(config)
+	if err != nil {
+		return nil, err
+	}
+	lo, hi, err := workerBounds(cfg.Workers, cfg.MinWorkers, cfg.MaxWorkers)
+	if err != nil {
+		return nil, err
+	}
+
+	client := cfg.Client
+	if client == nil {
+		if client, err = cfg.newHTTPClient(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.LoginURL != "" {
+		if err := login(context.Background(), client, cfg.LoginURL, cfg.loginFields); err != nil {
+			return nil, err
+		}
+		log.Printf("Logged in via %s", cfg.LoginURL)
+	}
+	cfg.warnRobotsOverride()
+
+	var media *mediaRegistry
+	if cfg.DedupMedia {
+		media = newMediaRegistry(cfg.DedupMediaMax)
+	}
+	var chunkFreq *chunkFrequency
+	if cfg.BoilerplateChunkPages > 0 {
+		chunkFreq = newChunkFrequency(cfg.BoilerplateChunkPages, cfg.BoilerplateMaxChunks)
+	}
+	var templates *templateLearner
+	if cfg.TemplatePages > 0 {
+		templates = newTemplateLearner(cfg.TemplatePages, cfg.TemplateMaxFingerprints)
+	}
+	stats := &CrawlerStats{}
+	if cfg.ContentStatsTop > 0 {
+		stats.content = model.NewContentAggregator(cfg.ContentStatsTop)
+	}
+	var contents *contentStore
+	if cfg.ChangeStore != "" {
+		if contents, err = loadContentStore(cfg.ChangeStore, cfg.ChangeMaxText, cfg.ChangeMaxPages, cfg.canonicalURL); err != nil {
+			return nil, err
+		}
+	}
+
+	frontier := cfg.Frontier
+	if frontier == nil && cfg.FrontierFile != "" {
+		frontier = fileFrontier{path: cfg.FrontierFile}
+	}
+	var pending *pendingURLs
+	if frontier != nil {
+		pending = newPendingURLs()
+	}
+	var crawled *mapSeen
+	if cfg.SnapshotDir != "" {
+		crawled = &mapSeen{}
+	}
+	seen := cfg.newSeenStore()
+	if cfg.ResumeSnapshot != "" {
+		snap, err := loadSnapshot(cfg.ResumeSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Resuming from snapshot taken %s: %d URLs already visited",
+			snap.TakenAt.Format(time.RFC3339), snap.restore(seen, cfg.Seeds, cfg.canonicalURL))
+	}
+
+	// Failed deliveries are retried from the spool, when there is one
+	var spool *deliverySpool
+	if cfg.Producer != nil && cfg.DeliverySpool != "" {
+		if spool, err = openDeliverySpool(cfg.DeliverySpool, cfg.DeliverySpoolMaxBytes, cfg.DeliveryMaxAge, cfg.DeliveryRetryDelay); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Crawler{
+		cfg:         cfg,
+		client:      client,
+		hook:        cfg.Hook,
+		spool:       spool,
+		hostMap:     make(map[string]*hostPolicies),
+		seen:        seen,
+		crawled:     crawled,
+		stats:       stats,
+		boilerplate: newBoilerplateDetector(cfg.BoilerplateTitleRepeats, cfg.BoilerplateMaxWords, cfg.BoilerplateMaxTitles),
+		media:       media,
+		chunkFreq:   chunkFreq,
+		templates:   templates,
+		contents:    contents,
+		retire:      make(chan struct{}),
+		minWorkers:  lo,
+		maxWorkers:  hi,
+		frontier:    frontier,
+		pending:     pending,
+	}, nil
+}
+
+// Stats returns a copy of the crawl counters, safe to call while running
+func (c *Crawler) Stats() StatsSnapshot {
+	return c.stats.Snapshot()
+}
+
+// Run crawls until a budget limit is reached or ctx is cancelled, then
+// drains the output pipeline and returns why it stopped
+func (c *Crawler) Run(ctx context.Context) ShutdownReason {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	started := time.Now()
+
+	// Delivery reports are read for as long as the crawl runs, failures
+	// going to the spool
+	spoolCtx, stopSpool := context.WithCancel(context.Background())
+	defer stopSpool()
+	if c.cfg.Producer != nil {
+		if c.spool != nil {
+			producer := c.cfg.Producer
+			go c.spool.run(spoolCtx, func(msg *kafka.Message) error { return producer.Produce(msg, nil) }, spoolRetryTick)
+		}
+		go handleKafkaEvents(c.cfg.Producer, c.spool)
+	}
+
+	urlQueue := make(chan URLWithMetadata, c.cfg.QueueSize)
+	rawOut := make(chan Document)
+	dreamOut := make(chan Document)
+
+	// Workers enqueue into urlQueue; with host fairness they are fed
+	// round-robin across hosts instead of in arrival order
+	var workQueue <-chan URLWithMetadata = urlQueue
+	if c.cfg.HostFairness {
+		dispatch := make(chan URLWithMetadata)
+		go newHostScheduler(c.cfg.QueueSize).run(ctx, urlQueue, dispatch)
+		workQueue = dispatch
+	}
+
+	// Link graph edges, when there's a producer and GraphTopic is set
+	edges := make(chan LinkEdge, c.cfg.QueueSize)
+	graphDone := make(chan struct{})
+	if c.cfg.Producer != nil && c.cfg.GraphTopic != "" {
+		c.graph = newLinkGraph(edges, c.cfg.canonicalURL)
+	}
+	go func() {
+		defer close(graphDone)
+		if c.cfg.Producer == nil {
+			for range edges {
+			}
+			return
+		}
+		graphProducer(c.cfg.Producer, edges, c.cfg.prefixedTopic(c.cfg.GraphTopic))
+	}()
+
+	// Failed URLs, when there's a producer and ErrorTopic is set
+	failures := make(chan CrawlError, c.cfg.QueueSize)
+	failuresDone := make(chan struct{})
+	if c.cfg.Producer != nil && c.cfg.ErrorTopic != "" {
+		c.failures = failures
+	}
+	go func() {
+		defer close(failuresDone)
+		if c.failures == nil {
+			for range failures {
+			}
+			return
+		}
+		errorProducer(c.cfg.Producer, failures, c.cfg.prefixedTopic(c.cfg.ErrorTopic))
+	}()
+
+	// Change events, under ChangeStore
+	changes := make(chan ContentChange, c.cfg.QueueSize)
+	changesDone := make(chan struct{})
+	if c.contents != nil {
+		c.contents.out = changes
+	}
+	go func() {
+		defer close(changesDone)
+		if c.cfg.Producer == nil || c.cfg.ChangeTopic == "" {
+			for range changes {
+			}
+			return
+		}
+		changeProducer(c.cfg.Producer, changes, c.cfg.prefixedTopic(c.cfg.ChangeTopic))
+	}()
+
+	// Start enhanced crawler workers
+	var wg sync.WaitGroup
+	if c.cfg.PerWorkerClient {
+		c.workerClients = workerClients(c.client, c.maxWorkers)
+	}
+	if c.cfg.ParseWorkers > 0 {
+		c.parseQueue = make(chan parseJob, c.cfg.ParseWorkers)
+		for i := 0; i < c.cfg.ParseWorkers; i++ {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				c.parseWorker(ctx, id, c.parseQueue, urlQueue, rawOut)
+			}(i)
+		}
+	}
+	nextWorker := 0
+	startWorker := func() {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			c.enhancedWorker(ctx, id, workQueue, urlQueue, rawOut)
+		}(nextWorker)
+		nextWorker++
+	}
+	for i := 0; i < c.cfg.Workers; i++ {
+		startWorker()
+	}
+
+	// Autoscale between MinWorkers and MaxWorkers on the queue depth
+	scalerDone := make(chan struct{})
+	go func() {
+		defer close(scalerDone)
+		if c.minWorkers == c.maxWorkers {
+			c.stats.SetWorkers(c.cfg.Workers)
+			return
+		}
+		scaler := &autoscaler{
+			min:       c.minWorkers,
+			max:       c.maxWorkers,
+			highWater: c.cfg.ScaleHighWater,
+			depth:     func() int { return len(urlQueue) },
+			spawn:     startWorker,
+			retire:    c.retire,
+			stats:     c.stats,
+			workers:   c.cfg.Workers,
+		}
+		scaler.run(ctx, c.cfg.ScaleInterval)
+	}()
+
+	// Dream processor (if enabled)
+	dreamDone := make(chan struct{})
+	if c.cfg.EnableDreaming {
+		go func() {
+			defer close(dreamDone)
+			c.cfg.dreamProcessor(ctx, rawOut, dreamOut)
+		}()
+	} else {
+		// If dreaming is disabled, just pass through
+		go func() {
+			defer close(dreamDone)
+			for doc := range rawOut {
+				dreamOut <- doc
+			}
+		}()
+	}
+
+	// Buffer output so a slow broker doesn't stall the workers
+	bufferedOut := make(chan Document)
+	go newSpillBuffer(c.cfg.OutputBuffer, c.cfg.SpillDir).run(dreamOut, bufferedOut)
+
+	// Seed the queue, after any URLs resumed from the saved frontier
+	go func() {
+		seeds := append([]URLWithMetadata{}, c.resumed...)
+		for _, s := range c.cfg.Seeds {
+			seeds = append(seeds, URLWithMetadata{URL: s, Metadata: URLMetadata{depth: 0, priority: 10}})
+		}
+		for _, u := range seeds {
+			c.pending.queued(u)
+			select {
+			case urlQueue <- u:
+			case <-ctx.Done():
+				return
+			}
+		}
+		c.enqueueSitemaps(ctx, urlQueue, c.cfg.Sitemaps)
+	}()
+
+	// Enhanced producer with multiple topics
+	producerDone := make(chan struct{})
+	go func() {
+		defer close(producerDone)
+		if c.cfg.Producer == nil {
+			for range bufferedOut {
+			}
+			return
+		}
+		c.cfg.enhancedProducer(c.cfg.Producer, bufferedOut)
+	}()
+
+	// Stats reporter
+	go c.cfg.statsReporter(ctx, c.stats, started)
+
+	if c.frontier != nil {
+		go frontierSaver(ctx, c.frontier, c.pending, c.cfg.FrontierInterval)
+	}
+	if c.cfg.SnapshotDir != "" {
+		go snapshotter(ctx, c.cfg.SnapshotDir, c.cfg.SnapshotInterval, c.crawled, c.stats)
+	}
+
+	reason := c.cfg.waitForBudget(ctx, c.stats, budgetPollInterval)
+	if reason == "" {
+		reason = ShutdownCancelled
+	}
+	c.stats.SetShutdownReason(reason)
+
+	log.Printf("Shutting down gracefully (%s)...", reason)
+	cancel()
+	<-scalerDone // it may still be starting a worker
+	wg.Wait()
+	c.dropParseJobs()
+	close(edges)
+	close(failures)
+	close(changes)
+	close(rawOut)
+	<-dreamDone
+	close(dreamOut)
+	<-producerDone // buffered and spilled documents are produced first
+	<-graphDone
+	<-failuresDone
+	<-changesDone
+	if c.cfg.Producer != nil {
+		c.cfg.Producer.Flush(15 * 1000)
+	}
+
+	if c.contents != nil {
+		if err := c.contents.save(c.cfg.ChangeStore); err != nil {
+			log.Printf("Saving change store failed: %v", err)
+		}
+	}
+	if c.frontier != nil {
+		saveFrontier(c.frontier, c.pending)
+	}
+	if c.cfg.SnapshotDir != "" {
+		if path, err := writeSnapshot(c.cfg.SnapshotDir, c.crawled, c.stats); err != nil {
+			log.Printf("Final snapshot failed: %v", err)
+		} else {
+			log.Printf("Final snapshot written to %s", path)
+		}
+	}
+
+	// Final stats
+	report := newCrawlReport(c.Stats(), time.Since(started), c.cfg.ReportTopHosts, true)
+	emitReport(report, c.cfg.StatsFormat)
+	if c.cfg.ReportFile != "" {
+		if err := writeReportFile(c.cfg.ReportFile, report, c.cfg.StatsFormat); err != nil {
+			log.Printf("Failed to write report file: %v", err)
+		} else {
+			log.Printf("Final report written to %s", c.cfg.ReportFile)
+		}
+	}
+	return reason
+}
+
+// events returns the hook to notify, never nil
+func (c *Crawler) events() EventHook {
+	if c.hook == nil {
+		return NopHook{}
+	}
+	return c.hook
+}
+
+// skip counts a URL dropped for reason and reports it to the hook
+func (c *Crawler) skip(rawurl string, reason SkipReason) {
+	c.stats.IncrementSkipped(reason)
+	c.events().OnSkip(rawurl, reason)
+}
+
+// fail counts a URL that couldn't be fetched, by error category, reports
+// it to the hook and queues it for ErrorTopic
+func (c *Crawler) fail(host, rawurl string, err error) {
+	c.stats.IncrementErrors()
+	c.stats.IncrementErrorCategory(errorCategory(err))
+	if host != "" {
+		c.stats.IncrementHostErrors(host)
+	}
+	c.events().OnError(rawurl, err)
+	if c.failures != nil {
+		// Drained until every worker has exited, so this can't block forever
+		c.failures <- newCrawlError(host, rawurl, err)
+	}
+}
+
+var errURLDeadline = errors.New("url deadline exceeded")
+
+// deadlinePassed reports whether the URL has run out of URLDeadline
+func (m URLMetadata) deadlinePassed() bool {
+	return !m.deadline.IsZero() && !time.Now().Before(m.deadline)
+}
+
+// maxWait caps a rate-limiter wait at the URL's remaining deadline, so a
+// URL that can't be fetched in time is deferred rather than waited on
+func (m URLMetadata) maxWait(limit time.Duration) time.Duration {
+	if m.deadline.IsZero() {
+		return limit
+	}
+	if remaining := time.Until(m.deadline); remaining < limit {
+		return remaining
+	}
+	return limit
+}
+
+// hostFailed feeds a failed fetch to the host's breaker and records the
+// resulting state
+func (c *Crawler) hostFailed(host string, hp *hostPolicies) {
+	state := hp.breaker.failure(time.Now(), hp.lim)
+	if state == breakerOpen {
+		log.Printf("circuit breaker open for %s, skipping it for %v", host, c.cfg.BreakerCooldown)
+	}
+	c.stats.SetHostBreaker(host, state)
+}
+
+// inScope reports whether host passes the domain whitelist, comparing in
+// punycode so Unicode and ASCII spellings of a host match
+func (c *Crawler) inScope(host string) bool {
+	if c.cfg.allowedDomains == nil {
+		return true
+	}
+	if ascii, err := toASCIIHost(host); err == nil {
+		host = ascii
+	}
+	return c.cfg.allowedDomains[host]
+}
+
+// clientFor returns worker id's HTTP client
+func (c *Crawler) clientFor(id int) *http.Client {
+	if id < len(c.workerClients) {
+		return c.workerClients[id]
+	}
+	return c.client
+}
+
+// Enhanced worker with AI-ready content extraction. URLs are read from
+// urlQueue and discovered links are offered to frontier.
+func (c *Crawler) enhancedWorker(ctx context.Context, id int, urlQueue <-chan URLWithMetadata, frontier chan<- URLWithMetadata, out chan<- Document) {
+	client := c.clientFor(id)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.retire:
+			return
+		case urlMeta := <-urlQueue:
+			if urlMeta.URL == "" {
+				continue
+			}
+			c.pending.taken(urlMeta.URL)
+
+			// Out of budget: stop dequeuing while Run shuts the crawl down
+			if c.cfg.budgetExceeded(c.stats) != "" {
+				c.pending.queued(urlMeta)
+				return
+			}
+
+			// Skip if already seen; retries and interrupted URLs claimed
+			// their entry the first time
+			if urlMeta.Metadata.retries == 0 && !urlMeta.Metadata.claimed {
+				if c.seen.visit(c.cfg.canonicalURL(urlMeta.URL)) {
+					c.skip(urlMeta.URL, SkipSeen)
+					continue
+				}
+			}
+
+			// Respect max depth
+			if urlMeta.Metadata.depth > c.cfg.MaxDepth {
+				c.skip(urlMeta.URL, SkipDepth)
+				continue
+			}
+
+			// The URL's deadline starts on first dequeue and survives retries
+			if urlMeta.Metadata.deadline.IsZero() && c.cfg.URLDeadline > 0 {
+				urlMeta.Metadata.deadline = time.Now().Add(c.cfg.URLDeadline)
+			}
+			if urlMeta.Metadata.deadlinePassed() {
+				log.Printf("worker %d: deadline exceeded, abandoning %s", id, urlMeta.URL)
+				c.fail("", urlMeta.URL, &FetchError{Category: FetchTimeout, URL: urlMeta.URL, Err: errURLDeadline})
+				continue
+			}
+
+			parsed, err := url.Parse(urlMeta.URL)
+			if err != nil {
+				log.Printf("worker %d: bad url %s: %v", id, urlMeta.URL, err)
+				c.fail("", urlMeta.URL, err)
+				continue
+			}
+
+			// Domain whitelist check
+			if !c.inScope(parsed.Host) {
+				c.skip(urlMeta.URL, SkipScope)
+				continue
+			}
+
+			host := parsed.Host
+
+			// Get/create host policies
+			c.hpMu.Lock()
+			hp, ok := c.hostMap[host]
+			if !ok && c.cfg.MaxHosts > 0 && len(c.hostMap) >= c.cfg.MaxHosts {
+				c.hpMu.Unlock()
+				c.skip(urlMeta.URL, SkipHostLimit)
+				continue
+			}
+			if !ok {
+				hp = c.cfg.newHostPolicies(host)
+				c.hostMap[host] = hp
+				c.stats.IncrementDistinctHosts()
+			}
+			c.hpMu.Unlock()
+			c.loadRobots(client, parsed, hp)
+
+			// Robots.txt check
+			if hp.robots != nil && !hp.ignoreRobots && !hp.robots.TestAgent(parsed.Path, "WebCrawlerThatDreams/1.0") {
+				log.Printf("worker %d: disallowed by robots: %s", id, urlMeta.URL)
+				c.skip(urlMeta.URL, SkipRobots)
+				continue
+			}
+
+			// A URL another worker is already fetching is left to that fetch.
+			// Waiting for it here, before the rate limiter and the host's
+			// slots, costs the host no token or slot.
+			if c.cfg.CoalesceFetches && c.fetchFlight.wait(ctx, c.cfg.canonicalURL(urlMeta.URL)) {
+				if ctx.Err() != nil {
+					c.interrupted(urlMeta)
+					return
+				}
+				c.skip(urlMeta.URL, SkipSeen)
+				continue
+			}
+
+			// Hosts that keep failing are left alone until their cooldown
+			allowed, probe := hp.breaker.allow(time.Now())
+			if !allowed {
+				c.skip(urlMeta.URL, SkipBreaker)
+				continue
+			}
+			// A half-open probe that ends without a success or failure is
+			// given up, or the host would stay skipped for good
+			releaseProbe := func() {
+				if probe {
+					hp.breaker.release()
+				}
+			}
+
+			// Rate limiting: rather than block on a slow host, defer the URL
+			// and keep working on others
+			if delay, err := reserveOrDefer(ctx, hp, urlMeta.Metadata.maxWait(c.cfg.MaxLimiterWait), c.cfg.RateJitter); err != nil {
+				releaseProbe()
+				c.interrupted(urlMeta)
+				continue
+			} else if delay > 0 {
+				if c.cfg.scheduleRetry(ctx, frontier, urlMeta, delay, c.pending) {
+					releaseProbe()
+					c.stats.IncrementRetries()
+					continue
+				}
+				// Out of retries, so wait our turn after all
+				if _, err := reserveOrDefer(ctx, hp, math.MaxInt64, c.cfg.RateJitter); err != nil {
+					releaseProbe()
+					c.interrupted(urlMeta)
+					continue
+				}
+			}
+
+			// Fetch; the page is parsed below or by a parse worker
+			log.Printf("worker %d: fetching %s (depth: %d)", id, urlMeta.URL, urlMeta.Metadata.depth)
+			start := time.Now()
+			fetchCtx, cancelFetch := ctx, context.CancelFunc(func() {})
+			if !urlMeta.Metadata.deadline.IsZero() {
+				fetchCtx, cancelFetch = context.WithDeadline(ctx, urlMeta.Metadata.deadline)
+			}
+			// Under the per-host concurrency cap from -host-config
+			page, err, shared := c.fetch(fetchCtx, client, hp, urlMeta)
+			cancelFetch()
+			if ctx.Err() != nil {
+				if !shared {
+					page.release(c.cfg)
+				}
+				releaseProbe()
+				c.interrupted(urlMeta)
+				return
+			}
+			if shared {
+				// Another worker fetched it and handles the result
+				releaseProbe()
+				c.skip(urlMeta.URL, SkipSeen)
+				continue
+			}
+			if errors.Is(err, context.DeadlineExceeded) && urlMeta.Metadata.deadlinePassed() {
+				log.Printf("worker %d: deadline exceeded, abandoning %s", id, urlMeta.URL)
+				releaseProbe()
+				c.fail(host, urlMeta.URL, &FetchError{Category: FetchTimeout, URL: urlMeta.URL, Err: errURLDeadline})
+				continue
+			}
+			if err != nil {
+				log.Printf("worker %d: fetch error %s: %v", id, urlMeta.URL, err)
+				c.fail(host, urlMeta.URL, err)
+				c.hostFailed(host, hp)
+				continue
+			}
+			status := page.doc.Status
+			c.events().OnFetch(urlMeta.URL, status, time.Since(start))
+
+			if status >= http.StatusInternalServerError {
+				c.hostFailed(host, hp)
+			} else if status != http.StatusTooManyRequests && hp.breaker.success(hp.lim) {
+				c.stats.SetHostBreaker(host, breakerClosed)
+			}
+
+			if status == http.StatusTooManyRequests {
+				releaseProbe()
+				delay := c.cfg.retryDelay(urlMeta.Metadata.retries, page.retryAfter)
+				if c.cfg.scheduleRetry(ctx, frontier, urlMeta, delay, c.pending) {
+					log.Printf("worker %d: rate limited, retrying %s in %v", id, urlMeta.URL, delay)
+					c.stats.IncrementRetries()
+				} else {
+					log.Printf("worker %d: rate limited, giving up on %s", id, urlMeta.URL)
+					c.fail(host, urlMeta.URL, &FetchError{
+						Category: FetchHTTPStatus,
+						URL:      urlMeta.URL,
+						Status:   status,
+						Err:      fmt.Errorf("still rate limited after %d retries", urlMeta.Metadata.retries),
+					})
+				}
+				continue
+			}
+
+			// Parsed here, or by a parse worker under ParseWorkers
+			job := parseJob{urlMeta: urlMeta, host: host, page: page}
+			if c.parseQueue == nil {
+				if !c.handlePage(ctx, fmt.Sprintf("worker %d", id), job, frontier, out) {
+					return
+				}
+				continue
+			}
+			select {
+			case c.parseQueue <- job:
+			case <-ctx.Done():
+				page.release(c.cfg)
+				c.interrupted(urlMeta)
+				return
+			}
+		}
+	}
+}
+
+// handlePage parses a fetched page, emits its document and queues its
+// links on frontier. It reports false once ctx is done.
+func (c *Crawler) handlePage(ctx context.Context, who string, job parseJob, frontier chan<- URLWithMetadata, out chan<- Document) bool {
+	urlMeta, host := job.urlMeta, job.host
+	parseCtx, cancelParse := ctx, context.CancelFunc(func() {})
+	if !urlMeta.Metadata.deadline.IsZero() {
+		parseCtx, cancelParse = context.WithDeadline(ctx, urlMeta.Metadata.deadline)
+	}
+	parser := pageParser{chunkFreq: c.chunkFreq, templates: c.templates}
+	doc, newLinks, err := c.cfg.parsePage(parseCtx, job.page, urlMeta.Metadata, parser)
+	cancelParse()
+	if ctx.Err() != nil {
+		c.interrupted(urlMeta)
+		return false
+	}
+	if err != nil {
+		// Unlike fetch errors, these don't count against the host's breaker
+		log.Printf("%s: parse error %s: %v", who, urlMeta.URL, err)
+		c.fail(host, urlMeta.URL, err)
+		return true
+	}
+
+	c.stats.IncrementPages()
+	c.stats.IncrementHostPages(host)
+	if c.crawled != nil && doc.Status < http.StatusInternalServerError {
+		// Only these go in snapshots, so failed URLs are retried on resume
+		c.crawled.add(c.cfg.canonicalURL(urlMeta.URL))
+	}
+	c.stats.AddBytes(doc.Metadata.Size)
+	c.stats.RecordTimings(doc.Timings)
+
+	if c.boilerplate.observe(doc) {
+		doc.Metadata.Boilerplate = true
+		if c.cfg.BoilerplateSkipDreams {
+			doc.DreamHints = DreamingHints{}
+		}
+		for i := range newLinks {
+			newLinks[i].Priority = max(1, newLinks[i].Priority-boilerplatePriorityPenalty)
+		}
+	}
+
+	if !c.contents.record(ctx, doc) {
+		return false
+	}
+
+	// Variants of crawled pages aren't emitted twice. Soft 404s are
+	// dropped unless asked for or there's a quarantine topic.
+	if c.crawledCanonical(urlMeta.URL, doc.Metadata.Canonical) {
+		log.Printf("%s: variant of %s, already crawled, not emitting: %s", who, doc.Metadata.Canonical, urlMeta.URL)
+	} else if doc.Metadata.Soft404 && !c.cfg.EmitSoft404 && c.cfg.QuarantineTopic == "" {
+		log.Printf("%s: soft 404, not emitting: %s", who, urlMeta.URL)
+	} else if c.cfg.tooThin(doc) {
+		log.Printf("%s: thin page (%d words), not emitting: %s", who, doc.Metadata.WordCount, urlMeta.URL)
+	} else if !c.cfg.sampled(urlMeta.URL, c.cfg.SampleRate) {
+		c.stats.IncrementSampledOut()
+	} else {
+		// Only emitted pages claim an asset's first occurrence
+		doc.Media = c.media.dedup(doc.Media, c.cfg.DedupMediaMark)
+		// Sends must not outlive ctx: downstream may have stopped reading
+		select {
+		case out <- doc:
+			c.stats.RecordContent(doc)
+			c.events().OnDocument(doc)
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	newLinks, pruned := c.cfg.focusLinks(newLinks, doc, urlMeta.Metadata.depth+1)
+	for _, link := range pruned {
+		c.skip(link.URL, SkipIrrelevant)
+	}
+
+	newLinks, paginate := c.cfg.withNextPage(newLinks, doc, urlMeta.Metadata)
+
+	// Queue new links with incremented depth; the next page of a
+	// series is lateral and stays at this depth
+	for _, link := range linksToFollow(newLinks, c.cfg.MaxFollowPerPage) {
+		newMeta := URLMetadata{
+			depth:    urlMeta.Metadata.depth + 1,
+			parent:   urlMeta.URL,
+			priority: link.Priority,
+		}
+		if paginate && link.URL == doc.NextPage {
+			newMeta.depth = urlMeta.Metadata.depth
+			newMeta.pageRun = urlMeta.Metadata.pageRun + 1
+		}
+		next := URLWithMetadata{URL: link.URL, Metadata: newMeta}
+		c.pending.queued(next)
+		select {
+		case frontier <- next:
+			edge := LinkEdge{
+				From:       urlMeta.URL,
+				To:         link.URL,
+				AnchorText: link.Text,
+				Depth:      newMeta.depth,
+				Priority:   link.Priority,
+			}
+			if !c.graph.record(ctx, edge) {
+				return false
+			}
+		case <-ctx.Done():
+			return false
+		default:
+			// Queue full, drop low priority links
+			c.pending.taken(link.URL)
+			c.skip(link.URL, SkipQueueFull)
+			if link.Priority >= 5 {
+				log.Printf("%s: queue full, dropping link: %s", who, link.URL)
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingHook keeps every event it is sent
+type recordingHook struct {
+	mu       sync.Mutex
+	statuses map[string]int
+	docs     []string
+	errs     []string
+	skips    map[string]SkipReason
+}
+
+func (h *recordingHook) OnFetch(url string, status int, elapsed time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.statuses[url] = status
+}
+
+func (h *recordingHook) OnError(url string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errs = append(h.errs, url)
+}
+
+func (h *recordingHook) OnDocument(doc Document) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.docs = append(h.docs, doc.URL)
+}
+
+func (h *recordingHook) OnSkip(url string, reason SkipReason) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.skips[url] = reason
+}
+
+// TestNewValidatesConfig checks that New rejects unusable configs.
+func TestNewValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*Config)
+	}{
+		{"no seeds", func(c *Config) { c.Seeds = nil }},
+		{"no workers", func(c *Config) { c.Workers = 0 }},
+		{"no queue", func(c *Config) { c.QueueSize = 0 }},
+		{"no stats interval", func(c *Config) { c.StatsInterval = 0 }},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Seeds = []string{"https://example.com/"}
+		cfg.Client = &http.Client{}
+		tt.edit(&cfg)
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: New succeeded, want error", tt.name)
+		}
+	}
+}
+
+// TestMaxHosts seeds a page linking to four other hosts and checks that
+// with -max-hosts=3 only three distinct hosts are crawled.
+func TestMaxHosts(t *testing.T) {
+	var links strings.Builder
+	var servers []*httptest.Server
+	for i := 0; i < 5; i++ {
+		i := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			body := fmt.Sprintf("<p>Host %d</p>", i)
+			if i == 0 {
+				body += links.String()
+			}
+			fmt.Fprintf(w, "<html><body>%s</body></html>", body)
+		}))
+		defer server.Close()
+		servers = append(servers, server)
+		if i > 0 {
+			fmt.Fprintf(&links, `<a href="%s/">Host %d</a>`, server.URL, i)
+		}
+	}
+
+	queue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+	c := &Crawler{cfg: testSettings(t, func(c *Config) { c.MaxHosts = 3 }), client: http.DefaultClient, hostMap: map[string]*hostPolicies{}, seen: &seen, stats: stats}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.enhancedWorker(ctx, 0, queue, queue, out)
+
+	queue <- URLWithMetadata{URL: servers[0].URL + "/"}
+	waitFor(t, "all five URLs", func() bool { return stats.progress() == 5 })
+	cancel()
+
+	crawled := map[string]bool{}
+	for len(out) > 0 {
+		u, _ := url.Parse((<-out).URL)
+		crawled[u.Host] = true
+	}
+	snap := stats.Snapshot()
+	if len(crawled) != 3 || snap.PagesProcessed != 3 {
+		t.Errorf("crawled %d pages on hosts %v, want 3 hosts", snap.PagesProcessed, crawled)
+	}
+	if snap.DistinctHosts != 3 || snap.SkippedHostLimit != 2 {
+		t.Errorf("distinct_hosts = %d, skipped_host_limit = %d; want 3 and 2", snap.DistinctHosts, snap.SkippedHostLimit)
+	}
+}
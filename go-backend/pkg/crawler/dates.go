@@ -0,0 +1,139 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// defaultDateLayouts is -date-layouts when unset. Day/month orders that
+// vary by locale, like 01/02/2006, are left out on purpose: a date that
+// could be read two ways is skipped rather than guessed.
+var defaultDateLayouts = []string{"rfc3339", "rfc1123", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02", "unix"}
+
+// parseDateLayouts validates a -date-layouts value
+func parseDateLayouts(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return defaultDateLayouts, nil
+	}
+	var layouts []string
+	for _, layout := range strings.Split(spec, ",") {
+		layout = strings.TrimSpace(layout)
+		switch {
+		case layout == "":
+			continue
+		case layout == "rfc3339", layout == "rfc1123", layout == "unix":
+		case !strings.Contains(layout, "2006"):
+			return nil, fmt.Errorf("date layout %q has no year (2006); want rfc3339, rfc1123, unix or a Go layout", layout)
+		}
+		layouts = append(layouts, layout)
+	}
+	return layouts, nil
+}
+
+// parsePublishedDate tries each of -date-layouts in order and returns the
+// first match in UTC. Values without a zone are read in -date-timezone.
+func (cfg *settings) parsePublishedDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range cfg.dateLayouts {
+		var t time.Time
+		var ok bool
+		switch layout {
+		case "rfc3339":
+			t, ok = parseLayout(time.RFC3339, value, cfg.dateLocation)
+		case "rfc1123":
+			if t, ok = parseLayout(time.RFC1123Z, value, cfg.dateLocation); !ok {
+				t, ok = parseZoneName(time.RFC1123, value, cfg.dateLocation)
+			}
+		case "unix":
+			t, ok = parseEpoch(value)
+		default:
+			t, ok = parseLayout(layout, value, cfg.dateLocation)
+		}
+		if ok {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseLayout(layout, value string, loc *time.Location) (time.Time, bool) {
+	t, err := time.ParseInLocation(layout, value, loc)
+	return t, err == nil
+}
+
+// parseZoneName parses a layout with a zone abbreviation. Go gives an
+// abbreviation it doesn't know a zero offset, so only UTC's names are
+// trusted; "EST" or "CEST" are skipped rather than read as UTC.
+func parseZoneName(layout, value string, loc *time.Location) (time.Time, bool) {
+	t, ok := parseLayout(layout, value, loc)
+	if !ok {
+		return t, false
+	}
+	switch name, offset := t.Zone(); {
+	case offset != 0, name == "GMT", name == "UTC", name == "UT", name == "Z":
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// parseEpoch reads Unix seconds (10 digits) or milliseconds (13 digits).
+// Other lengths, like 20240517, are more likely something else.
+func parseEpoch(value string) (time.Time, bool) {
+	if len(value) != 10 && len(value) != 13 {
+		return time.Time{}, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}, false
+	}
+	if len(value) == 13 {
+		return time.UnixMilli(n), true
+	}
+	return time.Unix(n, 0), true
+}
+
+// jsonLDPublishedAt parses the first datePublished in the page's JSON-LD
+// blocks, looking through arrays and @graph lists. It is nil when none has
+// one it can read.
+func (cfg *settings) jsonLDPublishedAt(doc *goquery.Document) *time.Time {
+	var published *time.Time
+	doc.Find("script[type='application/ld+json']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		if t, ok := cfg.parsePublishedDate(findDatePublished(data)); ok {
+			published = &t
+			return false
+		}
+		return true
+	})
+	return published
+}
+
+func findDatePublished(data interface{}) string {
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if date := findDatePublished(item); date != "" {
+				return date
+			}
+		}
+	case map[string]interface{}:
+		if date, ok := v["datePublished"].(string); ok && date != "" {
+			return date
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findDatePublished(graph)
+		}
+	}
+	return ""
+}
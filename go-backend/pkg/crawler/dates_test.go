@@ -0,0 +1,123 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParsePublishedDate(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	cfg := testSettings(t, nil)
+	cfg.dateLocation = newYork
+
+	want := time.Date(2024, 5, 17, 14, 30, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-05-17T16:30:00+02:00", want},
+		{"2024-05-17T14:30:00Z", want},
+		{"Fri, 17 May 2024 14:30:00 GMT", want},
+		{"Fri, 17 May 2024 10:30:00 -0400", want},
+		{"2024-05-17T10:30:00", want}, // no zone, read in -date-timezone
+		{"2024-05-17 10:30:00", want},
+		{"2024-05-17", time.Date(2024, 5, 17, 4, 0, 0, 0, time.UTC)},
+		{"1715956200", want},
+		{"1715956200000", want},
+		{"  2024-05-17T14:30:00Z\n", want},
+	}
+	for _, tt := range tests {
+		got, ok := cfg.parsePublishedDate(tt.value)
+		if !ok || !got.Equal(tt.want) || got.Location() != time.UTC {
+			t.Errorf("parsePublishedDate(%q) = %v, %v; want %v", tt.value, got, ok, tt.want)
+		}
+	}
+}
+
+// TestParsePublishedDateRejectsAmbiguous checks values that could be read
+// more than one way are skipped rather than guessed.
+func TestParsePublishedDateRejectsAmbiguous(t *testing.T) {
+	cfg := testSettings(t, nil)
+	for _, value := range []string{
+		"",
+		"03/04/2021",                    // March 4th or April 3rd
+		"Mon, 02 Jan 2006 15:04:05 EST", // zone Go can't place
+		"20240517",                      // not an epoch
+		"yesterday",
+	} {
+		if got, ok := cfg.parsePublishedDate(value); ok {
+			t.Errorf("parsePublishedDate(%q) = %v, want rejected", value, got)
+		}
+	}
+}
+
+func TestParseDateLayouts(t *testing.T) {
+	layouts, err := parseDateLayouts(" unix, 02.01.2006 ,,rfc3339")
+	if err != nil || fmt.Sprint(layouts) != "[unix 02.01.2006 rfc3339]" {
+		t.Errorf("layouts = %v, %v", layouts, err)
+	}
+	if layouts, _ := parseDateLayouts(""); len(layouts) != len(defaultDateLayouts) {
+		t.Errorf("empty spec = %v, want the defaults", layouts)
+	}
+	if _, err := parseDateLayouts("rfc3339,Jan 2"); err == nil {
+		t.Error("a layout without a year was accepted")
+	}
+
+	cfg := testSettings(t, func(c *Config) { c.DateLayouts = "02.01.2006" })
+	if got, ok := cfg.parsePublishedDate("17.05.2024"); !ok || !got.Equal(time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("custom layout = %v, %v", got, ok)
+	}
+	if _, ok := cfg.parsePublishedDate("2024-05-17T14:30:00Z"); ok {
+		t.Error("a layout not in -date-layouts was used")
+	}
+}
+
+// TestPublishedDateSources fetches pages dated by JSON-LD, by meta tag and
+// by both, where the meta tag wins.
+func TestPublishedDateSources(t *testing.T) {
+	pages := map[string]string{
+		"/jsonld": `<html><head><script type="application/ld+json">
+			{"@context": "https://schema.org", "@graph": [
+				{"@type": "WebSite", "name": "Dreams"},
+				{"@type": "Article", "datePublished": "2024-05-17T16:30:00+02:00"}]}
+			</script></head><body><p>Text</p></body></html>`,
+		"/meta": `<html><head><meta property="article:published_time" content="1715956200">
+			</head><body><p>Text</p></body></html>`,
+		"/both": `<html><head><meta name="date" content="Fri, 17 May 2024 14:30:00 GMT">
+			<script type="application/ld+json">[{"datePublished": "2020-01-01"}]</script>
+			</head><body><p>Text</p></body></html>`,
+		"/bad": `<html><head><meta name="date" content="03/04/2021">
+			<script type="application/ld+json">{"datePublished": "soon"}</script>
+			</head><body><p>Text</p></body></html>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	defer server.Close()
+
+	want := time.Date(2024, 5, 17, 14, 30, 0, 0, time.UTC)
+	for path := range pages {
+		doc, _, err := testSettings(t, nil).enhancedFetchAndParse(context.Background(), server.Client(), server.URL+path, URLMetadata{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := doc.Metadata.PublishedAt
+		if path == "/bad" {
+			if got != nil {
+				t.Errorf("%s: published = %v, want none", path, got)
+			}
+			continue
+		}
+		if got == nil || !got.Equal(want) {
+			t.Errorf("%s: published = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// deadlineHook records the errors a crawl reports
+type deadlineHook struct {
+	NopHook
+	mu   sync.Mutex
+	errs map[string]error
+}
+
+func (h *deadlineHook) OnError(url string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errs[url] = err
+}
+
+// TestURLDeadlineAbandonsSlowPage checks that a page slower than
+// -url-deadline is abandoned and counted as an error, and that the worker
+// goes on to the next URL.
+func TestURLDeadlineAbandonsSlowPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><p>Page %s</p></body></html>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+	}
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+	hook := &deadlineHook{errs: make(map[string]error)}
+
+	c := &Crawler{cfg: testSettings(t, func(c *Config) { c.URLDeadline = 100 * time.Millisecond }), client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats, hook: hook}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+
+	start := time.Now()
+	urlQueue <- URLWithMetadata{URL: server.URL + "/slow"}
+	urlQueue <- URLWithMetadata{URL: server.URL + "/fast"}
+
+	select {
+	case doc := <-out:
+		if doc.URL != server.URL+"/fast" {
+			t.Errorf("emitted %s, want %s/fast", doc.URL, server.URL)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("worker never got past the slow page")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("slow page held the worker for %v", elapsed)
+	}
+
+	snap := stats.Snapshot()
+	if snap.Errors != 1 {
+		t.Errorf("Errors = %d, want 1", snap.Errors)
+	}
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if err := hook.errs[server.URL+"/slow"]; !errors.Is(err, errURLDeadline) {
+		t.Errorf("error for /slow = %v, want %v", err, errURLDeadline)
+	}
+}
+
+// TestURLDeadlineMetadata checks the deadline helpers a retried URL relies
+// on: a passed deadline is detected and limiter waits are capped by it.
+func TestURLDeadlineMetadata(t *testing.T) {
+	meta := URLMetadata{retries: 1, deadline: time.Now().Add(-time.Second)}
+	if !meta.deadlinePassed() {
+		t.Error("deadlinePassed = false for a deadline in the past")
+	}
+	if (URLMetadata{}).deadlinePassed() {
+		t.Error("deadlinePassed = true without a deadline")
+	}
+
+	meta.deadline = time.Now().Add(50 * time.Millisecond)
+	if got := meta.maxWait(5 * time.Second); got > 50*time.Millisecond {
+		t.Errorf("maxWait = %v, want it capped by the deadline", got)
+	}
+	if got := (URLMetadata{}).maxWait(5 * time.Second); got != 5*time.Second {
+		t.Errorf("maxWait without deadline = %v, want 5s", got)
+	}
+}
@@ -0,0 +1,168 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsLookupTimeout bounds a shared lookup, which runs apart from the
+// context of the dial that started it
+const dnsLookupTimeout = 10 * time.Second
+
+// hostResolver is the part of *net.Resolver the DNS cache needs
+type hostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// dialFunc matches http.Transport.DialContext
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dnsCache resolves hosts once per TTL and hands out their addresses in
+// rotation so connections spread over every A/AAAA record. Concurrent
+// lookups of the same host share one query. Only "not found" answers are
+// negatively cached; timeouts and server failures are retried next dial.
+// Expired entries are swept out as new ones are added.
+type dnsCache struct {
+	resolver hostResolver
+	ttl      time.Duration
+	negTTL   time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*dnsEntry
+	inflight  map[string]*dnsCall
+	nextSweep time.Time
+}
+
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+	next    int // index of the address the next dial starts from
+}
+
+// dnsCall is a lookup in progress; entry is set before done is closed
+type dnsCall struct {
+	done  chan struct{}
+	entry *dnsEntry
+}
+
+func newDNSCache(resolver hostResolver, ttl, negTTL time.Duration) *dnsCache {
+	return &dnsCache{
+		resolver: resolver,
+		ttl:      ttl,
+		negTTL:   negTTL,
+		now:      time.Now,
+		entries:  make(map[string]*dnsEntry),
+		inflight: make(map[string]*dnsCall),
+	}
+}
+
+// lookup returns host's addresses, rotated one place on every call. A
+// caller whose ctx ends stops waiting without affecting the lookup.
+func (d *dnsCache) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	d.mu.Lock()
+	if e, ok := d.entries[host]; ok && d.now().Before(e.expires) {
+		defer d.mu.Unlock()
+		return e.rotate()
+	}
+	call, ok := d.inflight[host]
+	if !ok {
+		call = &dnsCall{done: make(chan struct{})}
+		d.inflight[host] = call
+		go d.resolve(host, call)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return call.entry.rotate()
+}
+
+// resolve runs call's query under its own timeout, so one caller being
+// cancelled neither fails the others nor gets cached
+func (d *dnsCache) resolve(host string, call *dnsCall) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+	addrs, err := d.resolver.LookupIPAddr(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	entry := &dnsEntry{addrs: addrs, err: err}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sweep()
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		entry.expires = d.now().Add(d.ttl)
+		d.entries[host] = entry
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		entry.expires = d.now().Add(d.negTTL)
+		d.entries[host] = entry
+	}
+	// Other errors reach the callers waiting now; the next dial asks again
+	call.entry = entry
+	delete(d.inflight, host)
+	close(call.done)
+}
+
+// sweep drops expired entries, at most once per TTL so adding stays cheap;
+// callers hold mu
+func (d *dnsCache) sweep() {
+	now := d.now()
+	if now.Before(d.nextSweep) {
+		return
+	}
+	for host, e := range d.entries {
+		if !now.Before(e.expires) {
+			delete(d.entries, host)
+		}
+	}
+	d.nextSweep = now.Add(d.ttl)
+}
+
+// rotate returns the entry's addresses starting from the next in turn
+func (e *dnsEntry) rotate() ([]net.IPAddr, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	out := make([]net.IPAddr, 0, len(e.addrs))
+	out = append(out, e.addrs[e.next:]...)
+	out = append(out, e.addrs[:e.next]...)
+	e.next = (e.next + 1) % len(e.addrs)
+	return out, nil
+}
+
+// dialContext wraps dial so host names are resolved through the cache. IP
+// literals go straight through; otherwise each address is tried in turn.
+func (d *dnsCache) dialContext(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		addrs, err := d.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
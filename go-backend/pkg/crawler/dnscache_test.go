@@ -0,0 +1,261 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers from a fixed table and counts queries per host
+type fakeResolver struct {
+	mu      sync.Mutex
+	answers map[string][]net.IPAddr
+	queries map[string]int
+}
+
+func (r *fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries[host]++
+	addrs, ok := r.answers[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func (r *fakeResolver) count(host string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.queries[host]
+}
+
+func ipAddrs(ips ...string) []net.IPAddr {
+	var out []net.IPAddr
+	for _, ip := range ips {
+		out = append(out, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return out
+}
+
+// TestDNSCacheHTTP fetches a test server twice under a made-up host name
+// with keep-alives off and checks the name was resolved only once.
+func TestDNSCacheHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	_, port, _ := net.SplitHostPort(serverURL.Host)
+
+	resolver := &fakeResolver{
+		answers: map[string][]net.IPAddr{"dreams.test": ipAddrs("127.0.0.1")},
+		queries: map[string]int{},
+	}
+	cache := newDNSCache(resolver, time.Minute, time.Minute)
+	var dialer net.Dialer
+	client := &http.Client{Transport: &http.Transport{
+		DialContext:       cache.dialContext(dialer.DialContext),
+		DisableKeepAlives: true,
+	}}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("http://dreams.test:" + port + "/")
+		if err != nil {
+			t.Fatalf("GET %d: %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if n := resolver.count("dreams.test"); n != 1 {
+		t.Errorf("resolved dreams.test %d times, want 1", n)
+	}
+}
+
+// TestDNSCacheRoundRobin checks that successive dials start from successive
+// addresses and fall through to the next address when one fails.
+func TestDNSCacheRoundRobin(t *testing.T) {
+	resolver := &fakeResolver{
+		answers: map[string][]net.IPAddr{"multi.test": ipAddrs("10.0.0.1", "10.0.0.2", "10.0.0.3")},
+		queries: map[string]int{},
+	}
+	cache := newDNSCache(resolver, time.Minute, time.Minute)
+
+	var dialed []string
+	refuse := errors.New("refused")
+	dial := cache.dialContext(func(_ context.Context, _, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, refuse
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := dial(context.Background(), "tcp", "multi.test:80"); !errors.Is(err, refuse) {
+			t.Fatalf("dial %d error = %v, want the last dial's error", i, err)
+		}
+	}
+
+	want := []string{
+		"10.0.0.1:80", "10.0.0.2:80", "10.0.0.3:80",
+		"10.0.0.2:80", "10.0.0.3:80", "10.0.0.1:80",
+	}
+	if fmt.Sprint(dialed) != fmt.Sprint(want) {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+	if n := resolver.count("multi.test"); n != 1 {
+		t.Errorf("resolved multi.test %d times, want 1", n)
+	}
+}
+
+// TestDNSCacheExpiry checks that answers, including "not found", are reused
+// until their TTL passes and then looked up again.
+func TestDNSCacheExpiry(t *testing.T) {
+	resolver := &fakeResolver{
+		answers: map[string][]net.IPAddr{"up.test": ipAddrs("10.0.0.1")},
+		queries: map[string]int{},
+	}
+	cache := newDNSCache(resolver, time.Minute, 10*time.Second)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, host := range []string{"up.test", "gone.test"} {
+		cache.lookup(ctx, host)
+		cache.lookup(ctx, host)
+	}
+	var dnsErr *net.DNSError
+	if _, err := cache.lookup(ctx, "gone.test"); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("cached NXDOMAIN error = %v, want a not-found DNS error", err)
+	}
+	if up, gone := resolver.count("up.test"), resolver.count("gone.test"); up != 1 || gone != 1 {
+		t.Fatalf("queries before expiry: up=%d gone=%d, want 1 and 1", up, gone)
+	}
+
+	now = now.Add(30 * time.Second)
+	cache.lookup(ctx, "up.test")
+	cache.lookup(ctx, "gone.test")
+	if up, gone := resolver.count("up.test"), resolver.count("gone.test"); up != 1 || gone != 2 {
+		t.Errorf("queries after negative TTL: up=%d gone=%d, want 1 and 2", up, gone)
+	}
+
+	now = now.Add(time.Minute)
+	cache.lookup(ctx, "up.test")
+	if up := resolver.count("up.test"); up != 2 {
+		t.Errorf("queries after TTL: up=%d, want 2", up)
+	}
+}
+
+// TestDNSCacheConcurrent checks that workers dialling the same host at once
+// share a single query.
+func TestDNSCacheConcurrent(t *testing.T) {
+	resolver := &fakeResolver{
+		answers: map[string][]net.IPAddr{"busy.test": ipAddrs("10.0.0.1", "10.0.0.2")},
+		queries: map[string]int{},
+	}
+	cache := newDNSCache(resolver, time.Minute, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if addrs, err := cache.lookup(context.Background(), "busy.test"); err != nil || len(addrs) != 2 {
+				t.Errorf("lookup = %v, %v", addrs, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := resolver.count("busy.test"); n != 1 {
+		t.Errorf("resolved busy.test %d times, want 1", n)
+	}
+}
+
+// blockingResolver answers once release is closed, or fails with its
+// context's error, and signals started on each query
+type blockingResolver struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.started <- struct{}{}
+	select {
+	case <-r.release:
+		return ipAddrs("10.0.0.1"), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TestDNSCacheCancelledLeader checks that the caller which started a
+// lookup giving up doesn't fail the callers sharing it, and that the
+// answer is still cached.
+func TestDNSCacheCancelledLeader(t *testing.T) {
+	resolver := &blockingResolver{started: make(chan struct{}, 1), release: make(chan struct{})}
+	cache := newDNSCache(resolver, time.Minute, time.Minute)
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := cache.lookup(leaderCtx, "slow.test")
+		leaderErr <- err
+	}()
+	<-resolver.started
+
+	waiter := make(chan error, 1)
+	go func() {
+		addrs, err := cache.lookup(context.Background(), "slow.test")
+		if err == nil && len(addrs) != 1 {
+			err = fmt.Errorf("got %d addresses, want 1", len(addrs))
+		}
+		waiter <- err
+	}()
+
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled leader err = %v, want context.Canceled", err)
+	}
+	close(resolver.release)
+	if err := <-waiter; err != nil {
+		t.Errorf("waiter sharing the lookup failed: %v", err)
+	}
+	if _, err := cache.lookup(context.Background(), "slow.test"); err != nil {
+		t.Errorf("cached lookup failed: %v", err)
+	}
+	select {
+	case <-resolver.started:
+		t.Error("the answer wasn't cached; slow.test was resolved again")
+	default:
+	}
+}
+
+// TestDNSCacheEvictsExpired checks expired entries are dropped from the
+// cache rather than kept for the whole crawl.
+func TestDNSCacheEvictsExpired(t *testing.T) {
+	resolver := &fakeResolver{
+		answers: map[string][]net.IPAddr{"a.test": ipAddrs("10.0.0.1"), "b.test": ipAddrs("10.0.0.2"), "c.test": ipAddrs("10.0.0.3")},
+		queries: map[string]int{},
+	}
+	cache := newDNSCache(resolver, time.Minute, 10*time.Second)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, host := range []string{"a.test", "b.test", "gone.test"} {
+		cache.lookup(ctx, host)
+	}
+	now = now.Add(2 * time.Minute)
+	cache.lookup(ctx, "c.test")
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if len(cache.entries) != 1 || cache.entries["c.test"] == nil {
+		t.Errorf("cache holds %d entries after expiry, want only c.test", len(cache.entries))
+	}
+}
@@ -0,0 +1,125 @@
+package crawler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/crawler"
+)
+
+// eventHook keeps the events an embedding program would see
+type eventHook struct {
+	crawler.NopHook
+	mu       sync.Mutex
+	statuses map[string]int
+	docs     []string
+	errs     []string
+	skips    map[string]crawler.SkipReason
+}
+
+func (h *eventHook) OnFetch(url string, status int, elapsed time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.statuses[url] = status
+}
+
+func (h *eventHook) OnError(url string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errs = append(h.errs, url)
+}
+
+func (h *eventHook) OnDocument(doc crawler.Document) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.docs = append(h.docs, doc.URL)
+}
+
+func (h *eventHook) OnSkip(url string, reason crawler.SkipReason) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.skips[url] = reason
+}
+
+// TestEmbeddedCrawlerHooks runs a Crawler from outside the package,
+// without Kafka, against a mock site and checks that each kind of hook
+// callback fires with the expected data.
+func TestEmbeddedCrawlerHooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><p>Home</p><a href="/a">A</a>
+				<a href="http://127.0.0.1:1/unreachable">Nowhere</a></body></html>`)
+		case "/a":
+			// Absolute, since extraction ignores one-character hrefs
+			fmt.Fprintf(w, `<html><body><p>Page A</p><a href="http://%s/">Home</a><a href="/missing">Missing</a></body></html>`, r.Host)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	hook := &eventHook{statuses: make(map[string]int), skips: make(map[string]crawler.SkipReason)}
+	cfg := crawler.DefaultConfig()
+	cfg.Seeds = []string{server.URL + "/"}
+	cfg.Workers = 2
+	cfg.QueueSize = 10
+	cfg.Client = server.Client()
+	cfg.Hook = hook
+	c, err := crawler.New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan crawler.ShutdownReason)
+	go func() { done <- c.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hook.mu.Lock()
+		ready := len(hook.docs) == 3 && len(hook.errs) == 1
+		hook.mu.Unlock()
+		if ready {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for three documents and an error")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if reason := <-done; reason != crawler.ShutdownCancelled {
+		t.Errorf("Run = %q, want %q", reason, crawler.ShutdownCancelled)
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if got := hook.statuses[server.URL+"/"]; got != http.StatusOK {
+		t.Errorf("OnFetch status for / = %d, want %d", got, http.StatusOK)
+	}
+	if got := hook.statuses[server.URL+"/missing"]; got != http.StatusNotFound {
+		t.Errorf("OnFetch status for /missing = %d, want %d", got, http.StatusNotFound)
+	}
+	if hook.docs[0] != server.URL+"/" {
+		t.Errorf("first OnDocument = %s, want the seed", hook.docs[0])
+	}
+	if got := hook.errs[0]; got != "http://127.0.0.1:1/unreachable" {
+		t.Errorf("OnError url = %s, want the unreachable link", got)
+	}
+	if reason, ok := hook.skips[server.URL+"/"]; !ok || reason != crawler.SkipSeen {
+		t.Errorf("OnSkip for / = %v (reported: %v), want SkipSeen", reason, ok)
+	}
+
+	if stats := c.Stats(); stats.PagesProcessed != 3 || stats.ShutdownReason != crawler.ShutdownCancelled {
+		t.Errorf("Stats = %d pages, reason %q; want 3 pages, reason %q",
+			stats.PagesProcessed, stats.ShutdownReason, crawler.ShutdownCancelled)
+	}
+}
@@ -0,0 +1,40 @@
+package crawler
+
+import (
+	"context"
+	"log"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"golang.org/x/time/rate"
+)
+
+// newEmitLimiter returns the EmitRate limiter, nil when unlimited. Unlike
+// the per-host fetch limits this caps the crawled documents reaching Kafka,
+// whatever the crawl rate; graph, error and change events, spool retries
+// and backfills aren't counted.
+func (cfg *settings) newEmitLimiter() *rate.Limiter {
+	if cfg.EmitRate <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(cfg.EmitRate), max(1, cfg.EmitBurst))
+}
+
+// emitDocuments routes each document to its topics and hands the messages
+// to produce, waiting on lim between messages. Waiting blocks the caller's
+// reads from input, so a throttled producer backs up into the output
+// buffer rather than dropping documents.
+func (cfg *settings) emitDocuments(input <-chan Document, lim *rate.Limiter, produce func(*kafka.Message)) {
+	for doc := range input {
+		for _, topic := range cfg.routeDocument(doc) {
+			msg, err := cfg.topicMessage(doc, topic)
+			if err != nil {
+				log.Printf("Serialization error: %v", err)
+				continue
+			}
+			if lim != nil {
+				lim.Wait(context.Background())
+			}
+			produce(msg)
+		}
+	}
+}
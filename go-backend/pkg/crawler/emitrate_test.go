@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"golang.org/x/time/rate"
+)
+
+// TestEmitRate produces documents under a low -emit-rate and checks that
+// emitting them takes at least as long as the rate allows, with none lost.
+func TestEmitRate(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) {
+		c.EmitRate = 20
+		c.EmitBurst = 1
+		c.DreamThreshold = 1 // one message per document
+	})
+
+	const n = 6
+	input := make(chan Document, n)
+	for i := 0; i < n; i++ {
+		input <- Document{URL: "https://example.com/", Status: http.StatusOK, CleanText: "Moths circle the lanterns."}
+	}
+	close(input)
+
+	var produced int
+	start := time.Now()
+	cfg.emitDocuments(input, cfg.newEmitLimiter(), func(*kafka.Message) { produced++ })
+	elapsed := time.Since(start)
+
+	if produced != n {
+		t.Errorf("produced %d messages, want %d", produced, n)
+	}
+	// The first message uses the burst; each later one waits 1/rate
+	if want := time.Duration(n-1) * time.Second / 20; elapsed < want {
+		t.Errorf("emitted %d messages in %v, want at least %v", n, elapsed, want)
+	}
+}
+
+// TestEmitRateDisabled checks that the limiter is off by default.
+func TestEmitRateDisabled(t *testing.T) {
+	cfg := testSettings(t, nil)
+	if lim := cfg.newEmitLimiter(); lim != nil {
+		t.Errorf("default emit limiter = %v, want nil", lim.Limit())
+	}
+	var lim *rate.Limiter
+	input := make(chan Document, 1)
+	input <- Document{Status: http.StatusOK, CleanText: "text"}
+	close(input)
+	var produced int
+	cfg.emitDocuments(input, lim, func(*kafka.Message) { produced++ })
+	if produced == 0 {
+		t.Error("no messages produced without a limiter")
+	}
+}
@@ -0,0 +1,209 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// FetchErrorCategory says which stage of a fetch failed
+type FetchErrorCategory int
+
+const (
+	FetchDNS FetchErrorCategory = iota
+	FetchTimeout
+	FetchConnection
+	FetchTLS
+	FetchHTTPStatus
+	FetchParse
+	FetchTooLarge
+	FetchBlocked
+	FetchDecompressionBomb
+	FetchRedirectLoop
+)
+
+func (c FetchErrorCategory) String() string {
+	switch c {
+	case FetchDNS:
+		return "dns"
+	case FetchTimeout:
+		return "timeout"
+	case FetchConnection:
+		return "connection"
+	case FetchTLS:
+		return "tls"
+	case FetchHTTPStatus:
+		return "http_status"
+	case FetchParse:
+		return "parse"
+	case FetchTooLarge:
+		return "too_large"
+	case FetchBlocked:
+		return "blocked"
+	case FetchDecompressionBomb:
+		return "decompression_bomb"
+	case FetchRedirectLoop:
+		return "redirect_loop"
+	}
+	return fmt.Sprintf("category(%d)", int(c))
+}
+
+// FetchError is returned for a URL that couldn't be fetched. Non-200
+// responses are still documents; FetchHTTPStatus is only used when a
+// status makes the crawler give up on the URL, such as a 429 that has
+// used up its retries.
+type FetchError struct {
+	Category FetchErrorCategory
+	URL      string
+	Status   int // for FetchHTTPStatus
+	Err      error
+}
+
+func (e *FetchError) Error() string {
+	if e.Category == FetchHTTPStatus {
+		return fmt.Sprintf("%s: %s %d: %v", e.URL, e.Category, e.Status, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.URL, e.Category, e.Err)
+}
+
+func (e *FetchError) Unwrap() error { return e.Err }
+
+var (
+	errBodyTooLarge      = errors.New("response body exceeds -max-body-bytes")
+	errDecompressionBomb = errors.New("decompressed body exceeds -max-decompressed-bytes")
+)
+
+// classifyRequestError wraps an error from sending a request, telling
+// name resolution, TLS, timeouts and other connection failures apart
+func classifyRequestError(rawurl string, err error) *FetchError {
+	fe := &FetchError{Category: FetchConnection, URL: rawurl, Err: err}
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	var loop *RedirectLoop
+	switch {
+	case errors.As(err, &loop):
+		fe.Category = FetchRedirectLoop
+	case errors.Is(err, errPrivateAddress):
+		fe.Category = FetchBlocked
+	case errors.As(err, &dnsErr):
+		fe.Category = FetchDNS
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &invalidCert):
+		fe.Category = FetchTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		fe.Category = FetchTimeout
+	}
+	return fe
+}
+
+// classifyBodyError wraps an error from reading or parsing a response body
+func classifyBodyError(rawurl string, err error) *FetchError {
+	if errors.Is(err, errBodyTooLarge) {
+		return &FetchError{Category: FetchTooLarge, URL: rawurl, Err: err}
+	}
+	if errors.Is(err, errDecompressionBomb) {
+		return &FetchError{Category: FetchDecompressionBomb, URL: rawurl, Err: err}
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return &FetchError{Category: FetchTimeout, URL: rawurl, Err: err}
+	}
+	return &FetchError{Category: FetchParse, URL: rawurl, Err: err}
+}
+
+// errorCategory names err's category for stats, "other" for errors that
+// aren't FetchErrors
+func errorCategory(err error) string {
+	var fe *FetchError
+	if errors.As(err, &fe) {
+		return fe.Category.String()
+	}
+	return "other"
+}
+
+// CrawlError is the error-topic payload for a URL that failed
+type CrawlError struct {
+	URL      string    `json:"url"`
+	Host     string    `json:"host,omitempty"`
+	Category string    `json:"category"`
+	Status   int       `json:"status,omitempty"` // for http_status
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+func newCrawlError(host, rawurl string, err error) CrawlError {
+	ce := CrawlError{
+		URL:      rawurl,
+		Host:     host,
+		Category: errorCategory(err),
+		Error:    err.Error(),
+		FailedAt: time.Now().UTC(),
+	}
+	var fe *FetchError
+	if errors.As(err, &fe) {
+		ce.Status = fe.Status
+	}
+	return ce
+}
+
+// errorProducer publishes failed URLs to topic, keyed by URL
+func errorProducer(producer *kafka.Producer, failures <-chan CrawlError, topic string) {
+	for failure := range failures {
+		value, err := json.Marshal(failure)
+		if err != nil {
+			log.Printf("JSON marshal error: %v", err)
+			continue
+		}
+		producer.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Value:          value,
+			Key:            []byte(failure.URL),
+		}, nil)
+	}
+}
+
+// limitedBody reads at most limit bytes, then fails with errBodyTooLarge
+// rather than silently truncating; limit <= 0 disables the cap. It counts
+// the bytes read either way, which is the body's real size when the
+// response is chunked and has no Content-Length.
+type limitedBody struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.limit <= 0 {
+		n, err := b.r.Read(p)
+		b.read += int64(n)
+		return n, err
+	}
+	if b.read > b.limit {
+		return 0, errBodyTooLarge
+	}
+	if remaining := b.limit + 1 - b.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,206 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFetchErrorCategories checks each way a fetch can fail is reported as
+// a FetchError of the matching category.
+func TestFetchErrorCategories(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.MaxBodyBytes = 1024 })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 4096)))
+	})
+	mux.HandleFunc("/huge-chunked", func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 4; i++ {
+			w.Write([]byte(strings.Repeat("a", 1024)))
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/truncated", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "500")
+		w.Write([]byte("<html><body><p>cut off"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(mux)
+	defer tlsServer.Close()
+
+	// dialErr fails every connection with err before reaching the network
+	dialErr := func(err error) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(context.Context, string, string) (net.Conn, error) { return nil, err },
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		client *http.Client
+		url    string
+		want   FetchErrorCategory
+	}{
+		{"dns", dialErr(&net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}),
+			"http://nowhere.invalid/", FetchDNS},
+		{"connection refused", http.DefaultClient, "http://127.0.0.1:1/", FetchConnection},
+		{"timeout", &http.Client{Timeout: 50 * time.Millisecond}, server.URL + "/slow", FetchTimeout},
+		{"untrusted certificate", http.DefaultClient, tlsServer.URL + "/huge", FetchTLS},
+		{"too large by header", server.Client(), server.URL + "/huge", FetchTooLarge},
+		{"too large while reading", server.Client(), server.URL + "/huge-chunked", FetchTooLarge},
+		{"truncated body", server.Client(), server.URL + "/truncated", FetchParse},
+	}
+	for _, tt := range tests {
+		_, _, err := cfg.enhancedFetchAndParse(context.Background(), tt.client, tt.url, URLMetadata{})
+		var fe *FetchError
+		if !errors.As(err, &fe) {
+			t.Errorf("%s: error %v (%T) is not a *FetchError", tt.name, err, err)
+			continue
+		}
+		if fe.Category != tt.want || fe.URL != tt.url {
+			t.Errorf("%s: got %s for %s, want %s (%v)", tt.name, fe.Category, fe.URL, tt.want, err)
+		}
+		if got := errorCategory(err); got != tt.want.String() {
+			t.Errorf("%s: errorCategory = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Bodies under the cap still parse
+	cfg = testSettings(t, func(c *Config) { c.MaxBodyBytes = 1 << 20 })
+	if _, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/huge", URLMetadata{}); err != nil {
+		t.Errorf("body under -max-body-bytes: %v", err)
+	}
+}
+
+// TestFetchErrorUnwrap checks callers can match both the category and the
+// underlying cause.
+func TestFetchErrorUnwrap(t *testing.T) {
+	err := error(&FetchError{Category: FetchHTTPStatus, URL: "https://example.com/", Status: http.StatusTooManyRequests,
+		Err: errors.New("still rate limited after 3 retries")})
+	if got := errorCategory(err); got != "http_status" {
+		t.Errorf("errorCategory = %q, want http_status", got)
+	}
+	if !strings.Contains(err.Error(), "429") {
+		t.Errorf("Error() = %q, want the status in it", err)
+	}
+
+	deadline := &FetchError{Category: FetchTimeout, Err: errURLDeadline}
+	if !errors.Is(deadline, errURLDeadline) {
+		t.Error("errors.Is can't see through FetchError to its cause")
+	}
+	if got := errorCategory(errors.New("plain")); got != "other" {
+		t.Errorf("errorCategory(plain error) = %q, want other", got)
+	}
+}
+
+// TestFailQueuesCrawlError checks a failed URL reaches the error topic's
+// channel with its category and status.
+func TestFailQueuesCrawlError(t *testing.T) {
+	failures := make(chan CrawlError, 1)
+	c := &Crawler{stats: &CrawlerStats{}, failures: failures}
+	c.fail("example.com", "https://example.com/busy", &FetchError{
+		Category: FetchHTTPStatus, URL: "https://example.com/busy", Status: http.StatusTooManyRequests,
+		Err: errors.New("still rate limited after 3 retries"),
+	})
+
+	got := <-failures
+	if got.URL != "https://example.com/busy" || got.Host != "example.com" || got.Category != "http_status" ||
+		got.Status != http.StatusTooManyRequests || !strings.Contains(got.Error, "rate limited") || got.FailedAt.IsZero() {
+		t.Errorf("queued %+v, want the URL's http_status 429 failure", got)
+	}
+}
+
+// TestChunkedResponseSize checks a response without Content-Length is
+// sized by the bytes actually read, with and without -max-body-bytes.
+func TestChunkedResponseSize(t *testing.T) {
+	parts := []string{"<html><head><title>Streamed</title></head><body>", strings.Repeat("<p>chunk of text</p>", 50), "</body></html>"}
+	body := strings.Join(parts, "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		for _, part := range parts {
+			w.Write([]byte(part))
+			w.(http.Flusher).Flush() // forces chunked transfer encoding
+		}
+	}))
+	defer server.Close()
+
+	for _, limit := range []int64{0, 1 << 20} {
+		cfg := testSettings(t, func(c *Config) { c.MaxBodyBytes = limit })
+		doc, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+		if err != nil {
+			t.Fatalf("limit %d: %v", limit, err)
+		}
+		if doc.Metadata.Size != int64(len(body)) || doc.Metadata.ContentLength != 0 {
+			t.Errorf("limit %d: size %d, content length %d, want %d and 0", limit, doc.Metadata.Size, doc.Metadata.ContentLength, len(body))
+		}
+	}
+}
+
+// TestDecompressionBomb serves a few kilobytes of gzip inflating to 8MB
+// and checks the fetch fails as a decompression bomb once the inflated
+// body passes -max-decompressed-bytes, is counted in the stats, and that
+// gzipped pages under the cap are inflated as usual.
+func TestDecompressionBomb(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.MaxDecompressedBytes = 1 << 20 })
+
+	gzipped := func(body string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(body))
+		zw.Close()
+		return buf.Bytes()
+	}
+	bomb := gzipped("<html><body><p>" + strings.Repeat("0", 8<<20) + "</p></body></html>")
+	page := gzipped("<html><head><title>Small</title></head><body><p>A modest gzipped page.</p></body></html>")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.URL.Path == "/bomb" {
+			w.Write(bomb)
+			return
+		}
+		w.Write(page)
+	}))
+	defer server.Close()
+	if int64(len(bomb)) > cfg.MaxBodyBytes {
+		t.Fatalf("bomb is %d bytes compressed, over -max-body-bytes", len(bomb))
+	}
+
+	_, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/bomb", URLMetadata{})
+	var fe *FetchError
+	if !errors.As(err, &fe) || fe.Category != FetchDecompressionBomb {
+		t.Fatalf("fetching the bomb: %v, want a decompression_bomb FetchError", err)
+	}
+
+	// Inflation stops one byte past the cap
+	buf := new(bytes.Buffer)
+	if err := cfg.gunzip(buf, bomb, cfg.MaxDecompressedBytes); !errors.Is(err, errDecompressionBomb) || int64(buf.Len()) != cfg.MaxDecompressedBytes+1 {
+		t.Errorf("gunzip: %v with %d bytes inflated, want errDecompressionBomb at %d", err, buf.Len(), cfg.MaxDecompressedBytes+1)
+	}
+
+	c := &Crawler{stats: &CrawlerStats{}}
+	c.fail("", server.URL+"/bomb", err)
+	if n := c.stats.Snapshot().ErrorCategories["decompression_bomb"]; n != 1 {
+		t.Errorf("stats counted %d decompression bombs, want 1", n)
+	}
+
+	doc, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/page", URLMetadata{})
+	if err != nil {
+		t.Fatalf("gzipped page under the cap: %v", err)
+	}
+	if doc.Title != "Small" || !strings.Contains(doc.CleanText, "modest gzipped page") {
+		t.Errorf("gzipped page parsed as %q: %q", doc.Title, doc.CleanText)
+	}
+}
@@ -0,0 +1,71 @@
+package crawler
+
+import (
+	"strings"
+)
+
+// parseFocusKeywords lowercases -focus-keywords and drops empty ones
+func parseFocusKeywords(keywords []string) []string {
+	var parsed []string
+	for _, kw := range keywords {
+		if kw = strings.ToLower(strings.TrimSpace(kw)); kw != "" {
+			parsed = append(parsed, kw)
+		}
+	}
+	return parsed
+}
+
+// Relevance weights: a keyword on the link itself says more about its
+// target than one elsewhere on the page
+const (
+	focusLinkWeight = 2
+	focusPageWeight = 1
+	maxFocusBoost   = 4
+)
+
+// keywordMatches counts the focus keywords occurring in text
+func (cfg *settings) keywordMatches(text string) int {
+	text = strings.ToLower(text)
+	n := 0
+	for _, kw := range cfg.focusKeywords {
+		if strings.Contains(text, kw) {
+			n++
+		}
+	}
+	return n
+}
+
+// linkRelevance scores a link by keyword overlap with its anchor text,
+// context and URL, plus whether the page it was found on is on topic
+func (cfg *settings) linkRelevance(link ExtractedLink, pageMatches int) int {
+	score := focusLinkWeight * cfg.keywordMatches(link.Text+" "+link.Context+" "+link.URL)
+	if pageMatches > 0 {
+		score += focusPageWeight
+	}
+	return score
+}
+
+// focusLinks boosts link priorities by relevance to -focus-keywords and,
+// with -prune-irrelevant, removes irrelevant links whose depth would be at
+// least -prune-depth. The pruned links are returned separately.
+func (cfg *settings) focusLinks(links []ExtractedLink, doc Document, linkDepth int) (kept, pruned []ExtractedLink) {
+	if len(cfg.focusKeywords) == 0 {
+		return links, nil
+	}
+	pageMatches := cfg.keywordMatches(doc.Title + " " + doc.CleanText)
+
+	kept = links[:0:0]
+	for _, link := range links {
+		score := cfg.linkRelevance(link, pageMatches)
+		if score == 0 && cfg.PruneIrrelevant && linkDepth >= cfg.PruneDepth {
+			pruned = append(pruned, link)
+			continue
+		}
+		if score > maxFocusBoost {
+			score = maxFocusBoost
+		}
+		link.Priority += score
+		kept = append(kept, link)
+	}
+	return kept, pruned
+}
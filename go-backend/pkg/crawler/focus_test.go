@@ -0,0 +1,92 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+// TestFocusLinks checks keyword matches on the link and on its page raise
+// priority, and that pruning drops only irrelevant links deep enough.
+func TestFocusLinks(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.FocusKeywords = []string{" Astronomy", " telescope "} })
+
+	links := []ExtractedLink{
+		{URL: "https://example.com/gear", Text: "Choosing a telescope", Priority: 3},
+		{URL: "https://example.com/astronomy/telescope-care", Text: "Care guide", Priority: 3},
+		{URL: "https://example.com/recipes", Text: "Dinner recipes", Priority: 3},
+	}
+	offTopic := Document{Title: "Cooking", CleanText: "Pasta and bread."}
+	onTopic := Document{Title: "Stargazing", CleanText: "Amateur astronomy for beginners."}
+
+	kept, pruned := cfg.focusLinks(links, offTopic, 1)
+	if got := []int{kept[0].Priority, kept[1].Priority, kept[2].Priority}; got[0] != 5 || got[1] != 7 || got[2] != 3 || len(pruned) != 0 {
+		t.Errorf("off-topic page priorities = %v (pruned %d), want [5 7 3] and none pruned", got, len(pruned))
+	}
+	if links[0].Priority != 3 {
+		t.Error("focusLinks modified its input")
+	}
+
+	kept, _ = cfg.focusLinks(links, onTopic, 1)
+	if kept[2].Priority != 4 {
+		t.Errorf("unmatched link on an on-topic page: priority %d, want 4", kept[2].Priority)
+	}
+
+	cfg = testSettings(t, func(c *Config) {
+		c.FocusKeywords = []string{"astronomy", "telescope"}
+		c.PruneIrrelevant = true
+		c.PruneDepth = 2
+	})
+	if kept, pruned = cfg.focusLinks(links, offTopic, 1); len(kept) != 3 || len(pruned) != 0 {
+		t.Errorf("above -prune-depth: kept %d, pruned %d, want 3 and 0", len(kept), len(pruned))
+	}
+	if kept, pruned = cfg.focusLinks(links, offTopic, 2); len(kept) != 2 || len(pruned) != 1 || pruned[0].URL != "https://example.com/recipes" {
+		t.Errorf("at -prune-depth: kept %v, pruned %v, want only the recipes link pruned", kept, pruned)
+	}
+	if _, pruned = cfg.focusLinks(links, onTopic, 2); len(pruned) != 0 {
+		t.Errorf("links on an on-topic page were pruned: %v", pruned)
+	}
+}
+
+// TestPruneIrrelevantCrawl checks irrelevant deep links are skipped, not
+// enqueued, during a crawl.
+func TestPruneIrrelevantCrawl(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) {
+		c.FocusKeywords = []string{"telescope"}
+		c.PruneIrrelevant = true
+		c.PruneDepth = 1
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><p>Links</p>
+			<a href="%[1]s/telescopes">Optics</a><a href="%[1]s/recipes">Recipes</a></body></html>`, "http://"+r.Host)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+	}
+	queue := make(chan URLWithMetadata, 10)
+	frontier := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+
+	c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.enhancedWorker(ctx, 0, queue, frontier, out)
+
+	queue <- URLWithMetadata{URL: server.URL + "/"}
+	waitFor(t, "links handled", func() bool { return len(frontier) == 1 && stats.Snapshot().SkippedIrrelevant == 1 })
+	if u := <-frontier; u.URL != server.URL+"/telescopes" {
+		t.Errorf("enqueued %s, want the telescopes link", u.URL)
+	}
+}
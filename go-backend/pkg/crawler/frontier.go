@@ -0,0 +1,221 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FrontierEntry is a URL waiting to be crawled, as persisted
+type FrontierEntry struct {
+	URL      string `json:"url"`
+	Depth    int    `json:"depth"`
+	Priority int    `json:"priority"`
+	Parent   string `json:"parent,omitempty"`
+	Retries  int    `json:"retries,omitempty"`
+	PageRun  int    `json:"page_run,omitempty"`
+	// Claimed URLs were dequeued, and so marked visited, by a worker
+	// that was stopped before fetching them
+	Claimed bool `json:"claimed,omitempty"`
+}
+
+// FrontierStore persists the pending queue across restarts
+type FrontierStore interface {
+	// Load returns the saved entries, none when nothing was saved yet
+	Load() ([]FrontierEntry, error)
+	// Save replaces the saved entries
+	Save(entries []FrontierEntry) error
+}
+
+// fileFrontier is the FrontierStore of -frontier-file
+type fileFrontier struct {
+	path string
+}
+
+// savedFrontier is the on-disk form of a fileFrontier
+type savedFrontier struct {
+	SavedAt time.Time       `json:"saved_at"`
+	URLs    []FrontierEntry `json:"urls"`
+}
+
+func (f fileFrontier) Load() ([]FrontierEntry, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved savedFrontier
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("parsing frontier %s: %w", f.path, err)
+	}
+	return saved.URLs, nil
+}
+
+// Save writes the entries under a temporary name and renames the file
+// into place, so a crash mid-save leaves the previous frontier intact
+func (f fileFrontier) Save(entries []FrontierEntry) error {
+	data, err := json.Marshal(savedFrontier{SavedAt: time.Now().UTC(), URLs: entries})
+	if err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// pendingURLs tracks every URL queued but not yet taken by a worker,
+// including those waiting out a retry delay, so the frontier can be saved
+// without draining the queue. A nil *pendingURLs tracks nothing.
+type pendingURLs struct {
+	mu      sync.Mutex
+	entries map[string]*pendingEntry
+}
+
+// pendingEntry counts the copies of a URL in the queue; the shallowest
+// one is kept for saving
+type pendingEntry struct {
+	u     URLWithMetadata
+	count int
+}
+
+func newPendingURLs() *pendingURLs {
+	return &pendingURLs{entries: make(map[string]*pendingEntry)}
+}
+
+// queued records u before it is offered to the queue
+func (p *pendingURLs) queued(u URLWithMetadata) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[u.URL]
+	if !ok {
+		p.entries[u.URL] = &pendingEntry{u: u, count: 1}
+		return
+	}
+	e.count++
+	if u.Metadata.depth < e.u.Metadata.depth {
+		e.u = u
+	}
+}
+
+// taken records that a copy of the URL left the queue, or was dropped
+// before reaching it
+func (p *pendingURLs) taken(rawurl string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.entries[rawurl]; ok {
+		if e.count--; e.count <= 0 {
+			delete(p.entries, rawurl)
+		}
+	}
+}
+
+// list returns the pending URLs, highest priority first
+func (p *pendingURLs) list() []FrontierEntry {
+	p.mu.Lock()
+	entries := make([]FrontierEntry, 0, len(p.entries))
+	for _, e := range p.entries {
+		m := e.u.Metadata
+		entries = append(entries, FrontierEntry{
+			URL:      e.u.URL,
+			Depth:    m.depth,
+			Priority: m.priority,
+			Parent:   m.parent,
+			Retries:  m.retries,
+			PageRun:  m.pageRun,
+			Claimed:  m.claimed,
+		})
+	}
+	p.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Priority != entries[j].Priority {
+			return entries[i].Priority > entries[j].Priority
+		}
+		return entries[i].URL < entries[j].URL
+	})
+	return entries
+}
+
+// frontierURL turns a saved entry back into a queue item
+func frontierURL(e FrontierEntry) URLWithMetadata {
+	return URLWithMetadata{URL: e.URL, Metadata: URLMetadata{
+		depth:    e.Depth,
+		parent:   e.Parent,
+		priority: e.Priority,
+		retries:  e.Retries,
+		pageRun:  e.PageRun,
+		claimed:  e.Claimed,
+	}}
+}
+
+// saveFrontier writes the pending URLs to store, logging failures
+func saveFrontier(store FrontierStore, pending *pendingURLs) {
+	entries := pending.list()
+	if err := store.Save(entries); err != nil {
+		log.Printf("Saving frontier failed: %v", err)
+		return
+	}
+	log.Printf("Frontier saved: %d pending URLs", len(entries))
+}
+
+// frontierSaver saves the pending URLs every interval until ctx is done
+func frontierSaver(ctx context.Context, store FrontierStore, pending *pendingURLs, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			saveFrontier(store, pending)
+		}
+	}
+}
+
+// ResumeFrontier loads the saved pending queue, to be crawled ahead of the
+// seeds. Entries the snapshot New resumed from already visited are
+// dropped, except claimed ones, whose fetch was interrupted. It returns
+// how many URLs were resumed.
+func (c *Crawler) ResumeFrontier() (int, error) {
+	if c.frontier == nil {
+		return 0, nil
+	}
+	entries, err := c.frontier.Load()
+	if err != nil {
+		return 0, err
+	}
+	c.resumed = c.resumed[:0]
+	for _, e := range entries {
+		if e.URL == "" || (!e.Claimed && e.Retries == 0 && c.seen.has(c.cfg.canonicalURL(e.URL))) {
+			continue
+		}
+		c.resumed = append(c.resumed, frontierURL(e))
+	}
+	return len(c.resumed), nil
+}
+
+// interrupted returns a URL a worker had claimed but was stopped before
+// fetching to the pending frontier, so a resumed crawl still fetches it
+func (c *Crawler) interrupted(u URLWithMetadata) {
+	u.Metadata.claimed = true
+	c.pending.queued(u)
+}
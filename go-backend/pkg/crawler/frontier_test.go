@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// TestFrontierSurvivesRestart queues URLs, saves the frontier, then
+// "restarts" with a new crawler resuming from the file and a snapshot,
+// and checks the pending URLs come back with their depth and priority.
+func TestFrontierSurvivesRestart(t *testing.T) {
+	store := fileFrontier{path: filepath.Join(t.TempDir(), "frontier.json")}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pending := newPendingURLs()
+	pending.queued(URLWithMetadata{URL: "https://example.com/a", Metadata: URLMetadata{depth: 1, priority: 7, parent: "https://example.com/"}})
+	pending.queued(URLWithMetadata{URL: "https://example.com/b", Metadata: URLMetadata{depth: 3, priority: 2}})
+	pending.queued(URLWithMetadata{URL: "https://example.com/b", Metadata: URLMetadata{depth: 2, priority: 4}})
+	pending.queued(URLWithMetadata{URL: "https://example.com/taken", Metadata: URLMetadata{depth: 1}})
+	pending.taken("https://example.com/taken")
+	pending.queued(URLWithMetadata{URL: "https://example.com/done", Metadata: URLMetadata{depth: 1, priority: 9}})
+	if !testSettings(t, nil).scheduleRetry(ctx, make(chan URLWithMetadata), URLWithMetadata{URL: "https://example.com/busy", Metadata: URLMetadata{depth: 2, priority: 5}}, time.Hour, pending) {
+		t.Fatal("scheduleRetry = false")
+	}
+	saveFrontier(store, pending)
+
+	cfg := DefaultConfig()
+	cfg.Seeds = []string{"https://example.com/"}
+	cfg.QueueSize = 10
+	cfg.Frontier = store
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The previous run fetched /done, and claimed /busy before retrying it
+	snap := &crawlSnapshot{Visited: []string{"https://example.com/done", "https://example.com/busy"}}
+	snap.restore(c.seen, cfg.Seeds, c.cfg.canonicalURL)
+	n, err := c.ResumeFrontier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []URLWithMetadata{
+		{URL: "https://example.com/a", Metadata: URLMetadata{depth: 1, priority: 7, parent: "https://example.com/"}},
+		{URL: "https://example.com/busy", Metadata: URLMetadata{depth: 2, priority: 5, retries: 1}},
+		{URL: "https://example.com/b", Metadata: URLMetadata{depth: 2, priority: 4}},
+	}
+	if n != len(want) || !reflect.DeepEqual(c.resumed, want) {
+		t.Errorf("resumed %d URLs:\n%+v\nwant\n%+v", n, c.resumed, want)
+	}
+}
+
+// TestFrontierKeepsInterruptedFetch checks a URL whose fetch is cut off by
+// shutdown is saved as claimed, and that a resumed worker fetches it even
+// though the snapshot lists it as visited.
+func TestFrontierKeepsInterruptedFetch(t *testing.T) {
+	requests := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.Path
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	crawl := func(ctx context.Context, seen *mapSeen, pending *pendingURLs, u URLWithMetadata) <-chan struct{} {
+		c := &Crawler{client: server.Client(), cfg: testSettings(t, nil), seen: seen, stats: &CrawlerStats{}, pending: pending,
+			hostMap: map[string]*hostPolicies{serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)}}}
+		q := make(chan URLWithMetadata, 1)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.enhancedWorker(ctx, 0, q, q, make(chan Document, 1))
+		}()
+		pending.queued(u)
+		q <- u
+		return done
+	}
+
+	seen, pending := &mapSeen{}, newPendingURLs()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := crawl(ctx, seen, pending, URLWithMetadata{URL: server.URL + "/slow", Metadata: URLMetadata{depth: 2, priority: 6}})
+	<-requests
+	cancel()
+	<-done
+
+	want := []FrontierEntry{{URL: server.URL + "/slow", Depth: 2, Priority: 6, Claimed: true}}
+	if got := pending.list(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("pending after shutdown = %+v, want %+v", got, want)
+	}
+	if !seen.has(testSettings(t, nil).canonicalURL(server.URL + "/slow")) {
+		t.Fatal("interrupted URL wasn't marked seen")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	crawl(ctx, seen, newPendingURLs(), frontierURL(want[0]))
+	select {
+	case path := <-requests:
+		if path != "/slow" {
+			t.Errorf("fetched %s, want /slow", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resumed worker skipped the claimed URL")
+	}
+}
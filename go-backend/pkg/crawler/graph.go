@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// LinkEdge is one followed link in the crawl graph
+type LinkEdge struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	AnchorText string `json:"anchor_text"`
+	Depth      int    `json:"depth"` // depth of the child page
+	Priority   int    `json:"priority"`
+}
+
+// linkGraph collects followed links as deduplicated edges. A nil *linkGraph
+// records nothing, which is how graph emission is turned off.
+type linkGraph struct {
+	mu   sync.Mutex
+	seen map[[2]string]bool
+	out  chan<- LinkEdge
+
+	canonical func(string) string // dedups edges by canonical URL
+}
+
+func newLinkGraph(out chan<- LinkEdge, canonical func(string) string) *linkGraph {
+	return &linkGraph{seen: make(map[[2]string]bool), out: out, canonical: canonical}
+}
+
+// record emits edge unless the same from/to pair was already emitted. It
+// returns false if ctx ended before the edge could be handed off.
+func (g *linkGraph) record(ctx context.Context, edge LinkEdge) bool {
+	if g == nil {
+		return true
+	}
+
+	key := [2]string{g.canonical(edge.From), g.canonical(edge.To)}
+	g.mu.Lock()
+	dup := g.seen[key]
+	g.seen[key] = true
+	g.mu.Unlock()
+	if dup {
+		return true
+	}
+
+	select {
+	case g.out <- edge:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// graphProducer publishes edges to topic, keyed by the parent URL so a
+// page's outlinks land on one partition
+func graphProducer(producer *kafka.Producer, edges <-chan LinkEdge, topic string) {
+	for edge := range edges {
+		value, err := json.Marshal(edge)
+		if err != nil {
+			log.Printf("JSON marshal error: %v", err)
+			continue
+		}
+		producer.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Value:          value,
+			Key:            []byte(edge.From),
+		}, nil)
+	}
+}
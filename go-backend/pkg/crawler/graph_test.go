@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+// TestLinkGraphEdges crawls a two-page site and checks that the single link
+// between the pages is emitted once, with its anchor text.
+func TestLinkGraphEdges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><p>Home</p><a href="/second">The second page</a></body></html>`)
+		default:
+			fmt.Fprint(w, `<html><body><p>The end of the line.</p></body></html>`)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+	}
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	edges := make(chan LinkEdge, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+
+	cfg := testSettings(t, nil)
+	c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats, graph: newLinkGraph(edges, cfg.canonicalURL)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+	waitFor(t, "both pages", func() bool { return pagesProcessed(stats) == 2 })
+	cancel()
+	close(edges)
+
+	var got []LinkEdge
+	for edge := range edges {
+		got = append(got, edge)
+	}
+	want := LinkEdge{
+		From:       server.URL + "/",
+		To:         server.URL + "/second",
+		AnchorText: "The second page",
+		Depth:      1,
+		Priority:   3,
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("edges = %+v, want [%+v]", got, want)
+	}
+}
+
+// TestLinkGraphDedup checks that a repeated from/to pair is emitted once.
+func TestLinkGraphDedup(t *testing.T) {
+	edges := make(chan LinkEdge, 3)
+	g := newLinkGraph(edges, testSettings(t, nil).canonicalURL)
+	ctx := context.Background()
+
+	g.record(ctx, LinkEdge{From: "https://a.example/", To: "https://b.example/", AnchorText: "B"})
+	g.record(ctx, LinkEdge{From: "https://a.example/", To: "https://b.example/#top", AnchorText: "B again"})
+	g.record(ctx, LinkEdge{From: "https://b.example/", To: "https://a.example/", AnchorText: "A"})
+	close(edges)
+
+	var n int
+	for range edges {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("emitted %d edges, want 2", n)
+	}
+}
@@ -0,0 +1,87 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// selectHeroImage picks the image that best stands for the page: its
+// og:image, else the largest content image by declared dimensions, else
+// the first content image not known to be small. Data URIs, images
+// declared under -hero-min-size and anything keepImage leaves out of media,
+// such as tracking pixels, are never chosen. It runs after
+// extractText has removed navigation, headers and footers, so their
+// logos aren't candidates.
+func (cfg *settings) selectHeroImage(doc *goquery.Document, pageURL string) *MediaAsset {
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	base := resolutionBase(doc, page)
+	resolve := func(src string) string {
+		src = strings.TrimSpace(src)
+		if src == "" || strings.HasPrefix(strings.ToLower(src), "data:") {
+			return ""
+		}
+		u, err := base.Parse(src)
+		if err != nil || !cfg.allowedSchemes[u.Scheme] {
+			return ""
+		}
+		return u.String()
+	}
+
+	if og, ok := doc.Find("meta[property='og:image'], meta[property='og:image:url']").First().Attr("content"); ok {
+		if u := resolve(og); u != "" && !cfg.isTrackingImage(u) {
+			return &MediaAsset{URL: u, Type: "image", Format: getFileExtension(u)}
+		}
+	}
+
+	var largest, first *MediaAsset
+	largestArea := 0
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		src, _ := s.Attr("src")
+		u := resolve(src)
+		if u == "" || !cfg.keepImage(s, u) {
+			return
+		}
+		width, hasWidth := imageDimension(s, "width")
+		height, hasHeight := imageDimension(s, "height")
+		if hasWidth && width < cfg.HeroMinSize || hasHeight && height < cfg.HeroMinSize {
+			return
+		}
+
+		alt, _ := s.Attr("alt")
+		asset := &MediaAsset{URL: u, Type: "image", Alt: alt, Format: getFileExtension(src)}
+		if hasWidth && hasHeight {
+			asset.Size = fmt.Sprintf("%dx%d", width, height)
+			if area := width * height; area > largestArea {
+				largest, largestArea = asset, area
+			}
+		}
+		if first == nil {
+			first = asset
+		}
+	})
+	if largest != nil {
+		return largest
+	}
+	return first
+}
+
+// imageDimension reads an img width or height attribute, accepting a
+// trailing "px"; percentages and other units count as undeclared
+func imageDimension(s *goquery.Selection, attr string) (int, bool) {
+	value, ok := s.Attr(attr)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "px"))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
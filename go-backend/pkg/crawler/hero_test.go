@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestSelectHeroImage(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string // hero URL, "" for none
+	}{
+		{
+			"largest content image",
+			`<body><img src="/pixel.gif" width="1" height="1">
+				<img src="/icons/star.png" width="32" height="32">
+				<img src="data:image/png;base64,iVBORw0KGgo=" width="900" height="900">
+				<img src="/img/thumb.jpg" width="300" height="200">
+				<img src="/img/moon.jpg" width="1200" height="800" alt="The moon"></body>`,
+			"https://example.com/img/moon.jpg",
+		},
+		{
+			"og:image wins",
+			`<head><meta property="og:image" content="/og/card.png"></head>
+				<body><img src="/img/moon.jpg" width="1200" height="800"></body>`,
+			"https://example.com/og/card.png",
+		},
+		{
+			"first significant without dimensions",
+			`<body><img src="/pixel.gif" width="1" height="1"><img src="/spacer.gif" height="2">
+				<img src="/img/first.jpg"><img src="/img/second.jpg"></body>`,
+			"https://example.com/img/first.jpg",
+		},
+		{
+			"tracking pixels without dimensions",
+			`<head><meta property="og:image" content="https://www.facebook.com/tr?id=1&ev=PageView"></head>
+				<body><img src="https://www.google-analytics.com/collect?v=1"><img src="/img/first.jpg"></body>`,
+			"https://example.com/img/first.jpg",
+		},
+		{
+			"only small and inline images",
+			`<body><img src="/pixel.gif" width="1" height="1"><img src="data:image/gif;base64,R0lGOD=="></body>`,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		page, err := goquery.NewDocumentFromReader(strings.NewReader("<html>" + tt.html + "</html>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hero := testSettings(t, nil).selectHeroImage(page, "https://example.com/posts/1")
+		got := ""
+		if hero != nil {
+			got = hero.URL
+		}
+		if got != tt.want {
+			t.Errorf("%s: hero = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestHeroImageDetails checks the chosen image keeps its alt text and
+// declared size.
+func TestHeroImageDetails(t *testing.T) {
+	page, _ := goquery.NewDocumentFromReader(strings.NewReader(
+		`<html><body><img src="/img/moon.jpg" width="1200px" height="800" alt="The moon"></body></html>`))
+	hero := testSettings(t, nil).selectHeroImage(page, "https://example.com/")
+	if hero == nil || hero.Alt != "The moon" || hero.Size != "1200x800" || hero.Format != "jpg" || hero.Type != "image" {
+		t.Errorf("hero = %+v", hero)
+	}
+}
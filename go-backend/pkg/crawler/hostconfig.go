@@ -0,0 +1,120 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Default politeness for hosts without an override
+const (
+	defaultHostInterval = 500 * time.Millisecond
+	defaultHostBurst    = 1
+)
+
+// hostOverride tunes politeness for the hosts matching one config key
+type hostOverride struct {
+	RatePerSec  float64 `json:"rate_per_sec"`
+	Burst       int     `json:"burst"`
+	Concurrency int     `json:"concurrency"` // max in-flight fetches, 0 = unlimited
+	// CrawlDelayOverride lets rate_per_sec win over a robots.txt Crawl-delay
+	CrawlDelayOverride bool `json:"crawl_delay_override"`
+	// CollapseIndex overrides -collapse-index for these hosts when set
+	CollapseIndex *bool `json:"collapse_index"`
+	// SignificantParams, when set, are the only query params kept when
+	// deduplicating these hosts' URLs, replacing -tracking-params
+	SignificantParams []string `json:"significant_params"`
+}
+
+// hostConfig resolves hostnames against exact and "*.suffix" entries
+type hostConfig struct {
+	exact    map[string]hostOverride
+	suffixes map[string]hostOverride // ".example.com" for "*.example.com"
+}
+
+func loadHostConfig(path string) (*hostConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading host config: %w", err)
+	}
+
+	var raw map[string]hostOverride
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing host config %s: %w", path, err)
+	}
+
+	cfg := &hostConfig{
+		exact:    make(map[string]hostOverride),
+		suffixes: make(map[string]hostOverride),
+	}
+	for pattern, override := range raw {
+		if override.RatePerSec < 0 || override.Burst < 0 || override.Concurrency < 0 {
+			return nil, fmt.Errorf("host config %q: values must not be negative", pattern)
+		}
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			cfg.suffixes["."+suffix] = override
+		} else {
+			cfg.exact[pattern] = override
+		}
+	}
+	return cfg, nil
+}
+
+// lookup finds the override for host: an exact entry first, then the
+// longest matching wildcard suffix.
+func (c *hostConfig) lookup(host string) (hostOverride, bool) {
+	if c == nil {
+		return hostOverride{}, false
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	if override, ok := c.exact[host]; ok {
+		return override, true
+	}
+	for rest := host; ; {
+		i := strings.IndexByte(rest, '.')
+		if i < 0 {
+			return hostOverride{}, false
+		}
+		rest = rest[i+1:]
+		if override, ok := c.suffixes["."+rest]; ok {
+			return override, true
+		}
+	}
+}
+
+// newHostPolicies creates the politeness state for a newly seen host,
+// applying any -host-config override on top of the defaults
+func (cfg *settings) newHostPolicies(host string) *hostPolicies {
+	hp := &hostPolicies{
+		lim:          rate.NewLimiter(rate.Every(defaultHostInterval), defaultHostBurst),
+		ignoreRobots: cfg.robotsIgnored(host),
+		breaker:      hostBreaker{policy: cfg.breakerPolicy()},
+	}
+	hp.robotsPending.Store(true)
+
+	override, ok := cfg.hostOverrides.lookup(host)
+	if !ok {
+		return hp
+	}
+	if override.RatePerSec > 0 {
+		hp.lim.SetLimit(rate.Limit(override.RatePerSec))
+	}
+	if override.Burst > 0 {
+		hp.lim.SetBurst(override.Burst)
+	}
+	if override.Concurrency > 0 {
+		hp.slots = make(chan struct{}, override.Concurrency)
+	}
+	hp.ignoreCrawlDelay = override.CrawlDelayOverride && override.RatePerSec > 0
+	return hp
+}
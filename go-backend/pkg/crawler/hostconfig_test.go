@@ -0,0 +1,50 @@
+package crawler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+// TestHostConfigOverrides verifies that -host-config entries configure the
+// limiters of matching hosts and leave other hosts at the defaults.
+func TestHostConfigOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.json")
+	config := `{
+		"slow.example.com":   {"rate_per_sec": 0.2, "burst": 1},
+		"*.fast.example.com": {"rate_per_sec": 20, "burst": 5, "concurrency": 4, "crawl_delay_override": true}
+	}`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("WriteFile() returned an error: %v", err)
+	}
+
+	cfg := testSettings(t, func(c *Config) { c.HostConfigFile = path })
+
+	tests := []struct {
+		host             string
+		limit            rate.Limit
+		burst            int
+		concurrency      int
+		ignoreCrawlDelay bool
+	}{
+		{"slow.example.com", 0.2, 1, 0, false},
+		{"a.fast.example.com", 20, 5, 4, true},
+		{"deep.a.fast.example.com:8443", 20, 5, 4, true},
+		{"fast.example.com", rate.Every(defaultHostInterval), defaultHostBurst, 0, false},
+		{"other.example.org", rate.Every(defaultHostInterval), defaultHostBurst, 0, false},
+	}
+	for _, tt := range tests {
+		hp := cfg.newHostPolicies(tt.host)
+		if hp.lim.Limit() != tt.limit || hp.lim.Burst() != tt.burst {
+			t.Errorf("%s: limiter = (%v, %d), want (%v, %d)", tt.host, hp.lim.Limit(), hp.lim.Burst(), tt.limit, tt.burst)
+		}
+		if cap(hp.slots) != tt.concurrency {
+			t.Errorf("%s: concurrency = %d, want %d", tt.host, cap(hp.slots), tt.concurrency)
+		}
+		if hp.ignoreCrawlDelay != tt.ignoreCrawlDelay {
+			t.Errorf("%s: ignoreCrawlDelay = %v, want %v", tt.host, hp.ignoreCrawlDelay, tt.ignoreCrawlDelay)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// schemeSet holds the lowercase URL schemes of -allowed-schemes, the link
+// schemes the crawler follows
+type schemeSet map[string]bool
+
+func newSchemeSet(schemes []string) (schemeSet, error) {
+	s := schemeSet{}
+	for _, scheme := range schemes {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			s[scheme] = true
+		}
+	}
+	if len(s) == 0 {
+		return nil, errors.New("at least one scheme is required")
+	}
+	return s, nil
+}
+
+// toASCIIHost lowercases host and converts internationalized labels to
+// punycode ("münchen.de" -> "xn--mnchen-3ya.de"), keeping any port, so a
+// Unicode host and its ASCII form compare equal. This is the punycode step
+// of IDNA only: no Unicode normalization is applied to the labels.
+func toASCIIHost(host string) (string, error) {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, ""
+	}
+
+	labels := strings.Split(strings.ToLower(hostname), ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		if !validIDNLabel(label) {
+			return "", fmt.Errorf("invalid internationalized host label %q", label)
+		}
+		encoded, err := punycodeEncode(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = "xn--" + encoded
+	}
+
+	hostname = strings.Join(labels, ".")
+	if port != "" {
+		return net.JoinHostPort(hostname, port), nil
+	}
+	return hostname, nil
+}
+
+// normalizeDomainSet converts a domain whitelist to the ASCII hosts URLs
+// are compared in. Entries that aren't valid IDNs are kept as given.
+func normalizeDomainSet(domains []string) map[string]bool {
+	if len(domains) == 0 {
+		return nil
+	}
+	normalized := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		if domain = strings.TrimSpace(domain); domain == "" {
+			continue
+		}
+		if ascii, err := toASCIIHost(domain); err == nil {
+			domain = ascii
+		}
+		normalized[domain] = true
+	}
+	return normalized
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// validIDNLabel rejects labels no registry would issue: broken UTF-8,
+// spaces, controls and punctuation outside '-'
+func validIDNLabel(label string) bool {
+	if !utf8.ValidString(label) {
+		return false
+	}
+	for _, r := range label {
+		if r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Punycode parameters from RFC 3492
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+var errPunycodeOverflow = errors.New("punycode: label too long")
+
+// punycodeEncode encodes a Unicode label with the RFC 3492 algorithm
+func punycodeEncode(s string) (string, error) {
+	output := make([]byte, 0, len(s)+8)
+	delta, n, bias := int32(0), int32(punyInitialN), int32(punyInitialBias)
+	basic, remaining := int32(0), int32(0)
+	for _, r := range s {
+		if r < 0x80 {
+			basic++
+			output = append(output, byte(r))
+		} else {
+			remaining++
+		}
+	}
+	handled := basic
+	if basic > 0 {
+		output = append(output, '-')
+	}
+
+	for remaining != 0 {
+		m := int32(0x7fffffff)
+		for _, r := range s {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += (m - n) * (handled + 1)
+		if delta < 0 {
+			return "", errPunycodeOverflow
+		}
+		n = m
+		for _, r := range s {
+			if r < n {
+				delta++
+				if delta < 0 {
+					return "", errPunycodeOverflow
+				}
+				continue
+			}
+			if r > n {
+				continue
+			}
+			q := delta
+			for k := int32(punyBase); ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				output = append(output, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			output = append(output, punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+			remaining--
+		}
+		delta++
+		n++
+	}
+	return string(output), nil
+}
+
+func punyDigit(d int32) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyAdapt(delta, numPoints int32, first bool) int32 {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := int32(0)
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
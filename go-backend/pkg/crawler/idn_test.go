@@ -0,0 +1,88 @@
+package crawler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestToASCIIHost covers RFC 3492 vectors, ports and invalid labels.
+func TestToASCIIHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{"münchen.de", "xn--mnchen-3ya.de", false},
+		{"MÜNCHEN.de:8080", "xn--mnchen-3ya.de:8080", false},
+		{"bücher.example", "xn--bcher-kva.example", false},
+		{"例え.jp", "xn--r8jz45g.jp", false},
+		{"xn--mnchen-3ya.de", "xn--mnchen-3ya.de", false},
+		{"Example.COM", "example.com", false},
+		{"bad hé.de", "", true},
+		{"\xff\xfe.de", "", true},
+	}
+	for _, tt := range tests {
+		got, err := toASCIIHost(tt.host)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("toASCIIHost(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("toASCIIHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+// TestIDNLinksDedupAndWhitelist checks that a Unicode-host link and its
+// punycode twin share a dedup key and both pass a Unicode whitelist.
+func TestIDNLinksDedupAndWhitelist(t *testing.T) {
+	html := `<html><body>
+		<a href="https://münchen.de/stadt">Unicode</a>
+		<a href="https://xn--mnchen-3ya.de/stadt">Punycode</a>
+		<a href="ftp://münchen.de/pub">FTP</a>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testSettings(t, func(c *Config) { c.AllowedDomains = []string{"münchen.de"} })
+	links := cfg.extractLinksWithPriority(doc, "https://example.com/", 0)
+	if len(links) != 2 {
+		t.Fatalf("extracted %d links, want 2 (ftp is not an allowed scheme)", len(links))
+	}
+	if a, b := cfg.canonicalURL(links[0].URL), cfg.canonicalURL(links[1].URL); a != b {
+		t.Errorf("dedup keys differ: %q vs %q", a, b)
+	}
+	if a, b := cfg.canonicalURL("https://münchen.de/stadt"), cfg.canonicalURL("https://xn--mnchen-3ya.de/stadt"); a != b {
+		t.Errorf("canonicalURL keys differ: %q vs %q", a, b)
+	}
+
+	c := &Crawler{cfg: cfg}
+	for _, host := range []string{"münchen.de", "xn--mnchen-3ya.de", "MÜNCHEN.DE"} {
+		if !c.inScope(host) {
+			t.Errorf("inScope(%q) = false with a Unicode whitelist, want true", host)
+		}
+	}
+	if c.inScope("example.com") {
+		t.Error("inScope(example.com) = true, want false")
+	}
+}
+
+// TestAllowedSchemes checks that AllowedSchemes are normalized and that
+// at least one is required.
+func TestAllowedSchemes(t *testing.T) {
+	s, err := newSchemeSet([]string{"HTTPS", " gopher"})
+	if err != nil {
+		t.Fatalf("newSchemeSet: %v", err)
+	}
+	if want := (schemeSet{"https": true, "gopher": true}); !reflect.DeepEqual(s, want) {
+		t.Errorf("schemes = %v, want %v", s, want)
+	}
+	if _, err := newSchemeSet([]string{" ", ""}); err == nil {
+		t.Error("newSchemeSet with no schemes succeeded, want error")
+	}
+}
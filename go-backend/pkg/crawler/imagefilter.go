@@ -0,0 +1,47 @@
+package crawler
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const defaultTrackingImages = "spacer.gif,blank.gif,1x1.gif,1x1.png,/pixel.gif,/pixel.png,/pixel?,/beacon,facebook.com/tr,google-analytics.com/,doubleclick.net/,bat.bing.com/,scorecardresearch.com/,quantserve.com/,/b/ss/"
+
+func parseTrackingImages(spec string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(spec, ",") {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// keepImage decides whether an img belongs in a page's media. Data URIs,
+// tracking pixels and images declared smaller than -min-image-width or
+// -min-image-height are decoration, not content.
+func (cfg *settings) keepImage(s *goquery.Selection, src string) bool {
+	src = strings.ToLower(strings.TrimSpace(src))
+	if strings.HasPrefix(src, "data:") || cfg.isTrackingImage(src) {
+		return false
+	}
+	if width, ok := imageDimension(s, "width"); ok && width < cfg.MinImageWidth {
+		return false
+	}
+	if height, ok := imageDimension(s, "height"); ok && height < cfg.MinImageHeight {
+		return false
+	}
+	return true
+}
+
+// isTrackingImage reports whether src matches one of -tracking-images
+func (cfg *settings) isTrackingImage(src string) bool {
+	src = strings.ToLower(src)
+	for _, pattern := range cfg.trackingImages {
+		if strings.Contains(src, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestMinImageDimensions checks a 1x1 pixel and an icon are left out of
+// media under -min-image-width/-min-image-height while a large image is
+// kept, and that tracking pixels and data URIs are always left out.
+func TestMinImageDimensions(t *testing.T) {
+	page := `<html><body>
+		<img src="/img/dot.png" width="1" height="1">
+		<img src="/img/icon.png" width="16px" height="16px">
+		<img src="/img/moon.jpg" width="800" height="600" alt="The moon">
+		<img src="https://www.facebook.com/tr?id=1&ev=PageView">
+		<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=">
+		<video src="/clip.mp4" width="1" height="1"></video></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testSettings(t, func(c *Config) { c.MinImageWidth, c.MinImageHeight = 50, 50 })
+	media := cfg.extractMediaAssets(doc, "https://example.com/")
+	if len(media) != 2 || media[0].URL != "https://example.com/img/moon.jpg" || media[1].Type != "video" {
+		t.Errorf("media = %+v, want only the large image and the video", media)
+	}
+
+	// Without minimums, only the tracking pixel and data URI are left out
+	if media := testSettings(t, nil).extractMediaAssets(doc, "https://example.com/"); len(media) != 4 {
+		t.Errorf("got %d assets without minimums, want 4: %+v", len(media), media)
+	}
+}
+
+func TestKeepImageUndeclaredSize(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<img src="/a.png"><img src="/b.png" width="50%">`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testSettings(t, func(c *Config) { c.MinImageWidth = 200 })
+	doc.Find("img").Each(func(_ int, s *goquery.Selection) {
+		if !cfg.keepImage(s, s.AttrOr("src", "")) {
+			t.Errorf("%s without a pixel width was dropped", s.AttrOr("src", ""))
+		}
+	})
+}
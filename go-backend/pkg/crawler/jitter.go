@@ -0,0 +1,54 @@
+package crawler
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// hostJitter spaces a host's requests a random amount around its nominal
+// interval, so workers sharing the host don't settle into periodic bursts.
+// The rate limiter still hands out the tokens; the jitter only moves when
+// each request goes after its token is due.
+type hostJitter struct {
+	mu   sync.Mutex
+	last time.Time // when the latest request went
+}
+
+// limiterInterval is the spacing lim's rate stands for; zero for an
+// unlimited host
+func limiterInterval(limit float64) time.Duration {
+	if limit <= 0 || math.IsInf(limit, 1) {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / limit)
+}
+
+// jitteredDelay delays a request whose token is due after delay by a
+// uniform share of up to jitter of the host's interval. Tokens are due one
+// interval apart, so consecutive requests go between interval*(1-jitter)
+// and interval*(1+jitter) apart, never closer than a robots Crawl-delay.
+// It returns delay unchanged when jitter is off or the host is unlimited.
+func (hp *hostPolicies) jitteredDelay(delay time.Duration, jitter float64) time.Duration {
+	interval := limiterInterval(float64(hp.lim.Limit()))
+	if jitter <= 0 || interval == 0 {
+		return delay
+	}
+	delay += time.Duration(rand.Float64() * jitter * float64(interval))
+
+	hp.jitter.mu.Lock()
+	defer hp.jitter.mu.Unlock()
+	if floor := time.Until(hp.jitter.last.Add(hp.crawlDelay)); delay < floor {
+		delay = floor
+	}
+	return delay
+}
+
+// jitterSent records that a request to hp went now. Timers fire late, so
+// the Crawl-delay floor is measured from when requests actually went.
+func (hp *hostPolicies) jitterSent() {
+	hp.jitter.mu.Lock()
+	hp.jitter.last = time.Now()
+	hp.jitter.mu.Unlock()
+}
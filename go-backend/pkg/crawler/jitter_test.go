@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// requestGaps times n back-to-back reservations on hp
+func requestGaps(t *testing.T, hp *hostPolicies, jitter float64, n int) []time.Duration {
+	t.Helper()
+	var gaps []time.Duration
+	var last time.Time
+	for i := 0; i < n; i++ {
+		if _, err := reserveOrDefer(context.Background(), hp, time.Hour, jitter); err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		if i > 0 {
+			gaps = append(gaps, now.Sub(last))
+		}
+		last = now
+	}
+	return gaps
+}
+
+// TestRateJitter checks request spacing varies within the jitter band
+// around the host's interval, and never drops below a robots Crawl-delay.
+func TestRateJitter(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	// Timers fire late, never early; allow for a loaded machine
+	const slack = 15 * time.Millisecond
+
+	t.Run("band", func(t *testing.T) {
+		hp := &hostPolicies{lim: rate.NewLimiter(rate.Every(interval), 1)}
+		gaps := requestGaps(t, hp, 0.5, 25)
+		shortest, longest := gaps[0], gaps[0]
+		for _, gap := range gaps {
+			if gap < interval/2 || gap > interval*3/2+slack {
+				t.Errorf("gap %v outside the ±50%% band around %v", gap, interval)
+			}
+			if gap < shortest {
+				shortest = gap
+			}
+			if gap > longest {
+				longest = gap
+			}
+		}
+		if longest-shortest < interval/5 {
+			t.Errorf("gaps only ranged %v to %v; jitter not applied", shortest, longest)
+		}
+	})
+
+	t.Run("crawl-delay floor", func(t *testing.T) {
+		hp := &hostPolicies{lim: rate.NewLimiter(rate.Every(interval), 1), crawlDelay: interval}
+		for _, gap := range requestGaps(t, hp, 0.5, 25) {
+			if gap < interval {
+				t.Errorf("gap %v below the %v Crawl-delay", gap, interval)
+			}
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		hp := &hostPolicies{lim: rate.NewLimiter(rate.Every(interval), 1)}
+		for _, gap := range requestGaps(t, hp, 0, 5) {
+			// A late timer makes the limiter's next gap that much shorter
+			if gap < interval-slack || gap > interval+slack {
+				t.Errorf("gap %v without jitter, want the %v interval", gap, interval)
+			}
+		}
+	})
+}
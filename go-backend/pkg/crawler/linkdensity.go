@@ -0,0 +1,29 @@
+package crawler
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// linkDensity is the fraction of the page's visible text that sits inside
+// links: near 1 for link lists and navigation hubs, near 0 for prose. A page
+// without text has density 0.
+func linkDensity(d *goquery.Document) float64 {
+	body := d.Find("body")
+	total := textLength(body.Text())
+	if total == 0 {
+		return 0
+	}
+	anchors := 0
+	body.Find("a").Each(func(_ int, a *goquery.Selection) {
+		anchors += textLength(a.Text())
+	})
+	return min(1.0, float64(anchors)/float64(total))
+}
+
+// textLength counts the characters of s with whitespace runs collapsed
+func textLength(s string) int {
+	return utf8.RuneCountInString(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestLinkDensityScores fetches the same sentences once as prose and once
+// as a list of links and checks the link list is dense and scores lower.
+func TestLinkDensityScores(t *testing.T) {
+	sentences := []string{
+		"The mystical moon dissolved into a river of glass.",
+		"Clocks melted over the branches of a silent forest.",
+		"A staircase of clouds wandered toward the ocean at night.",
+		"Dreams of golden birds drifted through the ancient city.",
+	}
+	var prose, links strings.Builder
+	for i, s := range sentences {
+		fmt.Fprintf(&prose, "<p>%s</p>", s)
+		fmt.Fprintf(&links, `<p><a href="/story/%d">%s</a></p>`, i, s)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		body := prose.String()
+		if r.URL.Path == "/links" {
+			body = links.String()
+		}
+		fmt.Fprintf(w, "<html><head><title>Night</title></head><body>%s</body></html>", body)
+	}))
+	defer server.Close()
+
+	fetch := func(path string) Document {
+		doc, _, err := testSettings(t, nil).enhancedFetchAndParse(context.Background(), server.Client(), server.URL+path, URLMetadata{})
+		if err != nil {
+			t.Fatalf("fetching %s: %v", path, err)
+		}
+		return doc
+	}
+	proseDoc, linkDoc := fetch("/prose"), fetch("/links")
+
+	if d := proseDoc.Metadata.LinkDensity; d != 0 {
+		t.Errorf("prose link density = %v, want 0", d)
+	}
+	if d := linkDoc.Metadata.LinkDensity; d < 0.9 {
+		t.Errorf("link list density = %v, want at least 0.9", d)
+	}
+	if p, l := proseDoc.DreamHints.Complexity, linkDoc.DreamHints.Complexity; l >= p {
+		t.Errorf("complexity: links %v, prose %v; want links lower", l, p)
+	}
+	if p, l := proseDoc.DreamHints.Surrealism, linkDoc.DreamHints.Surrealism; l >= p {
+		t.Errorf("surrealism: links %v, prose %v; want links lower", l, p)
+	}
+}
+
+// TestLinkDensity checks the ratio on small fragments, including a page
+// without any text.
+func TestLinkDensity(t *testing.T) {
+	tests := []struct {
+		html string
+		want float64
+	}{
+		{`<body></body>`, 0},
+		{`<body><a href="/x">   </a></body>`, 0},
+		{`<body><a href="/x">abcd</a></body>`, 1},
+		{`<body><p>abcd <a href="/x">efgh</a> ijkl</p></body>`, 4.0 / 14.0},
+	}
+	for _, tt := range tests {
+		d, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := linkDensity(d); got != tt.want {
+			t.Errorf("linkDensity(%s) = %v, want %v", tt.html, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var errLoginFailed = errors.New("login failed: the response still contains a login form")
+
+// parseLoginFields turns "user=$USER,password=${PASS}" into form values,
+// expanding environment variables so credentials need not be on the
+// command line
+func parseLoginFields(spec string) (url.Values, error) {
+	fields := url.Values{}
+	for i, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			// Don't echo the pair back, it may be a mistyped password
+			return nil, fmt.Errorf("login field %d must be in key=value form", i+1)
+		}
+		fields.Add(key, os.ExpandEnv(value))
+	}
+	return fields, nil
+}
+
+// login POSTs fields to loginURL and keeps the session cookies in the
+// client's jar, creating one if needed, so later requests are
+// authenticated. Field values never appear in the returned errors.
+func login(ctx context.Context, client *http.Client, loginURL string, fields url.Values) error {
+	if client.Jar == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return err
+		}
+		client.Jar = jar
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", loginURL, strings.NewReader(fields.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "WebCrawlerThatDreams/1.0 (+https://github.com/dreamweaver/crawler)")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("login request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("login failed: %s returned %d", loginURL, resp.StatusCode)
+	}
+
+	gqDoc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading login response: %w", err)
+	}
+	if hasLoginForm(gqDoc) {
+		return errLoginFailed
+	}
+	return nil
+}
+
+// hasLoginForm reports whether the page asks for a password, which after
+// a login POST means the credentials were rejected
+func hasLoginForm(doc *goquery.Document) bool {
+	return doc.Find("form input[type=password]").Length() > 0
+}
@@ -0,0 +1,109 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loginServer serves /private only to clients holding the session cookie
+// set by a correct POST to /login
+func loginServer() *httptest.Server {
+	const loginForm = `<html><body><form method="post" action="/login">
+		<input name="user"><input type="password" name="password"></form></body></html>`
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/login":
+			if r.Method == "POST" && r.FormValue("user") == "dreamer" && r.FormValue("password") == "s3cret" {
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: "ok", Path: "/"})
+				http.Redirect(w, r, "/private", http.StatusSeeOther)
+				return
+			}
+			fmt.Fprint(w, loginForm)
+		case "/private":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "ok" {
+				fmt.Fprint(w, loginForm)
+				return
+			}
+			fmt.Fprint(w, `<html><body><p>Members only: the moon is made of velvet.</p></body></html>`)
+		}
+	}))
+}
+
+// TestLoginThenFetch checks that the crawler logs in with credentials from
+// the environment and can then fetch protected content.
+func TestLoginThenFetch(t *testing.T) {
+	server := loginServer()
+	defer server.Close()
+	t.Setenv("CRAWLER_TEST_PASSWORD", "s3cret")
+
+	fields, err := parseLoginFields("user=dreamer, password=$CRAWLER_TEST_PASSWORD")
+	if err != nil {
+		t.Fatalf("parseLoginFields: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := server.Client()
+	if err := login(ctx, client, server.URL+"/login", fields); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	doc, _, err := testSettings(t, nil).enhancedFetchAndParse(ctx, client, server.URL+"/private", URLMetadata{})
+	if err != nil {
+		t.Fatalf("enhancedFetchAndParse: %v", err)
+	}
+	if !strings.Contains(doc.CleanText, "moon is made of velvet") {
+		t.Errorf("CleanText = %q, want the protected content", doc.CleanText)
+	}
+}
+
+// TestLoginFailure checks that rejected credentials are reported without
+// leaking the password.
+func TestLoginFailure(t *testing.T) {
+	server := loginServer()
+	defer server.Close()
+
+	fields, err := parseLoginFields("user=dreamer,password=wrong-password")
+	if err != nil {
+		t.Fatalf("parseLoginFields: %v", err)
+	}
+
+	err = login(context.Background(), server.Client(), server.URL+"/login", fields)
+	if !errors.Is(err, errLoginFailed) {
+		t.Fatalf("login error = %v, want %v", err, errLoginFailed)
+	}
+	if strings.Contains(err.Error(), "wrong-password") {
+		t.Errorf("login error %q leaks the password", err)
+	}
+}
+
+// TestParseLoginFields checks field parsing and that malformed pairs don't
+// echo their values.
+func TestParseLoginFields(t *testing.T) {
+	t.Setenv("CRAWLER_TEST_USER", "dreamer")
+
+	fields, err := parseLoginFields("user=${CRAWLER_TEST_USER},remember=1")
+	if err != nil {
+		t.Fatalf("parseLoginFields: %v", err)
+	}
+	if got := fields.Get("user"); got != "dreamer" {
+		t.Errorf("user = %q, want %q", got, "dreamer")
+	}
+	if got := fields.Get("remember"); got != "1" {
+		t.Errorf("remember = %q, want %q", got, "1")
+	}
+
+	if _, err := parseLoginFields("hunter2"); err == nil {
+		t.Error("parseLoginFields without '=' succeeded, want error")
+	} else if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("error %q echoes the malformed value", err)
+	}
+}
@@ -0,0 +1,61 @@
+package crawler
+
+import (
+	"container/list"
+	"sync"
+)
+
+// mediaRegistry remembers media URLs across the crawl so logos and sprites
+// repeated on every page are emitted once. Like boilerplateDetector it is a
+// fixed-size LRU; an evicted URL is emitted in full again when next seen.
+type mediaRegistry struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recently seen URL at the front
+	urls     map[string]*list.Element
+}
+
+func newMediaRegistry(capacity int) *mediaRegistry {
+	return &mediaRegistry{
+		capacity: capacity,
+		order:    list.New(),
+		urls:     make(map[string]*list.Element),
+	}
+}
+
+// seen records url and reports whether it was already registered
+func (r *mediaRegistry) seen(url string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.urls[url]; ok {
+		r.order.MoveToFront(e)
+		return true
+	}
+	r.urls[url] = r.order.PushFront(url)
+	if r.order.Len() > r.capacity {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.urls, oldest.Value.(string))
+	}
+	return false
+}
+
+// dedup returns media with already-registered assets dropped, or marked
+// SeenBefore when mark is set. A nil registry returns media unchanged.
+func (r *mediaRegistry) dedup(media []MediaAsset, mark bool) []MediaAsset {
+	if r == nil || len(media) == 0 {
+		return media
+	}
+	out := make([]MediaAsset, 0, len(media))
+	for _, m := range media {
+		if r.seen(m.URL) {
+			if !mark {
+				continue
+			}
+			m.SeenBefore = true
+		}
+		out = append(out, m)
+	}
+	return out
+}
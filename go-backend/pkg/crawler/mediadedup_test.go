@@ -0,0 +1,111 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// TestMediaDedupCrawl crawls two pages sharing a logo and checks the logo is
+// a full asset on the first page and marked or dropped on the second.
+func TestMediaDedupCrawl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><img src="/logo.png" alt="Logo"><p>Home</p><a href="/second">Next</a></body></html>`)
+		default:
+			fmt.Fprint(w, `<html><body><img src="/logo.png" alt="Logo"><img src="/moon.jpg" alt="Moon"><p>Second</p></body></html>`)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	crawl := func(t *testing.T, mark bool) map[string][]MediaAsset {
+		hostMap := map[string]*hostPolicies{
+			serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+		}
+		urlQueue := make(chan URLWithMetadata, 10)
+		out := make(chan Document, 10)
+		seen := mapSeen{}
+		c := &Crawler{cfg: testSettings(t, func(c *Config) { c.DedupMediaMark = mark }), client: server.Client(), hostMap: hostMap, seen: &seen, stats: &CrawlerStats{},
+			media: newMediaRegistry(100)}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+
+		urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+		media := make(map[string][]MediaAsset)
+		for len(media) < 2 {
+			select {
+			case doc := <-out:
+				media[doc.URL] = doc.Media
+			case <-time.After(5 * time.Second):
+				t.Fatalf("only got %d of 2 documents", len(media))
+			}
+		}
+		return media
+	}
+
+	logo := server.URL + "/logo.png"
+	for _, mark := range []bool{false, true} {
+		t.Run(fmt.Sprintf("mark=%v", mark), func(t *testing.T) {
+			media := crawl(t, mark)
+
+			first := media[server.URL+"/"]
+			if len(first) != 1 || first[0].URL != logo || first[0].SeenBefore || first[0].Alt != "Logo" {
+				t.Errorf("first page media = %+v, want the full logo", first)
+			}
+
+			var gotLogo *MediaAsset
+			second := media[server.URL+"/second"]
+			for i := range second {
+				if second[i].URL == logo {
+					gotLogo = &second[i]
+				}
+			}
+			switch {
+			case mark && (gotLogo == nil || !gotLogo.SeenBefore):
+				t.Errorf("second page media = %+v, want the logo marked seen_before", second)
+			case !mark && gotLogo != nil:
+				t.Errorf("second page media = %+v, want the logo dropped", second)
+			}
+			if want := map[bool]int{false: 1, true: 2}[mark]; len(second) != want {
+				t.Errorf("second page has %d assets, want %d", len(second), want)
+			}
+		})
+	}
+}
+
+// TestMediaRegistryEviction checks that the least recently seen URL is
+// forgotten once the registry is full.
+func TestMediaRegistryEviction(t *testing.T) {
+	r := newMediaRegistry(2)
+	r.seen("a")
+	r.seen("b")
+	r.seen("a") // b is now least recent
+	r.seen("c") // evicts b
+
+	if !r.seen("a") {
+		t.Error("a was evicted, want it kept")
+	}
+	if r.seen("b") {
+		t.Error("b is still registered, want it evicted")
+	}
+}
+
+// TestMediaRegistryNil checks that a crawl without -dedup-media passes media
+// through untouched.
+func TestMediaRegistryNil(t *testing.T) {
+	var r *mediaRegistry
+	media := []MediaAsset{{URL: "https://example.com/a.png"}, {URL: "https://example.com/a.png"}}
+	if got := r.dedup(media, false); len(got) != 2 {
+		t.Errorf("dedup on nil registry = %+v, want both assets", got)
+	}
+}
@@ -0,0 +1,152 @@
+package crawler
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MicrodataItem is one HTML microdata item (an itemscope element)
+type MicrodataItem struct {
+	Type       string              `json:"type,omitempty"` // itemtype, empty for untyped items
+	Properties map[string][]string `json:"properties,omitempty"`
+	// Items are the nested items, by the itemprop that holds them
+	Items map[string][]MicrodataItem `json:"items,omitempty"`
+}
+
+// extractMicrodata collects the page's top-level items by itemtype. It
+// must run before extractText removes headers and footers, where bylines
+// often sit.
+func extractMicrodata(doc *goquery.Document) map[string][]MicrodataItem {
+	var items map[string][]MicrodataItem
+	doc.Find("[itemscope]").Each(func(_ int, s *goquery.Selection) {
+		// An itemscope that is also an itemprop belongs to its parent item
+		if _, nested := s.Attr("itemprop"); nested {
+			return
+		}
+		item := microdataItem(s)
+		if items == nil {
+			items = make(map[string][]MicrodataItem)
+		}
+		items[item.Type] = append(items[item.Type], item)
+	})
+	return items
+}
+
+// microdataItem reads the item rooted at s, recursing into nested items
+func microdataItem(s *goquery.Selection) MicrodataItem {
+	itemtype, _ := s.Attr("itemtype")
+	item := MicrodataItem{Type: strings.TrimSpace(itemtype)}
+	var walk func(s *goquery.Selection)
+	walk = func(s *goquery.Selection) {
+		s.Children().Each(func(_ int, c *goquery.Selection) {
+			itemprop, _ := c.Attr("itemprop")
+			names := strings.Fields(itemprop)
+			_, scope := c.Attr("itemscope")
+			switch {
+			case len(names) > 0 && scope:
+				nested := microdataItem(c)
+				for _, name := range names {
+					if item.Items == nil {
+						item.Items = make(map[string][]MicrodataItem)
+					}
+					item.Items[name] = append(item.Items[name], nested)
+				}
+				return // its properties are its own
+			case len(names) > 0:
+				value := microdataValue(c)
+				for _, name := range names {
+					if item.Properties == nil {
+						item.Properties = make(map[string][]string)
+					}
+					item.Properties[name] = append(item.Properties[name], value)
+				}
+			case scope:
+				return // a separate top-level item
+			}
+			walk(c)
+		})
+	}
+	walk(s)
+	return item
+}
+
+// microdataValue is an itemprop's value, taken from the attribute the
+// microdata spec assigns to the element, or else its text
+func microdataValue(s *goquery.Selection) string {
+	var key string
+	switch goquery.NodeName(s) {
+	case "meta":
+		key = "content"
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		key = "src"
+	case "a", "area", "link":
+		key = "href"
+	case "object":
+		key = "data"
+	case "data", "meter":
+		key = "value"
+	case "time":
+		if value, ok := s.Attr("datetime"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	if key != "" {
+		return strings.TrimSpace(s.AttrOr(key, ""))
+	}
+	return strings.Join(strings.Fields(s.Text()), " ")
+}
+
+// applyMicrodata fills doc's title, author and published date from the
+// first items, by itemtype, that give them. Fields already set are kept.
+func (cfg *settings) applyMicrodata(doc *Document) {
+	types := make([]string, 0, len(doc.Metadata.Microdata))
+	for typ := range doc.Metadata.Microdata {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		for _, item := range doc.Metadata.Microdata[typ] {
+			if doc.Title == "" {
+				doc.Title = firstProperty(item, "name", "headline")
+			}
+			if doc.Metadata.Author == "" {
+				if author := microdataAuthor(item); author != "" {
+					setAuthors(&doc.Metadata, []string{author}, authorFromMicrodata)
+				}
+			}
+			if doc.Metadata.PublishedAt == nil {
+				if t, ok := cfg.parsePublishedDate(firstProperty(item, "datePublished")); ok {
+					doc.Metadata.PublishedAt = &t
+				}
+			}
+		}
+	}
+}
+
+// microdataAuthor is an item's author, given as text or as a nested
+// Person or Organization item
+func microdataAuthor(item MicrodataItem) string {
+	if author := firstProperty(item, "author"); author != "" {
+		return author
+	}
+	for _, author := range item.Items["author"] {
+		if name := firstProperty(author, "name"); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// firstProperty returns the first non-empty value of the named properties
+func firstProperty(item MicrodataItem, names ...string) string {
+	for _, name := range names {
+		for _, value := range item.Properties[name] {
+			if value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const microdataPage = `<html><body>
+	<header><div itemscope itemtype="https://schema.org/Article">
+		<h1 itemprop="headline">Sleeping under the sea</h1>
+		<span itemprop="author" itemscope itemtype="https://schema.org/Person">
+			By <span itemprop="name">Ada  Diver</span>
+			<a itemprop="url" href="https://example.com/ada">profile</a>
+		</span>
+		<time itemprop="datePublished" datetime="2024-05-17T14:30:00Z">May 17</time>
+		<meta itemprop="keywords" content="dreams">
+	</div></header>
+	<p>Divers report vivid dreams after long dives.</p>
+	<div itemscope itemtype="https://schema.org/Product">
+		<span itemprop="name">Dream journal</span>
+		<img itemprop="image" src="/journal.png">
+		<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+			<data itemprop="price" value="12.50">$12.50</data>
+		</div>
+		<div itemscope itemtype="https://schema.org/Review"><span itemprop="reviewBody">Lovely</span></div>
+	</div>
+</body></html>`
+
+// TestMicrodata checks a page's Article and Product items are extracted
+// with their nested items, and the article's schema.org properties fill
+// the title, author and published date the page otherwise lacks.
+func TestMicrodata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, microdataPage)
+	}))
+	defer server.Close()
+
+	doc, _, err := testSettings(t, nil).enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/dive", URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]MicrodataItem{
+		"https://schema.org/Article": {{
+			Type: "https://schema.org/Article",
+			Properties: map[string][]string{
+				"headline":      {"Sleeping under the sea"},
+				"datePublished": {"2024-05-17T14:30:00Z"},
+				"keywords":      {"dreams"},
+			},
+			Items: map[string][]MicrodataItem{"author": {{
+				Type:       "https://schema.org/Person",
+				Properties: map[string][]string{"name": {"Ada Diver"}, "url": {"https://example.com/ada"}},
+			}}},
+		}},
+		"https://schema.org/Product": {{
+			Type:       "https://schema.org/Product",
+			Properties: map[string][]string{"name": {"Dream journal"}, "image": {"/journal.png"}},
+			Items: map[string][]MicrodataItem{"offers": {{
+				Type:       "https://schema.org/Offer",
+				Properties: map[string][]string{"price": {"12.50"}},
+			}}},
+		}},
+		"https://schema.org/Review": {{
+			Type:       "https://schema.org/Review",
+			Properties: map[string][]string{"reviewBody": {"Lovely"}},
+		}},
+	}
+	if !reflect.DeepEqual(doc.Metadata.Microdata, want) {
+		t.Errorf("microdata = %+v\nwant %+v", doc.Metadata.Microdata, want)
+	}
+
+	if doc.Title != "Sleeping under the sea" {
+		t.Errorf("title = %q, want the article headline", doc.Title)
+	}
+	if doc.Metadata.Author != "Ada Diver" {
+		t.Errorf("author = %q, want the nested Person's name", doc.Metadata.Author)
+	}
+	if published := time.Date(2024, 5, 17, 14, 30, 0, 0, time.UTC); doc.Metadata.PublishedAt == nil || !doc.Metadata.PublishedAt.Equal(published) {
+		t.Errorf("published = %v, want %v", doc.Metadata.PublishedAt, published)
+	}
+}
+
+// TestMicrodataKeepsPageMetadata checks microdata only fills gaps, and is
+// skipped entirely under -microdata=false.
+func TestMicrodataKeepsPageMetadata(t *testing.T) {
+	page := `<html><head><title>Page title</title><meta name="author" content="Meta Author"></head><body>
+		<div itemscope itemtype="https://schema.org/Article"><span itemprop="name">Item name</span>
+		<span itemprop="author">Item Author</span></div></body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	doc, _, err := testSettings(t, nil).enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "Page title" || doc.Metadata.Author != "Item Author" {
+		t.Errorf("title %q, author %q; want the page's title and the item's author", doc.Title, doc.Metadata.Author)
+	}
+
+	cfg := testSettings(t, func(c *Config) { c.Microdata = false })
+	doc, _, err = cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata.Microdata != nil || doc.Metadata.Author != "Meta Author" {
+		t.Errorf("with -microdata=false: microdata %v, author %q", doc.Metadata.Microdata, doc.Metadata.Author)
+	}
+}
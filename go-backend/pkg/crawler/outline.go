@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// OutlineNode is one heading in a document's outline
+type OutlineNode struct {
+	Level    int           `json:"level"` // 1-6, from h1-h6
+	Text     string        `json:"text"`
+	ID       string        `json:"id"` // anchor: the heading's id, or a slug of its text
+	Children []OutlineNode `json:"children,omitempty"`
+}
+
+// extractOutline builds the heading tree of doc. Each heading nests under
+// the closest preceding heading of a lower level, so skipped levels
+// (h1 then h3) still nest and a page starting at h2 simply has h2 roots.
+func extractOutline(doc *goquery.Document) []OutlineNode {
+	var flat []OutlineNode
+	used := make(map[string]int)
+
+	doc.Find("h1, h2, h3, h4, h5, h6").Each(func(i int, s *goquery.Selection) {
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		if text == "" {
+			return
+		}
+		level := int(goquery.NodeName(s)[1] - '0')
+
+		id, ok := s.Attr("id")
+		if id = strings.TrimSpace(id); !ok || id == "" {
+			id = slugify(text)
+			// Generated anchors must be unique within the page
+			if n := used[id]; n > 0 {
+				used[id] = n + 1
+				id += "-" + strconv.Itoa(n+1)
+			} else {
+				used[id] = 1
+			}
+		}
+		flat = append(flat, OutlineNode{Level: level, Text: text, ID: id})
+	})
+
+	outline, _ := nestOutline(flat, 0, 0)
+	return outline
+}
+
+// nestOutline gathers flat[i:] headings deeper than parentLevel into a
+// tree, returning it and the index of the first heading that isn't part of it
+func nestOutline(flat []OutlineNode, i, parentLevel int) ([]OutlineNode, int) {
+	var nodes []OutlineNode
+	for i < len(flat) && flat[i].Level > parentLevel {
+		node := flat[i]
+		node.Children, i = nestOutline(flat, i+1, node.Level)
+		nodes = append(nodes, node)
+	}
+	return nodes, i
+}
+
+// slugify makes an anchor id from heading text: lowercase letters and
+// digits joined by single hyphens
+func slugify(text string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
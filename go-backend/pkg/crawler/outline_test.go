@@ -0,0 +1,61 @@
+package crawler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestExtractOutline checks nesting, anchors and skipped heading levels.
+func TestExtractOutline(t *testing.T) {
+	html := `<html><body>
+		<h2>Preface</h2>
+		<h1 id="intro">Getting  Started</h1>
+		<h2>Install</h2>
+		<h3>On Linux</h3>
+		<h3>On macOS</h3>
+		<h2>Install</h2>
+		<h1>Reference</h1>
+		<h3>Flags &amp; Options</h3>
+		<h2></h2>
+		<h2>Config</h2>
+	</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []OutlineNode{
+		{Level: 2, Text: "Preface", ID: "preface"},
+		{Level: 1, Text: "Getting Started", ID: "intro", Children: []OutlineNode{
+			{Level: 2, Text: "Install", ID: "install", Children: []OutlineNode{
+				{Level: 3, Text: "On Linux", ID: "on-linux"},
+				{Level: 3, Text: "On macOS", ID: "on-macos"},
+			}},
+			{Level: 2, Text: "Install", ID: "install-2"},
+		}},
+		{Level: 1, Text: "Reference", ID: "reference", Children: []OutlineNode{
+			{Level: 3, Text: "Flags & Options", ID: "flags-options"},
+			{Level: 2, Text: "Config", ID: "config"},
+		}},
+	}
+	if got := extractOutline(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("extractOutline() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Hello, World!":  "hello-world",
+		"  --Go 1.22-- ": "go-1-22",
+		"Über Café":      "über-café",
+		"!!!":            "section",
+	}
+	for in, want := range tests {
+		if got := slugify(in); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
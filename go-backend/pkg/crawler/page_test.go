@@ -0,0 +1,544 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/temoto/robotstxt"
+	"golang.org/x/time/rate"
+)
+
+// TestExtractText verifies the text extraction logic.
+func TestExtractText(t *testing.T) {
+	html := `
+	<html>
+		<head>
+			<title>Test Page</title>
+			<style>.dark{color: #333;}</style>
+		</head>
+		<body>
+			<header><h1>Main Title</h1></header>
+			<nav><a href="/nav">Nav Link</a></nav>
+			<p>This is the first paragraph.</p>
+			<div>Here is a div with more text.</div>
+			<script>alert("hello");</script>
+			<footer><p>Copyright info</p></footer>
+		</body>
+	</html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	extracted := cleanText(testSettings(t, nil).extractText(doc))
+	expected := "This is the first paragraph. Here is a div with more text."
+
+	if extracted != expected {
+		t.Errorf("extractText() failed:\nGot:  %s\nWant: %s", extracted, expected)
+	}
+}
+
+// TestFetchAndParse verifies the fetching, parsing, and link extraction logic
+// using a mock HTTP server.
+func TestFetchAndParse(t *testing.T) {
+	// 1. Create a mock HTTP server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Serve different content based on the request path
+		switch r.URL.Path {
+		case "/page1":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, `
+				<html>
+					<head><title>Page 1</title></head>
+					<body>
+						<p>Welcome to page 1.</p>
+						<a href="/page2">Go to Page 2</a>
+						<a href="https://example.com/external">External Link</a>
+						<a href="#fragment">Fragment Link</a>
+						<a href="mailto:test@example.com">Mail Link</a>
+					</body>
+				</html>`)
+		case "/page2":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, `<html><head><title>Page 2</title></head><body><p>This is page 2.</p></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// 2. Create a client that uses the mock server
+	client := server.Client()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 3. Call the function to be tested
+	page1URL := server.URL + "/page1"
+	doc, links, err := testSettings(t, nil).enhancedFetchAndParse(ctx, client, page1URL, URLMetadata{})
+
+	// 4. Assert the results
+	if err != nil {
+		t.Fatalf("enhancedFetchAndParse() returned an error: %v", err)
+	}
+
+	// Check the document content
+	if doc.URL != page1URL {
+		t.Errorf("doc.URL is incorrect. got %q, want %q", doc.URL, page1URL)
+	}
+	if doc.Title != "Page 1" {
+		t.Errorf("doc.Title is incorrect. got %q, want %q", doc.Title, "Page 1")
+	}
+	expectedText := "Welcome to page 1. Go to Page 2 External Link Fragment Link Mail Link"
+	if doc.CleanText != expectedText {
+		t.Errorf("doc.CleanText is incorrect. got %q, want %q", doc.CleanText, expectedText)
+	}
+
+	// Check the extracted links
+	if len(links) != 2 {
+		t.Fatalf("Expected 2 links, but got %d. Links: %v", len(links), links)
+	}
+
+	expectedLink1 := server.URL + "/page2"
+	if links[0].URL != expectedLink1 {
+		t.Errorf("Link 1 is incorrect. got %q, want %q", links[0].URL, expectedLink1)
+	}
+
+	expectedLink2 := "https://example.com/external"
+	if links[1].URL != expectedLink2 {
+		t.Errorf("Link 2 is incorrect. got %q, want %q", links[1].URL, expectedLink2)
+	}
+}
+
+// TestLinksToFollow verifies that -max-follow-per-page keeps only the
+// highest-priority links for the frontier while doc.Links keeps them all.
+func TestLinksToFollow(t *testing.T) {
+	var body strings.Builder
+	body.WriteString("<html><head><title>Links</title></head><body>")
+	for i := 0; i < 50; i++ {
+		if i%10 == 0 {
+			// Internal links mentioning "article" score highest (priority 5).
+			fmt.Fprintf(&body, `<a href="/article-%d">Read article %d</a>`, i, i)
+		} else {
+			fmt.Fprintf(&body, `<a href="https://external-%d.example.com/">External %d</a>`, i, i)
+		}
+	}
+	body.WriteString("</body></html>")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, body.String())
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	doc, links, err := testSettings(t, nil).enhancedFetchAndParse(ctx, server.Client(), server.URL+"/", URLMetadata{})
+	if err != nil {
+		t.Fatalf("enhancedFetchAndParse() returned an error: %v", err)
+	}
+	if len(links) != 50 || len(doc.Links) != 50 {
+		t.Fatalf("expected 50 extracted links, got %d (doc.Links: %d)", len(links), len(doc.Links))
+	}
+
+	// Add a sixth article link at the end so the cap, not the page, decides.
+	links = append(links, ExtractedLink{URL: server.URL + "/article-late", Priority: 5})
+
+	follow := linksToFollow(links, 5)
+	if len(follow) != 5 {
+		t.Fatalf("expected 5 links to follow, got %d: %v", len(follow), follow)
+	}
+	for i, link := range follow {
+		want := fmt.Sprintf("%s/article-%d", server.URL, i*10)
+		if link.URL != want {
+			t.Errorf("follow[%d] = %q, want %q", i, link.URL, want)
+		}
+	}
+
+	if got := linksToFollow(links, 0); len(got) != len(links) {
+		t.Errorf("unlimited follow returned %d links, want %d", len(got), len(links))
+	}
+}
+
+// TestWorkerSkipAccounting drives each skip path in enhancedWorker and checks
+// that the matching CrawlerStats counter is incremented.
+func TestWorkerSkipAccounting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body>
+			<a href="/one">One</a>
+			<a href="/two">Two</a>
+			<a href="/three">Three</a>
+		</body></html>`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	tests := []struct {
+		name           string
+		queueSize      int
+		url            string
+		depth          int
+		allowedDomains []string
+		preSeen        bool
+		robots         string
+		counter        func(*CrawlerStats) int64
+		want           int64
+	}{
+		{
+			name:    "seen",
+			url:     server.URL + "/",
+			preSeen: true,
+			counter: func(s *CrawlerStats) int64 { return s.SkippedSeen },
+			want:    1,
+		},
+		{
+			name:    "depth",
+			url:     server.URL + "/",
+			depth:   DefaultConfig().MaxDepth + 1,
+			counter: func(s *CrawlerStats) int64 { return s.SkippedDepth },
+			want:    1,
+		},
+		{
+			name:           "scope",
+			url:            server.URL + "/",
+			allowedDomains: []string{"elsewhere.example.com"},
+			counter:        func(s *CrawlerStats) int64 { return s.SkippedScope },
+			want:           1,
+		},
+		{
+			name:    "robots",
+			url:     server.URL + "/private/page",
+			robots:  "User-agent: *\nDisallow: /private\n",
+			counter: func(s *CrawlerStats) int64 { return s.SkippedRobots },
+			want:    1,
+		},
+		{
+			// The first of three links fills the single queue slot, the
+			// other two are dropped.
+			name:      "queue full",
+			queueSize: 1,
+			url:       server.URL + "/",
+			counter:   func(s *CrawlerStats) int64 { return s.SkippedQueueFull },
+			want:      2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queueSize := tt.queueSize
+			if queueSize == 0 {
+				queueSize = 10
+			}
+			urlQueue := make(chan URLWithMetadata, queueSize)
+			out := make(chan Document, 10)
+			stats := &CrawlerStats{}
+			seen := mapSeen{}
+			hostMap := make(map[string]*hostPolicies)
+
+			if tt.preSeen {
+				seen.add(tt.url)
+			}
+			if tt.robots != "" {
+				robots, err := robotstxt.FromString(tt.robots)
+				if err != nil {
+					t.Fatalf("robotstxt.FromString() returned an error: %v", err)
+				}
+				hostMap[serverURL.Host] = &hostPolicies{robots: robots, lim: rate.NewLimiter(rate.Inf, 1)}
+			}
+
+			cfg := testSettings(t, func(c *Config) { c.AllowedDomains = tt.allowedDomains })
+			c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+			}()
+
+			urlQueue <- URLWithMetadata{URL: tt.url, Metadata: URLMetadata{depth: tt.depth}}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				stats.mu.Lock()
+				got := tt.counter(stats)
+				stats.mu.Unlock()
+				if got == tt.want {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("skip counter = %d, want %d", got, tt.want)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			cancel()
+			<-done
+		})
+	}
+}
+
+// TestRequestHeaders verifies Referer propagation and Headers/AcceptLanguage
+// handling on outgoing requests.
+func TestRequestHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><p>Hello</p></body></html>`)
+	}))
+	defer server.Close()
+
+	cfg := testSettings(t, func(c *Config) {
+		c.Headers = http.Header{"X-Crawl-Tag": {"nightly"}}
+		c.AcceptLanguage = "de-DE,de;q=0.9"
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	parent := server.URL + "/index"
+	if _, _, err := cfg.enhancedFetchAndParse(ctx, server.Client(), server.URL+"/child", URLMetadata{depth: 1, parent: parent}); err != nil {
+		t.Fatalf("enhancedFetchAndParse() returned an error: %v", err)
+	}
+
+	if ref := got.Get("Referer"); ref != parent {
+		t.Errorf("Referer = %q, want %q", ref, parent)
+	}
+	if tag := got.Get("X-Crawl-Tag"); tag != "nightly" {
+		t.Errorf("X-Crawl-Tag = %q, want %q", tag, "nightly")
+	}
+	if lang := got.Get("Accept-Language"); lang != "de-DE,de;q=0.9" {
+		t.Errorf("Accept-Language = %q, want %q", lang, "de-DE,de;q=0.9")
+	}
+	if ua := got.Get("User-Agent"); !strings.HasPrefix(ua, "WebCrawlerThatDreams/1.0") {
+		t.Errorf("User-Agent = %q, want the crawler default", ua)
+	}
+
+	// An explicit User-Agent header replaces the default
+	cfg = testSettings(t, func(c *Config) { c.Headers = http.Header{"User-Agent": {"CustomBot/2.0"}} })
+	if _, _, err := cfg.enhancedFetchAndParse(ctx, server.Client(), server.URL+"/", URLMetadata{}); err != nil {
+		t.Fatalf("enhancedFetchAndParse() returned an error: %v", err)
+	}
+	if ua := got.Get("User-Agent"); ua != "CustomBot/2.0" {
+		t.Errorf("User-Agent = %q, want %q", ua, "CustomBot/2.0")
+	}
+	if ref := got.Get("Referer"); ref != "" {
+		t.Errorf("seed request sent Referer %q, want none", ref)
+	}
+}
+
+// TestWorkerShutdownWhileEmitting verifies that a worker blocked handing a
+// document downstream returns once its context is cancelled.
+func TestWorkerShutdownWhileEmitting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><p>Nobody is listening.</p></body></html>`)
+	}))
+	defer server.Close()
+
+	urlQueue := make(chan URLWithMetadata, 1)
+	out := make(chan Document) // never read, like a stopped dream processor
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+	hostMap := make(map[string]*hostPolicies)
+
+	c := &Crawler{client: server.Client(), cfg: testSettings(t, nil), hostMap: hostMap, seen: &seen, stats: stats}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+	}()
+
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+
+	// PagesProcessed is bumped right before the document is emitted
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats.mu.Lock()
+		pages := stats.PagesProcessed
+		stats.mu.Unlock()
+		if pages == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("worker never fetched the page")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not return after context cancellation")
+	}
+}
+
+// TestSoft404Detection verifies that a "not found" page served with a 200 is
+// flagged while a short but genuine article, even a noindex one, is not.
+func TestSoft404Detection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/missing":
+			fmt.Fprint(w, `<html><head><title>Page Not Found | Example</title>
+				<meta name="robots" content="noindex, follow"></head>
+				<body><h1>Oops!</h1><p>The page you requested doesn't exist.</p><a href="/">Home</a></body></html>`)
+		case "/short":
+			fmt.Fprint(w, `<html><head><title>Release notes 1.2</title></head>
+				<body><h1>Release notes</h1><p>Version 1.2 fixes the login bug and speeds up search.</p></body></html>`)
+		case "/private":
+			fmt.Fprint(w, `<html><head><title>Team calendar</title>
+				<meta name="robots" content="noindex"></head>
+				<body><h1>Team calendar</h1><p>Standup moves to ten on Fridays.</p></body></html>`)
+		case "/about-404":
+			fmt.Fprint(w, `<html><head><title>Why we redesigned our 404 page</title></head>
+				<body><article><p>`+strings.Repeat("A long essay about error pages and design. ", 40)+`</p></article></body></html>`)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/missing", true},
+		{"/short", false},
+		{"/private", false},
+		{"/about-404", false},
+	}
+	for _, tt := range tests {
+		doc, _, err := testSettings(t, nil).enhancedFetchAndParse(ctx, server.Client(), server.URL+tt.path, URLMetadata{})
+		if err != nil {
+			t.Fatalf("enhancedFetchAndParse(%s) returned an error: %v", tt.path, err)
+		}
+		if doc.Metadata.Soft404 != tt.want {
+			t.Errorf("%s: Soft404 = %v, want %v", tt.path, doc.Metadata.Soft404, tt.want)
+		}
+	}
+}
+
+// TestMinLengthFilter checks that a thin page is counted but not emitted,
+// that its links are still followed, and that a long page is emitted.
+func TestMinLengthFilter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><a href="/long">Read more here</a></body></html>`)
+		case "/long":
+			fmt.Fprint(w, `<html><body><p>`+strings.Repeat("word ", 300)+`</p></body></html>`)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+	}
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+
+	c := &Crawler{cfg: testSettings(t, func(c *Config) { c.MinWordCount = 100 }), client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+
+	// The thin page is fetched first, so the first emitted document shows
+	// whether it was suppressed
+	select {
+	case doc := <-out:
+		if doc.URL != server.URL+"/long" {
+			t.Errorf("emitted %s, want only %s/long", doc.URL, server.URL)
+		}
+		if doc.Metadata.WordCount != 300 {
+			t.Errorf("WordCount = %d, want 300", doc.Metadata.WordCount)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("long page was not emitted")
+	}
+	if got := pagesProcessed(stats); got != 2 {
+		t.Errorf("PagesProcessed = %d, want 2", got)
+	}
+	select {
+	case doc := <-out:
+		t.Errorf("unexpected second document %s", doc.URL)
+	default:
+	}
+}
+
+// TestBaseHref checks that links and media resolve against <base href>,
+// including a relative one, and fall back to the page URL without it.
+func TestBaseHref(t *testing.T) {
+	tests := []struct {
+		name      string
+		head      string
+		wantLink  string
+		wantType  string // relative to the page's host, not the base
+		wantImage string
+	}{
+		{"absolute base", `<base href="https://cdn.example.org/assets/">`,
+			"https://cdn.example.org/assets/docs/guide.html", "external", "https://cdn.example.org/assets/img/moon.png"},
+		{"relative base", `<base href="/v2/"><base href="/ignored/">`,
+			"https://example.com/v2/docs/guide.html", "internal", "https://example.com/v2/img/moon.png"},
+		{"no base", ``,
+			"https://example.com/blog/docs/guide.html", "internal", "https://example.com/blog/img/moon.png"},
+	}
+	for _, tt := range tests {
+		html := `<html><head>` + tt.head + `</head><body>
+			<a href="docs/guide.html">Guide</a><img src="img/moon.png"></body></html>`
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		links := testSettings(t, nil).extractLinksWithPriority(doc, "https://example.com/blog/post", 0)
+		if len(links) != 1 || links[0].URL != tt.wantLink {
+			t.Errorf("%s: links = %+v, want %s", tt.name, links, tt.wantLink)
+		} else if links[0].Type != tt.wantType {
+			t.Errorf("%s: link type = %s, want %s", tt.name, links[0].Type, tt.wantType)
+		}
+		media := testSettings(t, nil).extractMediaAssets(doc, "https://example.com/blog/post")
+		if len(media) != 1 || media[0].URL != tt.wantImage {
+			t.Errorf("%s: media = %+v, want %s", tt.name, media, tt.wantImage)
+		}
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// pagesProcessed reads the page counter under the stats lock.
+func pagesProcessed(stats *CrawlerStats) int64 {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	return stats.PagesProcessed
+}
@@ -0,0 +1,103 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// paginationPriority puts the next page of a series ahead of new links
+const paginationPriority = 9
+
+// nextAnchorTexts are link texts that mean "next page" when no rel says so
+var nextAnchorTexts = map[string]bool{
+	"next": true, "next page": true, "next »": true, "next ›": true, "next →": true,
+	"›": true, "»": true, "→": true, "older posts": true,
+}
+
+func init() {
+	RegisterDocumentProcessor("pagination", DocumentProcessorFunc(processPagination))
+}
+
+func processPagination(_ context.Context, doc *Document, page *ParsedPage) error {
+	doc.NextPage = page.cfg.findNextPage(page.Document, doc.URL)
+	return nil
+}
+
+// findNextPage returns the page's next-page URL: a rel="next" <link> or
+// anchor first, then an anchor reading "Next", "›" and the like. A link
+// back to the page itself doesn't count.
+func (cfg *settings) findNextPage(doc *goquery.Document, pageURL string) string {
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	base := resolutionBase(doc, page)
+
+	var next string
+	try := func(_ int, s *goquery.Selection) bool {
+		href, _ := s.Attr("href")
+		if href = strings.TrimSpace(href); href == "" {
+			return true
+		}
+		resolved, err := base.Parse(href)
+		if err != nil || !cfg.allowedSchemes[resolved.Scheme] {
+			return true
+		}
+		if resolved.Host, err = toASCIIHost(resolved.Host); err != nil {
+			return true
+		}
+		if cfg.canonicalURL(resolved.String()) == cfg.canonicalURL(pageURL) {
+			return true
+		}
+		next = resolved.String()
+		return false
+	}
+
+	doc.Find("link[rel][href], a[rel][href]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if rel, _ := s.Attr("rel"); !hasRel(rel, "next") {
+			return true
+		}
+		return try(i, s)
+	})
+	if next == "" {
+		doc.Find("a[href]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+			text := strings.ToLower(strings.Join(strings.Fields(s.Text()), " "))
+			if !nextAnchorTexts[text] {
+				return true
+			}
+			return try(i, s)
+		})
+	}
+	return next
+}
+
+// withNextPage makes sure links include doc's next page at pagination
+// priority, when the series may still continue. It reports whether
+// pagination applies, so the caller keeps the next page at this depth.
+func (cfg *settings) withNextPage(links []ExtractedLink, doc Document, meta URLMetadata) ([]ExtractedLink, bool) {
+	if doc.NextPage == "" || meta.pageRun >= cfg.MaxPagination {
+		return links, false
+	}
+	next := cfg.canonicalURL(doc.NextPage)
+	out := make([]ExtractedLink, 0, len(links)+1)
+	found := false
+	for _, link := range links {
+		if cfg.canonicalURL(link.URL) == next {
+			if found {
+				continue // listed twice, e.g. "Next" at the top and bottom
+			}
+			found = true
+			link.URL = doc.NextPage
+			link.Priority = paginationPriority
+		}
+		out = append(out, link)
+	}
+	if !found {
+		// Only a <link rel="next">, not an anchor
+		out = append(out, ExtractedLink{URL: doc.NextPage, Text: "next", Type: "internal", Priority: paginationPriority})
+	}
+	return out, true
+}
@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/time/rate"
+)
+
+// paginatedServer serves a three-page series: page 1 has a <link
+// rel="next">, page 2 a "Next ›" anchor, and page 3 points back to page 1.
+// Page 1 also links to an ordinary article one level deeper.
+func paginatedServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/list/1":
+			fmt.Fprint(w, `<html><head><link rel="next" href="/list/2"></head><body><p>Page one</p><a href="/article">Article</a></body></html>`)
+		case "/list/2":
+			fmt.Fprint(w, `<html><body><p>Page two</p><a href="/list/1">‹ Prev</a><a href="/list/3">Next ›</a></body></html>`)
+		case "/list/3":
+			fmt.Fprint(w, `<html><body><p>Page three</p><a rel="next" href="/list/1">Back to start</a></body></html>`)
+		default:
+			fmt.Fprint(w, `<html><body><p>An article</p></body></html>`)
+		}
+	}))
+}
+
+// TestPaginationFollowing crawls the series at -max-depth=0 and checks
+// every page is fetched because next pages don't use up depth, while the
+// ordinary link is still held to the depth limit.
+func TestPaginationFollowing(t *testing.T) {
+	server := paginatedServer()
+	defer server.Close()
+
+	tests := []struct {
+		maxPagination int
+		wantPages     []string
+		wantDepth     int64
+	}{
+		{20, []string{"/list/1", "/list/2", "/list/3"}, 1}, // only the article
+		{1, []string{"/list/1", "/list/2"}, 2},             // page 3 is then an ordinary, too-deep link
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("max-pagination=%d", tt.maxPagination), func(t *testing.T) {
+			cfg := testSettings(t, func(c *Config) {
+				c.MaxDepth = 0
+				c.MaxPagination = tt.maxPagination
+			})
+			serverURL, _ := url.Parse(server.URL)
+			hostMap := map[string]*hostPolicies{serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)}}
+			queue := make(chan URLWithMetadata, 20)
+			out := make(chan Document, 20)
+			stats := &CrawlerStats{}
+			seen := mapSeen{}
+			c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go c.enhancedWorker(ctx, 0, queue, queue, out)
+
+			queue <- URLWithMetadata{URL: server.URL + "/list/1"}
+			waitFor(t, "the series", func() bool {
+				snap := stats.Snapshot()
+				return snap.PagesProcessed == int64(len(tt.wantPages)) && snap.SkippedDepth == tt.wantDepth
+			})
+			cancel()
+
+			var got []string
+			for len(out) > 0 {
+				doc := <-out
+				got = append(got, strings.TrimPrefix(doc.URL, server.URL))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.wantPages, " ") {
+				t.Errorf("crawled %v, want %v", got, tt.wantPages)
+			}
+		})
+	}
+}
+
+// TestFindNextPage checks the rel and anchor-text forms, and that a link
+// back to the page itself is ignored.
+func TestFindNextPage(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{`<link rel="next" href="/p/2">`, "https://example.com/p/2"},
+		{`<a rel="nofollow next" href="?page=2">2</a>`, "https://example.com/p/1?page=2"},
+		{`<a href="/p/2">Next  »</a>`, "https://example.com/p/2"},
+		{`<a href="/p/1">Next</a>`, ""},
+		{`<a href="/p/2">Next steps for gardeners</a>`, ""},
+	}
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := testSettings(t, nil).findNextPage(doc, "https://example.com/p/1"); got != tt.want {
+			t.Errorf("findNextPage(%s) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
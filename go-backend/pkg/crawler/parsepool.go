@@ -0,0 +1,43 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+)
+
+// parseJob is a fetched page waiting for a parse worker
+type parseJob struct {
+	urlMeta URLWithMetadata
+	host    string
+	page    fetchedPage
+}
+
+// parseWorker builds, emits and follows the links of pages the fetch
+// workers queue on jobs, until ctx is done
+func (c *Crawler) parseWorker(ctx context.Context, id int, jobs <-chan parseJob, frontier chan<- URLWithMetadata, out chan<- Document) {
+	who := fmt.Sprintf("parser %d", id)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-jobs:
+			if !c.handlePage(ctx, who, job, frontier, out) {
+				return
+			}
+		}
+	}
+}
+
+// dropParseJobs releases pages still queued for parsing at shutdown,
+// keeping their URLs in the saved frontier. Workers must have stopped.
+func (c *Crawler) dropParseJobs() {
+	for {
+		select {
+		case job := <-c.parseQueue:
+			job.page.release(c.cfg)
+			c.interrupted(job.urlMeta)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestParseWorkers crawls a page linking to four others with one fetch
+// worker and three parse workers. A processor holding each linked page
+// until three are parsed at once shows parsing runs on its own pool,
+// sized apart from fetching, and every document still comes out.
+func TestParseWorkers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><body><p>Index</p>
+				<a href="/one">One</a><a href="/two">Two</a><a href="/three">Three</a><a href="/four">Four</a></body></html>`)
+			return
+		}
+		fmt.Fprintf(w, `<html><body><p>Page %s</p></body></html>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	parsing, peak := 0, 0
+	together := make(chan struct{})
+	RegisterDocumentProcessor("test-barrier", DocumentProcessorFunc(func(_ context.Context, doc *Document, _ *ParsedPage) error {
+		if strings.HasSuffix(doc.URL, "/") {
+			return nil
+		}
+		mu.Lock()
+		parsing++
+		if parsing > peak {
+			peak = parsing
+		}
+		if parsing == 3 {
+			close(together)
+		}
+		mu.Unlock()
+		select {
+		case <-together:
+		case <-time.After(3 * time.Second):
+		}
+		mu.Lock()
+		parsing--
+		mu.Unlock()
+		return nil
+	}))
+	defer RegisterDocumentProcessor("test-barrier", nil)
+
+	hook := &recordingHook{statuses: make(map[string]int), skips: make(map[string]SkipReason)}
+	cfg := DefaultConfig()
+	cfg.Seeds = []string{server.URL + "/"}
+	cfg.Workers, cfg.ParseWorkers, cfg.QueueSize = 1, 3, 10
+	cfg.Client, cfg.Hook = server.Client(), hook
+	crawler, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan ShutdownReason)
+	go func() { done <- crawler.Run(ctx) }()
+
+	waitFor(t, "all five documents", func() bool {
+		hook.mu.Lock()
+		defer hook.mu.Unlock()
+		return len(hook.docs) == 5
+	})
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if peak != 3 {
+		t.Errorf("at most %d pages were parsed at once, want 3 with one fetch worker", peak)
+	}
+	if stats := crawler.Stats(); stats.PagesProcessed != 5 || stats.Workers != 1 {
+		t.Errorf("stats: %d pages, %d fetch workers; want 5 and 1", stats.PagesProcessed, stats.Workers)
+	}
+}
+
+func TestNewRejectsNegativeParseWorkers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seeds, cfg.ParseWorkers, cfg.Client = []string{"https://example.com/"}, -1, &http.Client{}
+	if _, err := New(cfg); err == nil {
+		t.Error("New accepted -1 parse workers")
+	}
+}
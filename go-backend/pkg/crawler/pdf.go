@@ -0,0 +1,313 @@
+package crawler
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	errPDFEncrypted = errors.New("pdf is encrypted")
+	errPDFNoText    = errors.New("pdf has no extractable text")
+	errPDFCIDFonts  = errors.New("pdf uses CID fonts, which aren't supported")
+	errNotPDF       = errors.New("not a pdf")
+)
+
+// isPDF reports whether a Content-Type names a PDF
+func isPDF(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/pdf"
+}
+
+// pdfStreamPattern finds each stream's dictionary, which may hold one
+// level of nested dictionaries, and the start of its data
+var pdfStreamPattern = regexp.MustCompile(`(?s)<<((?:[^<>]|<[^<]|>[^>]|<<(?:[^<>]|<[^<]|>[^>])*>>)*)>>\s*stream\r?\n`)
+
+// pdfTrailerPattern finds each classic trailer dictionary
+var pdfTrailerPattern = regexp.MustCompile(`(?s)trailer\s*<<(.*?)>>\s*(?:startxref|%%EOF)`)
+
+// pdfCIDFontPattern finds fonts whose strings are two-byte glyph IDs
+var pdfCIDFontPattern = regexp.MustCompile(`/Encoding\s*/Identity-[HV]`)
+
+// pdfTitlePattern finds a literal /Title in the document info dictionary
+var pdfTitlePattern = regexp.MustCompile(`/Title\s*\(`)
+
+// extractPDFText pulls the title and text out of a PDF's content streams,
+// one line per text object. It covers what simple generators write: plain
+// or FlateDecode streams and single-byte or UTF-16 strings shown with
+// Tj/TJ/'/". It is deliberately small and bounded by -max-decompressed-bytes
+// rather than a full PDF reader, so it does not support:
+//   - CID fonts (Identity-H/V); such PDFs fail with errPDFCIDFonts
+//   - other custom font encodings, whose text comes out garbled
+//   - a /Title stored in a compressed object stream, which is left empty
+//   - stream dictionaries nested more than one level deep, which are skipped
+//
+// Encryption is read from the trailer or cross-reference stream dictionary.
+func (cfg *settings) extractPDFText(data []byte) (title, text string, err error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return "", "", errNotPDF
+	}
+	for _, m := range pdfTrailerPattern.FindAllSubmatch(data, -1) {
+		if bytes.Contains(m[1], []byte("/Encrypt")) {
+			return "", "", errPDFEncrypted
+		}
+	}
+	if pdfCIDFontPattern.Match(data) {
+		return "", "", errPDFCIDFonts
+	}
+
+	var lines []string
+	inflated := cfg.getBuffer()
+	defer cfg.putBuffer(inflated)
+	for _, m := range pdfStreamPattern.FindAllSubmatchIndex(data, -1) {
+		dict := string(data[m[2]:m[3]])
+		rest := data[m[1]:]
+		end := bytes.Index(rest, []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		raw := rest[:end]
+
+		switch {
+		case strings.Contains(dict, "/XRef"):
+			if strings.Contains(dict, "/Encrypt") {
+				return "", "", errPDFEncrypted
+			}
+			continue
+		case strings.Contains(dict, "/ObjStm"):
+			continue // objects, not page content
+		case strings.Contains(dict, "/Subtype") || strings.Contains(dict, "/Length1"):
+			continue // images and embedded fonts
+		case strings.Contains(dict, "/FlateDecode"):
+			if err := cfg.inflate(inflated, raw, cfg.MaxDecompressedBytes); errors.Is(err, errDecompressionBomb) {
+				return "", "", err
+			} else if err != nil && inflated.Len() == 0 {
+				continue
+			}
+			raw = inflated.Bytes()
+		case strings.Contains(dict, "/Filter"):
+			continue // other encodings aren't text we can read
+		}
+		lines = append(lines, pdfContentText(raw)...)
+	}
+	if len(lines) == 0 {
+		return "", "", errPDFNoText
+	}
+
+	if loc := pdfTitlePattern.FindIndex(data); loc != nil {
+		if s, _, ok := readPDFLiteral(data, loc[1]-1); ok {
+			title = strings.TrimSpace(decodePDFString(s))
+		}
+	}
+	return title, strings.Join(lines, "\n"), nil
+}
+
+// pdfWordGap is the TJ shift, in thousandths of a text unit, treated as a
+// space between words
+const pdfWordGap = 200
+
+// pdfContentText interprets the text operators of one content stream
+func pdfContentText(content []byte) []string {
+	var lines []string
+	var line strings.Builder
+	var operands [][]byte // strings since the last operator
+	var arrayParts []string
+	inArray := false
+
+	flush := func() {
+		if s := strings.TrimSpace(line.String()); s != "" {
+			lines = append(lines, strings.Join(strings.Fields(s), " "))
+		}
+		line.Reset()
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, next, ok := readPDFLiteral(content, i)
+			if !ok {
+				return lines
+			}
+			operands = append(operands, s)
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return lines
+			}
+			operands = append(operands, decodePDFHex(content[i+1:i+end]))
+			i += end + 1
+		case c == '[':
+			operands, arrayParts, inArray = nil, nil, true
+			i++
+		case c == ']':
+			for _, s := range operands {
+				arrayParts = append(arrayParts, decodePDFString(s))
+			}
+			operands, inArray = nil, false
+			i++
+		case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9':
+			start := i
+			for i++; i < len(content) && (content[i] == '.' || content[i] >= '0' && content[i] <= '9'); i++ {
+			}
+			// In a TJ array, a shift wider than a word gap reads as a space
+			if n, err := strconv.ParseFloat(string(content[start:i]), 64); err == nil && inArray && n <= -pdfWordGap {
+				operands = append(operands, []byte(" "))
+			}
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '\'' || c == '"' || c == '*':
+			start := i
+			for i < len(content) && (content[i] >= 'A' && content[i] <= 'Z' || content[i] >= 'a' && content[i] <= 'z' ||
+				content[i] == '\'' || content[i] == '"' || content[i] == '*') {
+				i++
+			}
+			switch string(content[start:i]) {
+			case "Tj":
+				for _, s := range operands {
+					line.WriteString(decodePDFString(s))
+				}
+			case "'", "\"":
+				flush()
+				for _, s := range operands {
+					line.WriteString(decodePDFString(s))
+				}
+			case "TJ":
+				line.WriteString(strings.Join(arrayParts, ""))
+			case "T*", "ET":
+				flush()
+			case "Td", "TD":
+				line.WriteByte(' ')
+			}
+			operands, arrayParts, inArray = nil, nil, false
+		default:
+			i++
+		}
+	}
+	flush()
+	return lines
+}
+
+// readPDFLiteral reads the (…) string starting at data[i], handling
+// escapes and balanced parentheses, and returns the index after it
+func readPDFLiteral(data []byte, i int) ([]byte, int, bool) {
+	var out []byte
+	depth := 0
+	for i++; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '\\':
+			i++
+			if i >= len(data) {
+				return nil, i, false
+			}
+			switch e := data[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					for n := 0; n < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; n++ {
+						v = v*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			if depth == 0 {
+				return out, i + 1, true
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return nil, i, false
+}
+
+// decodePDFHex decodes a <…> string body, ignoring whitespace and padding
+// an odd final digit with 0
+func decodePDFHex(hexDigits []byte) []byte {
+	var out []byte
+	var hi byte
+	odd := false
+	for _, c := range hexDigits {
+		var v byte
+		switch {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			continue
+		}
+		if odd {
+			out = append(out, hi<<4|v)
+		} else {
+			hi = v
+		}
+		odd = !odd
+	}
+	if odd {
+		out = append(out, hi<<4)
+	}
+	return out
+}
+
+// decodePDFString converts a PDF string to UTF-8: UTF-16BE when it has a
+// byte order mark, otherwise one character per byte
+func decodePDFString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// pdfToHTML renders extracted PDF text as a minimal page, one paragraph
+// per line, so it goes through the same analysis as HTML
+func pdfToHTML(title, text string) string {
+	var b strings.Builder
+	b.WriteString("<html><head><title>")
+	b.WriteString(html.EscapeString(title))
+	b.WriteString("</title></head><body>")
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString("<p>")
+		b.WriteString(html.EscapeString(line))
+		b.WriteString("</p>\n")
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
@@ -0,0 +1,166 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testPDF builds a small PDF whose page content is a FlateDecode stream,
+// alongside an embedded font stream the extractor must ignore
+func testPDF(content string, encrypted bool) []byte {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte(content))
+	zw.Close()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	b.WriteString("1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	b.WriteString("2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n")
+	b.WriteString("3 0 obj << /Type /Page /Parent 2 0 R /Contents 4 0 R >> endobj\n")
+	fmt.Fprintf(&b, "4 0 obj << /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	b.Write(compressed.Bytes())
+	b.WriteString("\nendstream endobj\n")
+	b.WriteString("5 0 obj << /Length 24 /Length1 24 >>\nstream\n(Not text) Tj BT ET xx\nendstream endobj\n")
+	b.WriteString("6 0 obj << /Title (Moon Survey) /Producer (test) >> endobj\n")
+	trailer := "trailer << /Root 1 0 R /Info 6 0 R >>\n%%EOF\n"
+	if encrypted {
+		trailer = "trailer << /Root 1 0 R /Info 6 0 R /Encrypt 7 0 R >>\n%%EOF\n"
+	}
+	b.WriteString(trailer)
+	return b.Bytes()
+}
+
+const testPDFContent = `BT /F1 12 Tf 72 720 Td (Lunar craters \(large\) are very old indeed.) Tj ET
+BT 72 700 Td [(Mare) -250 (Imbr) 15 (ium) -300 (is) -300 (vast.)] TJ ET
+BT 72 680 Td <FEFF004D006F006F006E> Tj ET`
+
+// TestExtractPDF serves a PDF and checks its text and title go through the
+// normal document pipeline with -extract-pdf, and that encrypted or broken
+// PDFs are skipped.
+func TestExtractPDF(t *testing.T) {
+	files := map[string][]byte{
+		"/survey.pdf":    testPDF(testPDFContent, false),
+		"/locked.pdf":    testPDF(testPDFContent, true),
+		"/truncated.pdf": []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\n"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(files[r.URL.Path])
+	}))
+	defer server.Close()
+
+	cfg := testSettings(t, func(c *Config) { c.ExtractPDF = true })
+	doc, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/survey.pdf", URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantText := "Lunar craters (large) are very old indeed.\nMare Imbrium is vast.\nMoon"
+	if doc.Text != wantText {
+		t.Errorf("Text = %q, want %q", doc.Text, wantText)
+	}
+	if doc.Title != "Moon Survey" || doc.Metadata.ContentType != "application/pdf" {
+		t.Errorf("Title = %q, ContentType = %q", doc.Title, doc.Metadata.ContentType)
+	}
+	if doc.Metadata.WordCount != 12 || len(doc.Chunks) == 0 {
+		t.Errorf("WordCount = %d, %d chunks: the PDF skipped the analysis pipeline", doc.Metadata.WordCount, len(doc.Chunks))
+	}
+
+	for path, want := range map[string]error{"/locked.pdf": errPDFEncrypted, "/truncated.pdf": errPDFNoText} {
+		_, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+path, URLMetadata{})
+		var fe *FetchError
+		if !errors.As(err, &fe) || fe.Category != FetchParse || !errors.Is(err, want) {
+			t.Errorf("%s: err = %v, want a parse FetchError wrapping %v", path, err, want)
+		}
+	}
+
+	doc, _, _ = testSettings(t, nil).enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/survey.pdf", URLMetadata{})
+	if strings.Contains(doc.Text, "Lunar craters") {
+		t.Error("PDF text extracted without -extract-pdf")
+	}
+}
+
+// rawPDF builds a PDF from object bodies, numbering them from 1
+func rawPDF(objects []string, trailer string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj %s endobj\n", i+1, obj)
+	}
+	b.WriteString(trailer)
+	return b.Bytes()
+}
+
+// pdfStream renders a stream object body for rawPDF
+func pdfStream(dict, data string) string {
+	return fmt.Sprintf("<< /Length %d %s >>\nstream\n%s\nendstream", len(data), dict, data)
+}
+
+// TestExtractPDFLimits covers the edges of the built-in extractor: what it
+// handles that a naive scan wouldn't, and the documented unsupported cases.
+func TestExtractPDFLimits(t *testing.T) {
+	var objStm bytes.Buffer
+	zw := zlib.NewWriter(&objStm)
+	zw.Write([]byte("6 0" + strings.Repeat(" ", 200) + "<< /Title (Hidden Title) >>")) // long enough not to be stored raw
+	zw.Close()
+
+	tests := []struct {
+		name      string
+		pdf       []byte
+		wantTitle string
+		wantText  string
+		wantErr   error
+	}{
+		{
+			name:     "text mentioning /Encrypt",
+			pdf:      rawPDF([]string{pdfStream("", "BT (Add /Encrypt to the trailer to lock a file.) Tj ET")}, "trailer << /Root 1 0 R >>\n%%EOF\n"),
+			wantText: "Add /Encrypt to the trailer to lock a file.",
+		},
+		{
+			name: "encrypted with a cross-reference stream",
+			pdf: rawPDF([]string{
+				pdfStream("", "BT (Secret) Tj ET"),
+				pdfStream("/Type /XRef /Size 3 /W [1 2 1] /Encrypt 3 0 R", ""),
+			}, "startxref\n0\n%%EOF\n"),
+			wantErr: errPDFEncrypted,
+		},
+		{
+			name:     "nested decode parameters",
+			pdf:      rawPDF([]string{pdfStream("/DecodeParms << /Columns 1 >>", "BT (Nested dictionaries are read.) Tj ET")}, "trailer << /Root 1 0 R >>\n%%EOF\n"),
+			wantText: "Nested dictionaries are read.",
+		},
+		{
+			name: "unsupported: CID font",
+			pdf: rawPDF([]string{
+				"<< /Type /Font /Subtype /Type0 /BaseFont /NotoSans /Encoding /Identity-H >>",
+				pdfStream("", "BT /F1 12 Tf <00120034> Tj ET"),
+			}, "trailer << /Root 1 0 R >>\n%%EOF\n"),
+			wantErr: errPDFCIDFonts,
+		},
+		{
+			name: "unsupported: title in a compressed object stream",
+			pdf: rawPDF([]string{
+				pdfStream("", "BT (Body text survives.) Tj ET"),
+				fmt.Sprintf("<< /Type /ObjStm /N 1 /First 204 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", objStm.Len(), objStm.Bytes()),
+			}, "trailer << /Root 1 0 R /Info 6 0 R >>\n%%EOF\n"),
+			wantText: "Body text survives.",
+		},
+	}
+	for _, tt := range tests {
+		title, text, err := testSettings(t, nil).extractPDFText(tt.pdf)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if title != tt.wantTitle || text != tt.wantText {
+			t.Errorf("%s: got (%q, %q), want (%q, %q)", tt.name, title, text, tt.wantTitle, tt.wantText)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// DocumentProcessor is one extraction step run on every parsed page, after
+// the title, text and word counts are filled in. Steps see the document as
+// left by the steps before them, so dream-hints belongs after chunks and
+// media, whose output it scores.
+type DocumentProcessor interface {
+	Process(ctx context.Context, doc *Document, page *ParsedPage) error
+}
+
+// DocumentProcessorFunc adapts a function to DocumentProcessor
+type DocumentProcessorFunc func(ctx context.Context, doc *Document, page *ParsedPage) error
+
+func (f DocumentProcessorFunc) Process(ctx context.Context, doc *Document, page *ParsedPage) error {
+	return f(ctx, doc, page)
+}
+
+// ParsedPage is the page processors extract from, with what the crawl
+// knows about it
+type ParsedPage struct {
+	*goquery.Document
+	Metadata URLMetadata
+
+	cfg       *settings
+	chunkFreq *chunkFrequency // nil unless -boilerplate-chunk-pages
+}
+
+// documentProcessors keeps processors in registration order
+var documentProcessors = newRegistry[DocumentProcessor]("document processor")
+
+func init() {
+	RegisterDocumentProcessor("metadata", DocumentProcessorFunc(processMetadata))
+	RegisterDocumentProcessor("chunks", DocumentProcessorFunc(processChunks))
+	RegisterDocumentProcessor("links", DocumentProcessorFunc(processLinks))
+	RegisterDocumentProcessor("media", DocumentProcessorFunc(processMedia))
+	RegisterDocumentProcessor("dream-hints", DocumentProcessorFunc(processDreamHints))
+}
+
+// RegisterDocumentProcessor adds p to the default pipeline. An existing
+// name is replaced in place; a nil p removes it.
+func RegisterDocumentProcessor(name string, p DocumentProcessor) {
+	documentProcessors.register(name, p)
+}
+
+// pipelineStage is a processor and the name it was registered under
+type pipelineStage = registered[DocumentProcessor]
+
+// documentPipeline resolves Config.Processors against the registry, or
+// returns every registered processor when it is nil. New resolves it once
+// per crawl.
+func documentPipeline(processors []string) ([]pipelineStage, error) {
+	var names []string
+	if processors != nil {
+		names = []string{}
+		for _, name := range processors {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return documentProcessors.lookup(names)
+}
+
+// Built-in processors
+
+func processMetadata(_ context.Context, doc *Document, page *ParsedPage) error {
+	page.cfg.extractMetadata(page.Document, &doc.Metadata)
+	doc.Metadata.AnalysisProfile = page.cfg.selectAnalysisProfile(*doc)
+	if doc.Metadata.PublishedAt == nil && page.cfg.analysisProfileFor(*doc).PublishDates {
+		doc.Metadata.PublishedAt = page.cfg.extraPublishedAt(page.Document)
+	}
+	return nil
+}
+
+func processChunks(_ context.Context, doc *Document, page *ParsedPage) error {
+	// Dropped before analysis, and left out of dream hints, so repeated
+	// boilerplate doesn't sway them
+	doc.Chunks, doc.boilerplate = page.chunkFreq.filter(doc.URL, page.cfg.extractContentChunks(page.Document, doc.CleanText))
+	page.cfg.analysisProfileFor(*doc).analyzeChunks(doc.Chunks)
+	return nil
+}
+
+func processLinks(_ context.Context, doc *Document, page *ParsedPage) error {
+	doc.Links = page.cfg.extractLinksWithPriority(page.Document, doc.URL, page.Metadata.depth)
+	doc.Alternates = page.cfg.extractAlternates(page.Document, doc.URL)
+	return nil
+}
+
+func processMedia(_ context.Context, doc *Document, page *ParsedPage) error {
+	doc.Media = page.cfg.extractMediaAssets(page.Document, doc.URL)
+	doc.HeroImage = page.cfg.selectHeroImage(page.Document, doc.URL)
+	return nil
+}
+
+func processDreamHints(_ context.Context, doc *Document, page *ParsedPage) error {
+	doc.DreamHints = page.cfg.generateDreamHints(*doc)
+	return nil
+}
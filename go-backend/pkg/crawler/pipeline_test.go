@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const pipelinePage = `<html><head><title>Night garden</title><meta name="author" content="Ada"></head><body>
+	<h1>The mystical night garden</h1>
+	<p>Moths circle the lanterns while the moon dissolves into a river of glass and dreams.</p>
+	<img src="/moon.jpg" alt="Moon">
+	<a href="/owls">Owls</a>
+</body></html>`
+
+func pipelineServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, pipelinePage)
+	}))
+}
+
+// TestPipelineWithoutDreamHints drops the dream-hints processor and checks
+// DreamHints stays zero while the other steps still fill the document.
+func TestPipelineWithoutDreamHints(t *testing.T) {
+	server := pipelineServer()
+	defer server.Close()
+
+	fetch := func(cfg *settings) Document {
+		doc, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/", URLMetadata{})
+		if err != nil {
+			t.Fatalf("enhancedFetchAndParse: %v", err)
+		}
+		return doc
+	}
+
+	if full := fetch(testSettings(t, nil)); reflect.DeepEqual(full.DreamHints, DreamingHints{}) {
+		t.Fatal("default pipeline left DreamHints empty")
+	}
+
+	doc := fetch(testSettings(t, func(c *Config) { c.Processors = []string{"metadata", "chunks", "links", "media"} }))
+	if !reflect.DeepEqual(doc.DreamHints, DreamingHints{}) {
+		t.Errorf("DreamHints = %+v, want zero without the dream-hints processor", doc.DreamHints)
+	}
+	if doc.Metadata.Author != "Ada" || len(doc.Chunks) == 0 || len(doc.Links) != 1 || len(doc.Media) != 1 {
+		t.Errorf("author %q, %d chunks, %d links, %d media; want every other step applied",
+			doc.Metadata.Author, len(doc.Chunks), len(doc.Links), len(doc.Media))
+	}
+}
+
+// TestPipelineOrder registers a processor and checks -processors runs the
+// named steps in the order given.
+func TestPipelineOrder(t *testing.T) {
+	defer RegisterDocumentProcessor("count-media", nil)
+	server := pipelineServer()
+	defer server.Close()
+
+	var seenMedia []int
+	RegisterDocumentProcessor("count-media", DocumentProcessorFunc(func(_ context.Context, doc *Document, _ *ParsedPage) error {
+		seenMedia = append(seenMedia, len(doc.Media))
+		return nil
+	}))
+
+	cfg := testSettings(t, func(c *Config) { c.Processors = []string{"count-media", " media", " count-media"} })
+	if _, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/", URLMetadata{}); err != nil {
+		t.Fatalf("enhancedFetchAndParse: %v", err)
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(seenMedia, want) {
+		t.Errorf("media seen by count-media = %v, want %v", seenMedia, want)
+	}
+}
+
+// TestPipelineUnknownProcessor checks that a misspelt name is reported.
+func TestPipelineUnknownProcessor(t *testing.T) {
+	if _, err := documentPipeline([]string{"metadata", "dreamhints"}); err == nil || !strings.Contains(err.Error(), "dreamhints") {
+		t.Errorf("documentPipeline error = %v, want one naming dreamhints", err)
+	}
+}
+
+// TestPipelineResolvedByNew checks New resolves Processors once for the
+// crawl, so the registry isn't looked up again per page.
+func TestPipelineResolvedByNew(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seeds = []string{"https://example.com/"}
+	cfg.Processors = []string{"metadata", " links"}
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var names []string
+	for _, stage := range c.cfg.pipeline {
+		names = append(names, stage.name)
+	}
+	if want := []string{"metadata", "links"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("pipeline = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,230 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/analyze"
+)
+
+// Analyzers a profile can switch off
+const (
+	analyzerEntities  = "entities"
+	analyzerSentiment = analyze.Sentiment
+	analyzerEmotions  = analyze.Emotions
+	analyzerThemes    = analyze.Themes
+	analyzerMotifs    = analyze.Motifs
+	analyzerColors    = analyze.Colors
+	analyzerTone      = analyze.Tone
+)
+
+var analyzerNames = map[string]bool{
+	analyzerEntities: true, analyzerSentiment: true, analyzerEmotions: true, analyzerThemes: true,
+	analyzerMotifs: true, analyzerColors: true, analyzerTone: true,
+}
+
+// defaultMaxEntities is how many entities a chunk keeps without a profile
+// override
+const defaultMaxEntities = 5
+
+// analysisProfile tunes the per-page analysis for one kind of site. The
+// zero value runs every analyzer with the default thresholds.
+type analysisProfile struct {
+	// Domains and Categories select the profile: hostnames or *.suffix
+	// patterns, and case-insensitive metadata categories
+	Domains    []string `json:"domains"`
+	Categories []string `json:"categories"`
+
+	Disable     []string `json:"disable"`      // analyzers not run
+	MaxEntities int      `json:"max_entities"` // per chunk, 0 = defaultMaxEntities
+	MotifWords  []string `json:"motif_words"`  // visual motifs looked for besides the built-in ones
+	ColorWords  []string `json:"color_words"`  // colors looked for besides the built-in ones
+	// PublishDates falls back to <time datetime> and schema.org markup
+	// when the page has no published-time meta tag
+	PublishDates bool `json:"publish_dates"`
+
+	disabled map[string]bool
+	domains  hostPatterns
+}
+
+// builtinAnalysisProfiles ship with the crawler; -analysis-profiles may
+// replace or add to them
+var builtinAnalysisProfiles = map[string]analysisProfile{
+	"default": {},
+	"news": {
+		Categories:   []string{"news", "politics", "world", "business"},
+		Disable:      []string{analyzerMotifs, analyzerColors},
+		MaxEntities:  20,
+		PublishDates: true,
+	},
+	"art": {
+		Categories: []string{"art", "arts", "design", "photography"},
+		Disable:    []string{analyzerEntities},
+		MotifWords: []string{"canvas", "brushstroke", "texture", "palette", "portrait", "landscape", "sculpture", "silhouette", "hue", "contrast", "glow", "mist"},
+		ColorWords: []string{"crimson", "scarlet", "azure", "indigo", "violet", "ochre", "amber", "teal", "turquoise", "ivory", "emerald", "cobalt"},
+	},
+	"docs": {
+		Categories: []string{"documentation", "docs", "reference"},
+		Disable:    []string{analyzerSentiment, analyzerEmotions, analyzerMotifs, analyzerColors, analyzerTone},
+	},
+}
+
+func compileAnalysisProfiles(raw map[string]analysisProfile) map[string]*analysisProfile {
+	profiles := make(map[string]*analysisProfile, len(raw))
+	for name, p := range raw {
+		p.disabled = make(map[string]bool)
+		for _, analyzer := range p.Disable {
+			p.disabled[analyzer] = true
+		}
+		p.domains = newHostPatterns(p.Domains)
+		profiles[name] = &p
+	}
+	return profiles
+}
+
+// loadAnalysisProfiles reads a JSON object of profiles keyed by name and
+// merges it over the built-ins
+func loadAnalysisProfiles(path string) (map[string]*analysisProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading analysis profiles: %w", err)
+	}
+	var loaded map[string]analysisProfile
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("parsing analysis profiles %s: %w", path, err)
+	}
+
+	raw := make(map[string]analysisProfile, len(builtinAnalysisProfiles)+len(loaded))
+	for name, p := range builtinAnalysisProfiles {
+		raw[name] = p
+	}
+	for name, p := range loaded {
+		if p.MaxEntities < 0 {
+			return nil, fmt.Errorf("analysis profile %q: max_entities must not be negative", name)
+		}
+		for _, analyzer := range p.Disable {
+			if !analyzerNames[analyzer] {
+				return nil, fmt.Errorf("analysis profile %q: unknown analyzer %q", name, analyzer)
+			}
+		}
+		raw[name] = p
+	}
+	return compileAnalysisProfiles(raw), nil
+}
+
+// validateAnalysisProfile checks that -analysis-profile names a profile
+func (cfg *settings) validateAnalysisProfile() error {
+	if _, ok := cfg.analysisProfiles[cfg.AnalysisProfile]; ok {
+		return nil
+	}
+	var names []string
+	for name := range cfg.analysisProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown analysis profile %q, have %s", cfg.AnalysisProfile, strings.Join(names, ", "))
+}
+
+// selectAnalysisProfile picks the profile for doc: one whose domains match
+// first, then one listing its category, then -analysis-profile. Ties go to
+// the alphabetically first name so the choice is stable.
+func (cfg *settings) selectAnalysisProfile(doc Document) string {
+	var names []string
+	for name := range cfg.analysisProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if cfg.analysisProfiles[name].domains.match(doc.Metadata.Domain) {
+			return name
+		}
+	}
+	if category := strings.TrimSpace(doc.Metadata.Category); category != "" {
+		for _, name := range names {
+			for _, c := range cfg.analysisProfiles[name].Categories {
+				if strings.EqualFold(c, category) {
+					return name
+				}
+			}
+		}
+	}
+	return cfg.AnalysisProfile
+}
+
+// analysisProfileFor returns doc's profile, selecting it when the metadata
+// processor hasn't already
+func (cfg *settings) analysisProfileFor(doc Document) *analysisProfile {
+	name := doc.Metadata.AnalysisProfile
+	if name == "" {
+		name = cfg.selectAnalysisProfile(doc)
+	}
+	if p, ok := cfg.analysisProfiles[name]; ok {
+		return p
+	}
+	return cfg.analysisProfiles["default"]
+}
+
+// enabled reports whether the profile runs analyzer; a nil profile runs
+// everything
+func (p *analysisProfile) enabled(analyzer string) bool {
+	return p == nil || !p.disabled[analyzer]
+}
+
+// analyzeOptions is the profile's dream hint settings; a nil profile runs
+// everything
+func (p *analysisProfile) analyzeOptions() analyze.Options {
+	if p == nil {
+		return analyze.Options{}
+	}
+	return analyze.Options{Disabled: p.disabled, MotifWords: p.MotifWords, ColorWords: p.ColorWords}
+}
+
+func (p *analysisProfile) maxEntities() int {
+	if p == nil || p.MaxEntities == 0 {
+		return defaultMaxEntities
+	}
+	return p.MaxEntities
+}
+
+// analyzeChunks applies the profile to chunks from the extractors, which
+// always use the defaults: disabled analyzers are cleared and entities
+// are re-extracted under a different limit
+func (p *analysisProfile) analyzeChunks(chunks []ContentChunk) {
+	for i := range chunks {
+		chunk := &chunks[i]
+		if !p.enabled(analyzerSentiment) {
+			chunk.Sentiment = ""
+		}
+		switch {
+		case chunk.Entities == nil:
+		case !p.enabled(analyzerEntities):
+			chunk.Entities = nil
+		case p.maxEntities() != defaultMaxEntities:
+			chunk.Entities = extractEntitiesN(chunk.Text, p.maxEntities())
+		}
+	}
+}
+
+// extraPublishedAt looks for a publish date outside the meta tags
+// extractMetadata reads
+func (cfg *settings) extraPublishedAt(page *goquery.Document) *time.Time {
+	var published *time.Time
+	page.Find("[itemprop='datePublished'], time[datetime]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		value, ok := s.Attr("datetime")
+		if !ok {
+			value, _ = s.Attr("content")
+		}
+		if t, ok := cfg.parsePublishedDate(value); ok {
+			published = &t
+			return false
+		}
+		return true
+	})
+	return published
+}
@@ -0,0 +1,110 @@
+package crawler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const profileTestPage = `<html><body><article>
+<p>Maria Lopez painted the canvas for Paris Gallery while Daniel Okafor, Helen Park and Tomas Berg from Lisbon Studio watched the crimson light turn to azure shadow.</p>
+<p>Every brushstroke left a texture of amber glow and ochre mist across the portrait, a landscape Sofia Marin and Pedro Alves called geometric and organic.</p>
+<time datetime="2024-03-05">March 5</time>
+</article></body></html>`
+
+// analyzeWithProfile runs the metadata, chunks and dream-hints processors
+// on profileTestPage with AnalysisProfile set to name.
+func analyzeWithProfile(t *testing.T, name string) Document {
+	t.Helper()
+	cfg := testSettings(t, func(c *Config) { c.AnalysisProfile = name })
+
+	page, err := goquery.NewDocumentFromReader(strings.NewReader(profileTestPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := Document{URL: "https://example.com/piece", Metadata: DocumentMetadata{Domain: "example.com"}}
+	doc.CleanText = strings.TrimSpace(page.Find("article").Text())
+	for _, p := range []DocumentProcessorFunc{processMetadata, processChunks, processDreamHints} {
+		if err := p(context.Background(), &doc, &ParsedPage{Document: page, cfg: cfg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return doc
+}
+
+func entityCount(doc Document) int {
+	n := 0
+	for _, chunk := range doc.Chunks {
+		n += len(chunk.Entities)
+	}
+	return n
+}
+
+// TestAnalysisProfiles checks that on the same page the art profile finds
+// more visual motifs and colors, and the news profile more entities and a
+// publish date.
+func TestAnalysisProfiles(t *testing.T) {
+	art := analyzeWithProfile(t, "art")
+	news := analyzeWithProfile(t, "news")
+	def := analyzeWithProfile(t, "default")
+
+	if art.Metadata.AnalysisProfile != "art" || news.Metadata.AnalysisProfile != "news" {
+		t.Errorf("profiles recorded as %q and %q", art.Metadata.AnalysisProfile, news.Metadata.AnalysisProfile)
+	}
+	if len(art.DreamHints.Motifs) <= len(news.DreamHints.Motifs) || len(art.DreamHints.Motifs) <= len(def.DreamHints.Motifs) {
+		t.Errorf("motifs: art %v, news %v, default %v", art.DreamHints.Motifs, news.DreamHints.Motifs, def.DreamHints.Motifs)
+	}
+	if len(art.DreamHints.ColorPalette) <= len(def.DreamHints.ColorPalette) {
+		t.Errorf("colors: art %v, default %v", art.DreamHints.ColorPalette, def.DreamHints.ColorPalette)
+	}
+	if entityCount(news) <= entityCount(art) || entityCount(news) <= entityCount(def) {
+		t.Errorf("entities: news %d, art %d, default %d", entityCount(news), entityCount(art), entityCount(def))
+	}
+	if news.Metadata.PublishedAt == nil || def.Metadata.PublishedAt != nil {
+		t.Errorf("published: news %v, default %v", news.Metadata.PublishedAt, def.Metadata.PublishedAt)
+	}
+}
+
+// TestSelectAnalysisProfile checks that a domain match beats a category
+// match, which beats AnalysisProfile.
+func TestSelectAnalysisProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	config := `{"forum": {"domains": ["*.forum.example"], "disable": ["entities"]}, "news": {"domains": ["news.example"], "categories": ["News"]}}`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testSettings(t, func(c *Config) { c.AnalysisProfilesFile = path })
+
+	tests := []struct {
+		domain, category, want string
+	}{
+		{"boards.forum.example", "art", "forum"},
+		{"news.example", "", "news"},
+		{"example.com", "NEWS", "news"},
+		{"example.com", "photography", "art"},
+		{"example.com", "", "default"},
+	}
+	for _, tt := range tests {
+		doc := Document{Metadata: DocumentMetadata{Domain: tt.domain, Category: tt.category}}
+		if got := cfg.selectAnalysisProfile(doc); got != tt.want {
+			t.Errorf("selectAnalysisProfile(%s, %q) = %q, want %q", tt.domain, tt.category, got, tt.want)
+		}
+	}
+	if cfg.analysisProfiles["news"].PublishDates {
+		t.Error("a loaded profile should replace the built-in of the same name")
+	}
+}
+
+func TestLoadAnalysisProfilesRejectsUnknownAnalyzer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte(`{"x": {"disable": ["astrology"]}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadAnalysisProfiles(path); err == nil {
+		t.Error("unknown analyzer accepted")
+	}
+}
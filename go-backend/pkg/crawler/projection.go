@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// fieldProjection selects Document fields by their JSON tag name
+type fieldProjection map[string]bool
+
+// documentField is a Document struct field, by index, and whether its JSON
+// tag has omitempty
+type documentField struct {
+	index     int
+	omitEmpty bool
+}
+
+// documentFields maps each Document JSON field name to its struct field
+var documentFields = func() map[string]documentField {
+	fields := make(map[string]documentField)
+	t := reflect.TypeOf(Document{})
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = documentField{index: i, omitEmpty: strings.Contains(","+opts+",", ",omitempty,")}
+		}
+	}
+	return fields
+}()
+
+// parseProjection parses a comma-separated field list, rejecting names that
+// aren't Document JSON fields and lists that name none, such as ",,". An
+// empty spec selects every field.
+func parseProjection(spec string) (fieldProjection, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	projection := make(fieldProjection)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := documentFields[name]; !ok {
+			known := make([]string, 0, len(documentFields))
+			for field := range documentFields {
+				known = append(known, field)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown document field %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		projection[name] = true
+	}
+	if len(projection) == 0 {
+		return nil, fmt.Errorf("%q names no document fields", spec)
+	}
+	return projection, nil
+}
+
+// marshal serializes only the selected fields of doc, leaving out empty
+// omitempty fields as json.Marshal would
+func (p fieldProjection) marshal(doc Document) ([]byte, error) {
+	if p == nil {
+		return json.Marshal(doc)
+	}
+
+	v := reflect.ValueOf(doc)
+	out := make(map[string]interface{}, len(p))
+	for name := range p {
+		field := documentFields[name]
+		value := v.Field(field.index)
+		if field.omitEmpty && isEmptyValue(value) {
+			continue
+		}
+		out[name] = value.Interface()
+	}
+	return json.Marshal(out)
+}
+
+// isEmptyValue is encoding/json's notion of empty for omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
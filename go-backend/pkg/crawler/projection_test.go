@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestFieldProjection verifies that excluded Document fields are left out
+// of the produced JSON while selected ones are kept.
+func TestFieldProjection(t *testing.T) {
+	doc := Document{
+		URL:        "https://example.com/",
+		Text:       "Raw   text with   spacing",
+		CleanText:  "Raw text with spacing",
+		Metadata:   DocumentMetadata{Domain: "example.com"},
+		DreamHints: DreamingHints{Surrealism: 0.7},
+	}
+
+	projection, err := parseProjection("url, clean_text, metadata, dream_hints")
+	if err != nil {
+		t.Fatalf("parseProjection() returned an error: %v", err)
+	}
+	data, err := projection.marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal() returned an error: %v", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("projected JSON is invalid: %v", err)
+	}
+	if _, ok := fields["text"]; ok {
+		t.Error("projected JSON contains the excluded text field")
+	}
+	if _, ok := fields["chunks"]; ok {
+		t.Error("projected JSON contains the excluded chunks field")
+	}
+	if len(fields) != 4 {
+		t.Errorf("projected JSON has %d fields, want 4: %s", len(fields), data)
+	}
+
+	var decoded Document
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("projected JSON does not decode as a Document: %v", err)
+	}
+	if decoded.CleanText != doc.CleanText || decoded.Metadata.Domain != "example.com" || decoded.DreamHints.Surrealism != 0.7 {
+		t.Errorf("projected document lost selected fields: %+v", decoded)
+	}
+
+	// Empty omitempty fields stay out, as in the full document
+	projection, err = parseProjection("url,stable_hash,timings_us")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := projection.marshal(doc); err != nil || string(data) != `{"url":"https://example.com/"}` {
+		t.Errorf("projection of empty omitempty fields = %s, %v", data, err)
+	}
+
+	if _, err := parseProjection("url,body"); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+	if _, err := parseProjection(" , ,"); err == nil {
+		t.Error("expected an error for a spec naming no fields")
+	}
+	if projection, err := parseProjection(""); err != nil || projection != nil {
+		t.Errorf("empty spec = (%v, %v), want all fields", projection, err)
+	}
+}
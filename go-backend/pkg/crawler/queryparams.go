@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+)
+
+const defaultTrackingParams = "utm_*,fbclid,gclid,dclid,msclkid,yclid,igshid,mc_cid,mc_eid,_ga"
+
+// paramMatcher matches query parameter names exactly or, for entries
+// ending in "*", by prefix
+type paramMatcher struct {
+	names    map[string]bool
+	prefixes []string
+}
+
+func parseTrackingParams(spec string) paramMatcher {
+	m := paramMatcher{names: make(map[string]bool)}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if prefix, ok := strings.CutSuffix(name, "*"); ok && prefix != "" {
+			m.prefixes = append(m.prefixes, prefix)
+		} else if name != "" {
+			m.names[name] = true
+		}
+	}
+	return m
+}
+
+func (m paramMatcher) match(name string) bool {
+	name = strings.ToLower(name)
+	if m.names[name] {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepQueryParam decides whether a query param is part of a URL's dedup
+// key. A host with significant_params in -host-config keeps only those,
+// since on some sites ?id= names the page and everything else is noise;
+// other hosts drop the -tracking-params denylist and keep the rest.
+func (cfg *settings) keepQueryParam(host string) func(name string) bool {
+	if override, ok := cfg.hostOverrides.lookup(host); ok && override.SignificantParams != nil {
+		significant := make(map[string]bool, len(override.SignificantParams))
+		for _, name := range override.SignificantParams {
+			significant[name] = true
+		}
+		return func(name string) bool { return significant[name] }
+	}
+	return func(name string) bool { return !cfg.trackingParams.match(name) }
+}
+
+// filterQuery removes the params keep rejects from a raw query, leaving the
+// remaining ones in their original order and encoding
+func filterQuery(rawQuery string, keep func(name string) bool) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var kept []string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if keep(name) {
+			kept = append(kept, pair)
+		}
+	}
+	return strings.Join(kept, "&")
+}
@@ -0,0 +1,43 @@
+package crawler
+
+import "testing"
+
+// TestSignificantQueryParams checks that a host with significant_params
+// keeps only those, so ?id=1 and ?id=2 stay distinct while tracking and
+// other params are dropped, and that other hosts only lose tracking params.
+func TestSignificantQueryParams(t *testing.T) {
+	cfg := testSettings(t, nil)
+	cfg.hostOverrides = &hostConfig{
+		exact:    map[string]hostOverride{"forum.example.com": {SignificantParams: []string{"id"}}},
+		suffixes: map[string]hostOverride{},
+	}
+
+	tests := []struct{ in, want string }{
+		{"https://forum.example.com/thread?id=1", "https://forum.example.com/thread?id=1"},
+		{"https://forum.example.com/thread?id=2&utm_x=y", "https://forum.example.com/thread?id=2"},
+		{"https://forum.example.com/thread?sort=new&id=2", "https://forum.example.com/thread?id=2"},
+		{"https://forum.example.com/thread?utm_x=y", "https://forum.example.com/thread"},
+		{"https://blog.example.com/post?page=2&utm_source=feed&fbclid=abc", "https://blog.example.com/post?page=2"},
+		{"https://blog.example.com/post?UTM_Medium=x&q=a%20b", "https://blog.example.com/post?q=a%20b"},
+		{"https://blog.example.com/post?", "https://blog.example.com/post"},
+	}
+	for _, tt := range tests {
+		if got := cfg.canonicalURL(tt.in); got != tt.want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if cfg.canonicalURL("https://forum.example.com/thread?id=1") == cfg.canonicalURL("https://forum.example.com/thread?id=2") {
+		t.Error("?id=1 and ?id=2 share a dedup key")
+	}
+}
+
+func TestTrackingParams(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.TrackingParams = "ref, session*" })
+	if got, want := cfg.canonicalURL("https://a.example/?ref=x&sessionid=1&utm_source=y"), "https://a.example/?utm_source=y"; got != want {
+		t.Errorf("canonicalURL = %q, want %q", got, want)
+	}
+	cfg = testSettings(t, func(c *Config) { c.TrackingParams = "" })
+	if got, want := cfg.canonicalURL("https://a.example/?utm_source=y"), "https://a.example/?utm_source=y"; got != want {
+		t.Errorf("empty TrackingParams: canonicalURL = %q, want %q", got, want)
+	}
+}
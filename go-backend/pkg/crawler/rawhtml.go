@@ -0,0 +1,10 @@
+package crawler
+
+// captureBody copies body for archiving, or returns "" when it is larger
+// than limit
+func captureBody(body []byte, limit int64) string {
+	if int64(len(body)) > limit {
+		return ""
+	}
+	return string(body)
+}
@@ -0,0 +1,75 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestStoreRawHTML checks the served bytes are kept only with
+// StoreRawHTML, and only up to RawHTMLMaxBytes.
+func TestStoreRawHTML(t *testing.T) {
+	page := "<html><head><title>Archive</title></head>\n<body><p>Keep me &amp; my  spacing.</p></body></html>\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		store    bool
+		maxBytes int64
+		want     string
+	}{
+		{"off", false, 1 << 20, ""},
+		{"on", true, 1 << 20, page},
+		{"exactly at cap", true, int64(len(page)), page},
+		{"over cap", true, int64(len(page)) - 1, ""},
+	}
+	for _, tt := range tests {
+		cfg := testSettings(t, func(c *Config) { c.StoreRawHTML, c.RawHTMLMaxBytes = tt.store, tt.maxBytes })
+		doc, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if doc.RawHTML != tt.want {
+			t.Errorf("%s: RawHTML = %q, want %q", tt.name, doc.RawHTML, tt.want)
+		}
+		// Capturing must not cost the parser any of the body
+		if doc.Title != "Archive" || !strings.Contains(doc.Text, "Keep me") {
+			t.Errorf("%s: parsed title %q, text %q", tt.name, doc.Title, doc.Text)
+		}
+	}
+}
+
+// TestRawHTMLRouting checks kept HTML goes to its own topic, outside the
+// document JSON.
+func TestRawHTMLRouting(t *testing.T) {
+	doc := Document{Status: http.StatusOK, CleanText: "text", RawHTML: "<p>text</p>"}
+	doc.Metadata.ContentType = "text/html"
+
+	cfg := testSettings(t, nil)
+	topics := cfg.routeDocument(doc)
+	if topics[len(topics)-1] != cfg.RawHTMLTopic {
+		t.Fatalf("routeDocument = %v, want it to include %s", topics, cfg.RawHTMLTopic)
+	}
+
+	msg, err := cfg.topicMessage(doc, cfg.RawHTMLTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Value) != doc.RawHTML {
+		t.Errorf("raw HTML message value = %q, want %q", msg.Value, doc.RawHTML)
+	}
+
+	msg, err = cfg.topicMessage(doc, cfg.KafkaTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(msg.Value), "<p>") {
+		t.Errorf("document JSON contains the raw HTML: %s", msg.Value)
+	}
+}
@@ -0,0 +1,44 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxRedirects is how many redirects are followed before giving up,
+// Go's own default
+const maxRedirects = 10
+
+var errTooManyRedirects = fmt.Errorf("stopped after %d redirects", maxRedirects)
+
+// RedirectLoop is returned for a URL whose redirects lead back to a URL
+// already in the chain, itself included
+type RedirectLoop struct {
+	Chain []string // every URL requested, from the first, ending with the repeated one
+}
+
+func (e *RedirectLoop) Error() string {
+	return "redirect loop: " + strings.Join(e.Chain, " -> ")
+}
+
+// checkRedirect is the crawler's redirect policy. It stops as soon as a
+// redirect revisits a URL of the chain, rather than following the cycle
+// until the redirect limit.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	next := req.URL.String()
+	for _, prev := range via {
+		if prev.URL.String() != next {
+			continue
+		}
+		chain := make([]string, 0, len(via)+1)
+		for _, r := range via {
+			chain = append(chain, r.URL.String())
+		}
+		return &RedirectLoop{Chain: append(chain, next)}
+	}
+	if len(via) >= maxRedirects {
+		return errTooManyRedirects
+	}
+	return nil
+}
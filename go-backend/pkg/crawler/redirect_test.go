@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRedirectLoop checks a self-redirect, a two-page cycle and a cycle
+// reached through another page fail as redirect loops on the first repeat,
+// not after the redirect limit, with the whole chain in the error.
+func TestRedirectLoop(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		switch r.URL.Path {
+		case "/self":
+			http.Redirect(w, r, "/self", http.StatusFound)
+		case "/ping":
+			http.Redirect(w, r, "/pong", http.StatusMovedPermanently)
+		case "/pong":
+			http.Redirect(w, r, "/ping", http.StatusMovedPermanently)
+		case "/start":
+			http.Redirect(w, r, "/ping", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+	client := server.Client()
+	client.CheckRedirect = checkRedirect
+
+	for _, tc := range []struct {
+		path  string
+		chain int
+	}{
+		{"/self", 2},
+		{"/ping", 3},
+		{"/start", 4},
+	} {
+		hits = 0
+		_, _, err := testSettings(t, nil).enhancedFetchAndParse(context.Background(), client, server.URL+tc.path, URLMetadata{})
+		var loop *RedirectLoop
+		if !errors.As(err, &loop) {
+			t.Fatalf("%s: got %v, want a RedirectLoop", tc.path, err)
+		}
+		if got := errorCategory(err); got != "redirect_loop" {
+			t.Errorf("%s: category %q, want redirect_loop", tc.path, got)
+		}
+		if len(loop.Chain) != tc.chain || loop.Chain[0] != server.URL+tc.path || !repeats(loop.Chain) {
+			t.Errorf("%s: chain %q, want %d URLs from %s ending with a repeat", tc.path, loop.Chain, tc.chain, tc.path)
+		}
+		if hits != tc.chain-1 {
+			t.Errorf("%s: server hit %d times, want %d", tc.path, hits, tc.chain-1)
+		}
+	}
+}
+
+// repeats reports whether the last URL of chain appears earlier in it
+func repeats(chain []string) bool {
+	for _, u := range chain[:len(chain)-1] {
+		if u == chain[len(chain)-1] {
+			return true
+		}
+	}
+	return false
+}
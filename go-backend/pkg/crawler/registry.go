@@ -0,0 +1,71 @@
+package crawler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// registry keeps named values in registration order, for the document
+// processors and chunk extractors
+type registry[T any] struct {
+	kind string // as named in errors
+
+	mu     sync.RWMutex
+	names  []string
+	values map[string]T
+}
+
+func newRegistry[T any](kind string) *registry[T] {
+	return &registry[T]{kind: kind, values: make(map[string]T)}
+}
+
+// registered is a value and the name it was registered under
+type registered[T any] struct {
+	name  string
+	value T
+}
+
+// register adds v under name. An existing name is replaced in place; a
+// nil v removes it.
+func (r *registry[T]) register(name string, v T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, exists := r.values[name]
+	switch {
+	case any(v) == nil && exists:
+		delete(r.values, name)
+		for i, n := range r.names {
+			if n == name {
+				r.names = append(r.names[:i:i], r.names[i+1:]...)
+				break
+			}
+		}
+	case any(v) != nil:
+		if !exists {
+			r.names = append(r.names, name)
+		}
+		r.values[name] = v
+	}
+}
+
+// lookup returns the values registered under names, in the order given,
+// or every value in registration order when names is nil
+func (r *registry[T]) lookup(names []string) ([]registered[T], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if names == nil {
+		names = r.names
+	}
+	found := make([]registered[T], 0, len(names))
+	for _, name := range names {
+		v, ok := r.values[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown %s %q, have %s", r.kind, name, strings.Join(r.names, ", "))
+		}
+		found = append(found, registered[T]{name: name, value: v})
+	}
+	return found, nil
+}
@@ -0,0 +1,154 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// crawlReport is a point-in-time view of the crawl with derived rates
+type crawlReport struct {
+	StatsSnapshot
+	ElapsedSeconds float64      `json:"elapsed_seconds"`
+	PagesPerSec    float64      `json:"pages_per_sec"`
+	ErrorRate      float64      `json:"error_rate"` // errors per fetch attempt
+	TopHosts       []hostReport `json:"top_hosts"`
+	Final          bool         `json:"final"`
+}
+
+type hostReport struct {
+	Host string `json:"host"`
+	HostStats
+}
+
+// newCrawlReport derives rates from snap and keeps the topN busiest hosts
+// in place of the full per-host map
+func newCrawlReport(snap StatsSnapshot, elapsed time.Duration, topN int, final bool) crawlReport {
+	r := crawlReport{
+		StatsSnapshot:  snap,
+		ElapsedSeconds: elapsed.Seconds(),
+		TopHosts:       []hostReport{},
+		Final:          final,
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		r.PagesPerSec = float64(snap.PagesProcessed) / secs
+	}
+	if attempts := snap.PagesProcessed + snap.Errors; attempts > 0 {
+		r.ErrorRate = float64(snap.Errors) / float64(attempts)
+	}
+
+	for host, hs := range snap.Hosts {
+		r.TopHosts = append(r.TopHosts, hostReport{Host: host, HostStats: hs})
+	}
+	sort.Slice(r.TopHosts, func(i, j int) bool {
+		a, b := r.TopHosts[i], r.TopHosts[j]
+		if a.Pages+a.Errors != b.Pages+b.Errors {
+			return a.Pages+a.Errors > b.Pages+b.Errors
+		}
+		return a.Host < b.Host
+	})
+	if topN >= 0 && len(r.TopHosts) > topN {
+		r.TopHosts = r.TopHosts[:topN]
+	}
+	r.Hosts = nil
+	return r
+}
+
+// textLines renders the report the way the crawler has always logged it
+func (r crawlReport) textLines() []string {
+	var lines []string
+	if r.Final {
+		lines = append(lines, fmt.Sprintf("Crawl complete (%s). Pages processed: %d, Errors: %d, Dreams generated: %d",
+			r.ShutdownReason, r.PagesProcessed, r.Errors, r.DreamsGenerated))
+	} else {
+		lines = append(lines, fmt.Sprintf("Stats: Pages: %d, Errors: %d, Dreams: %d, Avg Size: %.1f bytes",
+			r.PagesProcessed, r.Errors, r.DreamsGenerated, r.AveragePageSize))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Skipped: depth: %d, robots: %d, scope: %d, seen: %d, queue full: %d, breaker: %d, irrelevant: %d, host limit: %d, unmodified: %d",
+			r.SkippedDepth, r.SkippedRobots, r.SkippedScope, r.SkippedSeen, r.SkippedQueueFull, r.SkippedBreaker,
+			r.SkippedIrrelevant, r.SkippedHostLimit, r.SkippedUnmodified),
+		fmt.Sprintf("Retries: %d, Rate: %.2f pages/sec, Error rate: %.1f%%, Hosts: %d, Workers: %d",
+			r.Retries, r.PagesPerSec, r.ErrorRate*100, r.DistinctHosts, r.Workers))
+	if len(r.ErrorCategories) > 0 {
+		categories := make([]string, 0, len(r.ErrorCategories))
+		for category, n := range r.ErrorCategories {
+			categories = append(categories, fmt.Sprintf("%s: %d", category, n))
+		}
+		sort.Strings(categories)
+		lines = append(lines, "Errors by category: "+strings.Join(categories, ", "))
+	}
+	for _, h := range r.TopHosts {
+		line := fmt.Sprintf("  %s: %d pages, %d errors", h.Host, h.Pages, h.Errors)
+		if h.Breaker != "" {
+			line += ", breaker " + h.Breaker
+		}
+		lines = append(lines, line)
+	}
+	if r.Final {
+		lines = append(lines, contentLines(r.Content)...)
+	}
+	return lines
+}
+
+// writeReport writes r to w as text lines or a single JSON line
+func writeReport(w io.Writer, r crawlReport, format string) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(r)
+	}
+	_, err := io.WriteString(w, strings.Join(r.textLines(), "\n")+"\n")
+	return err
+}
+
+// emitReport publishes r in format: text goes to the log, JSON to stdout
+// so it can be piped straight into a parser
+func emitReport(r crawlReport, format string) {
+	if format == "json" {
+		if err := writeReport(os.Stdout, r, "json"); err != nil {
+			log.Printf("Writing stats report: %v", err)
+		}
+		return
+	}
+	for _, line := range r.textLines() {
+		log.Println(line)
+	}
+}
+
+// writeReportFile writes the final report to path in format
+func writeReportFile(path string, r crawlReport, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeReport(f, r, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// validStatsFormat reports whether format is one -stats-format accepts
+func validStatsFormat(format string) bool {
+	return format == "text" || format == "json"
+}
+
+// statsReporter emits a report every StatsInterval until ctx is done
+func (cfg *settings) statsReporter(ctx context.Context, stats *CrawlerStats, started time.Time) {
+	ticker := time.NewTicker(cfg.StatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			emitReport(newCrawlReport(stats.Snapshot(), now.Sub(started), cfg.ReportTopHosts, false), cfg.StatsFormat)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestJSONReportAfterCrawl runs a short crawl and checks that its JSON report
+// parses and carries the aggregate counters and derived rates.
+func TestJSONReportAfterCrawl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><p>Home</p><a href="/a">A</a><a href="http://127.0.0.1:1/down">Down</a></body></html>`)
+		case "/a":
+			fmt.Fprint(w, `<html><body><p>Page A</p></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	cfg := DefaultConfig()
+	cfg.Seeds = []string{server.URL + "/"}
+	cfg.Workers = 2
+	cfg.QueueSize = 10
+	cfg.Client = server.Client()
+	crawler, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	started := time.Now()
+	go func() {
+		defer close(done)
+		crawler.Run(ctx)
+	}()
+	waitFor(t, "two pages and an error", func() bool {
+		stats := crawler.Stats()
+		return stats.PagesProcessed == 2 && stats.Errors == 1
+	})
+	cancel()
+	<-done
+
+	var buf bytes.Buffer
+	report := newCrawlReport(crawler.Stats(), time.Since(started), 10, true)
+	if err := writeReport(&buf, report, "json"); err != nil {
+		t.Fatalf("writeReport: %v", err)
+	}
+
+	var got crawlReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("report is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if got.PagesProcessed != 2 || got.Errors != 1 {
+		t.Errorf("pages/errors = %d/%d, want 2/1", got.PagesProcessed, got.Errors)
+	}
+	if want := 1.0 / 3; got.ErrorRate < want-1e-9 || got.ErrorRate > want+1e-9 {
+		t.Errorf("error_rate = %v, want %v", got.ErrorRate, want)
+	}
+	if got.PagesPerSec <= 0 {
+		t.Errorf("pages_per_sec = %v, want > 0", got.PagesPerSec)
+	}
+	if !got.Final || got.ShutdownReason != ShutdownCancelled {
+		t.Errorf("final/shutdown_reason = %v/%q, want true/%q", got.Final, got.ShutdownReason, ShutdownCancelled)
+	}
+	if len(got.TopHosts) == 0 || got.TopHosts[0].Host != serverURL.Host || got.TopHosts[0].Pages != 2 {
+		t.Errorf("top_hosts = %+v, want %s with 2 pages first", got.TopHosts, serverURL.Host)
+	}
+	if got.Hosts != nil {
+		t.Errorf("report includes the full hosts map, want only top_hosts")
+	}
+}
+
+// TestNewCrawlReportTopHosts checks host ordering and the top-N cut.
+func TestNewCrawlReportTopHosts(t *testing.T) {
+	snap := StatsSnapshot{Hosts: map[string]HostStats{
+		"a.example": {Pages: 1},
+		"b.example": {Pages: 5, Errors: 1},
+		"c.example": {Pages: 3},
+	}}
+	r := newCrawlReport(snap, time.Second, 2, false)
+
+	var hosts []string
+	for _, h := range r.TopHosts {
+		hosts = append(hosts, h.Host)
+	}
+	if got := strings.Join(hosts, ","); got != "b.example,c.example" {
+		t.Errorf("top hosts = %s, want b.example,c.example", got)
+	}
+}
+
+// TestWriteReportFile checks that the final report lands in the report
+// file in the given format.
+func TestWriteReportFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	report := newCrawlReport(StatsSnapshot{PagesProcessed: 4, ShutdownReason: ShutdownPages}, 2*time.Second, 10, true)
+
+	if err := writeReportFile(path, report, "text"); err != nil {
+		t.Fatalf("writeReportFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "Crawl complete (pages). Pages processed: 4") {
+		t.Errorf("text report = %q, want it to start with the completion line", data)
+	}
+	if !strings.Contains(string(data), "Rate: 2.00 pages/sec") {
+		t.Errorf("text report = %q, want the page rate", data)
+	}
+}
@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetryDelay caps both backoff and server-supplied Retry-After values
+const maxRetryDelay = 5 * time.Minute
+
+// scheduleRetry re-offers u to frontier once delay has passed, from a timer
+// goroutine so the calling worker can move on to other hosts. It returns
+// false when u has already used up MaxRetries. The URL counts as pending
+// while it waits.
+func (cfg *settings) scheduleRetry(ctx context.Context, frontier chan<- URLWithMetadata, u URLWithMetadata, delay time.Duration, pending *pendingURLs) bool {
+	if u.Metadata.retries >= cfg.MaxRetries {
+		return false
+	}
+	u.Metadata.retries++
+	pending.queued(u)
+
+	time.AfterFunc(delay, func() {
+		select {
+		case frontier <- u:
+		case <-ctx.Done():
+		}
+	})
+	return true
+}
+
+// retryDelay picks how long to wait before retrying a 429: the server's
+// Retry-After when it gives one, otherwise exponential backoff from
+// RetryBaseDelay
+func (cfg *settings) retryDelay(attempt int, retryAfter string) time.Duration {
+	delay := cfg.RetryBaseDelay << attempt
+	if retryAfter = strings.TrimSpace(retryAfter); retryAfter != "" {
+		if secs, err := strconv.Atoi(retryAfter); err == nil && secs >= 0 {
+			delay = time.Duration(secs) * time.Second
+		} else if at, err := http.ParseTime(retryAfter); err == nil {
+			delay = time.Until(at)
+			if delay < 0 {
+				return 0
+			}
+		}
+	}
+	if delay < 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// reserveOrDefer takes a token from hp's limiter, waiting for it when that
+// takes no longer than maxWait. When the wait would be longer the
+// reservation is handed back and the required delay returned, so the
+// caller can retry the URL later instead. Under -rate-jitter the wait is
+// moved by jitteredDelay, so the request goes at its jittered time with
+// the token it was given rather than waiting again after taking it.
+func reserveOrDefer(ctx context.Context, hp *hostPolicies, maxWait time.Duration, jitter float64) (time.Duration, error) {
+	r := hp.lim.Reserve()
+	delay := r.Delay()
+	if delay > maxWait {
+		r.Cancel()
+		return delay, nil
+	}
+	if delay = hp.jitteredDelay(delay, jitter); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			r.Cancel()
+			return 0, ctx.Err()
+		}
+	}
+	if jitter > 0 {
+		hp.jitterSent()
+	}
+	return 0, nil
+}
@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// TestWorkerRetriesAfter429 checks that a 429 sends the URL back through the
+// frontier after the backoff delay and that the retry is fetched normally.
+func TestWorkerRetriesAfter429(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.RetryBaseDelay = 100 * time.Millisecond })
+
+	var mu sync.Mutex
+	var hits []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits = append(hits, time.Now())
+		first := len(hits) == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><p>Worth the wait.</p></body></html>`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+	}
+	urlQueue := make(chan URLWithMetadata, 1)
+	out := make(chan Document, 1)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+
+	c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+
+	select {
+	case doc := <-out:
+		if doc.Status != http.StatusOK {
+			t.Errorf("Status = %d, want %d", doc.Status, http.StatusOK)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("retried URL was never emitted")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hits) != 2 {
+		t.Fatalf("server hit %d times, want 2", len(hits))
+	}
+	if gap := hits[1].Sub(hits[0]); gap < cfg.RetryBaseDelay {
+		t.Errorf("retry came %v after the 429, want at least %v", gap, cfg.RetryBaseDelay)
+	}
+
+	snap := stats.Snapshot()
+	if snap.Retries != 1 {
+		t.Errorf("Retries = %d, want 1", snap.Retries)
+	}
+	if snap.Errors != 0 {
+		t.Errorf("Errors = %d, want 0", snap.Errors)
+	}
+}
+
+// TestScheduleRetryBound checks that a URL is not re-queued past -max-retries.
+func TestScheduleRetryBound(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.MaxRetries = 2 })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frontier := make(chan URLWithMetadata, 4)
+
+	u := URLWithMetadata{URL: "https://example.com/"}
+	for attempt := 1; attempt <= 2; attempt++ {
+		if !cfg.scheduleRetry(ctx, frontier, u, 0, nil) {
+			t.Fatalf("attempt %d: scheduleRetry = false, want true", attempt)
+		}
+		u = <-frontier
+		if u.Metadata.retries != attempt {
+			t.Errorf("retries = %d, want %d", u.Metadata.retries, attempt)
+		}
+	}
+	if cfg.scheduleRetry(ctx, frontier, u, 0, nil) {
+		t.Error("scheduleRetry past -max-retries = true, want false")
+	}
+}
+
+// TestRetryDelay covers backoff and both Retry-After forms.
+func TestRetryDelay(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.RetryBaseDelay = time.Second })
+	tests := []struct {
+		attempt    int
+		retryAfter string
+		want       time.Duration
+	}{
+		{0, "", time.Second},
+		{2, "", 4 * time.Second},
+		{2, "7", 7 * time.Second},
+		{0, "86400", maxRetryDelay},
+		{0, "Mon, 02 Jan 2006 15:04:05 GMT", 0}, // already past
+		{1, "soon", 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := cfg.retryDelay(tt.attempt, tt.retryAfter); got != tt.want {
+			t.Errorf("retryDelay(%d, %q) = %v, want %v", tt.attempt, tt.retryAfter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"log"
+	"net"
+	"sort"
+	"strings"
+)
+
+// hostPatterns is a set of lowercase hostnames and "*.suffix" wildcards,
+// matched like -host-config keys
+type hostPatterns map[string]bool
+
+func newHostPatterns(patterns []string) hostPatterns {
+	p := hostPatterns{}
+	for _, pattern := range patterns {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			p[pattern] = true
+		}
+	}
+	return p
+}
+
+func (p hostPatterns) String() string {
+	var patterns []string
+	for pattern := range p {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return strings.Join(patterns, ",")
+}
+
+// match reports whether host, with or without a port, is listed exactly or
+// falls under a wildcard
+func (p hostPatterns) match(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if p[host] {
+		return true
+	}
+	for rest := host; ; {
+		i := strings.IndexByte(rest, '.')
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+1:]
+		if p["*."+rest] {
+			return true
+		}
+	}
+}
+
+// robotsIgnored reports whether host's robots.txt Disallow rules are
+// bypassed. Its Crawl-delay and the rate limiter still apply.
+func (cfg *settings) robotsIgnored(host string) bool {
+	return cfg.IgnoreRobots || cfg.robotsOverrideHosts.match(host)
+}
+
+// warnRobotsOverride logs loudly when robots.txt enforcement is weakened
+func (cfg *settings) warnRobotsOverride() {
+	if cfg.IgnoreRobots {
+		log.Println("WARNING: -ignore-robots is set, robots.txt Disallow rules will NOT be obeyed on any host")
+	} else if len(cfg.robotsOverrideHosts) > 0 {
+		log.Printf("WARNING: robots.txt Disallow rules will NOT be obeyed on %s", cfg.robotsOverrideHosts)
+	}
+}
@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/temoto/robotstxt"
+	"golang.org/x/time/rate"
+)
+
+// TestRobotsOverrideHosts crawls a disallowed path and checks it is skipped
+// normally but fetched once its host is in -robots-override-hosts.
+func TestRobotsOverrideHosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><p>Staff only</p></body></html>`)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	robots, err := robotstxt.FromString("User-agent: *\nDisallow: /private\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, override := range []bool{false, true} {
+		t.Run(fmt.Sprintf("override=%v", override), func(t *testing.T) {
+			cfg := testSettings(t, func(c *Config) {
+				if override {
+					c.RobotsOverrideHosts = []string{"localhost", serverURL.Hostname()}
+				}
+			})
+			hp := cfg.newHostPolicies(serverURL.Host)
+			hp.robots = robots
+			hp.robotsPending.Store(false)
+			hp.lim.SetLimit(rate.Inf)
+			hostMap := map[string]*hostPolicies{serverURL.Host: hp}
+			queue := make(chan URLWithMetadata, 10)
+			out := make(chan Document, 10)
+			stats := &CrawlerStats{}
+			seen := mapSeen{}
+
+			c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go c.enhancedWorker(ctx, 0, queue, queue, out)
+
+			queue <- URLWithMetadata{URL: server.URL + "/private"}
+			waitFor(t, "the URL to be handled", func() bool { return stats.progress() > 0 })
+
+			snap := stats.Snapshot()
+			if override && (snap.PagesProcessed != 1 || snap.SkippedRobots != 0) {
+				t.Errorf("with override: pages=%d skipped_robots=%d, want 1 and 0", snap.PagesProcessed, snap.SkippedRobots)
+			}
+			if !override && (snap.PagesProcessed != 0 || snap.SkippedRobots != 1) {
+				t.Errorf("without override: pages=%d skipped_robots=%d, want 0 and 1", snap.PagesProcessed, snap.SkippedRobots)
+			}
+		})
+	}
+}
+
+// TestHostPatternsMatch checks exact, port-qualified and wildcard matches.
+func TestHostPatternsMatch(t *testing.T) {
+	p := newHostPatterns([]string{"Docs.Example.com", " *.internal.example"})
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"docs.example.com", true},
+		{"docs.example.com:8443", true},
+		{"www.example.com", false},
+		{"wiki.internal.example", true},
+		{"a.b.internal.example", true},
+		{"internal.example", false},
+	}
+	for _, tt := range tests {
+		if got := p.match(tt.host); got != tt.want {
+			t.Errorf("match(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
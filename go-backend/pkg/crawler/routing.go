@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// prefixedTopic applies TopicPrefix to topic
+func (cfg *settings) prefixedTopic(topic string) string {
+	return model.PrefixedTopic(cfg.TopicPrefix, topic)
+}
+
+// quarantineReason reports why doc fails validation, or "" when it is fit
+// for the main topics
+func quarantineReason(doc Document) string {
+	switch {
+	case doc.Status != http.StatusOK:
+		return fmt.Sprintf("status %d", doc.Status)
+	case doc.Metadata.Soft404:
+		return "soft 404"
+	case strings.TrimSpace(doc.CleanText) == "":
+		return "empty clean text"
+	}
+	return ""
+}
+
+// routeDocument decides which topics doc is published to. Invalid documents
+// go only to QuarantineTopic when one is set; everything else goes to the
+// raw topic, to the dream topic above DreamThreshold, and to RawHTMLTopic
+// when the page's HTML was kept.
+func (cfg *settings) routeDocument(doc Document) []string {
+	if cfg.QuarantineTopic != "" && quarantineReason(doc) != "" {
+		return []string{cfg.QuarantineTopic}
+	}
+	topics := []string{cfg.KafkaTopic}
+	if doc.DreamHints.Surrealism > cfg.DreamThreshold {
+		topics = append(topics, cfg.DreamTopic)
+	}
+	if doc.RawHTML != "" && cfg.RawHTMLTopic != "" {
+		topics = append(topics, cfg.RawHTMLTopic)
+	}
+	return topics
+}
+
+// topicMessage builds the Kafka message for doc on one of its routed topics
+func (cfg *settings) topicMessage(doc Document, topic string) (*kafka.Message, error) {
+	surrealism := []byte(fmt.Sprintf("%.2f", doc.DreamHints.Surrealism))
+
+	var value []byte
+	var headers []kafka.Header
+	var err error
+	produced := cfg.prefixedTopic(topic)
+	switch topic {
+	case cfg.DreamTopic:
+		value, err = cfg.dreamProjection.marshal(doc)
+		headers = []kafka.Header{
+			{Key: "dream_ready", Value: []byte("true")},
+			{Key: "surrealism_score", Value: surrealism},
+		}
+	case cfg.RawHTMLTopic:
+		value = []byte(doc.RawHTML)
+		headers = []kafka.Header{
+			{Key: "content_type", Value: []byte(doc.Metadata.ContentType)},
+		}
+	case cfg.QuarantineTopic:
+		value, err = cfg.serializeDocument(cfg.rawProjection, produced, doc)
+		headers = []kafka.Header{
+			{Key: "content_type", Value: []byte(cfg.documentContentType())},
+			{Key: "quarantine_reason", Value: []byte(quarantineReason(doc))},
+		}
+	default:
+		value, err = cfg.serializeDocument(cfg.rawProjection, produced, doc)
+		headers = []kafka.Header{
+			{Key: "content_type", Value: []byte(cfg.documentContentType())},
+			{Key: "crawler_version", Value: []byte("dream-crawler-v1.0")},
+			{Key: "surrealism_score", Value: surrealism},
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &produced, Partition: kafka.PartitionAny},
+		Value:          value,
+		Key:            []byte(doc.ID),
+		Headers:        headers,
+	}, nil
+}
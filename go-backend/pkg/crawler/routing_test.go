@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// TestRouteDocument checks topic selection for dream-worthy, ordinary and
+// invalid documents.
+func TestRouteDocument(t *testing.T) {
+	raw, dream := DefaultConfig().KafkaTopic, DefaultConfig().DreamTopic
+	valid := func(surrealism float64) Document {
+		doc := Document{Status: http.StatusOK, CleanText: "Clocks melt over the branches."}
+		doc.DreamHints.Surrealism = surrealism
+		return doc
+	}
+	soft404 := valid(0.9)
+	soft404.Metadata.Soft404 = true
+	empty := valid(0.9)
+	empty.CleanText = "  "
+	notFound := valid(0.9)
+	notFound.Status = http.StatusNotFound
+
+	tests := []struct {
+		name       string
+		doc        Document
+		quarantine string
+		want       []string
+	}{
+		{"high surrealism", valid(0.8), "crawl.quarantine", []string{raw, dream}},
+		{"low surrealism", valid(0.2), "crawl.quarantine", []string{raw}},
+		{"at threshold", valid(0.5), "crawl.quarantine", []string{raw}},
+		{"soft 404", soft404, "crawl.quarantine", []string{"crawl.quarantine"}},
+		{"empty text", empty, "crawl.quarantine", []string{"crawl.quarantine"}},
+		{"non-200", notFound, "crawl.quarantine", []string{"crawl.quarantine"}},
+		{"invalid without quarantine topic", notFound, "", []string{raw, dream}},
+	}
+	for _, tt := range tests {
+		cfg := testSettings(t, func(c *Config) {
+			c.DreamThreshold = 0.5
+			c.QuarantineTopic = tt.quarantine
+		})
+		if got := cfg.routeDocument(tt.doc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: routeDocument = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestTopicMessageQuarantineReason checks that quarantined messages say why.
+func TestTopicMessageQuarantineReason(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.QuarantineTopic = "crawl.quarantine" })
+	msg, err := cfg.topicMessage(Document{URL: "https://example.com/gone", Status: http.StatusGone}, cfg.QuarantineTopic)
+	if err != nil {
+		t.Fatalf("topicMessage: %v", err)
+	}
+	if got := *msg.TopicPartition.Topic; got != "crawl.quarantine" {
+		t.Errorf("topic = %q, want %q", got, "crawl.quarantine")
+	}
+	var reason string
+	for _, h := range msg.Headers {
+		if h.Key == "quarantine_reason" {
+			reason = string(h.Value)
+		}
+	}
+	if reason != "status 410" {
+		t.Errorf("quarantine_reason = %q, want %q", reason, "status 410")
+	}
+}
+
+// TestTopicMessagePrefix checks that TopicPrefix is applied to every
+// routed topic while routing itself still works on the bare names.
+func TestTopicMessagePrefix(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) {
+		c.TopicPrefix = "acme"
+		c.DreamThreshold = 0.5
+	})
+
+	doc := Document{URL: "https://example.com/", Status: http.StatusOK, CleanText: "Clocks melt over the branches."}
+	doc.DreamHints.Surrealism = 0.9
+	var got []string
+	for _, topic := range cfg.routeDocument(doc) {
+		msg, err := cfg.topicMessage(doc, topic)
+		if err != nil {
+			t.Fatalf("topicMessage(%q): %v", topic, err)
+		}
+		got = append(got, *msg.TopicPartition.Topic)
+	}
+	want := []string{"acme." + cfg.KafkaTopic, "acme." + cfg.DreamTopic}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("topics = %v, want %v", got, want)
+	}
+}
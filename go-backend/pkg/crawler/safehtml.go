@@ -0,0 +1,98 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const defaultSafeHTMLTags = "a,b,strong,i,em,u,s,mark,small,sub,sup,code,pre,br,p,ul,ol,li,blockquote,q"
+
+// unsafeElements are dropped with their contents whatever the policy,
+// since their text isn't page content
+var unsafeElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Object: true, atom.Embed: true,
+	atom.Template: true, atom.Noscript: true, atom.Svg: true, atom.Math: true, atom.Form: true,
+}
+
+func parseSafeHTMLTags(spec string) map[string]bool {
+	tags := make(map[string]bool)
+	for _, tag := range strings.Split(spec, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags[tag] = true
+		}
+	}
+	return tags
+}
+
+// safeHTML is the sanitized markup inside s, keeping the allowed tags
+func safeHTML(s *goquery.Selection, allowed map[string]bool) string {
+	var b strings.Builder
+	for _, n := range s.Nodes {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			sanitizeNode(&b, c, allowed)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// sanitizeNode writes n keeping only the allowed tags, without any of
+// their attributes except a link's href. Disallowed tags are unwrapped;
+// scripts and the like are dropped whole.
+func sanitizeNode(b *strings.Builder, n *nethtml.Node, allowed map[string]bool) {
+	switch n.Type {
+	case nethtml.TextNode:
+		b.WriteString(nethtml.EscapeString(n.Data))
+		return
+	case nethtml.ElementNode:
+	default:
+		return // comments, doctypes
+	}
+	if unsafeElements[n.DataAtom] {
+		return
+	}
+
+	keep := allowed[n.Data]
+	if keep {
+		b.WriteString("<" + n.Data)
+		if n.DataAtom == atom.A {
+			if href, ok := safeHref(n); ok {
+				b.WriteString(` href="` + nethtml.EscapeString(href) + `" rel="nofollow noopener"`)
+			}
+		}
+		b.WriteString(">")
+		if n.DataAtom == atom.Br {
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c, allowed)
+	}
+	if keep {
+		b.WriteString("</" + n.Data + ">")
+	}
+}
+
+// safeHref returns a link's href when it is relative or uses a scheme
+// that can't run script
+func safeHref(n *nethtml.Node) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key != "href" {
+			continue
+		}
+		href := strings.TrimSpace(a.Val)
+		u, err := url.Parse(href)
+		if err != nil {
+			return "", false
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "", "http", "https", "mailto":
+			return href, true
+		}
+		return "", false
+	}
+	return "", false
+}
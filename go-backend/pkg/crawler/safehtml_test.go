@@ -0,0 +1,55 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TestSafeHTML checks a chunk's script and event handlers are removed
+// while bold text and a plain link survive, and that nothing is emitted
+// without EmitSafeHTML.
+func TestSafeHTML(t *testing.T) {
+	page := `<html><body><p onclick="steal()">Dreams are <b>vivid</b> at dawn<script>alert("x")</script>,
+		says <a href="https://example.com/lab" onmouseover="steal()">the lab</a>; <a href="javascript:steal()">more</a>
+		<span style="color:red">here</span>.</p></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if chunks := testSettings(t, nil).extractContentChunks(doc, ""); len(chunks) != 1 || chunks[0].SafeHTML != "" {
+		t.Fatalf("without EmitSafeHTML: chunks = %+v", chunks)
+	}
+
+	chunks := testSettings(t, func(c *Config) { c.EmitSafeHTML = true }).extractContentChunks(doc, "")
+	if len(chunks) != 1 {
+		t.Fatalf("got %d chunks, want 1", len(chunks))
+	}
+	got := chunks[0].SafeHTML
+	for _, banned := range []string{"<script", "alert", "onclick", "onmouseover", "javascript:", "style=", "<span"} {
+		if strings.Contains(got, banned) {
+			t.Errorf("safe HTML contains %q: %s", banned, got)
+		}
+	}
+	for _, kept := range []string{"Dreams are <b>vivid</b> at dawn", `<a href="https://example.com/lab" rel="nofollow noopener">the lab</a>`, "<a>more</a>", "here"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("safe HTML is missing %q: %s", kept, got)
+		}
+	}
+}
+
+func TestSanitizeNodeTagPolicy(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div><b>bold</b> <i>italic</i> &lt;tag&gt;</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	for c := doc.Find("div").Nodes[0].FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(&b, c, parseSafeHTMLTags(" B "))
+	}
+	if want := "<b>bold</b> italic &lt;tag&gt;"; b.String() != want {
+		t.Errorf("sanitized = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	nethtml "golang.org/x/net/html"
+)
+
+// Why a page's text was salvaged, for DocumentMetadata.Degraded
+const (
+	degradedParseError   = "parse_error"         // the parser failed outright
+	degradedUnterminated = "unterminated_markup" // an unclosed comment, script or title swallowed the page
+)
+
+var (
+	salvageBlocks = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>|<!--.*?-->`)
+	salvageTags   = regexp.MustCompile(`(?s)<[^>]*>|<!--`)
+	// closingMarkup in a raw text node means it ran on to the end of the
+	// page; scripts writing markup of their own rarely close the body
+	closingMarkup = regexp.MustCompile(`(?i)</(body|html)\s*>`)
+)
+
+// parseHTML parses page, turning a panic in the parser into an error so a
+// pathological page costs its markup rather than the worker
+func parseHTML(page []byte) (doc *goquery.Document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("html parser panicked: %v", r)
+		}
+	}()
+	return goquery.NewDocumentFromReader(bytes.NewReader(page))
+}
+
+// emptyDocument stands in for a page the parser couldn't handle, so the
+// processors still run over the salvaged text
+func emptyDocument() *goquery.Document {
+	return goquery.NewDocumentFromNode(&nethtml.Node{Type: nethtml.DocumentNode})
+}
+
+// swallowedMarkup reports whether an unterminated comment or raw text
+// element (script, style, title, textarea) took in the page's markup, as
+// happens with a stray "<!--" on legacy sites. It must run before
+// extractText removes scripts.
+func swallowedMarkup(doc *goquery.Document) bool {
+	swallowed := false
+	doc.Find("script, style, title, textarea").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		swallowed = closingMarkup.MatchString(s.Text())
+		return !swallowed
+	})
+	if swallowed {
+		return true
+	}
+	var walk func(n *nethtml.Node)
+	walk = func(n *nethtml.Node) {
+		for c := n.FirstChild; c != nil && !swallowed; c = c.NextSibling {
+			if c.Type == nethtml.CommentNode {
+				swallowed = closingMarkup.MatchString(c.Data)
+			} else {
+				walk(c)
+			}
+		}
+	}
+	for _, n := range doc.Nodes {
+		walk(n)
+	}
+	return swallowed
+}
+
+// salvageText strips markup from page with regular expressions, for when
+// the parse tree can't be trusted. Closed scripts, styles and comments are
+// dropped; an unclosed one's contents are kept, since that is where the
+// page's text ended up.
+func salvageText(page []byte) string {
+	text := salvageBlocks.ReplaceAllString(string(page), " ")
+	text = salvageTags.ReplaceAllString(text, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// recoverText decides a page's text: parsed is what extractText found,
+// and the page is salvaged instead when parsing failed or left nothing
+// because markup was swallowed. The second result is the Degraded reason.
+func recoverText(parsed string, page []byte, parseErr error, swallowed bool) (string, string) {
+	switch {
+	case parseErr != nil:
+		return salvageText(page), degradedParseError
+	case swallowed && strings.TrimSpace(parsed) == "":
+		if salvaged := salvageText(page); salvaged != "" {
+			return salvaged, degradedUnterminated
+		}
+	}
+	return parsed, ""
+}
@@ -0,0 +1,70 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestSalvageBrokenHTML fetches pages whose markup a stray "<!--" or an
+// unclosed <script> swallows whole, and checks their text is still
+// extracted and the documents flagged degraded.
+func TestSalvageBrokenHTML(t *testing.T) {
+	pages := map[string]string{
+		"/comment": `<html><head><title>Lighthouse log</title></head><body>
+			<!-- old navigation removed <div class="nav">Home</div>
+			<article><h1>Winter entries</h1>
+			<p>The keeper saw whales beneath the light &amp; wrote it down.</p></article>
+			</body></html>`,
+		"/script": `<html><head><title>Lighthouse log</title>
+			<script>var tracker = "legacy"
+			</head><body><p>The keeper saw whales beneath the light &amp; wrote it down.</p></body></html>`,
+		"/fine": `<html><body><nav><a href="/">Home</a></nav>
+			<script>document.write("<p>hi</p>")</script></body></html>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/comment", "/script"} {
+		doc, _, err := testSettings(t, nil).enhancedFetchAndParse(context.Background(), server.Client(), server.URL+path, URLMetadata{})
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if doc.Metadata.Degraded != degradedUnterminated {
+			t.Errorf("%s: degraded = %q, want %q", path, doc.Metadata.Degraded, degradedUnterminated)
+		}
+		if !strings.Contains(doc.Text, "The keeper saw whales beneath the light & wrote it down.") {
+			t.Errorf("%s: text = %q, want the paragraph salvaged", path, doc.Text)
+		}
+		if doc.Metadata.WordCount == 0 || doc.ContentHash == "" {
+			t.Errorf("%s: salvaged text not counted or hashed: %+v", path, doc.Metadata)
+		}
+	}
+
+	// A page with no article text but nothing swallowed is left alone
+	doc, _, err := testSettings(t, nil).enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/fine", URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata.Degraded != "" {
+		t.Errorf("well-formed page flagged degraded: %q", doc.Metadata.Degraded)
+	}
+}
+
+func TestRecoverTextParseError(t *testing.T) {
+	page := []byte(`<p>Still <b>readable</b></p><style>p { color: red }</style>`)
+	text, degraded := recoverText("", page, errors.New("boom"), false)
+	if text != "Still readable" || degraded != degradedParseError {
+		t.Errorf("recoverText = %q, %q", text, degraded)
+	}
+	if text, degraded := recoverText("Parsed text", page, nil, true); text != "Parsed text" || degraded != "" {
+		t.Errorf("page with parsed text was salvaged: %q, %q", text, degraded)
+	}
+}
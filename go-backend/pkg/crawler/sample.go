@@ -0,0 +1,21 @@
+package crawler
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+)
+
+// sampled reports whether the document at rawurl falls inside rate. The
+// choice hashes the canonical URL, so it is the same on every run.
+func (cfg *settings) sampled(rawurl string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	// FNV clusters on URLs differing only near the end; SHA-256 doesn't
+	sum := sha256.Sum256([]byte(cfg.canonicalURL(rawurl)))
+	return float64(binary.BigEndian.Uint64(sum[:8])) < rate*math.MaxUint64
+}
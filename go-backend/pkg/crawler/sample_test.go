@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+// TestSampledDeterministic checks that the sample is stable across calls,
+// roughly the requested size, and nested as the rate grows.
+func TestSampledDeterministic(t *testing.T) {
+	cfg := testSettings(t, nil)
+	const n = 2000
+	included := 0
+	for i := 0; i < n; i++ {
+		u := fmt.Sprintf("https://example.com/item/%d", i)
+		in := cfg.sampled(u, 0.5)
+		if cfg.sampled(u, 0.5) != in {
+			t.Fatalf("%s sampled inconsistently", u)
+		}
+		if cfg.sampled(u+"#reviews", 0.5) != in {
+			t.Errorf("%s and its fragment form sampled differently", u)
+		}
+		if cfg.sampled(u, 0.25) && !in {
+			t.Errorf("%s is in the 25%% sample but not the 50%% one", u)
+		}
+		if in {
+			included++
+		}
+	}
+	if frac := float64(included) / n; frac < 0.45 || frac > 0.55 {
+		t.Errorf("sample-rate 0.5 kept %.3f of URLs", frac)
+	}
+	if !cfg.sampled("https://example.com/", 1) || cfg.sampled("https://example.com/", 0) {
+		t.Error("rates 1 and 0 must keep everything and nothing")
+	}
+}
+
+// TestSampleRateCrawl checks a sampled crawl still follows every link but
+// emits exactly the sampled pages, the same ones on every run.
+func TestSampleRateCrawl(t *testing.T) {
+	const pages = 10
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><p>A page worth surveying.</p>`)
+		if r.URL.Path == "/" {
+			for i := 0; i < pages; i++ {
+				fmt.Fprintf(w, `<a href="/p%d">Page %d</a>`, i, i)
+			}
+		}
+		fmt.Fprint(w, `</body></html>`)
+	}))
+	defer server.Close()
+
+	cfg := testSettings(t, func(c *Config) { c.SampleRate = 0.5 })
+	crawl := func() map[string]bool {
+		serverURL, _ := url.Parse(server.URL)
+		hostMap := map[string]*hostPolicies{
+			serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+		}
+		urlQueue := make(chan URLWithMetadata, 20)
+		out := make(chan Document, 20)
+		stats := &CrawlerStats{}
+		seen := mapSeen{}
+
+		c := &Crawler{cfg: cfg, client: server.Client(), hostMap: hostMap, seen: &seen, stats: stats}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+
+		urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+		waitFor(t, "every page", func() bool { return pagesProcessed(stats) == pages+1 })
+		cancel()
+
+		emitted := make(map[string]bool)
+		for len(out) > 0 {
+			emitted[(<-out).URL] = true
+		}
+		if got := stats.Snapshot().SampledOut; got != int64(pages+1-len(emitted)) {
+			t.Errorf("SampledOut = %d, want %d", got, pages+1-len(emitted))
+		}
+		return emitted
+	}
+
+	first, second := crawl(), crawl()
+	for _, u := range []string{"/", "/p0", "/p1", "/p2", "/p3", "/p4", "/p5", "/p6", "/p7", "/p8", "/p9"} {
+		u = server.URL + u
+		if want := testSettings(t, nil).sampled(u, 0.5); first[u] != want || second[u] != want {
+			t.Errorf("%s emitted %v then %v, want %v both times", u, first[u], second[u], want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+)
+
+// hostScheduler sits between the frontier and the workers when
+// -host-fairness is set. It keeps a FIFO sub-queue per host and hands URLs
+// out round-robin across hosts, so one large site can't monopolize the
+// workers while others wait.
+type hostScheduler struct {
+	capacity int
+	queues   map[string][]URLWithMetadata
+	order    []string // hosts with pending URLs, in round-robin order
+	cursor   int
+	pending  int
+}
+
+func newHostScheduler(capacity int) *hostScheduler {
+	return &hostScheduler{
+		capacity: capacity,
+		queues:   make(map[string][]URLWithMetadata),
+	}
+}
+
+// run accepts URLs from intake and dispatches them to workers until ctx is
+// done. Intake is only read while fewer than capacity URLs are pending, so a
+// full scheduler shows up as a full queue to the workers.
+func (s *hostScheduler) run(ctx context.Context, intake <-chan URLWithMetadata, dispatch chan<- URLWithMetadata) {
+	for {
+		// Absorb everything already waiting so each round sees all hosts
+	drain:
+		for s.pending < s.capacity {
+			select {
+			case u := <-intake:
+				s.add(u)
+			default:
+				break drain
+			}
+		}
+
+		var recv <-chan URLWithMetadata
+		if s.pending < s.capacity {
+			recv = intake
+		}
+		var send chan<- URLWithMetadata
+		var next URLWithMetadata
+		if s.pending > 0 {
+			send = dispatch
+			next = s.peek()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case u := <-recv:
+			s.add(u)
+		case send <- next:
+			s.pop()
+		}
+	}
+}
+
+func (s *hostScheduler) add(u URLWithMetadata) {
+	host := ""
+	if parsed, err := url.Parse(u.URL); err == nil {
+		host = parsed.Host
+	}
+
+	if len(s.queues[host]) == 0 {
+		s.order = append(s.order, host)
+	}
+	s.queues[host] = append(s.queues[host], u)
+	s.pending++
+}
+
+func (s *hostScheduler) peek() URLWithMetadata {
+	return s.queues[s.order[s.cursor]][0]
+}
+
+// pop removes the URL returned by peek and advances to the next host
+func (s *hostScheduler) pop() {
+	host := s.order[s.cursor]
+	queue := s.queues[host]
+	queue[0] = URLWithMetadata{}
+	s.pending--
+
+	if len(queue) == 1 {
+		delete(s.queues, host)
+		s.order = append(s.order[:s.cursor], s.order[s.cursor+1:]...)
+	} else {
+		s.queues[host] = queue[1:]
+		s.cursor++
+	}
+	if s.cursor >= len(s.order) {
+		s.cursor = 0
+	}
+}
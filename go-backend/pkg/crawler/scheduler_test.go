@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestHostSchedulerInterleavesHosts verifies that a lopsided frontier is
+// served round-robin across hosts rather than draining the largest first.
+func TestHostSchedulerInterleavesHosts(t *testing.T) {
+	counts := []struct {
+		host string
+		n    int
+	}{{"big.example.com", 10}, {"mid.example.com", 3}, {"small.example.com", 1}}
+
+	intake := make(chan URLWithMetadata, 20)
+	total := 0
+	for _, c := range counts {
+		for i := 0; i < c.n; i++ {
+			intake <- URLWithMetadata{URL: fmt.Sprintf("https://%s/%d", c.host, i)}
+			total++
+		}
+	}
+
+	dispatch := make(chan URLWithMetadata)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go newHostScheduler(100).run(ctx, intake, dispatch)
+
+	var hosts []string
+	for i := 0; i < total; i++ {
+		select {
+		case u := <-dispatch:
+			parsed, _ := url.Parse(u.URL)
+			hosts = append(hosts, parsed.Host)
+		case <-time.After(time.Second):
+			t.Fatalf("scheduler stalled after %d of %d URLs", i, total)
+		}
+	}
+
+	want := []string{
+		"big.example.com", "mid.example.com", "small.example.com",
+		"big.example.com", "mid.example.com",
+		"big.example.com", "mid.example.com",
+	}
+	for i, host := range want {
+		if hosts[i] != host {
+			t.Fatalf("dispatch order = %v, want prefix %v", hosts, want)
+		}
+	}
+	for _, host := range hosts[len(want):] {
+		if host != "big.example.com" {
+			t.Fatalf("expected only big.example.com after the small hosts drained, got %v", hosts)
+		}
+	}
+}
+
+// TestHostSchedulerCapacity verifies the scheduler stops accepting URLs once
+// capacity URLs are pending, so workers see a full queue.
+func TestHostSchedulerCapacity(t *testing.T) {
+	intake := make(chan URLWithMetadata)
+	dispatch := make(chan URLWithMetadata)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go newHostScheduler(2).run(ctx, intake, dispatch)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case intake <- URLWithMetadata{URL: fmt.Sprintf("https://example.com/%d", i)}:
+		case <-time.After(time.Second):
+			t.Fatalf("scheduler refused URL %d below capacity", i)
+		}
+	}
+
+	select {
+	case intake <- URLWithMetadata{URL: "https://example.com/overflow"}:
+		t.Fatal("scheduler accepted a URL beyond capacity")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-dispatch
+	select {
+	case intake <- URLWithMetadata{URL: "https://example.com/overflow"}:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not accept a URL after freeing capacity")
+	}
+}
@@ -0,0 +1,184 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// seenStore records which canonical URLs the crawl has already claimed
+type seenStore interface {
+	// visit marks u seen, reporting whether it already was
+	visit(u string) bool
+	add(u string)
+	// has reports whether u was seen, without marking it
+	has(u string) bool
+}
+
+// seenLister is a seenStore that can list its URLs, which snapshots need
+type seenLister interface {
+	seenStore
+	each(fn func(u string))
+}
+
+// newSeenStore picks the store selected by SeenBloom
+func (cfg *settings) newSeenStore() seenStore {
+	if cfg.SeenBloom {
+		return newBloomSeen(cfg.SeenBloomCapacity, cfg.SeenBloomFP)
+	}
+	return &mapSeen{}
+}
+
+// mapSeen is the exact seen set; it keeps every URL for the whole crawl
+type mapSeen struct {
+	m sync.Map
+}
+
+func (s *mapSeen) visit(u string) bool {
+	_, loaded := s.m.LoadOrStore(u, true)
+	return loaded
+}
+
+func (s *mapSeen) add(u string) { s.m.Store(u, true) }
+
+func (s *mapSeen) has(u string) bool {
+	_, ok := s.m.Load(u)
+	return ok
+}
+
+func (s *mapSeen) each(fn func(u string)) {
+	s.m.Range(func(key, _ interface{}) bool {
+		fn(key.(string))
+		return true
+	})
+}
+
+// Scalable bloom filter growth: each new stage holds bloomGrowth times the
+// previous one at bloomTightening times its false-positive rate, so the
+// overall rate stays below fp however many stages are added
+const (
+	bloomGrowth     = 2
+	bloomTightening = 0.5
+)
+
+// bloomSeen is a scalable bloom filter: memory grows with the number of
+// URLs at a few bytes each, rather than with their length
+type bloomSeen struct {
+	mu     sync.Mutex
+	stages []*bloomStage
+}
+
+type bloomStage struct {
+	bits     []uint64
+	m        uint64 // bit count
+	k        int    // hash functions
+	capacity int
+	count    int
+	fp       float64
+}
+
+func newBloomSeen(capacity int, fp float64) *bloomSeen {
+	if capacity <= 0 {
+		capacity = 1 << 20
+	}
+	if fp <= 0 || fp >= 1 {
+		fp = 0.001
+	}
+	// The first stage gets half the budget; later stages share the rest
+	return &bloomSeen{stages: []*bloomStage{newBloomStage(capacity, fp*(1-bloomTightening))}}
+}
+
+func newBloomStage(capacity int, fp float64) *bloomStage {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) &^ 63
+	k := int(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomStage{bits: make([]uint64, m/64), m: m, k: k, capacity: capacity, fp: fp}
+}
+
+// bloomHashes derives the two base hashes for double hashing
+func bloomHashes(u string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(u))
+	sum := h.Sum(nil)
+	var h1, h2 uint64
+	for i := 0; i < 8; i++ {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[8+i])
+	}
+	return h1, h2 | 1
+}
+
+func (st *bloomStage) has(h1, h2 uint64) bool {
+	for i := 0; i < st.k; i++ {
+		bit := (h1 + uint64(i)*h2) % st.m
+		if st.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (st *bloomStage) set(h1, h2 uint64) {
+	for i := 0; i < st.k; i++ {
+		bit := (h1 + uint64(i)*h2) % st.m
+		st.bits[bit/64] |= 1 << (bit % 64)
+	}
+	st.count++
+}
+
+func (b *bloomSeen) visit(u string) bool {
+	h1, h2 := bloomHashes(u)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, st := range b.stages {
+		if st.has(h1, h2) {
+			return true
+		}
+	}
+	b.insert(h1, h2)
+	return false
+}
+
+func (b *bloomSeen) add(u string) {
+	h1, h2 := bloomHashes(u)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.insert(h1, h2)
+}
+
+func (b *bloomSeen) has(u string) bool {
+	h1, h2 := bloomHashes(u)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, st := range b.stages {
+		if st.has(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// insert sets the hashes in the newest stage, starting a larger one when it
+// is full; callers hold mu
+func (b *bloomSeen) insert(h1, h2 uint64) {
+	last := b.stages[len(b.stages)-1]
+	if last.count >= last.capacity {
+		last = newBloomStage(last.capacity*bloomGrowth, last.fp*bloomTightening)
+		b.stages = append(b.stages, last)
+	}
+	last.set(h1, h2)
+}
+
+// sizeBytes is the memory held by the filter's bit arrays
+func (b *bloomSeen) sizeBytes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, st := range b.stages {
+		n += len(st.bits) * 8
+	}
+	return n
+}
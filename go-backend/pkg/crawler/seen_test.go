@@ -0,0 +1,76 @@
+package crawler
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestBloomSeen checks that inserted URLs are always reported seen, that
+// fresh URLs rarely are, and that memory stays a few bytes per URL.
+func TestBloomSeen(t *testing.T) {
+	const n = 50000
+	const fp = 0.01
+	b := newBloomSeen(4096, fp) // small first stage so the filter has to grow
+
+	for i := 0; i < n; i++ {
+		u := fmt.Sprintf("https://example.com/articles/%d/some-long-slug-for-the-page", i)
+		if i%2 == 0 {
+			b.add(u)
+		} else {
+			b.visit(u)
+		}
+	}
+	for i := 0; i < n; i++ {
+		u := fmt.Sprintf("https://example.com/articles/%d/some-long-slug-for-the-page", i)
+		if !b.visit(u) {
+			t.Fatalf("%s was inserted but is not reported seen", u)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if b.visit(fmt.Sprintf("https://other.example.org/%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / n; rate > 2*fp {
+		t.Errorf("false-positive rate = %.4f, want at most %.4f", rate, 2*fp)
+	}
+
+	if len(b.stages) < 2 {
+		t.Errorf("filter has %d stages, expected it to grow past its first", len(b.stages))
+	}
+	// Each URL above is ~55 bytes of string alone; the filter keeps well
+	// under a tenth of that
+	if size := b.sizeBytes(); size > n*5 {
+		t.Errorf("filter holds %d bytes for %d URLs, want at most %d", size, n, n*5)
+	}
+}
+
+// TestSeenStores checks both stores follow the same visit/add contract.
+func TestSeenStores(t *testing.T) {
+	for name, s := range map[string]seenStore{"map": &mapSeen{}, "bloom": newBloomSeen(100, 0.001)} {
+		if s.visit("https://example.com/a") {
+			t.Errorf("%s: first visit reported seen", name)
+		}
+		if !s.visit("https://example.com/a") {
+			t.Errorf("%s: second visit not reported seen", name)
+		}
+		s.add("https://example.com/b")
+		if !s.visit("https://example.com/b") {
+			t.Errorf("%s: added URL not reported seen", name)
+		}
+	}
+}
+
+// TestSeenBloomRejectsSnapshots checks New refuses a combination whose
+// snapshots would silently be empty.
+func TestSeenBloomRejectsSnapshots(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seeds = []string{"https://example.com/"}
+	cfg.SeenBloom = true
+	cfg.SnapshotDir = t.TempDir()
+	if _, err := New(cfg); err == nil {
+		t.Error("New() accepted SeenBloom with SnapshotDir")
+	}
+}
@@ -0,0 +1,69 @@
+package crawler
+
+import (
+	"encoding/json"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// newDocumentSerializer builds the serializer that encodes the raw and
+// quarantine topics; nil for JSON, which is written straight from the
+// projection
+func newDocumentSerializer(format, registry string) (model.Serializer, error) {
+	s, err := model.NewSerializer(format, registry)
+	if err != nil || format == model.FormatJSON {
+		return nil, err
+	}
+	return s, nil
+}
+
+// serializeDocument encodes the projected fields of doc for topic. Binary
+// formats go through model.Document, so fields left out by the projection
+// are sent as their zero values.
+func (cfg *settings) serializeDocument(p fieldProjection, topic string, doc Document) ([]byte, error) {
+	value, err := p.marshal(doc)
+	if err != nil || cfg.serializer == nil {
+		return value, err
+	}
+	var m model.Document
+	if err := json.Unmarshal(value, &m); err != nil {
+		return nil, err
+	}
+	return cfg.serializer.Marshal(topic, m)
+}
+
+// modelDocument converts doc to the shared model.Document by its JSON
+// fields, which the two types have in common
+func modelDocument(doc Document) (model.Document, error) {
+	var m model.Document
+	value, err := json.Marshal(doc)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(value, &m)
+	return m, err
+}
+
+// deserializeDocument decodes a document written by serializeDocument
+func (cfg *settings) deserializeDocument(data []byte, doc *Document) error {
+	if cfg.serializer == nil {
+		return json.Unmarshal(data, doc)
+	}
+	var m model.Document
+	if err := cfg.serializer.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	value, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, doc)
+}
+
+// documentContentType is the content_type header for serialized documents
+func (cfg *settings) documentContentType() string {
+	if cfg.serializer == nil {
+		return "application/json"
+	}
+	return cfg.serializer.ContentType()
+}
@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// TestTopicMessageSerialization checks the raw topic is encoded with
+// Serialization while the dream topic stays JSON, and that EmitFields
+// still decides which fields are sent.
+func TestTopicMessageSerialization(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) {
+		c.Serialization = model.FormatProtobuf
+		c.EmitFields = "url,title,chunks,dream_hints"
+	})
+
+	doc := Document{
+		URL:        "https://example.com/",
+		Title:      "Example",
+		CleanText:  "not projected",
+		Chunks:     []ContentChunk{{ID: "chunk_0", Type: "paragraph", Text: "Hello", Entities: []string{"Hello"}}},
+		DreamHints: DreamingHints{Motifs: []string{"light"}, Surrealism: 0.9},
+	}
+	msg, err := cfg.topicMessage(doc, cfg.KafkaTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range msg.Headers {
+		if h.Key == "content_type" && string(h.Value) != "application/x-protobuf" {
+			t.Errorf("content_type = %q", h.Value)
+		}
+	}
+	var got model.Document
+	if err := cfg.serializer.Unmarshal(msg.Value, &got); err != nil {
+		t.Fatalf("raw topic value isn't protobuf: %v", err)
+	}
+	if got.URL != doc.URL || got.Title != doc.Title || got.CleanText != "" ||
+		len(got.Chunks) != 1 || got.Chunks[0].Entities[0] != "Hello" || got.DreamHints.Motifs[0] != "light" {
+		t.Errorf("decoded %+v", got)
+	}
+
+	dream, err := cfg.topicMessage(doc, cfg.DreamTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(dream.Value) {
+		t.Error("dream topic value isn't JSON")
+	}
+}
+
+func TestNewDocumentSerializer(t *testing.T) {
+	if s, err := newDocumentSerializer(model.FormatJSON, ""); s != nil || err != nil {
+		t.Errorf("json: got %v, %v; want the projection's own JSON", s, err)
+	}
+	if _, err := newDocumentSerializer(model.FormatProtobuf, "http://registry:8081"); err == nil {
+		t.Error("a schema registry was accepted without avro")
+	}
+}
@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// flightGroup coalesces concurrent calls with the same key, in the manner
+// of golang.org/x/sync/singleflight: the first caller runs fn and later
+// ones wait for it and share its result. The zero value is ready to use.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+// flightCall is a call in progress; val and err are set before done is closed
+type flightCall[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// do runs fn for key unless a call for key is already running, in which
+// case it waits for that call. shared reports whether the result came
+// from another caller's call.
+func (g *flightGroup[T]) do(key string, fn func() (T, error)) (val T, err error, shared bool) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.val, call.err, true
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	call := &flightCall[T]{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.val, call.err = fn()
+	return call.val, call.err, false
+}
+
+// wait blocks until the call running for key, if any, has finished or
+// ctx is done, and reports whether there was one
+func (g *flightGroup[T]) wait(ctx context.Context, key string) bool {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	g.mu.Unlock()
+	if !ok {
+		return false
+	}
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+	}
+	return true
+}
+
+// loadRobots fetches hp's robots.txt the first time any worker reaches
+// the host. Workers arriving meanwhile wait for that one fetch, so the
+// host's first pages are checked against its rules too.
+func (c *Crawler) loadRobots(client *http.Client, u *url.URL, hp *hostPolicies) {
+	if !hp.robotsPending.Load() {
+		return
+	}
+	c.robotsFlight.do(u.Host, func() (struct{}, error) {
+		// A flight that just finished may already have loaded it
+		if hp.robotsPending.Load() {
+			fetchRobotsTxt(client, u, hp)
+			hp.robotsPending.Store(false)
+		}
+		return struct{}{}, nil
+	})
+}
+
+// fetch fetches urlMeta's page, sharing the fetch with any worker already
+// fetching the same URL under -coalesce-fetches. A shared page belongs to
+// the worker that fetched it: waiters must not parse or release it. The
+// host's -host-config concurrency slot is taken only by the worker that
+// fetches, so waiters don't hold one while they wait.
+func (c *Crawler) fetch(ctx context.Context, client *http.Client, hp *hostPolicies, urlMeta URLWithMetadata) (fetchedPage, error, bool) {
+	fetch := func() (fetchedPage, error) {
+		if hp.slots != nil {
+			select {
+			case hp.slots <- struct{}{}:
+			case <-ctx.Done():
+				return fetchedPage{}, ctx.Err()
+			}
+			defer func() { <-hp.slots }()
+		}
+		return c.cfg.fetchPage(ctx, client, urlMeta.URL, urlMeta.Metadata)
+	}
+	if !c.cfg.CoalesceFetches {
+		page, err := fetch()
+		return page, err, false
+	}
+	return c.fetchFlight.do(c.cfg.canonicalURL(urlMeta.URL), fetch)
+}
@@ -0,0 +1,173 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// TestRobotsFetchedOnce sends many workers at one fresh host and checks
+// robots.txt is fetched exactly once, and that it is obeyed from the very
+// first page rather than once a background fetch happens to finish.
+func TestRobotsFetchedOnce(t *testing.T) {
+	var robotsHits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsHits.Add(1)
+			time.Sleep(50 * time.Millisecond) // keep the fetch in flight while the others arrive
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+			return
+		}
+		fmt.Fprint(w, `<html><body><p>A page with enough words to count as content.</p></body></html>`)
+	}))
+	defer server.Close()
+
+	// Few public pages: a fresh host gets the default politeness delay
+	const workers, public = 8, 2
+	queue := make(chan URLWithMetadata, workers+public)
+	out := make(chan Document, workers+public)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+	c := &Crawler{client: server.Client(), cfg: testSettings(t, nil), hostMap: map[string]*hostPolicies{}, seen: &seen, stats: stats}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < workers; i++ {
+		queue <- URLWithMetadata{URL: fmt.Sprintf("%s/private/%d", server.URL, i)}
+	}
+	for i := 0; i < public; i++ {
+		queue <- URLWithMetadata{URL: fmt.Sprintf("%s/public/%d", server.URL, i)}
+	}
+	for i := 0; i < workers; i++ {
+		go c.enhancedWorker(ctx, i, queue, queue, out)
+	}
+
+	waitFor(t, "every URL", func() bool { return stats.progress() == workers+public })
+	if n := robotsHits.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want 1", n)
+	}
+	snap := stats.Snapshot()
+	if snap.SkippedRobots != workers || snap.PagesProcessed != public {
+		t.Errorf("skipped by robots %d, processed %d; want %d and %d", snap.SkippedRobots, snap.PagesProcessed, workers, public)
+	}
+}
+
+// TestFlightGroup checks concurrent calls for one key run fn once and
+// share its result, while other keys run separately.
+func TestFlightGroup(t *testing.T) {
+	var g flightGroup[int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var sharedCount atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, shared := g.do("a", func() (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+			if v != 42 || err != nil {
+				t.Errorf("do = %d, %v", v, err)
+			}
+			if shared {
+				sharedCount.Add(1)
+			}
+		}()
+	}
+	waitFor(t, "the callers to queue up", func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.calls["a"] != nil
+	})
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("fn ran %d times, want 1", calls.Load())
+	}
+	if sharedCount.Load() == 0 {
+		t.Error("no caller shared the result")
+	}
+	if v, _, shared := g.do("a", func() (int, error) { return 7, nil }); v != 7 || shared {
+		t.Errorf("call after the flight landed = %d (shared %v), want a fresh 7", v, shared)
+	}
+}
+
+// TestCoalescedWaitersHoldNothing checks a worker waiting on another's
+// fetch of the same URL takes neither a rate-limit token nor a host slot,
+// so a third worker can fetch a different page meanwhile.
+func TestCoalescedWaitersHoldNothing(t *testing.T) {
+	release := make(chan struct{})
+	var slowHits, otherHits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/slow":
+			slowHits.Add(1)
+			<-release
+		case "/other":
+			otherHits.Add(1)
+		}
+		fmt.Fprint(w, `<html><body><p>A page with enough words to count as content.</p></body></html>`)
+	}))
+	defer server.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	serverURL, _ := url.Parse(server.URL)
+	// Two tokens and two slots: enough for /slow and /other, not for a
+	// waiter as well
+	hp := &hostPolicies{lim: rate.NewLimiter(rate.Every(time.Hour), 2), slots: make(chan struct{}, 2)}
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+	c := &Crawler{client: server.Client(), cfg: testSettings(t, nil), hostMap: map[string]*hostPolicies{serverURL.Host: hp}, seen: &seen, stats: stats}
+	queue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.enhancedWorker(ctx, i, queue, queue, out)
+		}()
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	queue <- URLWithMetadata{URL: server.URL + "/slow"}
+	waitFor(t, "the first fetch", func() bool { return slowHits.Load() == 1 })
+	// Claimed, as by a resumed crawl, so it gets past the seen check
+	queue <- URLWithMetadata{URL: server.URL + "/slow", Metadata: URLMetadata{claimed: true}}
+	time.Sleep(50 * time.Millisecond) // let a second worker start waiting
+	queue <- URLWithMetadata{URL: server.URL + "/other"}
+	waitFor(t, "/other to be fetched while /slow is in flight", func() bool { return otherHits.Load() == 1 })
+
+	close(release)
+	waitFor(t, "every URL", func() bool { return stats.progress() == 3 })
+	if n := slowHits.Load(); n != 1 {
+		t.Errorf("/slow fetched %d times, want 1", n)
+	}
+	if skipped := stats.Snapshot().SkippedSeen; skipped != 1 {
+		t.Errorf("skipped %d as seen, want the waiting duplicate", skipped)
+	}
+}
@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSitemapBytes is the sitemaps.org size limit, uncompressed
+	maxSitemapBytes = 50 << 20
+	// sitemapPriority ranks sitemap pages below seeds but above most links
+	sitemapPriority = 8
+)
+
+// sitemapEntry is a <url> of a urlset or a <sitemap> of a sitemap index
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	Lastmod string `xml:"lastmod"`
+}
+
+type sitemapDoc struct {
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// sitemapLastmodLayouts are the W3C datetime forms sitemaps use
+var sitemapLastmodLayouts = []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02"}
+
+// lastmodTime parses a <lastmod>; ok is false when it is missing or
+// unreadable, in which case the page is always crawled
+func lastmodTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range sitemapLastmodLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// fetchSitemap downloads and decodes one sitemap, gzipped or not
+func fetchSitemap(ctx context.Context, client *http.Client, u string) (sitemapDoc, error) {
+	var doc sitemapDoc
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return doc, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return doc, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return doc, fmt.Errorf("sitemap %s: status %d", u, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSitemapBytes))
+	if err != nil {
+		return doc, err
+	}
+	var body io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return doc, fmt.Errorf("sitemap %s: %w", u, err)
+		}
+		body = io.LimitReader(zr, maxSitemapBytes)
+	}
+	if err := xml.NewDecoder(body).Decode(&doc); err != nil {
+		return doc, fmt.Errorf("sitemap %s: %w", u, err)
+	}
+	return doc, nil
+}
+
+// sitemapPages returns the pages listed by the sitemap at u. A sitemap
+// index is followed one level down; nested indexes are ignored.
+func (c *Crawler) sitemapPages(ctx context.Context, u string) []sitemapEntry {
+	doc, err := fetchSitemap(ctx, c.client, u)
+	if err != nil {
+		log.Printf("Skipping sitemap: %v", err)
+		return nil
+	}
+	pages := doc.URLs
+	for _, child := range doc.Sitemaps {
+		childDoc, err := fetchSitemap(ctx, c.client, strings.TrimSpace(child.Loc))
+		if err != nil {
+			log.Printf("Skipping sitemap: %v", err)
+			continue
+		}
+		pages = append(pages, childDoc.URLs...)
+	}
+	return pages
+}
+
+// unmodified reports whether the page's <lastmod> is no later than its
+// last crawl recorded in -change-store
+func (c *Crawler) unmodified(entry sitemapEntry) bool {
+	lastmod, ok := lastmodTime(entry.Lastmod)
+	if !ok {
+		return false
+	}
+	crawled, ok := c.contents.lastFetched(entry.Loc)
+	return ok && !lastmod.After(crawled)
+}
+
+// enqueueSitemaps queues the pages of each sitemap that are new or
+// modified since their last crawl
+func (c *Crawler) enqueueSitemaps(ctx context.Context, queue chan<- URLWithMetadata, sitemapURLs []string) {
+	for _, sitemapURL := range sitemapURLs {
+		for _, entry := range c.sitemapPages(ctx, sitemapURL) {
+			entry.Loc = strings.TrimSpace(entry.Loc)
+			if entry.Loc == "" {
+				continue
+			}
+			if c.unmodified(entry) {
+				c.skip(entry.Loc, SkipUnmodified)
+				continue
+			}
+			page := URLWithMetadata{URL: entry.Loc, Metadata: URLMetadata{depth: 0, priority: sitemapPriority}}
+			c.pending.queued(page)
+			select {
+			case queue <- page:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package crawler
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestSitemapLastmodRecrawl lists pages in a gzipped sitemap behind a
+// sitemap index and checks that, against a change store from an earlier
+// crawl, only pages modified since, new to the store or without a usable
+// lastmod are enqueued.
+func TestSitemapLastmodRecrawl(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+				<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<sitemap><loc>%s/posts.xml.gz</loc></sitemap>
+				</sitemapindex>`, server.URL)
+		case "/posts.xml.gz":
+			zw := gzip.NewWriter(w)
+			fmt.Fprintf(zw, `<?xml version="1.0" encoding="UTF-8"?>
+				<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<url><loc>%[1]s/old</loc><lastmod>2024-05-01</lastmod></url>
+					<url><loc>%[1]s/same</loc><lastmod>2024-06-01T12:00:00+00:00</lastmod></url>
+					<url><loc>%[1]s/updated</loc><lastmod>2024-06-02T09:30+02:00</lastmod></url>
+					<url><loc>%[1]s/undated</loc></url>
+					<url><loc>%[1]s/garbled</loc><lastmod>last tuesday</lastmod></url>
+					<url><loc>%[1]s/brand-new</loc><lastmod>2024-01-01</lastmod></url>
+				</urlset>`, server.URL)
+			zw.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	crawled := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cfg := testSettings(t, nil)
+	contents := newContentStore(1<<10, 0, cfg.canonicalURL)
+	for _, path := range []string{"/old", "/same", "/updated", "/undated", "/garbled"} {
+		contents.put(cfg.canonicalURL(server.URL+path), storedContent{Hash: "h", FetchedAt: crawled})
+	}
+	stats := &CrawlerStats{}
+	c := &Crawler{cfg: cfg, client: server.Client(), stats: stats, contents: contents}
+
+	queue := make(chan URLWithMetadata, 10)
+	c.enqueueSitemaps(context.Background(), queue, []string{server.URL + "/sitemap_index.xml", server.URL + "/missing.xml"})
+	close(queue)
+	var got []string
+	for u := range queue {
+		got = append(got, u.URL[len(server.URL):])
+	}
+	sort.Strings(got)
+
+	if want := "[/brand-new /garbled /undated /updated]"; fmt.Sprint(got) != want {
+		t.Errorf("enqueued %v, want %s", got, want)
+	}
+	if n := stats.Snapshot().SkippedUnmodified; n != 2 {
+		t.Errorf("skipped %d unmodified pages, want 2", n)
+	}
+}
+
+func TestSitemapWithoutChangeStore(t *testing.T) {
+	c := &Crawler{stats: &CrawlerStats{}}
+	if c.unmodified(sitemapEntry{Loc: "https://example.com/", Lastmod: "2000-01-01"}) {
+		t.Error("a page was skipped with nothing recording its last crawl")
+	}
+}
@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// crawlSnapshot is the on-disk record of a crawl's progress. Visited holds
+// only pages fetched and parsed without a server error; URLs that failed or
+// were still pending are left out so a resumed crawl tries them again.
+type crawlSnapshot struct {
+	TakenAt time.Time     `json:"taken_at"`
+	Visited []string      `json:"visited"`
+	Stats   StatsSnapshot `json:"stats"`
+}
+
+// snapshotter periodically writes snapshots until ctx is done
+func snapshotter(ctx context.Context, dir string, interval time.Duration, crawled seenLister, stats *CrawlerStats) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if path, err := writeSnapshot(dir, crawled, stats); err != nil {
+				log.Printf("Snapshot failed: %v", err)
+			} else {
+				log.Printf("Snapshot written to %s", path)
+			}
+		}
+	}
+}
+
+// writeSnapshot records the crawled set and stats to a timestamped file in
+// dir. The file is written under a temporary name and renamed, so readers
+// never see a partial snapshot.
+func writeSnapshot(dir string, crawled seenLister, stats *CrawlerStats) (string, error) {
+	snap := crawlSnapshot{
+		TakenAt: time.Now().UTC(),
+		Visited: []string{},
+		Stats:   stats.Snapshot(),
+	}
+	crawled.each(func(u string) {
+		snap.Visited = append(snap.Visited, u)
+	})
+	sort.Strings(snap.Visited)
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("snapshot-%s.json", snap.TakenAt.Format("20060102T150405.000Z"))
+	path := filepath.Join(dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+func loadSnapshot(path string) (*crawlSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap crawlSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("parsing snapshot %s: %w", path, err)
+	}
+	return &snap, nil
+}
+
+// restore marks the snapshot's visited URLs as seen so a resumed crawl
+// doesn't fetch them again. URLs in keep (the new run's seeds) are left
+// out so the crawl has somewhere to start; canonical keys them as the
+// snapshot's URLs are.
+func (snap *crawlSnapshot) restore(seen seenStore, keep []string, canonical func(string) string) int {
+	skip := make(map[string]bool, len(keep))
+	for _, u := range keep {
+		skip[canonical(u)] = true
+	}
+
+	restored := 0
+	for _, u := range snap.Visited {
+		if !skip[u] {
+			seen.add(u)
+			restored++
+		}
+	}
+	return restored
+}
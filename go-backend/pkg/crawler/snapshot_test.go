@@ -0,0 +1,88 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+// TestSnapshotAfterCrawl crawls a small mock site, writes a snapshot and
+// checks it records the crawled URLs and per-host stats, then resumes from it.
+// A page that failed with a server error is left out of the snapshot so it's retried.
+func TestSnapshotAfterCrawl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><a href="/a">A</a><a href="/b">B</a><a href="/gone">Gone</a></body></html>`)
+		case "/gone":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprintf(w, `<html><body><p>Page %s</p></body></html>`, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	stats := &CrawlerStats{}
+	seen := mapSeen{}
+	hostMap := map[string]*hostPolicies{serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)}}
+
+	c := &Crawler{client: server.Client(), cfg: testSettings(t, nil), hostMap: hostMap, seen: &seen, crawled: &mapSeen{}, stats: stats}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+	}()
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+
+	waitFor(t, "four pages to be crawled", func() bool { return pagesProcessed(stats) == 4 })
+	cancel()
+	<-done
+
+	dir := t.TempDir()
+	path, err := writeSnapshot(dir, c.crawled, stats)
+	if err != nil {
+		t.Fatalf("writeSnapshot() returned an error: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || filepath.Join(dir, entries[0].Name()) != path {
+		t.Fatalf("expected exactly the snapshot file in %s, got %v", dir, entries)
+	}
+
+	snap, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatalf("loadSnapshot() returned an error: %v", err)
+	}
+	want := []string{server.URL + "/", server.URL + "/a", server.URL + "/b"}
+	if fmt.Sprint(snap.Visited) != fmt.Sprint(want) {
+		t.Errorf("snapshot visited = %v, want %v", snap.Visited, want)
+	}
+	if snap.Stats.PagesProcessed != 4 || snap.Stats.Hosts[serverURL.Host].Pages != 4 {
+		t.Errorf("snapshot stats = %+v, want 4 pages for %s", snap.Stats, serverURL.Host)
+	}
+
+	// Resuming marks everything but the seeds as seen
+	resumed := mapSeen{}
+	if n := snap.restore(&resumed, []string{server.URL + "/"}, testSettings(t, nil).canonicalURL); n != 2 {
+		t.Errorf("restore() marked %d URLs, want 2", n)
+	}
+	if !resumed.visit(server.URL + "/a") {
+		t.Error("restored seen set is missing /a")
+	}
+	if resumed.visit(server.URL + "/") {
+		t.Error("restored seen set should not contain the seed")
+	}
+	if resumed.visit(server.URL + "/gone") {
+		t.Error("restored seen set should not contain the failed URL")
+	}
+}
@@ -0,0 +1,275 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const (
+	maxDeliveryRetryDelay = time.Minute
+	spoolRetryTick        = time.Second // how often the spool looks for due retries
+	deadLetterFile        = "dead-letter.ndjson"
+)
+
+// spoolID marks a re-produced message in its Opaque field, so its
+// delivery report is matched back to the spool entry
+type spoolID string
+
+// spooledMessage is a failed message as written to the spool
+type spooledMessage struct {
+	Topic       string         `json:"topic"`
+	Key         []byte         `json:"key,omitempty"`
+	Value       []byte         `json:"value"`
+	Headers     []kafka.Header `json:"headers,omitempty"`
+	FirstFailed time.Time      `json:"first_failed"`
+	Error       string         `json:"error,omitempty"` // last delivery error, for the dead-letter file
+	size        int64          // bytes on disk
+	attempts    int            // retries so far in this process
+	next        time.Time      // when the next retry is due
+	inFlight    bool           // re-produced, waiting for its delivery report
+}
+
+// deliverySpool keeps messages whose delivery failed, one file each, and
+// re-produces them until they are delivered or too old. A nil spool keeps
+// nothing, leaving failures logged and lost as before.
+type deliverySpool struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	delay    time.Duration
+
+	mu      sync.Mutex
+	entries map[spoolID]*spooledMessage
+	bytes   int64
+	seq     int
+}
+
+// openDeliverySpool prepares dir, picking up messages left by an earlier run
+func openDeliverySpool(dir string, maxBytes int64, maxAge, delay time.Duration) (*deliverySpool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &deliverySpool{dir: dir, maxBytes: maxBytes, maxAge: maxAge, delay: delay, entries: make(map[spoolID]*spooledMessage)}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var msg spooledMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("Delivery spool: skipping unreadable %s: %v", path, err)
+			continue
+		}
+		msg.size = int64(len(data))
+		s.entries[spoolID(strings.TrimSuffix(filepath.Base(path), ".json"))] = &msg
+		s.bytes += msg.size
+	}
+	if len(s.entries) > 0 {
+		log.Printf("Delivery spool: %d messages from an earlier run to retry", len(s.entries))
+	}
+	return s, nil
+}
+
+// failed handles a delivery report with an error: a new failure is
+// spooled, a failed retry is rescheduled with backoff
+func (s *deliverySpool) failed(msg *kafka.Message) {
+	if s == nil {
+		log.Printf("Kafka delivery failed: %v", msg.TopicPartition)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := msg.Opaque.(spoolID); ok {
+		if entry, ok := s.entries[id]; ok {
+			entry.inFlight = false
+			entry.attempts++
+			entry.Error = msg.TopicPartition.Error.Error()
+			entry.next = time.Now().Add(s.backoff(entry.attempts))
+			return
+		}
+	}
+
+	entry := &spooledMessage{
+		Key:         msg.Key,
+		Value:       msg.Value,
+		Headers:     msg.Headers,
+		FirstFailed: time.Now().UTC(),
+		Error:       msg.TopicPartition.Error.Error(),
+	}
+	if msg.TopicPartition.Topic != nil {
+		entry.Topic = *msg.TopicPartition.Topic
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Delivery spool: dropping message for %s: %v", entry.Topic, err)
+		return
+	}
+	if s.bytes+int64(len(data)) > s.maxBytes {
+		log.Printf("Delivery spool full (%d bytes), dead-lettering message for %s", s.bytes, entry.Topic)
+		s.deadLetter(entry)
+		return
+	}
+
+	s.seq++
+	id := spoolID(fmt.Sprintf("%d-%06d", time.Now().UnixNano(), s.seq))
+	if err := writeFileAtomic(s.path(id), data); err != nil {
+		log.Printf("Delivery spool: dead-lettering message for %s: %v", entry.Topic, err)
+		s.deadLetter(entry)
+		return
+	}
+	entry.size = int64(len(data))
+	entry.next = time.Now().Add(s.backoff(0))
+	s.entries[id] = entry
+	s.bytes += entry.size
+}
+
+// delivered handles a successful delivery report, removing the message
+// from the spool if it was a retry
+func (s *deliverySpool) delivered(msg *kafka.Message) {
+	id, ok := msg.Opaque.(spoolID)
+	if s == nil || !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(id)
+}
+
+// run re-produces due messages every tick until ctx is done. Messages
+// older than maxAge are moved to the dead-letter file instead.
+func (s *deliverySpool) run(ctx context.Context, produce func(*kafka.Message) error, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.retryDue(produce)
+		}
+	}
+}
+
+func (s *deliverySpool) retryDue(produce func(*kafka.Message) error) {
+	due := s.takeDue(time.Now())
+	for _, msg := range due {
+		if err := produce(msg); err != nil {
+			// Not even queued, e.g. the local queue is full; try again later
+			s.failed(&kafka.Message{TopicPartition: kafka.TopicPartition{Error: err}, Opaque: msg.Opaque})
+		}
+	}
+}
+
+// takeDue returns the messages due for a retry, oldest first, marking them
+// in flight, and dead-letters those past maxAge
+func (s *deliverySpool) takeDue(now time.Time) []*kafka.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+
+	var due []*kafka.Message
+	for _, key := range ids {
+		id := spoolID(key)
+		entry := s.entries[id]
+		switch {
+		case entry.inFlight || now.Before(entry.next):
+		case now.Sub(entry.FirstFailed) > s.maxAge:
+			log.Printf("Delivery spool: giving up on message for %s after %v: %s", entry.Topic, s.maxAge, entry.Error)
+			s.deadLetter(entry)
+			s.remove(id)
+		default:
+			topic := entry.Topic
+			due = append(due, &kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+				Key:            entry.Key,
+				Value:          entry.Value,
+				Headers:        entry.Headers,
+				Opaque:         id,
+			})
+			entry.inFlight = true
+		}
+	}
+	return due
+}
+
+// pending is the number of messages waiting in the spool
+func (s *deliverySpool) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func (s *deliverySpool) backoff(attempts int) time.Duration {
+	delay := s.delay << attempts
+	if delay <= 0 || delay > maxDeliveryRetryDelay {
+		return maxDeliveryRetryDelay
+	}
+	return delay
+}
+
+func (s *deliverySpool) path(id spoolID) string {
+	return filepath.Join(s.dir, string(id)+".json")
+}
+
+// remove drops an entry and its file; callers hold mu
+func (s *deliverySpool) remove(id spoolID) {
+	entry, ok := s.entries[id]
+	if !ok {
+		return
+	}
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Delivery spool: %v", err)
+	}
+	delete(s.entries, id)
+	s.bytes -= entry.size
+}
+
+// deadLetter appends a message to the dead-letter file; callers hold mu
+func (s *deliverySpool) deadLetter(entry *spooledMessage) {
+	line, err := json.Marshal(entry)
+	if err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(filepath.Join(s.dir, deadLetterFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644); err == nil {
+			_, err = f.Write(append(line, '\n'))
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("Delivery spool: lost message for %s: %v", entry.Topic, err)
+	}
+}
+
+// writeFileAtomic writes data under a temporary name and renames it into
+// place, so a crash never leaves a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// flakyPublisher reports the first failures deliveries as failed, like a
+// broker that is down for a while, and records what got through
+type flakyPublisher struct {
+	mu        sync.Mutex
+	failures  int
+	delivered []string
+	events    chan kafka.Event
+}
+
+func (p *flakyPublisher) produce(msg *kafka.Message) error {
+	report := *msg
+	p.mu.Lock()
+	if p.failures > 0 {
+		p.failures--
+		report.TopicPartition.Error = errors.New("broker transport failure")
+	} else {
+		p.delivered = append(p.delivered, string(msg.Value))
+	}
+	p.mu.Unlock()
+	p.events <- &report
+	return nil
+}
+
+func (p *flakyPublisher) deliveredValues() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	values := append([]string{}, p.delivered...)
+	sort.Strings(values)
+	return values
+}
+
+// TestDeliverySpoolRetries produces three messages through a publisher
+// that fails the first five deliveries and checks the spool's retrier
+// eventually delivers every message and empties the spool.
+func TestDeliverySpoolRetries(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := openDeliverySpool(dir, 1<<20, time.Minute, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &flakyPublisher{failures: 5, events: make(chan kafka.Event, 16)}
+	go handleDeliveries(pub.events, spool)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go spool.run(ctx, pub.produce, time.Millisecond)
+
+	topic := "raw.content"
+	for _, value := range []string{"one", "two", "three"} {
+		pub.produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Key:            []byte("key-" + value),
+			Value:          []byte(value),
+		})
+	}
+
+	waitFor(t, "every message to be delivered", func() bool { return len(pub.deliveredValues()) == 3 })
+	if got := pub.deliveredValues(); strings.Join(got, ",") != "one,three,two" {
+		t.Errorf("delivered %v, want each message once", got)
+	}
+	waitFor(t, "the spool to empty", func() bool { return spool.pending() == 0 })
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 0 {
+		t.Errorf("spool files left behind: %v", files)
+	}
+}
+
+// TestDeliverySpoolDeadLetters checks messages past -delivery-max-age, or
+// failing while the spool is full, end up in the dead-letter file, and
+// that spooled messages survive a restart.
+func TestDeliverySpoolDeadLetters(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := openDeliverySpool(dir, 1<<20, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic := "raw.content"
+	fail := func(s *deliverySpool, value string) {
+		s.failed(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Error: errors.New("broker down")},
+			Value:          []byte(value),
+		})
+	}
+	fail(spool, "kept")
+
+	// A restart picks the message up again
+	restarted, err := openDeliverySpool(dir, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.pending() != 1 {
+		t.Fatalf("restarted spool has %d messages, want 1", restarted.pending())
+	}
+	fail(restarted, "overflow") // past the 1 byte bound
+	if due := restarted.takeDue(time.Now().Add(time.Second)); len(due) != 0 {
+		t.Errorf("expired message was retried: %v", due)
+	}
+	if restarted.pending() != 0 {
+		t.Errorf("expired message still spooled")
+	}
+
+	f, err := os.Open(filepath.Join(dir, deadLetterFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var dead []string
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		dead = append(dead, scanner.Text())
+	}
+	if len(dead) != 2 || !strings.Contains(dead[0], `"error":"broker down"`) {
+		t.Errorf("dead letters = %q, want the overflow and the expired message", dead)
+	}
+}
@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+var errPrivateAddress = errors.New("refusing to connect to an internal address (see -allow-private-networks)")
+
+// internalPrefixes are internal ranges the netip predicates don't cover
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT, some cloud metadata services
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+}
+
+// internalAddress reports whether ip is loopback, private, link-local
+// (which includes the 169.254.169.254 cloud metadata endpoint) or
+// otherwise not on the public internet
+func internalAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardDial is a net.Dialer Control function refusing connections to
+// internal addresses. It sees the address actually being connected to,
+// after DNS resolution, so a name that re-resolves to an internal address
+// between checks (DNS rebinding) is still refused. Proxies are dialed
+// without it; see guardPrivateNetworks.
+func guardDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("dialing %s: %w", address, err)
+	}
+	if internalAddress(addrPort.Addr()) {
+		return fmt.Errorf("%s: %w", address, errPrivateAddress)
+	}
+	return nil
+}
+
+// proxyFunc picks the proxy for a request, as http.Transport.Proxy does
+type proxyFunc func(*http.Request) (*url.URL, error)
+
+// guardPrivateNetworks refuses internal targets whether or not a proxy
+// carries the request. Direct connections go through guarded, which
+// checks the dialed address with guardDial. A proxy may itself be on
+// an internal address, such as HTTP_PROXY=127.0.0.1:3128, so it is dialed
+// with direct; the target is then checked by the returned proxy function,
+// since only the proxy connects to it. A proxied target is resolved here
+// and again by the proxy, so unlike a direct one it isn't safe from DNS
+// rebinding.
+func guardPrivateNetworks(proxy proxyFunc, direct, guarded dialFunc) (proxyFunc, dialFunc) {
+	proxies := proxyAddresses(proxy)
+	guardedProxy := func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil {
+			if proxies[requestAddress(req.URL)] {
+				// Straight to the proxy's own address, such as a NO_PROXY
+				// loopback request
+				return nil, fmt.Errorf("%s: %w", req.URL.Host, errPrivateAddress)
+			}
+			return u, err
+		}
+		if err := checkProxiedTarget(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if proxies[addr] {
+			return direct(ctx, network, addr)
+		}
+		return guarded(ctx, network, addr)
+	}
+	return guardedProxy, dial
+}
+
+// proxyAddresses returns the host:port of the proxies proxy picks for
+// http and https URLs, as the transport dials them
+func proxyAddresses(proxy proxyFunc) map[string]bool {
+	addrs := make(map[string]bool)
+	for _, target := range []string{"http://example.com/", "https://example.com/"} {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			continue
+		}
+		if u, err := proxy(req); err == nil && u != nil {
+			addrs[requestAddress(u)] = true
+		}
+	}
+	return addrs
+}
+
+// requestAddress is the host:port a URL is dialed at, with the scheme's
+// default port when it has none
+func requestAddress(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// checkProxiedTarget refuses a proxied request's host when it is, or
+// resolves to, an internal address. A name that doesn't resolve here is
+// left for the proxy to fail on.
+func checkProxiedTarget(ctx context.Context, host string) error {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if internalAddress(ip) {
+			return fmt.Errorf("%s: %w", host, errPrivateAddress)
+		}
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if internalAddress(ip) {
+			return fmt.Errorf("%s resolves to %s: %w", host, ip, errPrivateAddress)
+		}
+	}
+	return nil
+}
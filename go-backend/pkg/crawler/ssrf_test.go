@@ -0,0 +1,123 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestPrivateNetworkGuard checks a URL resolving to 127.0.0.1 is refused
+// by default, after name resolution, and fetched with
+// -allow-private-networks.
+func TestPrivateNetworkGuard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Metadata</title></head><body><p>secret</p></body></html>`)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	// A name, so the check has to happen on the resolved address
+	target := "http://localhost:" + serverURL.Port() + "/"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cfg := testSettings(t, nil)
+	client, err := cfg.newHTTPClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = cfg.enhancedFetchAndParse(ctx, client, target, URLMetadata{})
+	var fe *FetchError
+	if !errors.As(err, &fe) || fe.Category != FetchBlocked || !errors.Is(err, errPrivateAddress) {
+		t.Fatalf("default fetch of %s: err = %v, want a blocked FetchError", target, err)
+	}
+
+	cfg = testSettings(t, func(c *Config) { c.AllowPrivateNetworks = true })
+	if client, err = cfg.newHTTPClient(); err != nil {
+		t.Fatal(err)
+	}
+	doc, _, err := cfg.enhancedFetchAndParse(ctx, client, target, URLMetadata{})
+	if err != nil || doc.Title != "Metadata" {
+		t.Errorf("with AllowPrivateNetworks: title %q, err %v", doc.Title, err)
+	}
+}
+
+func TestInternalAddress(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.100.100.200":  true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"fd00:ec2::254":    true,
+		"fe80::1":          true,
+		"::ffff:127.0.0.1": true,
+		"93.184.216.34":    false,
+		"2606:4700::1111":  false,
+	} {
+		if got := internalAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("internalAddress(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+// TestPrivateNetworkGuardProxy checks a proxy on loopback is still
+// dialed, while internal targets sent through it, and requests straight
+// to the proxy, are refused.
+func TestPrivateNetworkGuardProxy(t *testing.T) {
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>Proxied %s</title></head><body><p>via the proxy</p></body></html>`, r.URL.Host)
+	}))
+	defer proxyServer.Close()
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	proxy := func(req *http.Request) (*url.URL, error) {
+		if req.URL.Hostname() == "127.0.0.1" {
+			return nil, nil // like NO_PROXY for loopback
+		}
+		return proxyURL, nil
+	}
+
+	guardedDialer := &net.Dialer{Control: guardDial}
+	guardedProxy, dial := guardPrivateNetworks(proxy, (&net.Dialer{}).DialContext, guardedDialer.DialContext)
+	client := &http.Client{Transport: &http.Transport{Proxy: guardedProxy, DialContext: dial}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	doc, _, err := testSettings(t, nil).enhancedFetchAndParse(ctx, client, "http://93.184.216.34/", URLMetadata{})
+	if err != nil || doc.Title != "Proxied 93.184.216.34" {
+		t.Errorf("public target through a loopback proxy: title %q, err %v", doc.Title, err)
+	}
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data/", "http://[::1]:8080/", proxyServer.URL + "/"} {
+		_, _, err := testSettings(t, nil).enhancedFetchAndParse(ctx, client, target, URLMetadata{})
+		if !errors.Is(err, errPrivateAddress) {
+			t.Errorf("%s: err = %v, want it refused", target, err)
+		}
+	}
+}
+
+func TestRequestAddress(t *testing.T) {
+	for raw, want := range map[string]string{
+		"http://proxy.internal":       "proxy.internal:80",
+		"https://proxy.internal":      "proxy.internal:443",
+		"socks5://127.0.0.1":          "127.0.0.1:1080",
+		"http://127.0.0.1:3128":       "127.0.0.1:3128",
+		"http://[::1]:3128/some/path": "[::1]:3128",
+	} {
+		u, _ := url.Parse(raw)
+		if got := requestAddress(u); got != want {
+			t.Errorf("requestAddress(%s) = %s, want %s", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+const defaultStripSelectors = "script, style, noscript, nav, footer, header, aside, .advertisement, .ad, .sidebar"
+
+// elementFilter is the compiled -strip-selectors and -keep-selectors; nil
+// matchers match nothing
+type elementFilter struct {
+	strip, keep goquery.Matcher
+}
+
+// parseElementFilter compiles the strip and keep selectors, failing on
+// any the parser can't read
+func parseElementFilter(strip, keep string) (elementFilter, error) {
+	var f elementFilter
+	var err error
+	if f.strip, err = compileSelectors(strip); err != nil {
+		return f, fmt.Errorf("-strip-selectors %q: %w", strip, err)
+	}
+	if f.keep, err = compileSelectors(keep); err != nil {
+		return f, fmt.Errorf("-keep-selectors %q: %w", keep, err)
+	}
+	return f, nil
+}
+
+// compileSelectors compiles a comma-separated selector list, returning a
+// nil matcher for an empty one
+func compileSelectors(spec string) (goquery.Matcher, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	sel, err := cascadia.Compile(spec)
+	if err != nil {
+		return nil, err
+	}
+	return sel, nil
+}
+
+// apply removes the elements matching strip from d, except those matching
+// keep. Stripped elements inside a kept one, such as its scripts, still go.
+func (f elementFilter) apply(d *goquery.Document) {
+	if f.strip == nil {
+		return
+	}
+	stripped := d.FindMatcher(f.strip)
+	if f.keep != nil {
+		stripped = stripped.NotMatcher(f.keep)
+	}
+	stripped.Remove()
+}
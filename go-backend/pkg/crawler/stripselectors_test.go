@@ -0,0 +1,42 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestStripSelectors(t *testing.T) {
+	html := `<html><body>
+		<p>The article itself.</p>
+		<div class="comments">First!</div>
+		<aside>A related fact.</aside>
+	</body></html>`
+	extract := func(strip, keep string) string {
+		t.Helper()
+		cfg := testSettings(t, func(c *Config) { c.StripSelectors, c.KeepSelectors = strip, keep })
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatalf("Failed to parse HTML: %v", err)
+		}
+		return cleanText(cfg.extractText(doc))
+	}
+
+	if got := extract(defaultStripSelectors, ""); got != "The article itself. First!" {
+		t.Errorf("default selectors extracted %q", got)
+	}
+	if got := extract(defaultStripSelectors+", .comments", ""); strings.Contains(got, "First!") {
+		t.Errorf("stripping .comments extracted %q", got)
+	}
+	if got := extract(defaultStripSelectors, "aside"); !strings.Contains(got, "A related fact.") {
+		t.Errorf("keeping aside extracted %q", got)
+	}
+
+	if _, err := parseElementFilter("div[", ""); err == nil {
+		t.Error("invalid -strip-selectors parsed")
+	}
+	if _, err := parseElementFilter(defaultStripSelectors, "::nope("); err == nil {
+		t.Error("invalid -keep-selectors parsed")
+	}
+}
@@ -0,0 +1,172 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	nethtml "golang.org/x/net/html"
+)
+
+// templateBlocks are the elements fingerprinted as template candidates
+const templateBlocks = "div, section, aside, nav, header, footer, ul, ol, table, form, p"
+
+// templateLearner learns each host's page template from its first pages:
+// subtrees found at the same tag path with the same text on at least half
+// of them. Later pages from the host have those subtrees removed before
+// content extraction, so CleanText holds only what's unique to the page.
+// Fingerprints per host are capped, and dropped once the template is
+// learned, leaving only the template's.
+type templateLearner struct {
+	mu       sync.Mutex
+	pages    int
+	capacity int
+	hosts    map[string]*hostTemplate
+}
+
+type hostTemplate struct {
+	learned  int
+	counts   map[uint64]int  // pages each fingerprint was seen on, while learning
+	template map[uint64]bool // recurring fingerprints, once learned
+}
+
+func newTemplateLearner(pages, capacity int) *templateLearner {
+	return &templateLearner{
+		pages:    pages,
+		capacity: capacity,
+		hosts:    make(map[string]*hostTemplate),
+	}
+}
+
+// learn records a page's fingerprints against its host, returning the
+// host's template once it has been learned from earlier pages, or nil
+// while the host is still being learned
+func (l *templateLearner) learn(host string, fingerprints map[uint64]bool) map[uint64]bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := l.hosts[host]
+	if h == nil {
+		h = &hostTemplate{counts: make(map[uint64]int)}
+		l.hosts[host] = h
+	}
+	if h.template != nil {
+		return h.template
+	}
+	for fp := range fingerprints {
+		if _, ok := h.counts[fp]; ok || len(h.counts) < l.capacity {
+			h.counts[fp]++
+		}
+	}
+	if h.learned++; h.learned == l.pages {
+		recurring := max(2, (l.pages+1)/2)
+		h.template = make(map[uint64]bool)
+		for fp, n := range h.counts {
+			if n >= recurring {
+				h.template[fp] = true
+			}
+		}
+		h.counts = nil
+	}
+	return nil
+}
+
+// strip learns from page and, once its host's template is known, removes
+// the template's subtrees from it. It reports how many were removed; a
+// nil learner removes nothing. A page that would be left without text is
+// kept whole, as it's likely a duplicate rather than all template.
+func (l *templateLearner) strip(pageURL string, page *goquery.Document) int {
+	if l == nil || l.pages <= 0 {
+		return 0
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return 0
+	}
+	blocks := page.Find(templateBlocks)
+	fingerprints := make(map[uint64]bool, blocks.Length())
+	byNode := make(map[*nethtml.Node]uint64, blocks.Length())
+	blocks.Each(func(_ int, s *goquery.Selection) {
+		if fp, ok := subtreeFingerprint(s); ok {
+			fingerprints[fp] = true
+			byNode[s.Get(0)] = fp
+		}
+	})
+	template := l.learn(strings.ToLower(u.Host), fingerprints)
+	if len(template) == 0 {
+		return 0
+	}
+
+	// Only outermost template subtrees are removed; their text is summed
+	// to make sure something of the page survives
+	var remove []*goquery.Selection
+	removedText := 0
+	blocks.Each(func(_ int, s *goquery.Selection) {
+		if !template[byNode[s.Get(0)]] {
+			return
+		}
+		for _, r := range remove {
+			if r.Get(0) == s.Get(0) || nodeContains(r.Get(0), s.Get(0)) {
+				return
+			}
+		}
+		remove = append(remove, s)
+		removedText += len(strings.Join(strings.Fields(s.Text()), " "))
+	})
+	if removedText >= len(strings.Join(strings.Fields(page.Find("body").Text()), " ")) {
+		return 0
+	}
+	for _, s := range remove {
+		s.Remove()
+	}
+	return len(remove)
+}
+
+// subtreeFingerprint hashes an element's tag path with its normalized
+// text, so the same block in the same place matches across pages. Blocks
+// without text aren't fingerprinted.
+func subtreeFingerprint(s *goquery.Selection) (uint64, bool) {
+	text := strings.ToLower(strings.Join(strings.Fields(s.Text()), " "))
+	if text == "" {
+		return 0, false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(tagPath(s.Get(0))))
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return h.Sum64(), true
+}
+
+// tagPath names an element by its ancestry, with ids and classes, as in
+// "html>body>div#side.widget>ul"
+func tagPath(n *nethtml.Node) string {
+	var steps []string
+	for ; n != nil && n.Type == nethtml.ElementNode; n = n.Parent {
+		step := n.Data
+		for _, attr := range n.Attr {
+			switch attr.Key {
+			case "id":
+				step += "#" + attr.Val
+			case "class":
+				step += "." + strings.Join(strings.Fields(attr.Val), ".")
+			}
+		}
+		steps = append(steps, step)
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return strings.Join(steps, ">")
+}
+
+// nodeContains reports whether n is a descendant of ancestor
+func nodeContains(ancestor, n *nethtml.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/time/rate"
+)
+
+const templateSidebar = "Popular dreams: flying over oceans and falling through clocks"
+
+// TestTemplateLearning crawls same-template pages with -template-pages=3
+// and checks the shared sidebar and menu, in plain divs the static
+// selectors don't catch, stay in the first three pages' content but are
+// left out of later ones, whose own text is kept.
+func TestTemplateLearning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		name, links := "post "+path.Base(r.URL.Path), ""
+		if r.URL.Path == "/" {
+			name = "the index"
+			for i := 1; i <= 4; i++ {
+				links += fmt.Sprintf(`<a href="/post/%d">Post %d</a>`, i, i)
+			}
+		}
+		fmt.Fprintf(w, `<html><head><title>%s</title></head><body>
+			<div id="menu"><ul><li>Archive</li><li>About the dream journal</li></ul></div>
+			<div id="page">
+				<div class="story"><p>This story is only told on %s.</p>%s</div>
+				<div id="side"><p>%s</p></div>
+			</div></body></html>`, r.URL.Path, name, links, templateSidebar)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	hostMap := map[string]*hostPolicies{
+		serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)},
+	}
+	urlQueue := make(chan URLWithMetadata, 10)
+	out := make(chan Document, 10)
+	seen := mapSeen{}
+	c := &Crawler{client: server.Client(), cfg: testSettings(t, nil), hostMap: hostMap, seen: &seen, stats: &CrawlerStats{},
+		templates: newTemplateLearner(3, 100)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.enhancedWorker(ctx, 0, urlQueue, urlQueue, out)
+
+	urlQueue <- URLWithMetadata{URL: server.URL + "/"}
+
+	// One worker, so pages arrive in crawl order
+	paths := []string{"/", "/post/1", "/post/2", "/post/3", "/post/4"}
+	for i, p := range paths {
+		var doc Document
+		select {
+		case doc = <-out:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got %d of %d documents", i, len(paths))
+		}
+		if doc.URL != server.URL+p {
+			t.Fatalf("document %d is %s, want %s", i, doc.URL, p)
+		}
+		learned := i >= 3
+		if got := strings.Contains(doc.CleanText, templateSidebar); got == learned {
+			t.Errorf("%s: sidebar in content = %v, want %v", p, got, !learned)
+		}
+		if got := strings.Contains(doc.CleanText, "About the dream journal"); got == learned {
+			t.Errorf("%s: menu in content = %v, want %v", p, got, !learned)
+		}
+		own := "only told on post " + path.Base(p)
+		if p == "/" {
+			own = "only told on the index"
+		}
+		if !strings.Contains(doc.CleanText, own) {
+			t.Errorf("%s: own text %q missing from %q", p, own, doc.CleanText)
+		}
+	}
+}
+
+// TestTemplateLearnerBounded checks the fingerprints remembered while a
+// host is learned are capped, and that a page made only of the template
+// is kept whole.
+func TestTemplateLearnerBounded(t *testing.T) {
+	l := newTemplateLearner(3, 3)
+	page := func(body string) *goquery.Document {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + body + "</body></html>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+	l.strip("https://example.com/1", page(`<p>one</p><p>two</p>`))
+	l.strip("https://example.com/2", page(`<p>one</p><p>two</p><p>three</p><p>four</p><p>five</p>`))
+	if n := len(l.hosts["example.com"].counts); n != 3 {
+		t.Errorf("remembered %d fingerprints, want the cap of 3", n)
+	}
+	l.strip("https://example.com/3", page(`<p>one</p><p>two</p><p>other</p>`))
+	if h := l.hosts["example.com"]; h.counts != nil || len(h.template) != 2 {
+		t.Errorf("learned template of %d fingerprints with counts %v, want 2 and none", len(h.template), h.counts)
+	}
+	if n := l.strip("https://example.com/4", page(`<p>one</p><p>two</p>`)); n != 0 {
+		t.Errorf("stripped %d blocks from an all-template page, want 0", n)
+	}
+	if n := l.strip("https://example.com/5", page(`<p>one</p><p>two</p><p>new</p>`)); n != 2 {
+		t.Errorf("stripped %d blocks, want 2", n)
+	}
+}
@@ -0,0 +1,62 @@
+package crawler
+
+import (
+	"strings"
+	"time"
+)
+
+// Stages timed outside the processor pipeline. Fetch covers the request
+// and reading the body as sent; decompress is the time spent inflating a
+// gzipped body and extracting a PDF's text, and zero for other pages.
+const (
+	stageFetch      = "fetch"
+	stageDecompress = "decompress"
+	stageParse      = "parse"
+)
+
+// stageTiming sums one stage's microseconds across documents
+type stageTiming struct {
+	total, count int64
+}
+
+// timeStage adds the microseconds since start to stage. Microseconds
+// rather than milliseconds, since link and media extraction usually finish
+// well inside one. Without -timing the document has no map to fill.
+func (d *Document) timeStage(stage string, start time.Time) {
+	if d.Timings == nil {
+		return
+	}
+	d.Timings[stage] += time.Since(start).Microseconds()
+}
+
+// stageName is the timing key for a pipeline processor, named for what it
+// extracts so "chunks" is reported as extract_chunks
+func stageName(processor string) string {
+	name := strings.ReplaceAll(processor, "-", "_")
+	switch processor {
+	case "metadata", "chunks", "links", "media":
+		return "extract_" + name
+	}
+	return name
+}
+
+// RecordTimings adds a document's stage timings to the running averages
+func (s *CrawlerStats) RecordTimings(timings map[string]int64) {
+	if len(timings) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timings == nil {
+		s.timings = make(map[string]*stageTiming)
+	}
+	for stage, us := range timings {
+		t := s.timings[stage]
+		if t == nil {
+			t = &stageTiming{}
+			s.timings[stage] = t
+		}
+		t.total += us
+		t.count++
+	}
+}
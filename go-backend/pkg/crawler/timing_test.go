@@ -0,0 +1,58 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestTimings checks -timing records every stage on the document with a
+// non-negative duration, that the stats average them, and that documents
+// carry no timings without the flag.
+func TestTimings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Timed</title></head><body>
+			<p>A page long enough to chunk, with a <a href="/next">link</a> and some words.</p></body></html>`)
+	}))
+	defer server.Close()
+
+	cfg := testSettings(t, func(c *Config) { c.Timing = true })
+	doc, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stage := range []string{"fetch", "decompress", "parse", "extract_chunks", "extract_links", "dream_hints"} {
+		us, ok := doc.Timings[stage]
+		if !ok {
+			t.Errorf("no %s timing in %v", stage, doc.Timings)
+		} else if us < 0 {
+			t.Errorf("%s took %dµs", stage, us)
+		}
+	}
+
+	// A stage well under a millisecond still registers
+	quick := Document{Timings: map[string]int64{}}
+	quick.timeStage("quick", time.Now().Add(-300*time.Microsecond))
+	if us := quick.Timings["quick"]; us < 300 || us >= 1000*1000 {
+		t.Errorf("a 300µs stage recorded %dµs", us)
+	}
+
+	stats := &CrawlerStats{}
+	stats.RecordTimings(map[string]int64{"fetch": 10000, "parse": 500})
+	stats.RecordTimings(map[string]int64{"fetch": 30000})
+	if got := stats.Snapshot().Timings; got["fetch"] != 20 || got["parse"] != 0.5 {
+		t.Errorf("averages = %v, want fetch 20ms and parse 0.5ms", got)
+	}
+
+	doc, _, err = testSettings(t, nil).enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Timings != nil {
+		t.Errorf("timings recorded without -timing: %v", doc.Timings)
+	}
+}
@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// newHTTPClient builds the crawler's HTTP client from the config
+func (cfg *settings) newHTTPClient() (*http.Client, error) {
+	tlsConfig, err := cfg.buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:       cfg.Timeout,
+		Transport:     cfg.newTransport(tlsConfig),
+		CheckRedirect: checkRedirect,
+	}, nil
+}
+
+// newTransport builds the connection-level transport from the tuning
+// settings
+func (cfg *settings) newTransport(tlsConfig *tls.Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	guardedDialer := *dialer
+	guardedDialer.Control = guardDial
+	direct, guarded := dialFunc(dialer.DialContext), dialFunc(guardedDialer.DialContext)
+	if cfg.DNSCacheTTL > 0 {
+		cache := newDNSCache(net.DefaultResolver, cfg.DNSCacheTTL, cfg.DNSNegativeTTL)
+		direct, guarded = cache.dialContext(direct), cache.dialContext(guarded)
+	}
+	proxy, dial := proxyFunc(http.ProxyFromEnvironment), direct
+	if !cfg.AllowPrivateNetworks {
+		proxy, dial = guardPrivateNetworks(proxy, direct, guarded)
+	}
+
+	transport := &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dial,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		DisableKeepAlives:   cfg.DisableKeepAlives,
+		TLSHandshakeTimeout: cfg.TLSHandshakeTimeout,
+		TLSClientConfig:     tlsConfig,
+		// A custom dialer or TLS config turns off HTTP/2 unless asked for
+		ForceAttemptHTTP2: !cfg.ForceHTTP1,
+	}
+	if cfg.ForceHTTP1 {
+		// A non-nil, empty map disables the HTTP/2 upgrade entirely
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// workerClients copies base once per worker. Each copy gets a clone of
+// base's transport, so TLS, proxy and dial settings match but idle pools
+// are independent; the cookie jar, redirect policy and timeout are shared
+// so a login session covers every worker. A custom RoundTripper can't be
+// cloned and stays shared.
+func workerClients(base *http.Client, n int) []*http.Client {
+	clients := make([]*http.Client, n)
+	for i := range clients {
+		client := *base
+		if t, ok := base.Transport.(*http.Transport); ok {
+			client.Transport = t.Clone()
+		} else if base.Transport == nil {
+			client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+		}
+		clients[i] = &client
+	}
+	return clients
+}
+
+// buildTLSConfig loads and validates the CA bundle and client certificate
+// named by the TLS settings. It returns nil when none is set so the
+// transport keeps Go's defaults.
+func (cfg *settings) buildTLSConfig() (*tls.Config, error) {
+	if cfg.CAFile == "" && cfg.ClientCert == "" && cfg.ClientKey == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if cfg.CAFile != "" {
+		pemData, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("CA file %s contains no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		return nil, errors.New("-client-cert and -client-key must be set together")
+	}
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.InsecureSkipVerify {
+		log.Println("WARNING: -insecure-skip-verify is set, TLS certificates will NOT be verified")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	return tlsConfig, nil
+}
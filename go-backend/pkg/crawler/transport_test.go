@@ -0,0 +1,173 @@
+package crawler
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestTLSCAFile verifies that a crawl of a self-signed server fails by
+// default and succeeds once the server's certificate is trusted via CAFile.
+func TestTLSCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Intranet</title></head><body><p>Internal page.</p></body></html>`)
+	}))
+	defer server.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caPath, caPEM, 0o600); err != nil {
+		t.Fatalf("WriteFile() returned an error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cfg := testSettings(t, func(c *Config) { c.AllowPrivateNetworks = true }) // the test server is on loopback
+	client, err := cfg.newHTTPClient()
+	if err != nil {
+		t.Fatalf("newHTTPClient() returned an error: %v", err)
+	}
+	if _, _, err := cfg.enhancedFetchAndParse(ctx, client, server.URL, URLMetadata{}); err == nil {
+		t.Fatal("expected a certificate error without CAFile")
+	}
+
+	cfg = testSettings(t, func(c *Config) { c.AllowPrivateNetworks, c.CAFile = true, caPath })
+	client, err = cfg.newHTTPClient()
+	if err != nil {
+		t.Fatalf("newHTTPClient() returned an error: %v", err)
+	}
+	doc, _, err := cfg.enhancedFetchAndParse(ctx, client, server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatalf("enhancedFetchAndParse() returned an error with CAFile: %v", err)
+	}
+	if doc.Title != "Intranet" {
+		t.Errorf("doc.Title = %q, want %q", doc.Title, "Intranet")
+	}
+}
+
+// TestTLSConfigValidation verifies that bad TLS settings are rejected at startup.
+func TestTLSConfigValidation(t *testing.T) {
+	badCA := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(badCA, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("WriteFile() returned an error: %v", err)
+	}
+	if _, err := testSettings(t, func(c *Config) { c.CAFile = badCA }).buildTLSConfig(); err == nil {
+		t.Error("expected an error for a CA file without certificates")
+	}
+
+	if _, err := testSettings(t, func(c *Config) { c.ClientCert = "client.pem" }).buildTLSConfig(); err == nil {
+		t.Error("expected an error for ClientCert without ClientKey")
+	}
+}
+
+// TestNewTransportSettings verifies the transport picks up the tuning settings.
+func TestNewTransportSettings(t *testing.T) {
+	transport := testSettings(t, nil).newTransport(nil)
+	if !transport.ForceAttemptHTTP2 || transport.TLSNextProto != nil {
+		t.Error("expected HTTP/2 to be attempted by default")
+	}
+
+	transport = testSettings(t, func(c *Config) {
+		c.MaxIdleConns = 7
+		c.MaxIdleConnsPerHost = 3
+		c.MaxConnsPerHost = 4
+		c.IdleConnTimeout = 5 * time.Second
+		c.TLSHandshakeTimeout = 2 * time.Second
+		c.DisableKeepAlives = true
+		c.ForceHTTP1 = true
+	}).newTransport(nil)
+	if transport.MaxIdleConns != 7 {
+		t.Errorf("MaxIdleConns = %d, want 7", transport.MaxIdleConns)
+	}
+	if transport.MaxIdleConnsPerHost != 3 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 3", transport.MaxIdleConnsPerHost)
+	}
+	if transport.MaxConnsPerHost != 4 {
+		t.Errorf("MaxConnsPerHost = %d, want 4", transport.MaxConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 5*time.Second {
+		t.Errorf("IdleConnTimeout = %v, want 5s", transport.IdleConnTimeout)
+	}
+	if transport.TLSHandshakeTimeout != 2*time.Second {
+		t.Errorf("TLSHandshakeTimeout = %v, want 2s", transport.TLSHandshakeTimeout)
+	}
+	if !transport.DisableKeepAlives {
+		t.Error("DisableKeepAlives = false, want true")
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Error("expected HTTP/2 to be disabled with ForceHTTP1")
+	}
+}
+
+// TestWorkerClients checks that per-worker clients are distinct, with
+// transports of their own configured exactly like the shared one.
+func TestWorkerClients(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) {
+		c.InsecureSkipVerify = true
+		c.MaxIdleConnsPerHost = 3
+	})
+	base, err := cfg.newHTTPClient()
+	if err != nil {
+		t.Fatalf("newHTTPClient: %v", err)
+	}
+	base.Jar, _ = cookiejar.New(nil)
+	baseTransport := base.Transport.(*http.Transport)
+
+	clients := workerClients(base, 3)
+	transports := map[*http.Transport]bool{baseTransport: true}
+	for i, client := range clients {
+		if client == base {
+			t.Fatalf("client %d is the shared client", i)
+		}
+		tr := client.Transport.(*http.Transport)
+		if transports[tr] {
+			t.Fatalf("client %d reuses another client's transport", i)
+		}
+		transports[tr] = true
+
+		if client.Jar != base.Jar || client.Timeout != base.Timeout {
+			t.Errorf("client %d: jar or timeout differs from the shared client", i)
+		}
+		if !tr.TLSClientConfig.InsecureSkipVerify || tr.MaxIdleConnsPerHost != 3 {
+			t.Errorf("client %d: transport missing the TLS or pool settings", i)
+		}
+		if reflect.ValueOf(tr.Proxy).Pointer() != reflect.ValueOf(baseTransport.Proxy).Pointer() {
+			t.Errorf("client %d: proxy function differs", i)
+		}
+	}
+}
+
+// TestClientFor checks that workers fall back to the shared client unless
+// per-worker clients were made.
+func TestClientFor(t *testing.T) {
+	shared := &http.Client{}
+	c := &Crawler{client: shared}
+	if c.clientFor(1) != shared {
+		t.Error("clientFor without per-worker clients should return the shared client")
+	}
+	c.workerClients = workerClients(shared, 2)
+	if c.clientFor(1) != c.workerClients[1] || c.clientFor(0) == c.clientFor(1) {
+		t.Error("clientFor should return each worker's own client")
+	}
+}
+
+// BenchmarkWorkerClients measures building clients for a large worker pool.
+func BenchmarkWorkerClients(b *testing.B) {
+	base, err := testSettings(b, nil).newHTTPClient()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		workerClients(base, 64)
+	}
+}
@@ -0,0 +1,37 @@
+package crawler
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/drawnparadox/web-crawler-that-dreams/go-backend/pkg/model"
+)
+
+// usageDirectives returns the noarchive and nosnippet directives of the
+// page's robots meta tags, in the order given
+func usageDirectives(doc *goquery.Document) []string {
+	var directives []string
+	doc.Find("meta[name]").Each(func(_ int, s *goquery.Selection) {
+		if !strings.EqualFold(s.AttrOr("name", ""), "robots") {
+			return
+		}
+		for _, directive := range strings.Split(s.AttrOr("content", ""), ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			if (directive == model.UsageNoArchive || directive == model.UsageNoSnippet) && !slices.Contains(directives, directive) {
+				directives = append(directives, directive)
+			}
+		}
+	})
+	return directives
+}
+
+// withholdContent drops what a noarchive or nosnippet page asks not to be
+// stored. Links, metadata and the content hash are kept, so the page is
+// still crawled through and its changes detected.
+func withholdContent(doc *Document) {
+	doc.Text = ""
+	doc.CleanText = ""
+	doc.Chunks = nil
+	doc.RawHTML = ""
+}
@@ -0,0 +1,50 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// TestUsageDirectives checks a nosnippet page's text, chunks and raw HTML
+// are left out of the document while its links are still extracted.
+func TestUsageDirectives(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Diary</title><meta name="ROBOTS" content="index, NoSnippet"></head><body>
+			<p>A private diary of recurring dreams about flying over the oceans at night.</p>
+			<p>Read the <a href="/next">next entry</a> too.</p></body></html>`)
+	}))
+	defer server.Close()
+
+	cfg := testSettings(t, func(c *Config) { c.StoreRawHTML = true })
+	doc, links, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/diary", URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc.Metadata.UsageDirectives, []string{"nosnippet"}) {
+		t.Errorf("usage directives = %q, want [nosnippet]", doc.Metadata.UsageDirectives)
+	}
+	if doc.Text != "" || doc.CleanText != "" || len(doc.Chunks) != 0 || doc.RawHTML != "" {
+		t.Errorf("nosnippet content kept: text %q, clean %q, %d chunks, %d bytes raw", doc.Text, doc.CleanText, len(doc.Chunks), len(doc.RawHTML))
+	}
+	if doc.Title != "Diary" || doc.ContentHash == "" {
+		t.Errorf("title %q, hash %q; want both kept", doc.Title, doc.ContentHash)
+	}
+	if len(links) != 1 || links[0].URL != server.URL+"/next" {
+		t.Errorf("links = %+v, want the next entry", links)
+	}
+
+	// Without the policy the page is stored like any other
+	cfg = testSettings(t, func(c *Config) { c.StoreRawHTML, c.RespectUsageDirectives = true, false })
+	doc, _, err = cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL+"/diary", URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata.UsageDirectives != nil || doc.CleanText == "" || len(doc.Chunks) == 0 {
+		t.Errorf("with -respect-usage-directives=false: directives %q, clean %q, %d chunks", doc.Metadata.UsageDirectives, doc.CleanText, len(doc.Chunks))
+	}
+}
@@ -0,0 +1,92 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+)
+
+// variantHostPrefixes are subdomains serving mobile or AMP copies
+var variantHostPrefixes = []string{"m.", "mobile.", "amp."}
+
+// desktopVariant rewrites an AMP or mobile URL to the desktop URL it
+// copies, reporting whether it changed anything. Hosts are expected
+// lowercased.
+func desktopVariant(u *url.URL) bool {
+	changed := false
+	if host := desktopHost(u.Host); host != u.Host {
+		u.Host, changed = host, true
+	}
+
+	if p := u.EscapedPath(); p != "" {
+		segments := strings.Split(p, "/")
+		kept := segments[:0]
+		for _, segment := range segments {
+			switch {
+			case strings.EqualFold(segment, "amp"):
+				changed = true
+				continue
+			case strings.Contains(strings.ToLower(segment), ".amp"):
+				// story.amp.html -> story.html, story.amp -> story
+				lower := strings.ToLower(segment)
+				if i := strings.Index(lower, ".amp"); i > 0 && (len(lower) == i+4 || lower[i+4] == '.') {
+					segment, changed = segment[:i]+segment[i+4:], true
+				}
+			}
+			kept = append(kept, segment)
+		}
+		p = strings.Join(kept, "/")
+		if p == "" {
+			p = "/"
+		}
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			u.Path, u.RawPath = unescaped, p
+		}
+	}
+
+	if u.RawQuery != "" {
+		pairs := strings.Split(u.RawQuery, "&")
+		kept := pairs[:0]
+		for _, pair := range pairs {
+			name, value, _ := strings.Cut(pair, "=")
+			if strings.EqualFold(name, "amp") || strings.EqualFold(name, "outputType") && strings.EqualFold(value, "amp") {
+				changed = true
+				continue
+			}
+			kept = append(kept, pair)
+		}
+		u.RawQuery = strings.Join(kept, "&")
+	}
+	return changed
+}
+
+// desktopHost strips a mobile or AMP subdomain, leaving at least a
+// registrable-looking name
+func desktopHost(host string) string {
+	for _, prefix := range variantHostPrefixes {
+		if rest, ok := strings.CutPrefix(host, prefix); ok && strings.Contains(rest, ".") {
+			return rest
+		}
+	}
+	return host
+}
+
+// isVariant reports whether raw looks like an AMP or mobile URL
+func isVariant(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	u.Host = strings.ToLower(u.Host)
+	return desktopVariant(u)
+}
+
+// crawledCanonical marks an AMP or mobile page's canonical page as seen
+// under -canonicalize-variants, so it isn't fetched again, and reports
+// whether it already was
+func (c *Crawler) crawledCanonical(pageURL, canonical string) bool {
+	if !c.cfg.CanonicalizeVariants || canonical == "" || !isVariant(pageURL) {
+		return false
+	}
+	key := c.cfg.canonicalURL(canonical)
+	return key != c.cfg.canonicalURL(pageURL) && c.seen.visit(key)
+}
@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestCanonicalURLVariants(t *testing.T) {
+	variants := map[string]string{
+		"https://m.example.com/news/story":                 "https://example.com/news/story",
+		"https://example.com/news/amp/story":               "https://example.com/news/story",
+		"https://example.com/news/story/amp/":              "https://example.com/news/story/",
+		"https://example.com/news/story.amp.html":          "https://example.com/news/story.html",
+		"https://example.com/news/story?amp=1&id=7":        "https://example.com/news/story?id=7",
+		"https://example.com/news/story?outputType=amp":    "https://example.com/news/story",
+		"https://amp.example.com/news/story?amp":           "https://example.com/news/story",
+		"https://example.com/news/ampersands.html?amp=yes": "https://example.com/news/ampersands.html",
+	}
+
+	cfg := testSettings(t, nil)
+	for variant, desktop := range variants {
+		if cfg.canonicalURL(variant) == cfg.canonicalURL(desktop) {
+			t.Errorf("without CanonicalizeVariants %s dedups against %s", variant, desktop)
+		}
+	}
+	cfg = testSettings(t, func(c *Config) { c.CanonicalizeVariants = true })
+	for variant, desktop := range variants {
+		if got := cfg.canonicalURL(variant); got != desktop {
+			t.Errorf("canonicalURL(%q) = %q, want %q", variant, got, desktop)
+		}
+	}
+	if got := cfg.canonicalURL("https://m.com/amplifier"); got != "https://m.com/amplifier" {
+		t.Errorf("canonicalURL of a non-variant = %q", got)
+	}
+}
+
+// TestVariantDedupsAgainstDeclaredCanonical crawls an AMP page whose
+// rel="canonical" desktop page has a URL no pattern maps it to, and
+// checks the two dedup whichever is crawled first.
+func TestVariantDedupsAgainstDeclaredCanonical(t *testing.T) {
+	cfg := testSettings(t, func(c *Config) { c.CanonicalizeVariants = true })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/amp/dreams":
+			fmt.Fprint(w, `<html><head><link rel="canonical" href="/articles/dreams-123"></head>
+				<body><p>Why we dream, the fast version.</p></body></html>`)
+		case "/articles/dreams-123":
+			fmt.Fprint(w, `<html><head><link rel="canonical" href="/articles/dreams-123"></head>
+				<body><p>Why we dream.</p></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, order := range [][]string{{"/amp/dreams", "/articles/dreams-123"}, {"/articles/dreams-123", "/amp/dreams"}} {
+		serverURL, _ := url.Parse(server.URL)
+		seen := mapSeen{}
+		stats := &CrawlerStats{}
+		c := &Crawler{
+			cfg:     cfg,
+			client:  server.Client(),
+			hostMap: map[string]*hostPolicies{serverURL.Host: {lim: rate.NewLimiter(rate.Inf, 1)}},
+			seen:    &seen,
+			stats:   stats,
+		}
+		queue := make(chan URLWithMetadata, 10)
+		out := make(chan Document, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.enhancedWorker(ctx, 0, queue, queue, out)
+		}()
+
+		queue <- URLWithMetadata{URL: server.URL + order[0]}
+		waitFor(t, "the first page", func() bool { return len(out) == 1 })
+		queue <- URLWithMetadata{URL: server.URL + order[1]}
+		waitFor(t, "the second page", func() bool { return stats.Snapshot().PagesProcessed+stats.Snapshot().SkippedSeen == 2 })
+		cancel()
+		<-done // it reads -canonicalize-variants, restored on return
+
+		if doc := <-out; doc.URL != server.URL+order[0] || len(out) != 0 {
+			t.Errorf("crawling %v emitted %s and %d more, want only the first", order, doc.URL, len(out))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"crypto/md5"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	defaultVolatileSelectors = "time, .timestamp, .date, .views, .view-count, .counter"
+	defaultVolatilePattern   = `(?i)\b\d{1,2}:\d{2}(:\d{2})?\s*(am|pm)?\b|\b\d[\d,.]*\s*(views|comments|likes|shares|reads)\b|\b\d+\s+(seconds?|minutes?|hours?|days?)\s+ago\b`
+)
+
+// volatileRegions is what stable_hash leaves out of a page; nil fields
+// leave nothing out
+type volatileRegions struct {
+	selectors goquery.Matcher
+	pattern   *regexp.Regexp
+}
+
+// parseVolatileRegions compiles the volatile selectors and pattern,
+// failing on either the parser can't read
+func parseVolatileRegions(selectors, pattern string) (volatileRegions, error) {
+	var v volatileRegions
+	var err error
+	if v.selectors, err = compileSelectors(selectors); err != nil {
+		return v, fmt.Errorf("-volatile-selectors %q: %w", selectors, err)
+	}
+	if pattern != "" {
+		if v.pattern, err = regexp.Compile(pattern); err != nil {
+			return v, fmt.Errorf("-volatile-pattern: %w", err)
+		}
+	}
+	return v, nil
+}
+
+// stableHash is the hash of a page's clean text with its volatile regions
+// left out, so it holds still across loads that only move a clock or a
+// counter. d is the page after extractText, which is repeated on a copy
+// without the volatile elements when there are any; degraded pages, whose
+// text didn't come from d, only have the pattern left out.
+func (cfg *settings) stableHash(d *goquery.Document, doc Document) string {
+	v := cfg.volatile
+	text := doc.CleanText
+	if v.selectors != nil && doc.Metadata.Degraded == "" && d.FindMatcher(v.selectors).Length() > 0 {
+		stable := goquery.NewDocumentFromNode(d.Selection.Clone().Get(0))
+		stable.FindMatcher(v.selectors).Remove()
+		text = cleanText(cfg.extractText(stable))
+	}
+	if v.pattern != nil {
+		text = strings.Join(strings.Fields(v.pattern.ReplaceAllString(text, " ")), " ")
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(text)))
+}
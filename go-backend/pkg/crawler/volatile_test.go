@@ -0,0 +1,46 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestStableHash fetches a page twice, differing only in its timestamp,
+// and checks the stable hash holds while the raw hash moves.
+func TestStableHash(t *testing.T) {
+	loads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loads++
+		fmt.Fprintf(w, `<html><head><title>Night log</title></head><body>
+			<p>The tide came in twice tonight and the lighthouse keeper wrote it down.</p>
+			<span class="timestamp">Rendered in %d ms at load %d</span>
+		</body></html>`, loads*7, loads)
+	}))
+	defer server.Close()
+
+	cfg := testSettings(t, nil)
+	first, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := cfg.enhancedFetchAndParse(context.Background(), server.Client(), server.URL, URLMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ContentHash == second.ContentHash {
+		t.Errorf("raw hash %s unchanged though the timestamp moved", first.ContentHash)
+	}
+	if first.StableHash == "" || first.StableHash != second.StableHash {
+		t.Errorf("stable hashes %q and %q differ, want equal", first.StableHash, second.StableHash)
+	}
+
+	if _, err := parseVolatileRegions("span[", ""); err == nil {
+		t.Error("invalid -volatile-selectors parsed")
+	}
+	if _, err := parseVolatileRegions("", "(unclosed"); err == nil {
+		t.Error("invalid -volatile-pattern parsed")
+	}
+}
@@ -0,0 +1,677 @@
+package model
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DocumentAvroSchema is the Avro schema of Document, registered with the
+// schema registry when one is configured. Fields are encoded in this
+// order by avroDocument; keep the two in step.
+const DocumentAvroSchema = `{
+  "type": "record", "name": "Document", "namespace": "dreamcrawler",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "url", "type": "string"},
+    {"name": "title", "type": "string"},
+    {"name": "text", "type": "string"},
+    {"name": "clean_text", "type": "string"},
+    {"name": "fetched_at", "type": ["null", {"type": "long", "logicalType": "timestamp-nanos"}]},
+    {"name": "status", "type": "int"},
+    {"name": "content_hash", "type": "string"},
+    {"name": "metadata", "type": {
+      "type": "record", "name": "DocumentMetadata",
+      "fields": [
+        {"name": "domain", "type": "string"},
+        {"name": "language", "type": "string"},
+        {"name": "word_count", "type": "int"},
+        {"name": "author", "type": "string"},
+        {"name": "published_at", "type": ["null", {"type": "long", "logicalType": "timestamp-nanos"}]},
+        {"name": "tags", "type": {"type": "array", "items": "string"}},
+        {"name": "category", "type": "string"},
+        {"name": "headers", "type": {"type": "map", "values": "string"}},
+        {"name": "content_type", "type": "string"},
+        {"name": "size", "type": "long"},
+        {"name": "content_length", "type": "long"},
+        {"name": "soft_404", "type": "boolean"},
+        {"name": "boilerplate", "type": "boolean"},
+        {"name": "link_density", "type": "double"},
+        {"name": "analysis_profile", "type": "string"},
+        {"name": "reading_time_sec", "type": "int"},
+        {"name": "readability_score", "type": "double"},
+        {"name": "degraded", "type": "string", "default": ""},
+        {"name": "microdata", "type": {"type": "map", "values": {"type": "array", "items": {
+          "type": "record", "name": "MicrodataItem",
+          "fields": [
+            {"name": "type", "type": "string"},
+            {"name": "properties", "type": {"type": "map", "values": {"type": "array", "items": "string"}}},
+            {"name": "items", "type": {"type": "map", "values": {"type": "array", "items": "MicrodataItem"}}}
+          ]}}}, "default": {}},
+        {"name": "usage_directives", "type": {"type": "array", "items": "string"}, "default": []},
+        {"name": "authors", "type": {"type": "array", "items": "string"}, "default": []},
+        {"name": "author_source", "type": "string", "default": ""},
+        {"name": "canonical", "type": "string", "default": ""},
+        {"name": "canonical_source", "type": "string", "default": ""}
+      ]}},
+    {"name": "chunks", "type": {"type": "array", "items": {
+      "type": "record", "name": "ContentChunk",
+      "fields": [
+        {"name": "id", "type": "string"},
+        {"name": "type", "type": "string"},
+        {"name": "text", "type": "string"},
+        {"name": "position", "type": "int"},
+        {"name": "confidence", "type": "double"},
+        {"name": "keywords", "type": {"type": "array", "items": "string"}},
+        {"name": "sentiment", "type": "string"},
+        {"name": "entities", "type": {"type": "array", "items": "string"}},
+        {"name": "safe_html", "type": "string", "default": ""}
+      ]}}},
+    {"name": "outline", "type": {"type": "array", "items": {
+      "type": "record", "name": "OutlineNode",
+      "fields": [
+        {"name": "level", "type": "int"},
+        {"name": "text", "type": "string"},
+        {"name": "id", "type": "string"},
+        {"name": "children", "type": {"type": "array", "items": "OutlineNode"}}
+      ]}}},
+    {"name": "links", "type": {"type": "array", "items": {
+      "type": "record", "name": "ExtractedLink",
+      "fields": [
+        {"name": "url", "type": "string"},
+        {"name": "text", "type": "string"},
+        {"name": "type", "type": "string"},
+        {"name": "context", "type": "string"},
+        {"name": "priority", "type": "int"},
+        {"name": "attrs", "type": {"type": "map", "values": "string"}}
+      ]}}},
+    {"name": "alternates", "type": {"type": "map", "values": "string"}},
+    {"name": "next_page", "type": "string"},
+    {"name": "media", "type": {"type": "array", "items": {
+      "type": "record", "name": "MediaAsset",
+      "fields": [
+        {"name": "url", "type": "string"},
+        {"name": "type", "type": "string"},
+        {"name": "alt", "type": "string"},
+        {"name": "caption", "type": "string"},
+        {"name": "size", "type": "string"},
+        {"name": "format", "type": "string"},
+        {"name": "attrs", "type": {"type": "map", "values": "string"}},
+        {"name": "seen_before", "type": "boolean"}
+      ]}}},
+    {"name": "dream_hints", "type": {
+      "type": "record", "name": "DreamingHints",
+      "fields": [
+        {"name": "emotions", "type": {"type": "array", "items": "string"}},
+        {"name": "themes", "type": {"type": "array", "items": "string"}},
+        {"name": "motifs", "type": {"type": "array", "items": "string"}},
+        {"name": "tone", "type": "string"},
+        {"name": "complexity", "type": "double"},
+        {"name": "surrealism_potential", "type": "double"},
+        {"name": "visual_cues", "type": {"type": "array", "items": "string"}},
+        {"name": "audio_cues", "type": {"type": "array", "items": "string"}},
+        {"name": "color_palette", "type": {"type": "array", "items": "string"}},
+        {"name": "abstractness", "type": "double"},
+        {"name": "sentiment", "type": "string"},
+        {"name": "sentiment_score", "type": "double"}
+      ]}},
+    {"name": "embedding", "type": {"type": "array", "items": "double"}},
+    {"name": "hero_image", "type": ["null", "MediaAsset"], "default": null},
+    {"name": "timings_us", "type": {"type": "map", "values": "long"}, "default": {}},
+    {"name": "stable_hash", "type": "string", "default": ""}
+  ]
+}`
+
+// avroMagic starts every message framed for a schema registry, followed
+// by the schema ID as a big-endian uint32
+const avroMagic = 0
+
+var errAvroTruncated = errors.New("avro: truncated message")
+
+// avroSerializer encodes Documents as Avro binary with DocumentAvroSchema.
+// With a registry the schema is registered per topic subject on first use
+// and messages use the Confluent wire format.
+type avroSerializer struct {
+	registry *SchemaRegistry
+	current  sync.Map // schema ID -> whether it is DocumentAvroSchema
+}
+
+func (s *avroSerializer) Marshal(topic string, doc Document) ([]byte, error) {
+	var w avroWriter
+	if s.registry != nil {
+		id, err := s.registry.Register(SubjectName(topic), DocumentAvroSchema)
+		if err != nil {
+			return nil, err
+		}
+		w.buf = binary.BigEndian.AppendUint32([]byte{avroMagic}, uint32(id))
+	}
+	avroDocument(&w, doc)
+	return w.buf, nil
+}
+
+func (s *avroSerializer) Unmarshal(data []byte, doc *Document) error {
+	if s.registry != nil {
+		if len(data) < 5 || data[0] != avroMagic {
+			return errors.New("avro: message is missing the schema registry header")
+		}
+		if err := s.checkWriterSchema(int(binary.BigEndian.Uint32(data[1:5]))); err != nil {
+			return err
+		}
+		data = data[5:]
+	}
+	r := avroReader{buf: data}
+	*doc = avroReadDocument(&r)
+	if r.err == nil && len(r.buf) > 0 {
+		return fmt.Errorf("avro: %d trailing bytes", len(r.buf))
+	}
+	return r.err
+}
+
+func (s *avroSerializer) ContentType() string { return "avro/binary" }
+
+// checkWriterSchema rejects a message written with any schema but
+// DocumentAvroSchema. Avro binary can only be read with the schema that
+// wrote it, and only the current one is decoded here, so an older or
+// foreign schema is refused rather than misread. Lookup failures aren't
+// remembered, since the registry may just be unreachable.
+func (s *avroSerializer) checkWriterSchema(id int) error {
+	current, ok := s.current.Load(id)
+	if !ok {
+		schema, err := s.registry.Schema(id)
+		if err != nil {
+			return fmt.Errorf("avro: %w", err)
+		}
+		current = sameSchema(schema, DocumentAvroSchema)
+		s.current.Store(id, current)
+	}
+	if !current.(bool) {
+		return fmt.Errorf("avro: message written with schema %d, not the document schema this build reads", id)
+	}
+	return nil
+}
+
+// sameSchema reports whether two schemas are the same JSON, ignoring the
+// layout the registry may have normalized away
+func sameSchema(a, b string) bool {
+	var av, bv any
+	if json.Unmarshal([]byte(a), &av) != nil || json.Unmarshal([]byte(b), &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// avroWriter appends Avro binary values
+type avroWriter struct {
+	buf []byte
+}
+
+// long writes an int or long; binary.AppendVarint zig-zag encodes like Avro
+func (w *avroWriter) long(v int64) { w.buf = binary.AppendVarint(w.buf, v) }
+
+func (w *avroWriter) string(s string) {
+	w.long(int64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *avroWriter) boolean(b bool) {
+	if b {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *avroWriter) double(f float64) {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(f))
+}
+
+// array writes n items as a single block
+func (w *avroWriter) array(n int, item func(i int)) {
+	if n > 0 {
+		w.long(int64(n))
+		for i := 0; i < n; i++ {
+			item(i)
+		}
+	}
+	w.long(0)
+}
+
+func (w *avroWriter) strings(ss []string) {
+	w.array(len(ss), func(i int) { w.string(ss[i]) })
+}
+
+func (w *avroWriter) stringMap(m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w.array(len(keys), func(i int) {
+		w.string(keys[i])
+		w.string(m[keys[i]])
+	})
+}
+
+// time writes a ["null", timestamp-nanos] union; nil and the zero time
+// are null
+func (w *avroWriter) time(t *time.Time) {
+	if t == nil || t.IsZero() {
+		w.long(0)
+		return
+	}
+	w.long(1)
+	w.long(t.UnixNano())
+}
+
+// avroReader reads Avro binary values. The first error sticks: later
+// reads return zero values, so decoders check err once at the end.
+type avroReader struct {
+	buf []byte
+	err error
+}
+
+func (r *avroReader) long() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = errAvroTruncated
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *avroReader) int() int { return int(r.long()) }
+
+func (r *avroReader) string() string {
+	n := r.long()
+	if r.err != nil {
+		return ""
+	}
+	if n < 0 || n > int64(len(r.buf)) {
+		r.err = errAvroTruncated
+		return ""
+	}
+	s := string(r.buf[:n])
+	r.buf = r.buf[n:]
+	return s
+}
+
+func (r *avroReader) boolean() bool {
+	if r.err != nil {
+		return false
+	}
+	if len(r.buf) < 1 {
+		r.err = errAvroTruncated
+		return false
+	}
+	b := r.buf[0] != 0
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *avroReader) double() float64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 8 {
+		r.err = errAvroTruncated
+		return 0
+	}
+	f := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+	r.buf = r.buf[8:]
+	return f
+}
+
+// array reads blocks until the empty one, calling item for each element.
+// A negative count is followed by the block's size in bytes.
+func (r *avroReader) array(item func()) {
+	for r.err == nil {
+		n := r.long()
+		if n == 0 {
+			return
+		}
+		if n < 0 {
+			n = -n
+			r.long()
+		}
+		for ; n > 0 && r.err == nil; n-- {
+			item()
+		}
+	}
+}
+
+func (r *avroReader) strings() []string {
+	var ss []string
+	r.array(func() { ss = append(ss, r.string()) })
+	return ss
+}
+
+func (r *avroReader) stringMap() map[string]string {
+	var m map[string]string
+	r.array(func() {
+		if m == nil {
+			m = make(map[string]string)
+		}
+		k := r.string()
+		m[k] = r.string()
+	})
+	return m
+}
+
+func (r *avroReader) time() *time.Time {
+	switch branch := r.long(); {
+	case r.err != nil, branch == 0:
+		return nil
+	case branch != 1:
+		r.err = fmt.Errorf("avro: bad union branch %d", branch)
+		return nil
+	}
+	t := time.Unix(0, r.long()).UTC()
+	return &t
+}
+
+// Document and its parts, in DocumentAvroSchema field order
+
+func avroDocument(w *avroWriter, d Document) {
+	w.string(d.ID)
+	w.string(d.URL)
+	w.string(d.Title)
+	w.string(d.Text)
+	w.string(d.CleanText)
+	w.time(&d.FetchedAt)
+	w.long(int64(d.Status))
+	w.string(d.ContentHash)
+	avroMetadata(w, d.Metadata)
+	w.array(len(d.Chunks), func(i int) { avroChunk(w, d.Chunks[i]) })
+	w.array(len(d.Outline), func(i int) { avroOutlineNode(w, d.Outline[i]) })
+	w.array(len(d.Links), func(i int) { avroLink(w, d.Links[i]) })
+	w.stringMap(d.Alternates)
+	w.string(d.NextPage)
+	w.array(len(d.Media), func(i int) { avroMedia(w, d.Media[i]) })
+	avroDreamHints(w, d.DreamHints)
+	w.array(len(d.Embedding), func(i int) { w.double(d.Embedding[i]) })
+	if d.HeroImage == nil {
+		w.long(0)
+	} else {
+		w.long(1)
+		avroMedia(w, *d.HeroImage)
+	}
+	stages := sortedKeys(d.Timings)
+	w.array(len(stages), func(i int) {
+		w.string(stages[i])
+		w.long(d.Timings[stages[i]])
+	})
+	w.string(d.StableHash)
+}
+
+func avroReadDocument(r *avroReader) Document {
+	var d Document
+	d.ID = r.string()
+	d.URL = r.string()
+	d.Title = r.string()
+	d.Text = r.string()
+	d.CleanText = r.string()
+	if t := r.time(); t != nil {
+		d.FetchedAt = *t
+	}
+	d.Status = r.int()
+	d.ContentHash = r.string()
+	d.Metadata = avroReadMetadata(r)
+	r.array(func() { d.Chunks = append(d.Chunks, avroReadChunk(r)) })
+	r.array(func() { d.Outline = append(d.Outline, avroReadOutlineNode(r)) })
+	r.array(func() { d.Links = append(d.Links, avroReadLink(r)) })
+	d.Alternates = r.stringMap()
+	d.NextPage = r.string()
+	r.array(func() { d.Media = append(d.Media, avroReadMedia(r)) })
+	d.DreamHints = avroReadDreamHints(r)
+	r.array(func() { d.Embedding = append(d.Embedding, r.double()) })
+	switch branch := r.long(); {
+	case r.err != nil, branch == 0:
+	case branch == 1:
+		hero := avroReadMedia(r)
+		d.HeroImage = &hero
+	default:
+		r.err = fmt.Errorf("avro: bad union branch %d", branch)
+	}
+	r.array(func() {
+		if d.Timings == nil {
+			d.Timings = make(map[string]int64)
+		}
+		stage := r.string()
+		d.Timings[stage] = r.long()
+	})
+	d.StableHash = r.string()
+	return d
+}
+
+func avroMetadata(w *avroWriter, m DocumentMetadata) {
+	w.string(m.Domain)
+	w.string(m.Language)
+	w.long(int64(m.WordCount))
+	w.string(m.Author)
+	w.time(m.PublishedAt)
+	w.strings(m.Tags)
+	w.string(m.Category)
+	w.stringMap(m.Headers)
+	w.string(m.ContentType)
+	w.long(m.Size)
+	w.long(m.ContentLength)
+	w.boolean(m.Soft404)
+	w.boolean(m.Boilerplate)
+	w.double(m.LinkDensity)
+	w.string(m.AnalysisProfile)
+	w.long(int64(m.ReadingTimeSec))
+	w.double(m.ReadabilityScore)
+	w.string(m.Degraded)
+	avroMicrodataMap(w, m.Microdata)
+	w.strings(m.UsageDirectives)
+	w.strings(m.Authors)
+	w.string(m.AuthorSource)
+	w.string(m.Canonical)
+	w.string(m.CanonicalSource)
+}
+
+func avroReadMetadata(r *avroReader) DocumentMetadata {
+	var m DocumentMetadata
+	m.Domain = r.string()
+	m.Language = r.string()
+	m.WordCount = r.int()
+	m.Author = r.string()
+	m.PublishedAt = r.time()
+	m.Tags = r.strings()
+	m.Category = r.string()
+	m.Headers = r.stringMap()
+	m.ContentType = r.string()
+	m.Size = r.long()
+	m.ContentLength = r.long()
+	m.Soft404 = r.boolean()
+	m.Boilerplate = r.boolean()
+	m.LinkDensity = r.double()
+	m.AnalysisProfile = r.string()
+	m.ReadingTimeSec = r.int()
+	m.ReadabilityScore = r.double()
+	m.Degraded = r.string()
+	m.Microdata = avroReadMicrodataMap(r)
+	m.UsageDirectives = r.strings()
+	m.Authors = r.strings()
+	m.AuthorSource = r.string()
+	m.Canonical = r.string()
+	m.CanonicalSource = r.string()
+	return m
+}
+
+// avroMicrodataMap writes a map of item arrays, shared by the metadata's
+// items by type and each item's nested items by itemprop
+func avroMicrodataMap(w *avroWriter, m map[string][]MicrodataItem) {
+	keys := sortedKeys(m)
+	w.array(len(keys), func(i int) {
+		w.string(keys[i])
+		items := m[keys[i]]
+		w.array(len(items), func(j int) { avroMicrodataItem(w, items[j]) })
+	})
+}
+
+func avroReadMicrodataMap(r *avroReader) map[string][]MicrodataItem {
+	var m map[string][]MicrodataItem
+	r.array(func() {
+		if m == nil {
+			m = make(map[string][]MicrodataItem)
+		}
+		k := r.string()
+		var items []MicrodataItem
+		r.array(func() { items = append(items, avroReadMicrodataItem(r)) })
+		m[k] = items
+	})
+	return m
+}
+
+func avroMicrodataItem(w *avroWriter, item MicrodataItem) {
+	w.string(item.Type)
+	names := sortedKeys(item.Properties)
+	w.array(len(names), func(i int) {
+		w.string(names[i])
+		w.strings(item.Properties[names[i]])
+	})
+	avroMicrodataMap(w, item.Items)
+}
+
+func avroReadMicrodataItem(r *avroReader) MicrodataItem {
+	var item MicrodataItem
+	item.Type = r.string()
+	r.array(func() {
+		if item.Properties == nil {
+			item.Properties = make(map[string][]string)
+		}
+		k := r.string()
+		item.Properties[k] = r.strings()
+	})
+	item.Items = avroReadMicrodataMap(r)
+	return item
+}
+
+func avroChunk(w *avroWriter, c ContentChunk) {
+	w.string(c.ID)
+	w.string(c.Type)
+	w.string(c.Text)
+	w.long(int64(c.Position))
+	w.double(c.Confidence)
+	w.strings(c.Keywords)
+	w.string(c.Sentiment)
+	w.strings(c.Entities)
+	w.string(c.SafeHTML)
+}
+
+func avroReadChunk(r *avroReader) ContentChunk {
+	var c ContentChunk
+	c.ID = r.string()
+	c.Type = r.string()
+	c.Text = r.string()
+	c.Position = r.int()
+	c.Confidence = r.double()
+	c.Keywords = r.strings()
+	c.Sentiment = r.string()
+	c.Entities = r.strings()
+	c.SafeHTML = r.string()
+	return c
+}
+
+func avroOutlineNode(w *avroWriter, n OutlineNode) {
+	w.long(int64(n.Level))
+	w.string(n.Text)
+	w.string(n.ID)
+	w.array(len(n.Children), func(i int) { avroOutlineNode(w, n.Children[i]) })
+}
+
+func avroReadOutlineNode(r *avroReader) OutlineNode {
+	var n OutlineNode
+	n.Level = r.int()
+	n.Text = r.string()
+	n.ID = r.string()
+	r.array(func() { n.Children = append(n.Children, avroReadOutlineNode(r)) })
+	return n
+}
+
+func avroLink(w *avroWriter, l ExtractedLink) {
+	w.string(l.URL)
+	w.string(l.Text)
+	w.string(l.Type)
+	w.string(l.Context)
+	w.long(int64(l.Priority))
+	w.stringMap(l.Attrs)
+}
+
+func avroReadLink(r *avroReader) ExtractedLink {
+	var l ExtractedLink
+	l.URL = r.string()
+	l.Text = r.string()
+	l.Type = r.string()
+	l.Context = r.string()
+	l.Priority = r.int()
+	l.Attrs = r.stringMap()
+	return l
+}
+
+func avroMedia(w *avroWriter, m MediaAsset) {
+	w.string(m.URL)
+	w.string(m.Type)
+	w.string(m.Alt)
+	w.string(m.Caption)
+	w.string(m.Size)
+	w.string(m.Format)
+	w.stringMap(m.Attrs)
+	w.boolean(m.SeenBefore)
+}
+
+func avroReadMedia(r *avroReader) MediaAsset {
+	var m MediaAsset
+	m.URL = r.string()
+	m.Type = r.string()
+	m.Alt = r.string()
+	m.Caption = r.string()
+	m.Size = r.string()
+	m.Format = r.string()
+	m.Attrs = r.stringMap()
+	m.SeenBefore = r.boolean()
+	return m
+}
+
+func avroDreamHints(w *avroWriter, h DreamingHints) {
+	w.strings(h.Emotions)
+	w.strings(h.Themes)
+	w.strings(h.Motifs)
+	w.string(h.Tone)
+	w.double(h.Complexity)
+	w.double(h.Surrealism)
+	w.strings(h.VisualCues)
+	w.strings(h.AudioCues)
+	w.strings(h.ColorPalette)
+	w.double(h.Abstractness)
+	w.string(h.Sentiment)
+	w.double(h.SentimentScore)
+}
+
+func avroReadDreamHints(r *avroReader) DreamingHints {
+	var h DreamingHints
+	h.Emotions = r.strings()
+	h.Themes = r.strings()
+	h.Motifs = r.strings()
+	h.Tone = r.string()
+	h.Complexity = r.double()
+	h.Surrealism = r.double()
+	h.VisualCues = r.strings()
+	h.AudioCues = r.strings()
+	h.ColorPalette = r.strings()
+	h.Abstractness = r.double()
+	h.Sentiment = r.string()
+	h.SentimentScore = r.double()
+	return h
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// chunkIDHexLen is how much of the content hash goes into a chunk ID
+const chunkIDHexLen = 16
+
+// ChunkID derives a stable ID from a chunk's type and normalized text, so
+// the same chunk keeps its ID across recrawls however the page is laid out.
+// The type's initial is kept as a prefix for readability, e.g. "p_3fa2…".
+func ChunkID(chunkType, text string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	sum := sha256.Sum256([]byte(chunkType + "\x00" + normalized))
+	prefix := "c"
+	if chunkType != "" {
+		prefix = chunkType[:1]
+	}
+	return prefix + "_" + hex.EncodeToString(sum[:])[:chunkIDHexLen]
+}
+
+// ChunkIDs hands out the IDs of one document's chunks in document order.
+// A chunk whose ID is already taken in the document, by repeated text or
+// a hash collision, gets a "-2", "-3", … suffix.
+type ChunkIDs map[string]int
+
+// Next returns the ChunkID of the document's next chunk
+func (ids ChunkIDs) Next(chunkType, text string) string {
+	id := ChunkID(chunkType, text)
+	n := ids[id] + 1
+	ids[id] = n
+	if n > 1 {
+		id += "-" + strconv.Itoa(n)
+	}
+	return id
+}
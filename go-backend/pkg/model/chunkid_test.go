@@ -0,0 +1,26 @@
+package model
+
+import "testing"
+
+// TestChunkIDsDisambiguate checks repeated chunks in one document get
+// distinct IDs, and that the type is part of the ID.
+func TestChunkIDsDisambiguate(t *testing.T) {
+	ids := ChunkIDs{}
+	got := []string{
+		ids.Next("paragraph", "Subscribe to our newsletter for more."),
+		ids.Next("paragraph", "Something else entirely."),
+		ids.Next("paragraph", "Subscribe to our  newsletter for more."),
+		ids.Next("quote", "Subscribe to our newsletter for more."),
+	}
+
+	base := ChunkID("paragraph", "Subscribe to our newsletter for more.")
+	if got[0] != base || got[2] != base+"-2" {
+		t.Errorf("repeated chunk IDs = %q, %q, want %q, %q", got[0], got[2], base, base+"-2")
+	}
+	if got[3] == base || got[3][:2] != "q_" {
+		t.Errorf("quote ID = %q, want its own q_ ID", got[3])
+	}
+	if other := (ChunkIDs{}).Next("paragraph", "Subscribe to our newsletter for more."); other != base {
+		t.Errorf("ID in a fresh document = %q, want %q", other, base)
+	}
+}
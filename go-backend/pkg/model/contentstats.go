@@ -0,0 +1,194 @@
+package model
+
+import (
+	"container/heap"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// Count-min sketch dimensions: with 4 rows of 4096 counters an estimate
+// exceeds the true count by more than 0.07% of all terms added with under
+// 2% probability, in 64 KiB per TopK
+const (
+	sketchDepth = 4
+	sketchWidth = 4096
+)
+
+// TermCount is a term and its (estimated) number of occurrences
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int64  `json:"count"`
+}
+
+// TopK tracks the k most frequent terms of an unbounded stream in fixed
+// memory. A count-min sketch estimates every term's count, never under
+// it, and a min-heap keeps the k terms with the highest estimates. It is
+// safe for concurrent use.
+type TopK struct {
+	mu     sync.Mutex
+	k      int
+	counts [sketchDepth][]uint32
+	top    termHeap
+	index  map[string]int // term -> position in top
+}
+
+// NewTopK returns a TopK keeping k terms; k must be positive
+func NewTopK(k int) *TopK {
+	t := &TopK{k: k, index: make(map[string]int, k)}
+	for i := range t.counts {
+		t.counts[i] = make([]uint32, sketchWidth)
+	}
+	t.top.index = t.index
+	return t
+}
+
+// Add counts one occurrence of term
+func (t *TopK) Add(term string) {
+	if term == "" {
+		return
+	}
+	h1, h2 := termHashes(term)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	estimate := uint32(0)
+	for i := range t.counts {
+		cell := &t.counts[i][(h1+uint64(i)*h2)%sketchWidth]
+		if *cell < ^uint32(0) {
+			*cell++
+		}
+		if i == 0 || *cell < estimate {
+			estimate = *cell
+		}
+	}
+
+	switch pos, ok := t.index[term]; {
+	case ok:
+		t.top.items[pos].Count = int64(estimate)
+		heap.Fix(&t.top, pos)
+	case len(t.top.items) < t.k:
+		heap.Push(&t.top, TermCount{Term: term, Count: int64(estimate)})
+	case int64(estimate) > t.top.items[0].Count:
+		delete(t.index, t.top.items[0].Term)
+		t.top.items[0] = TermCount{Term: term, Count: int64(estimate)}
+		t.index[term] = 0
+		heap.Fix(&t.top, 0)
+	}
+}
+
+// Top returns the tracked terms, most frequent first
+func (t *TopK) Top() []TermCount {
+	t.mu.Lock()
+	top := append([]TermCount{}, t.top.items...)
+	t.mu.Unlock()
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Term < top[j].Term
+	})
+	return top
+}
+
+// termHashes derives the two base hashes for the sketch's rows
+func termHashes(term string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(term))
+	sum := h.Sum(nil)
+	var h1, h2 uint64
+	for i := 0; i < 8; i++ {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[8+i])
+	}
+	return h1, h2 | 1
+}
+
+// termHeap is a min-heap by count that keeps index pointing at each
+// term's position
+type termHeap struct {
+	items []TermCount
+	index map[string]int
+}
+
+func (h termHeap) Len() int           { return len(h.items) }
+func (h termHeap) Less(i, j int) bool { return h.items[i].Count < h.items[j].Count }
+func (h termHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i].Term] = i
+	h.index[h.items[j].Term] = j
+}
+func (h *termHeap) Push(x interface{}) {
+	item := x.(TermCount)
+	h.index[item.Term] = len(h.items)
+	h.items = append(h.items, item)
+}
+func (h *termHeap) Pop() interface{} {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.index, item.Term)
+	return item
+}
+
+// ContentStats summarizes what a set of documents is about
+type ContentStats struct {
+	Documents int64       `json:"documents"`
+	Keywords  []TermCount `json:"top_keywords"`
+	Entities  []TermCount `json:"top_entities"`
+	Themes    []TermCount `json:"themes"`
+}
+
+// ContentAggregator accumulates the keywords, entities and themes of every
+// document it is given. It is safe for concurrent use.
+type ContentAggregator struct {
+	mu        sync.Mutex
+	documents int64
+	keywords  *TopK
+	entities  *TopK
+	themes    *TopK
+}
+
+// NewContentAggregator keeps the top k keywords, entities and themes
+func NewContentAggregator(k int) *ContentAggregator {
+	return &ContentAggregator{keywords: NewTopK(k), entities: NewTopK(k), themes: NewTopK(k)}
+}
+
+// Add counts one document: each keyword and entity occurrence in its
+// chunks, and each of its themes once
+func (a *ContentAggregator) Add(keywords, entities, themes []string) {
+	a.mu.Lock()
+	a.documents++
+	a.mu.Unlock()
+	for _, term := range keywords {
+		a.keywords.Add(term)
+	}
+	for _, term := range entities {
+		a.entities.Add(term)
+	}
+	for _, term := range themes {
+		a.themes.Add(term)
+	}
+}
+
+// Stats returns the current totals
+func (a *ContentAggregator) Stats() ContentStats {
+	a.mu.Lock()
+	documents := a.documents
+	a.mu.Unlock()
+	return ContentStats{
+		Documents: documents,
+		Keywords:  a.keywords.Top(),
+		Entities:  a.entities.Top(),
+		Themes:    a.themes.Top(),
+	}
+}
+
+// AddDocument counts doc's chunk keywords and entities and its themes
+func (a *ContentAggregator) AddDocument(doc Document) {
+	var keywords, entities []string
+	for _, chunk := range doc.Chunks {
+		keywords = append(keywords, chunk.Keywords...)
+		entities = append(entities, chunk.Entities...)
+	}
+	a.Add(keywords, entities, doc.DreamHints.Themes)
+}
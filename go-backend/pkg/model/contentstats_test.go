@@ -0,0 +1,56 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestTopKHeavyHitters streams a few frequent terms among many one-off
+// ones and checks the frequent terms come out on top, in order, while
+// only k terms are kept.
+func TestTopKHeavyHitters(t *testing.T) {
+	top := NewTopK(3)
+	for i := 0; i < 20000; i++ {
+		top.Add(fmt.Sprintf("noise-%d", i))
+		if i%10 == 0 {
+			top.Add("dream")
+		}
+		if i%25 == 0 {
+			top.Add("moon")
+		}
+		if i%40 == 0 {
+			top.Add("ocean")
+		}
+	}
+
+	got := top.Top()
+	if len(got) != 3 || len(top.index) != 3 {
+		t.Fatalf("kept %d terms (%d indexed), want 3", len(got), len(top.index))
+	}
+	for i, want := range []string{"dream", "moon", "ocean"} {
+		if got[i].Term != want {
+			t.Fatalf("top = %v, want dream, moon, ocean", got)
+		}
+	}
+	if got[0].Count < 2000 {
+		t.Errorf("dream counted %d times, want at least 2000", got[0].Count)
+	}
+}
+
+func TestContentAggregator(t *testing.T) {
+	a := NewContentAggregator(5)
+	a.AddDocument(Document{
+		Chunks:     []ContentChunk{{Keywords: []string{"moon", "tide"}, Entities: []string{"Lisbon"}}, {Keywords: []string{"moon"}}},
+		DreamHints: DreamingHints{Themes: []string{"cosmos"}},
+	})
+	a.AddDocument(Document{DreamHints: DreamingHints{Themes: []string{"cosmos", "nature"}}})
+
+	stats := a.Stats()
+	if stats.Documents != 2 {
+		t.Errorf("documents = %d, want 2", stats.Documents)
+	}
+	if fmt.Sprint(stats.Keywords) != "[{moon 2} {tide 1}]" || fmt.Sprint(stats.Entities) != "[{Lisbon 1}]" ||
+		fmt.Sprint(stats.Themes) != "[{cosmos 2} {nature 1}]" {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// docIDHexLen is how much of the URL hash goes into a document ID
+const docIDHexLen = 24
+
+// NormalizeURL reduces a URL to the form document IDs are derived from:
+// lowercase scheme and host, no default port, no fragment, and "/" for an
+// empty path. Unparseable input is returned trimmed but otherwise as is.
+func NormalizeURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = u.Hostname()
+		if strings.Contains(u.Host, ":") {
+			u.Host = "[" + u.Host + "]" // IPv6 literal
+		}
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+// DocumentIDFor derives the canonical ID for the document at rawURL. Every
+// service computes it the same way, so it can be recomputed anywhere and
+// is stable across recrawls.
+func DocumentIDFor(rawURL string) string {
+	sum := sha256.Sum256([]byte(NormalizeURL(rawURL)))
+	return hex.EncodeToString(sum[:])[:docIDHexLen]
+}
+
+// DocumentID returns d.ID, or derives it from d.URL when unset
+func (d Document) DocumentID() string {
+	if d.ID != "" {
+		return d.ID
+	}
+	return DocumentIDFor(d.URL)
+}
@@ -0,0 +1,51 @@
+package model
+
+import "testing"
+
+// TestDocumentIDFor checks that URLs differing only in ways NormalizeURL
+// removes share an ID, and that different pages don't.
+func TestDocumentIDFor(t *testing.T) {
+	base := DocumentIDFor("https://example.com/dreams?page=2")
+	same := []string{
+		"https://example.com/dreams?page=2",
+		"HTTPS://Example.COM/dreams?page=2",
+		"https://example.com:443/dreams?page=2",
+		"https://example.com/dreams?page=2#section",
+		"  https://example.com/dreams?page=2 ",
+	}
+	for _, u := range same {
+		if got := DocumentIDFor(u); got != base {
+			t.Errorf("DocumentIDFor(%q) = %s, want %s", u, got, base)
+		}
+	}
+
+	different := []string{
+		"http://example.com/dreams?page=2",
+		"https://example.com/Dreams?page=2",
+		"https://example.com/dreams?page=3",
+		"https://example.com:8443/dreams?page=2",
+		"https://www.example.com/dreams?page=2",
+	}
+	for _, u := range different {
+		if got := DocumentIDFor(u); got == base {
+			t.Errorf("DocumentIDFor(%q) collides with the base URL", u)
+		}
+	}
+
+	if a, b := DocumentIDFor("https://example.com"), DocumentIDFor("https://example.com/"); a != b {
+		t.Errorf("empty path and / differ: %s vs %s", a, b)
+	}
+	if got := len(base); got != docIDHexLen {
+		t.Errorf("ID length = %d, want %d", got, docIDHexLen)
+	}
+}
+
+// TestDocumentIDPrefersField checks that an ID already set is kept.
+func TestDocumentIDPrefersField(t *testing.T) {
+	if got := (Document{ID: "abc", URL: "https://example.com/"}).DocumentID(); got != "abc" {
+		t.Errorf("DocumentID = %q, want the existing ID", got)
+	}
+	if got, want := (Document{URL: "https://example.com/"}).DocumentID(), DocumentIDFor("https://example.com/"); got != want {
+		t.Errorf("DocumentID = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,703 @@
+package model
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// protobufSerializer encodes Documents in the protobuf wire format
+// described by document.proto. Encoding is hand-written to avoid a code
+// generator; unknown fields are skipped when decoding so older consumers
+// can read newer messages.
+type protobufSerializer struct{}
+
+func (protobufSerializer) Marshal(_ string, doc Document) ([]byte, error) {
+	var w pbWriter
+	pbDocument(&w, doc)
+	return w.buf, nil
+}
+
+func (protobufSerializer) Unmarshal(data []byte, doc *Document) error {
+	*doc = Document{}
+	return pbDecodeDocument(data, doc)
+}
+
+func (protobufSerializer) ContentType() string { return "application/x-protobuf" }
+
+// Protobuf wire types
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+	pbFixed32 = 5
+)
+
+var errProtobufTruncated = errors.New("protobuf: truncated message")
+
+// pbWriter appends fields, leaving out proto3 default values
+type pbWriter struct {
+	buf []byte
+}
+
+func (w *pbWriter) key(field, wire int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field)<<3|uint64(wire))
+}
+
+func (w *pbWriter) bytes(field int, s string) {
+	w.key(field, pbBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *pbWriter) string(field int, s string) {
+	if s != "" {
+		w.bytes(field, s)
+	}
+}
+
+func (w *pbWriter) strings(field int, ss []string) {
+	for _, s := range ss {
+		w.bytes(field, s)
+	}
+}
+
+func (w *pbWriter) int(field int, v int64) {
+	if v != 0 {
+		w.key(field, pbVarint)
+		w.buf = binary.AppendUvarint(w.buf, uint64(v))
+	}
+}
+
+func (w *pbWriter) bool(field int, b bool) {
+	if b {
+		w.key(field, pbVarint)
+		w.buf = append(w.buf, 1)
+	}
+}
+
+func (w *pbWriter) double(field int, f float64) {
+	if f != 0 {
+		w.key(field, pbFixed64)
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(f))
+	}
+}
+
+// doubles writes a packed repeated double
+func (w *pbWriter) doubles(field int, fs []float64) {
+	if len(fs) == 0 {
+		return
+	}
+	w.key(field, pbBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(8*len(fs)))
+	for _, f := range fs {
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(f))
+	}
+}
+
+func (w *pbWriter) message(field int, encode func(w *pbWriter)) {
+	var sub pbWriter
+	encode(&sub)
+	w.bytes(field, string(sub.buf))
+}
+
+// stringMap writes a map<string, string> as key-sorted entries
+func (w *pbWriter) stringMap(field int, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.message(field, func(e *pbWriter) {
+			e.string(1, k)
+			e.string(2, m[k])
+		})
+	}
+}
+
+// sortedKeys returns m's keys in order, so maps encode deterministically
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// time writes a google.protobuf.Timestamp; the zero time is left out
+func (w *pbWriter) time(field int, t time.Time) {
+	if !t.IsZero() {
+		w.message(field, func(ts *pbWriter) {
+			ts.int(1, t.Unix())
+			ts.int(2, int64(t.Nanosecond()))
+		})
+	}
+}
+
+// pbField is one decoded field; u holds varint and fixed values, b the
+// contents of length-delimited ones
+type pbField struct {
+	num  int
+	wire int
+	u    uint64
+	b    []byte
+}
+
+func (f pbField) str() string       { return string(f.b) }
+func (f pbField) int() int          { return int(int64(f.u)) }
+func (f pbField) int64() int64      { return int64(f.u) }
+func (f pbField) bool() bool        { return f.u != 0 }
+func (f pbField) double() float64   { return math.Float64frombits(f.u) }
+func (f pbField) wireIs(w int) bool { return f.wire == w }
+
+// pbEach calls fn for every field in data in order
+func pbEach(data []byte, fn func(f pbField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtobufTruncated
+		}
+		data = data[n:]
+		f := pbField{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case pbVarint:
+			if f.u, n = binary.Uvarint(data); n <= 0 {
+				return errProtobufTruncated
+			}
+			data = data[n:]
+		case pbFixed64:
+			if len(data) < 8 {
+				return errProtobufTruncated
+			}
+			f.u, data = binary.LittleEndian.Uint64(data), data[8:]
+		case pbFixed32:
+			if len(data) < 4 {
+				return errProtobufTruncated
+			}
+			f.u, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case pbBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return errProtobufTruncated
+			}
+			f.b, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return fmt.Errorf("protobuf: field %d has unsupported wire type %d", f.num, f.wire)
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pbDecodeTime(data []byte) (time.Time, error) {
+	var sec, nsec int64
+	err := pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			sec = f.int64()
+		case 2:
+			nsec = f.int64()
+		}
+		return nil
+	})
+	return time.Unix(sec, nsec).UTC(), err
+}
+
+func pbDecodeMapEntry(data []byte, m *map[string]string) error {
+	var k, v string
+	err := pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			k = f.str()
+		case 2:
+			v = f.str()
+		}
+		return nil
+	})
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	(*m)[k] = v
+	return err
+}
+
+// pbDecodeTiming reads one map<string, int64> entry of Document.timings_us
+func pbDecodeTiming(data []byte, m *map[string]int64) error {
+	var k string
+	var v int64
+	err := pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			k = f.str()
+		case 2:
+			v = f.int64()
+		}
+		return nil
+	})
+	if *m == nil {
+		*m = make(map[string]int64)
+	}
+	(*m)[k] = v
+	return err
+}
+
+// pbDecodeDoubles accepts both packed and unpacked repeated doubles
+func pbDecodeDoubles(f pbField, fs []float64) []float64 {
+	if !f.wireIs(pbBytes) {
+		return append(fs, f.double())
+	}
+	for b := f.b; len(b) >= 8; b = b[8:] {
+		fs = append(fs, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	}
+	return fs
+}
+
+// Document and its parts, numbered as in document.proto
+
+func pbDocument(w *pbWriter, d Document) {
+	w.string(1, d.ID)
+	w.string(2, d.URL)
+	w.string(3, d.Title)
+	w.string(4, d.Text)
+	w.string(5, d.CleanText)
+	w.time(6, d.FetchedAt)
+	w.int(7, int64(d.Status))
+	w.string(8, d.ContentHash)
+	w.message(9, func(w *pbWriter) { pbMetadata(w, d.Metadata) })
+	for _, c := range d.Chunks {
+		w.message(10, func(w *pbWriter) { pbChunk(w, c) })
+	}
+	for _, n := range d.Outline {
+		w.message(11, func(w *pbWriter) { pbOutlineNode(w, n) })
+	}
+	for _, l := range d.Links {
+		w.message(12, func(w *pbWriter) { pbLink(w, l) })
+	}
+	w.stringMap(13, d.Alternates)
+	w.string(14, d.NextPage)
+	for _, m := range d.Media {
+		w.message(15, func(w *pbWriter) { pbMedia(w, m) })
+	}
+	w.message(16, func(w *pbWriter) { pbDreamHints(w, d.DreamHints) })
+	w.doubles(17, d.Embedding)
+	if d.HeroImage != nil {
+		w.message(18, func(w *pbWriter) { pbMedia(w, *d.HeroImage) })
+	}
+	for _, stage := range sortedKeys(d.Timings) {
+		w.message(19, func(e *pbWriter) {
+			e.string(1, stage)
+			e.int(2, d.Timings[stage])
+		})
+	}
+	w.string(20, d.StableHash)
+}
+
+func pbDecodeDocument(data []byte, d *Document) error {
+	return pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			d.ID = f.str()
+		case 2:
+			d.URL = f.str()
+		case 3:
+			d.Title = f.str()
+		case 4:
+			d.Text = f.str()
+		case 5:
+			d.CleanText = f.str()
+		case 6:
+			t, err := pbDecodeTime(f.b)
+			d.FetchedAt = t
+			return err
+		case 7:
+			d.Status = f.int()
+		case 8:
+			d.ContentHash = f.str()
+		case 9:
+			return pbDecodeMetadata(f.b, &d.Metadata)
+		case 10:
+			var c ContentChunk
+			err := pbDecodeChunk(f.b, &c)
+			d.Chunks = append(d.Chunks, c)
+			return err
+		case 11:
+			var n OutlineNode
+			err := pbDecodeOutlineNode(f.b, &n)
+			d.Outline = append(d.Outline, n)
+			return err
+		case 12:
+			var l ExtractedLink
+			err := pbDecodeLink(f.b, &l)
+			d.Links = append(d.Links, l)
+			return err
+		case 13:
+			return pbDecodeMapEntry(f.b, &d.Alternates)
+		case 14:
+			d.NextPage = f.str()
+		case 15:
+			var m MediaAsset
+			err := pbDecodeMedia(f.b, &m)
+			d.Media = append(d.Media, m)
+			return err
+		case 16:
+			return pbDecodeDreamHints(f.b, &d.DreamHints)
+		case 17:
+			d.Embedding = pbDecodeDoubles(f, d.Embedding)
+		case 18:
+			d.HeroImage = &MediaAsset{}
+			return pbDecodeMedia(f.b, d.HeroImage)
+		case 19:
+			return pbDecodeTiming(f.b, &d.Timings)
+		case 20:
+			d.StableHash = f.str()
+		}
+		return nil
+	})
+}
+
+func pbMetadata(w *pbWriter, m DocumentMetadata) {
+	w.string(1, m.Domain)
+	w.string(2, m.Language)
+	w.int(3, int64(m.WordCount))
+	w.string(4, m.Author)
+	if m.PublishedAt != nil {
+		w.time(5, *m.PublishedAt)
+	}
+	w.strings(6, m.Tags)
+	w.string(7, m.Category)
+	w.stringMap(8, m.Headers)
+	w.string(9, m.ContentType)
+	w.int(10, m.Size)
+	w.int(11, m.ContentLength)
+	w.bool(12, m.Soft404)
+	w.bool(13, m.Boilerplate)
+	w.double(14, m.LinkDensity)
+	w.string(15, m.AnalysisProfile)
+	w.int(16, int64(m.ReadingTimeSec))
+	w.double(17, m.ReadabilityScore)
+	w.string(18, m.Degraded)
+	for _, typ := range sortedKeys(m.Microdata) {
+		for _, item := range m.Microdata[typ] {
+			w.message(19, func(w *pbWriter) { pbMicrodataItem(w, item) })
+		}
+	}
+	w.strings(20, m.UsageDirectives)
+	w.strings(21, m.Authors)
+	w.string(22, m.AuthorSource)
+	w.string(23, m.Canonical)
+	w.string(24, m.CanonicalSource)
+}
+
+func pbDecodeMetadata(data []byte, m *DocumentMetadata) error {
+	return pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			m.Domain = f.str()
+		case 2:
+			m.Language = f.str()
+		case 3:
+			m.WordCount = f.int()
+		case 4:
+			m.Author = f.str()
+		case 5:
+			t, err := pbDecodeTime(f.b)
+			m.PublishedAt = &t
+			return err
+		case 6:
+			m.Tags = append(m.Tags, f.str())
+		case 7:
+			m.Category = f.str()
+		case 8:
+			return pbDecodeMapEntry(f.b, &m.Headers)
+		case 9:
+			m.ContentType = f.str()
+		case 10:
+			m.Size = f.int64()
+		case 11:
+			m.ContentLength = f.int64()
+		case 12:
+			m.Soft404 = f.bool()
+		case 13:
+			m.Boilerplate = f.bool()
+		case 14:
+			m.LinkDensity = f.double()
+		case 15:
+			m.AnalysisProfile = f.str()
+		case 16:
+			m.ReadingTimeSec = f.int()
+		case 17:
+			m.ReadabilityScore = f.double()
+		case 18:
+			m.Degraded = f.str()
+		case 19:
+			var item MicrodataItem
+			err := pbDecodeMicrodataItem(f.b, &item)
+			if m.Microdata == nil {
+				m.Microdata = make(map[string][]MicrodataItem)
+			}
+			m.Microdata[item.Type] = append(m.Microdata[item.Type], item)
+			return err
+		case 20:
+			m.UsageDirectives = append(m.UsageDirectives, f.str())
+		case 21:
+			m.Authors = append(m.Authors, f.str())
+		case 22:
+			m.AuthorSource = f.str()
+		case 23:
+			m.Canonical = f.str()
+		case 24:
+			m.CanonicalSource = f.str()
+		}
+		return nil
+	})
+}
+
+// pbMicrodataItem writes properties and nested items sharing an itemprop
+// as one MicrodataValues entry
+func pbMicrodataItem(w *pbWriter, item MicrodataItem) {
+	w.string(1, item.Type)
+	names := sortedKeys(item.Properties)
+	for _, name := range sortedKeys(item.Items) {
+		if _, ok := item.Properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.message(2, func(e *pbWriter) {
+			e.string(1, name)
+			e.message(2, func(v *pbWriter) {
+				v.strings(1, item.Properties[name])
+				for _, nested := range item.Items[name] {
+					v.message(2, func(w *pbWriter) { pbMicrodataItem(w, nested) })
+				}
+			})
+		})
+	}
+}
+
+func pbDecodeMicrodataItem(data []byte, item *MicrodataItem) error {
+	return pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			item.Type = f.str()
+		case 2:
+			var name string
+			var values []byte
+			if err := pbEach(f.b, func(e pbField) error {
+				switch e.num {
+				case 1:
+					name = e.str()
+				case 2:
+					values = e.b
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			return pbEach(values, func(v pbField) error {
+				switch v.num {
+				case 1:
+					if item.Properties == nil {
+						item.Properties = make(map[string][]string)
+					}
+					item.Properties[name] = append(item.Properties[name], v.str())
+				case 2:
+					var nested MicrodataItem
+					err := pbDecodeMicrodataItem(v.b, &nested)
+					if item.Items == nil {
+						item.Items = make(map[string][]MicrodataItem)
+					}
+					item.Items[name] = append(item.Items[name], nested)
+					return err
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func pbOutlineNode(w *pbWriter, n OutlineNode) {
+	w.int(1, int64(n.Level))
+	w.string(2, n.Text)
+	w.string(3, n.ID)
+	for _, child := range n.Children {
+		w.message(4, func(w *pbWriter) { pbOutlineNode(w, child) })
+	}
+}
+
+func pbDecodeOutlineNode(data []byte, n *OutlineNode) error {
+	return pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			n.Level = f.int()
+		case 2:
+			n.Text = f.str()
+		case 3:
+			n.ID = f.str()
+		case 4:
+			var child OutlineNode
+			err := pbDecodeOutlineNode(f.b, &child)
+			n.Children = append(n.Children, child)
+			return err
+		}
+		return nil
+	})
+}
+
+func pbChunk(w *pbWriter, c ContentChunk) {
+	w.string(1, c.ID)
+	w.string(2, c.Type)
+	w.string(3, c.Text)
+	w.int(4, int64(c.Position))
+	w.double(5, c.Confidence)
+	w.strings(6, c.Keywords)
+	w.string(7, c.Sentiment)
+	w.strings(8, c.Entities)
+	w.string(9, c.SafeHTML)
+}
+
+func pbDecodeChunk(data []byte, c *ContentChunk) error {
+	return pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			c.ID = f.str()
+		case 2:
+			c.Type = f.str()
+		case 3:
+			c.Text = f.str()
+		case 4:
+			c.Position = f.int()
+		case 5:
+			c.Confidence = f.double()
+		case 6:
+			c.Keywords = append(c.Keywords, f.str())
+		case 7:
+			c.Sentiment = f.str()
+		case 8:
+			c.Entities = append(c.Entities, f.str())
+		case 9:
+			c.SafeHTML = f.str()
+		}
+		return nil
+	})
+}
+
+func pbLink(w *pbWriter, l ExtractedLink) {
+	w.string(1, l.URL)
+	w.string(2, l.Text)
+	w.string(3, l.Type)
+	w.string(4, l.Context)
+	w.int(5, int64(l.Priority))
+	w.stringMap(6, l.Attrs)
+}
+
+func pbDecodeLink(data []byte, l *ExtractedLink) error {
+	return pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			l.URL = f.str()
+		case 2:
+			l.Text = f.str()
+		case 3:
+			l.Type = f.str()
+		case 4:
+			l.Context = f.str()
+		case 5:
+			l.Priority = f.int()
+		case 6:
+			return pbDecodeMapEntry(f.b, &l.Attrs)
+		}
+		return nil
+	})
+}
+
+func pbMedia(w *pbWriter, m MediaAsset) {
+	w.string(1, m.URL)
+	w.string(2, m.Type)
+	w.string(3, m.Alt)
+	w.string(4, m.Caption)
+	w.string(5, m.Size)
+	w.string(6, m.Format)
+	w.stringMap(7, m.Attrs)
+	w.bool(8, m.SeenBefore)
+}
+
+func pbDecodeMedia(data []byte, m *MediaAsset) error {
+	return pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			m.URL = f.str()
+		case 2:
+			m.Type = f.str()
+		case 3:
+			m.Alt = f.str()
+		case 4:
+			m.Caption = f.str()
+		case 5:
+			m.Size = f.str()
+		case 6:
+			m.Format = f.str()
+		case 7:
+			return pbDecodeMapEntry(f.b, &m.Attrs)
+		case 8:
+			m.SeenBefore = f.bool()
+		}
+		return nil
+	})
+}
+
+func pbDreamHints(w *pbWriter, h DreamingHints) {
+	w.strings(1, h.Emotions)
+	w.strings(2, h.Themes)
+	w.strings(3, h.Motifs)
+	w.string(4, h.Tone)
+	w.double(5, h.Complexity)
+	w.double(6, h.Surrealism)
+	w.strings(7, h.VisualCues)
+	w.strings(8, h.AudioCues)
+	w.strings(9, h.ColorPalette)
+	w.double(10, h.Abstractness)
+	w.string(11, h.Sentiment)
+	w.double(12, h.SentimentScore)
+}
+
+func pbDecodeDreamHints(data []byte, h *DreamingHints) error {
+	return pbEach(data, func(f pbField) error {
+		switch f.num {
+		case 1:
+			h.Emotions = append(h.Emotions, f.str())
+		case 2:
+			h.Themes = append(h.Themes, f.str())
+		case 3:
+			h.Motifs = append(h.Motifs, f.str())
+		case 4:
+			h.Tone = f.str()
+		case 5:
+			h.Complexity = f.double()
+		case 6:
+			h.Surrealism = f.double()
+		case 7:
+			h.VisualCues = append(h.VisualCues, f.str())
+		case 8:
+			h.AudioCues = append(h.AudioCues, f.str())
+		case 9:
+			h.ColorPalette = append(h.ColorPalette, f.str())
+		case 10:
+			h.Abstractness = f.double()
+		case 11:
+			h.Sentiment = f.str()
+		case 12:
+			h.SentimentScore = f.double()
+		}
+		return nil
+	})
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaRegistry registers schemas with a Confluent-compatible schema
+// registry, caching the ID assigned to each subject and the schema behind
+// each ID
+type SchemaRegistry struct {
+	baseURL string
+	client  *http.Client
+
+	mu      sync.Mutex
+	ids     map[string]int // by subject
+	schemas map[int]string // by ID
+}
+
+func NewSchemaRegistry(baseURL string) *SchemaRegistry {
+	return &SchemaRegistry{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		ids:     make(map[string]int),
+		schemas: make(map[int]string),
+	}
+}
+
+// SubjectName is the registry subject for a topic's message values,
+// following the registry's default TopicNameStrategy
+func SubjectName(topic string) string {
+	return topic + "-value"
+}
+
+// Register registers an Avro schema under subject and returns its ID. The
+// registry returns the existing ID when the schema is already registered,
+// so this is safe to call from every producer on startup.
+func (r *SchemaRegistry) Register(subject, schema string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.ids[subject]; ok {
+		return id, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"schema": schema})
+	if err != nil {
+		return 0, err
+	}
+	endpoint := fmt.Sprintf("%s/subjects/%s/versions", r.baseURL, url.PathEscape(subject))
+	resp, err := r.client.Post(endpoint, "application/vnd.schemaregistry.v1+json", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("registering schema for %s: %w", subject, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("registering schema for %s: %s: %s", subject, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("registering schema for %s: %w", subject, err)
+	}
+	r.ids[subject] = result.ID
+	r.schemas[result.ID] = schema
+	return result.ID, nil
+}
+
+// Schema returns the schema registered under id, as a consumer needs to
+// tell which schema a message was written with
+func (r *SchemaRegistry) Schema(id int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if schema, ok := r.schemas[id]; ok {
+		return schema, nil
+	}
+
+	resp, err := r.client.Get(fmt.Sprintf("%s/schemas/ids/%d", r.baseURL, id))
+	if err != nil {
+		return "", fmt.Errorf("fetching schema %d: %w", id, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("fetching schema %d: %s: %s", id, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("fetching schema %d: %w", id, err)
+	}
+	r.schemas[id] = result.Schema
+	return result.Schema, nil
+}
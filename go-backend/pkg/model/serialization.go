@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Document serialization formats on Kafka
+const (
+	FormatJSON     = "json"
+	FormatProtobuf = "protobuf"
+	FormatAvro     = "avro"
+)
+
+// Serializer encodes Documents for Kafka and decodes them back. topic is
+// the topic the message is produced to; Avro uses it to pick the schema
+// registry subject.
+//
+// The binary formats can't tell an empty slice or map from a missing one,
+// so both decode as nil.
+type Serializer interface {
+	Marshal(topic string, doc Document) ([]byte, error)
+	Unmarshal(data []byte, doc *Document) error
+	ContentType() string
+}
+
+// NewSerializer returns the serializer for format. registryURL names a
+// Confluent-compatible schema registry and is only valid with Avro: the
+// schema is registered under SubjectName(topic) and each message is
+// framed with its ID. Consumers must be given the same registry setting,
+// and reject messages whose ID names a schema other than DocumentAvroSchema.
+func NewSerializer(format, registryURL string) (Serializer, error) {
+	if registryURL != "" && format != FormatAvro {
+		return nil, fmt.Errorf("a schema registry needs %s serialization, not %q", FormatAvro, format)
+	}
+	switch format {
+	case "", FormatJSON:
+		return jsonSerializer{}, nil
+	case FormatProtobuf:
+		return protobufSerializer{}, nil
+	case FormatAvro:
+		s := &avroSerializer{}
+		if registryURL != "" {
+			s.registry = NewSchemaRegistry(registryURL)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown serialization %q, want %s, %s or %s", format, FormatJSON, FormatProtobuf, FormatAvro)
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Marshal(_ string, doc Document) ([]byte, error) { return json.Marshal(doc) }
+func (jsonSerializer) Unmarshal(data []byte, doc *Document) error     { return json.Unmarshal(data, doc) }
+func (jsonSerializer) ContentType() string                            { return "application/json" }
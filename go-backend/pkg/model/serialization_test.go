@@ -0,0 +1,251 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleDocument fills every field, with nested chunks, outline children
+// and dream hints, and negative numbers where the types allow them.
+func sampleDocument() Document {
+	fetched := time.Date(2024, 5, 17, 9, 30, 15, 123456789, time.UTC)
+	published := time.Date(2024, 5, 16, 22, 0, 0, 0, time.UTC)
+	return Document{
+		ID:          DocumentIDFor("https://example.com/dreams"),
+		URL:         "https://example.com/dreams",
+		Title:       "Dreams — a field guide",
+		Text:        "Raw text ✨",
+		CleanText:   "Clean text",
+		FetchedAt:   fetched,
+		Status:      200,
+		ContentHash: "d41d8cd98f00b204e9800998ecf8427e",
+		StableHash:  "9e107d9d372bb6826bd81d3542a419d6",
+		Metadata: DocumentMetadata{
+			Domain:           "example.com",
+			Language:         "en",
+			WordCount:        1234,
+			Author:           "A. Writer, B. Editor",
+			PublishedAt:      &published,
+			Tags:             []string{"sleep", "", "lucid"},
+			Category:         "science",
+			Headers:          map[string]string{"Content-Type": "text/html", "Server": "test"},
+			ContentType:      "text/html",
+			Size:             1 << 33,
+			ContentLength:    4096,
+			Soft404:          true,
+			Boilerplate:      true,
+			LinkDensity:      0.25,
+			AnalysisProfile:  "news",
+			ReadingTimeSec:   300,
+			ReadabilityScore: 8.5,
+			Degraded:         "unterminated_markup",
+			Microdata: map[string][]MicrodataItem{
+				"https://schema.org/Article": {{
+					Type:       "https://schema.org/Article",
+					Properties: map[string][]string{"headline": {"Dreams"}, "keywords": {"sleep", "lucid"}},
+					Items: map[string][]MicrodataItem{"author": {{
+						Type:       "https://schema.org/Person",
+						Properties: map[string][]string{"name": {"A. Writer"}},
+					}}},
+				}},
+				"": {{Items: map[string][]MicrodataItem{"about": {{Properties: map[string][]string{"name": {"Sleep"}}}}}}},
+			},
+			UsageDirectives: []string{"nosnippet"},
+			Authors:         []string{"A. Writer", "B. Editor"},
+			AuthorSource:    "json-ld",
+			Canonical:       "https://example.com/dreams",
+			CanonicalSource: "canonical+og:url",
+		},
+		Chunks: []ContentChunk{
+			{ID: "chunk_0", Type: "headline", Text: "Dreams", Position: 0, Confidence: 0.9, Keywords: []string{"dreams"}},
+			{ID: "chunk_1", Type: "paragraph", Text: "Sleep is strange.", Position: 1, Confidence: 0.8,
+				Keywords: []string{"sleep", "strange"}, Sentiment: "negative", Entities: []string{"Sleep"},
+				SafeHTML: "Sleep is <b>strange</b>."},
+		},
+		Outline: []OutlineNode{
+			{Level: 1, Text: "Dreams", ID: "dreams", Children: []OutlineNode{
+				{Level: 2, Text: "Lucid", ID: "lucid", Children: []OutlineNode{{Level: 3, Text: "Tips", ID: "tips"}}},
+			}},
+		},
+		Links: []ExtractedLink{
+			{URL: "https://example.com/a", Text: "A", Type: "internal", Context: "see A", Priority: 9, Attrs: map[string]string{"rel": "next"}},
+			{URL: "https://other.org/", Text: "Other", Type: "external", Priority: -1},
+		},
+		Alternates: map[string]string{"de": "https://example.com/de/dreams", "fr": "https://example.com/fr/dreams"},
+		NextPage:   "https://example.com/dreams?page=2",
+		Media: []MediaAsset{
+			{URL: "https://example.com/moon.png", Type: "image", Alt: "Moon", Caption: "The moon", Size: "640x480",
+				Format: "png", Attrs: map[string]string{"loading": "lazy"}, SeenBefore: true},
+		},
+		DreamHints: DreamingHints{
+			Emotions:       []string{"mystical", "dark"},
+			Themes:         []string{"scientific"},
+			Motifs:         []string{"light", "shadow"},
+			Tone:           "formal",
+			Complexity:     0.7,
+			Surrealism:     0.9,
+			VisualCues:     []string{"ethereal lighting"},
+			AudioCues:      []string{"ambient whispers"},
+			ColorPalette:   []string{"silver"},
+			Abstractness:   0.4,
+			Sentiment:      "negative",
+			SentimentScore: -0.6,
+		},
+		HeroImage: &MediaAsset{URL: "https://example.com/moon.png", Type: "image", Alt: "Moon", Size: "640x480", Format: "png"},
+		Embedding: []float64{0.1, -0.2, 3e-10},
+		Timings:   map[string]int64{"fetch": 120, "parse": 8, "dream_hints": 0},
+	}
+}
+
+// TestSerializerRoundTrip checks each format decodes to exactly the
+// document it encoded.
+func TestSerializerRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatProtobuf, FormatAvro} {
+		t.Run(format, func(t *testing.T) {
+			s, err := NewSerializer(format, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, doc := range map[string]Document{"full": sampleDocument(), "empty": {}} {
+				data, err := s.Marshal(TopicRawContent, doc)
+				if err != nil {
+					t.Fatalf("%s: Marshal: %v", name, err)
+				}
+				var got Document
+				if err := s.Unmarshal(data, &got); err != nil {
+					t.Fatalf("%s: Unmarshal: %v", name, err)
+				}
+				if !reflect.DeepEqual(got, doc) {
+					gotJSON, _ := json.Marshal(got)
+					wantJSON, _ := json.Marshal(doc)
+					t.Errorf("%s: round trip changed the document\n got %s\nwant %s", name, gotJSON, wantJSON)
+				}
+			}
+		})
+	}
+}
+
+// TestSerializerRejectsTruncated checks a cut-off message is an error
+// rather than a partly filled document.
+func TestSerializerRejectsTruncated(t *testing.T) {
+	for _, format := range []string{FormatProtobuf, FormatAvro} {
+		s, _ := NewSerializer(format, "")
+		data, _ := s.Marshal(TopicRawContent, sampleDocument())
+		var doc Document
+		if err := s.Unmarshal(data[:len(data)/2], &doc); err == nil {
+			t.Errorf("%s: truncated message decoded without error", format)
+		}
+	}
+}
+
+// TestAvroSchemaRegistry checks the schema is registered once per topic
+// subject and each message carries the registry's wire header.
+func TestAvroSchemaRegistry(t *testing.T) {
+	var subjects []string
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Schema string `json:"schema"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Schema != DocumentAvroSchema {
+			http.Error(w, "bad schema", http.StatusUnprocessableEntity)
+			return
+		}
+		subjects = append(subjects, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/subjects/"), "/versions"))
+		json.NewEncoder(w).Encode(map[string]int{"id": 42})
+	}))
+	defer registry.Close()
+
+	s, err := NewSerializer(FormatAvro, registry.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := sampleDocument()
+	for i := 0; i < 2; i++ {
+		data, err := s.Marshal("acme.raw.content", doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data[0] != avroMagic || binary.BigEndian.Uint32(data[1:5]) != 42 {
+			t.Fatalf("header = % x, want magic byte and schema ID 42", data[:5])
+		}
+		var got Document
+		if err := s.Unmarshal(data, &got); err != nil || !reflect.DeepEqual(got, doc) {
+			t.Fatalf("framed round trip failed: %v", err)
+		}
+	}
+	if want := []string{"acme.raw.content-value"}; !reflect.DeepEqual(subjects, want) {
+		t.Errorf("registered subjects %v, want %v", subjects, want)
+	}
+
+	if _, err := NewSerializer(FormatProtobuf, registry.URL); err == nil {
+		t.Error("a schema registry was accepted for protobuf")
+	}
+	if _, err := NewSerializer("xml", ""); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+// TestAvroWriterSchema checks a consumer looks up each message's schema ID
+// once, reads messages written with the document schema however the
+// registry lays it out, and rejects other schemas and unknown IDs.
+func TestAvroWriterSchema(t *testing.T) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(DocumentAvroSchema)); err != nil {
+		t.Fatal(err)
+	}
+	schemas := map[string]string{
+		"/schemas/ids/42": compact.String(),
+		"/schemas/ids/7":  `{"type": "record", "name": "Document", "fields": [{"name": "id", "type": "string"}]}`,
+	}
+	lookups := 0
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		schema, ok := schemas[r.URL.Path]
+		if !ok {
+			http.Error(w, `{"error_code": 40403, "message": "Schema not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"schema": schema})
+	}))
+	defer registry.Close()
+
+	s, err := NewSerializer(FormatAvro, registry.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewSerializer(FormatAvro, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := plain.Marshal("raw.content", sampleDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	framed := func(id uint32) []byte {
+		return append(binary.BigEndian.AppendUint32([]byte{avroMagic}, id), body...)
+	}
+
+	for i := 0; i < 2; i++ {
+		var got Document
+		if err := s.Unmarshal(framed(42), &got); err != nil || !reflect.DeepEqual(got, sampleDocument()) {
+			t.Fatalf("document schema: %v", err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("looked schema 42 up %d times, want once", lookups)
+	}
+	for _, id := range []uint32{7, 99} {
+		var got Document
+		if err := s.Unmarshal(framed(id), &got); err == nil || !strings.Contains(err.Error(), fmt.Sprint(id)) {
+			t.Errorf("schema %d: err = %v, want a rejection naming it", id, err)
+		}
+	}
+}
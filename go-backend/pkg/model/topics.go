@@ -0,0 +1,37 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// maxTopicPrefixLen leaves room for the longest topic name under Kafka's
+// 249 character limit
+const maxTopicPrefixLen = 200
+
+// topicPrefixPattern allows Kafka's topic characters, in dot-separated parts
+var topicPrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)*$`)
+
+// ValidateTopicPrefix checks that prefix yields legal Kafka topic names.
+// The empty prefix is valid and leaves topics unchanged.
+func ValidateTopicPrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	if len(prefix) > maxTopicPrefixLen {
+		return fmt.Errorf("topic prefix is %d characters, the limit is %d", len(prefix), maxTopicPrefixLen)
+	}
+	if !topicPrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("topic prefix %q may only contain letters, digits, '_', '-' and single dots between them", prefix)
+	}
+	return nil
+}
+
+// PrefixedTopic scopes topic to a tenant: "acme" and "raw.content" give
+// "acme.raw.content"
+func PrefixedTopic(prefix, topic string) string {
+	if prefix == "" {
+		return topic
+	}
+	return prefix + "." + topic
+}
@@ -6,32 +6,102 @@ import (
 
 // Document represents the enhanced structured data extracted from a web page
 type Document struct {
-	URL         string           `json:"url"`
-	Title       string           `json:"title"`
-	Text        string           `json:"text"`
-	CleanText   string           `json:"clean_text"`
-	FetchedAt   time.Time        `json:"fetched_at"`
-	Status      int              `json:"status"`
-	ContentHash string           `json:"content_hash"`
-	Metadata    DocumentMetadata `json:"metadata"`
-	Chunks      []ContentChunk   `json:"chunks"`
-	Links       []ExtractedLink  `json:"links"`
-	Media       []MediaAsset     `json:"media"`
-	DreamHints  DreamingHints    `json:"dream_hints"`
+	ID          string            `json:"id"` // see DocumentIDFor
+	URL         string            `json:"url"`
+	Title       string            `json:"title"`
+	Text        string            `json:"text"`
+	CleanText   string            `json:"clean_text"`
+	FetchedAt   time.Time         `json:"fetched_at"`
+	Status      int               `json:"status"`
+	ContentHash string            `json:"content_hash"`
+	StableHash  string            `json:"stable_hash,omitempty"` // ContentHash without volatile regions
+	Metadata    DocumentMetadata  `json:"metadata"`
+	Chunks      []ContentChunk    `json:"chunks"`
+	Outline     []OutlineNode     `json:"outline,omitempty"`
+	Links       []ExtractedLink   `json:"links"`
+	Alternates  map[string]string `json:"alternates,omitempty"` // hreflang -> URL
+	NextPage    string            `json:"next_page,omitempty"`  // following page of a paginated series
+	Media       []MediaAsset      `json:"media"`
+	HeroImage   *MediaAsset       `json:"hero_image,omitempty"` // representative image for thumbnails
+	DreamHints  DreamingHints     `json:"dream_hints"`
+	Embedding   []float64         `json:"embedding,omitempty"` // set by the ML service when available
+	// Timings are the microseconds each crawl stage took, under the
+	// crawler's -timing
+	Timings map[string]int64 `json:"timings_us,omitempty"`
 }
 
 // DocumentMetadata contains enriched metadata for AI processing
 type DocumentMetadata struct {
-	Domain      string            `json:"domain"`
-	Language    string            `json:"language,omitempty"`
-	WordCount   int               `json:"word_count"`
-	Author      string            `json:"author,omitempty"`
-	PublishedAt *time.Time        `json:"published_at,omitempty"`
-	Tags        []string          `json:"tags,omitempty"`
-	Category    string            `json:"category,omitempty"`
-	Headers     map[string]string `json:"headers"`
-	ContentType string            `json:"content_type"`
-	Size        int64             `json:"size"`
+	Domain        string            `json:"domain"`
+	Language      string            `json:"language,omitempty"`
+	WordCount     int               `json:"word_count"`
+	Author        string            `json:"author,omitempty"`
+	PublishedAt   *time.Time        `json:"published_at,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	Category      string            `json:"category,omitempty"`
+	Headers       map[string]string `json:"headers"`
+	ContentType   string            `json:"content_type"`
+	Size          int64             `json:"size"`                     // body bytes actually read
+	ContentLength int64             `json:"content_length,omitempty"` // declared by the response, 0 when absent
+	Soft404       bool              `json:"soft_404,omitempty"`       // 200 response that looks like a "not found" page
+	Boilerplate   bool              `json:"boilerplate,omitempty"`    // thin page sharing its title with many others
+	LinkDensity   float64           `json:"link_density"`             // share of the page's text inside links
+	// AnalysisProfile names the profile that tuned the page's analysis
+	AnalysisProfile string `json:"analysis_profile,omitempty"`
+	// Degraded says why the text was salvaged from malformed HTML rather
+	// than parsed, empty for pages that parsed normally
+	Degraded string `json:"degraded,omitempty"`
+	// Microdata holds the page's top-level itemscope items by itemtype
+	Microdata map[string][]MicrodataItem `json:"microdata,omitempty"`
+	// UsageDirectives are the robots meta directives restricting how the
+	// page's content may be used, see UsageNoArchive and UsageNoSnippet
+	UsageDirectives []string `json:"usage_directives,omitempty"`
+	// Authors lists each of the page's authors, Author joining them, and
+	// AuthorSource says where they were found: json-ld, microdata, meta
+	// or byline
+	Authors      []string `json:"authors,omitempty"`
+	AuthorSource string   `json:"author_source,omitempty"`
+	// Canonical is the page's canonical URL from its rel="canonical" link
+	// or og:url, and CanonicalSource the signal it came from: canonical,
+	// og:url, or canonical+og:url when both agree
+	Canonical       string `json:"canonical,omitempty"`
+	CanonicalSource string `json:"canonical_source,omitempty"`
+	// Set by the content processor
+	ReadingTimeSec   int     `json:"reading_time_sec,omitempty"`
+	ReadabilityScore float64 `json:"readability_score,omitempty"` // Flesch-Kincaid grade level
+}
+
+// Robots meta directives on using a page's content. Documents carrying
+// either have their text left out, and must not be shown in snippets.
+const (
+	UsageNoArchive = "noarchive"
+	UsageNoSnippet = "nosnippet"
+)
+
+// AllowsSnippets reports whether the page's text may be quoted in results
+func (m DocumentMetadata) AllowsSnippets() bool {
+	for _, directive := range m.UsageDirectives {
+		if directive == UsageNoArchive || directive == UsageNoSnippet {
+			return false
+		}
+	}
+	return true
+}
+
+// MicrodataItem is one HTML microdata item (an itemscope element)
+type MicrodataItem struct {
+	Type       string              `json:"type,omitempty"` // itemtype, empty for untyped items
+	Properties map[string][]string `json:"properties,omitempty"`
+	// Items are the nested items, by the itemprop that holds them
+	Items map[string][]MicrodataItem `json:"items,omitempty"`
+}
+
+// OutlineNode is one heading in a document's outline
+type OutlineNode struct {
+	Level    int           `json:"level"` // 1-6, from h1-h6
+	Text     string        `json:"text"`
+	ID       string        `json:"id"`
+	Children []OutlineNode `json:"children,omitempty"`
 }
 
 // ContentChunk represents semantic chunks for AI processing
@@ -44,6 +114,8 @@ type ContentChunk struct {
 	Keywords   []string `json:"keywords,omitempty"`
 	Sentiment  string   `json:"sentiment,omitempty"`
 	Entities   []string `json:"entities,omitempty"`
+	// SafeHTML is the chunk's markup, sanitized so it is safe to render
+	SafeHTML string `json:"safe_html,omitempty"`
 }
 
 // ExtractedLink contains enriched link information
@@ -53,6 +125,8 @@ type ExtractedLink struct {
 	Type     string `json:"type"` // internal, external, media
 	Context  string `json:"context,omitempty"`
 	Priority int    `json:"priority"` // for crawl prioritization
+	// Attrs holds the anchor attributes named by -link-attrs
+	Attrs map[string]string `json:"attrs,omitempty"`
 }
 
 // MediaAsset represents images, videos, etc. found on the page
@@ -63,20 +137,26 @@ type MediaAsset struct {
 	Caption string `json:"caption,omitempty"`
 	Size    string `json:"size,omitempty"`
 	Format  string `json:"format,omitempty"`
+	// Attrs holds the element attributes named by -media-attrs
+	Attrs map[string]string `json:"attrs,omitempty"`
+	// SeenBefore marks an asset already emitted on an earlier page (-dedup-media-mark)
+	SeenBefore bool `json:"seen_before,omitempty"`
 }
 
 // DreamingHints provides context clues for AI dreaming
 type DreamingHints struct {
-	Emotions     []string `json:"emotions"`
-	Themes       []string `json:"themes"`
-	Motifs       []string `json:"motifs"`
-	Tone         string   `json:"tone"`
-	Complexity   float64  `json:"complexity"`
-	Surrealism   float64  `json:"surrealism_potential"`
-	VisualCues   []string `json:"visual_cues"`
-	AudioCues    []string `json:"audio_cues"`
-	ColorPalette []string `json:"color_palette,omitempty"`
-	Abstractness float64  `json:"abstractness"`
+	Emotions       []string `json:"emotions"`
+	Themes         []string `json:"themes"`
+	Motifs         []string `json:"motifs"`
+	Tone           string   `json:"tone"`
+	Complexity     float64  `json:"complexity"`
+	Surrealism     float64  `json:"surrealism_potential"`
+	VisualCues     []string `json:"visual_cues"`
+	AudioCues      []string `json:"audio_cues"`
+	ColorPalette   []string `json:"color_palette,omitempty"`
+	Abstractness   float64  `json:"abstractness"`
+	Sentiment      string   `json:"sentiment"`       // positive, negative or neutral
+	SentimentScore float64  `json:"sentiment_score"` // -1 (negative) to 1 (positive)
 }
 
 // DreamOutput represents the AI-generated dream content
@@ -102,6 +182,8 @@ type CrawlJob struct {
 	Filters   []string  `json:"filters,omitempty"`
 	UserAgent string    `json:"user_agent,omitempty"`
 	RateLimit int       `json:"rate_limit,omitempty"`
+	// URLs are the seeds of a batch job, crawled together; URL is the first
+	URLs []string `json:"urls,omitempty"`
 }
 
 // SearchQuery represents a search request
@@ -112,7 +194,8 @@ type SearchQuery struct {
 	Offset     int      `json:"offset"`
 	SearchType string   `json:"search_type"` // text, semantic, dream
 	SortBy     string   `json:"sort_by,omitempty"`
-	DateRange  string   `json:"date_range,omitempty"`
+	DateRange  string   `json:"date_range,omitempty"` // "7d", "24h" or "from..to"
+	DateField  string   `json:"date_field,omitempty"` // fetched (default) or published
 }
 
 // SearchResult represents a search result
@@ -153,6 +153,10 @@ def main(broker: str, group_id: str, topic: str):
 if __name__ == "__main__":
     broker = os.environ.get("KAFKA_BROKER", "localhost:9092")
     topic = os.environ.get("KAFKA_DREAM_TOPIC", "dream.seeds")
+    # Must match the crawler's -topic-prefix when tenants share a cluster
+    prefix = os.environ.get("KAFKA_TOPIC_PREFIX", "")
+    if prefix:
+        topic = f"{prefix}.{topic}"
     group_id = "dream-processor-group"
 
     main(broker, group_id, topic)
\ No newline at end of file